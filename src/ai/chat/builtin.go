@@ -0,0 +1,322 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxAttachmentSize is the largest file /add will attach
+const maxAttachmentSize = 256 * 1024
+
+// builtinCommands returns the commands available in every chat session
+func builtinCommands(registry *Registry) []Command {
+	return []Command{
+		NewCommand("help", "/help", "Show available commands", func(ctx context.Context, s *Session, args string) (Action, error) {
+			s.Printf("Available commands:\n")
+			for _, cmd := range registry.Commands() {
+				s.Printf("  %-22s %s\n", cmd.Usage(), cmd.Description())
+			}
+			return ActionNone, nil
+		}),
+		NewCommand("exit", "/exit", "End the chat session", exitCommand),
+		NewCommand("quit", "/quit", "End the chat session", exitCommand),
+		NewCommand("model", "/model [name] [provider]", "Show or switch the model", modelCommand),
+		NewCommand("profile", "/profile [name]", "List profiles or switch to one", profileCommand),
+		NewCommand("system", "/system [prompt]", "Show or replace the system prompt", systemCommand),
+		NewCommand("clear", "/clear", "Clear the conversation history", clearCommand),
+		NewCommand("retry", "/retry", "Regenerate the last response", retryCommand),
+		NewCommand("undo-turn", "/undo-turn", "Remove the last message and its response", undoTurnCommand),
+		NewCommand("add", "/add <file|glob>", "Attach files to the next message", addCommand),
+		NewCommand("context", "/context [off]", "Refresh or disable the project context", contextCommand),
+		NewCommand("save", "/save [file]", "Save the conversation (markdown or .json)", saveCommand),
+		NewCommand("pin", "/pin", "Pin the last turn so it is never compacted", pinCommand),
+		NewCommand("unpin", "/unpin", "Unpin all messages", unpinCommand),
+		NewCommand("tokens", "/tokens", "Show token usage for this session", tokensCommand),
+		NewCommand("edit", "/edit [system]", "Compose a message or the system prompt in $EDITOR", editCommand),
+		NewCommand("multiline", "/multiline", "Toggle multi-line input (Alt-Enter or Ctrl-D sends)", multilineCommand),
+	}
+}
+
+func exitCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	return ActionExit, nil
+}
+
+func modelCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		provider := s.Provider
+		if provider == "" {
+			provider = "default"
+		}
+		s.Printf("Model: %s (provider: %s)\n", s.Model, provider)
+		return ActionNone, nil
+	}
+
+	s.Model = fields[0]
+	if len(fields) > 1 {
+		s.Provider = fields[1]
+	}
+	s.Printf("Switched to model %s\n", s.Model)
+	return ActionNone, nil
+}
+
+func profileCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	if args == "" {
+		if len(s.Profiles) == 0 {
+			s.Printf("No profiles configured. Add them under ai.profiles in your config file.\n")
+			return ActionNone, nil
+		}
+
+		names := make([]string, 0, len(s.Profiles))
+		for name := range s.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		s.Printf("Profiles:\n")
+		for _, name := range names {
+			marker := " "
+			if name == s.Profile {
+				marker = "*"
+			}
+			p := s.Profiles[name]
+			s.Printf(" %s %s (model: %s)\n", marker, name, p.Model)
+		}
+		return ActionNone, nil
+	}
+
+	p, ok := s.Profiles[args]
+	if !ok {
+		return ActionNone, fmt.Errorf("profile %q not found", args)
+	}
+	p.Name = args
+	s.ApplyProfile(p)
+	s.Printf("Switched to profile %s (model: %s)\n", args, s.Model)
+	return ActionNone, nil
+}
+
+func systemCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	if args == "" {
+		s.Printf("System prompt:\n%s\n", s.SystemPrompt)
+		return ActionNone, nil
+	}
+
+	s.SystemPrompt = args
+	s.Printf("System prompt updated\n")
+	return ActionNone, nil
+}
+
+func clearCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	s.History = nil
+	s.Attachments = nil
+	s.Printf("Conversation cleared\n")
+	return ActionNone, nil
+}
+
+func retryCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	for i := len(s.History) - 1; i >= 0; i-- {
+		if s.History[i].Role == "user" {
+			return ActionResend, nil
+		}
+	}
+	return ActionNone, fmt.Errorf("nothing to retry")
+}
+
+func undoTurnCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	if !s.UndoTurn() {
+		return ActionNone, fmt.Errorf("nothing to undo")
+	}
+	s.Printf("Removed the last turn\n")
+	return ActionNone, nil
+}
+
+func addCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	if args == "" {
+		return ActionNone, fmt.Errorf("usage: /add <file|glob>")
+	}
+
+	var paths []string
+	for _, pattern := range strings.Fields(args) {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return ActionNone, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return ActionNone, fmt.Errorf("no files match %q", pattern)
+		}
+		paths = append(paths, matches...)
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return ActionNone, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if info.IsDir() {
+			continue
+		}
+		if info.Size() > maxAttachmentSize {
+			s.Printf("Skipping %s: larger than %d KB\n", path, maxAttachmentSize/1024)
+			continue
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return ActionNone, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		s.Attachments = append(s.Attachments, Attachment{Path: path, Content: string(content)})
+		s.Printf("Attached %s\n", path)
+	}
+
+	return ActionNone, nil
+}
+
+func contextCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	if args == "off" {
+		s.Context = nil
+		s.Printf("Project context disabled\n")
+		return ActionNone, nil
+	}
+
+	if s.ContextLoader == nil {
+		return ActionNone, fmt.Errorf("project context is not available in this session")
+	}
+
+	s.Printf("Analyzing project context...\n")
+	contextData, err := s.ContextLoader(ctx, true)
+	if err != nil {
+		return ActionNone, fmt.Errorf("failed to refresh project context: %w", err)
+	}
+	s.Context = contextData
+	s.Printf("Project context refreshed\n")
+	return ActionNone, nil
+}
+
+func saveCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	path := args
+	if path == "" {
+		path = fmt.Sprintf("crazy-chat-%s.md", time.Now().Format("20060102-150405"))
+	}
+
+	var data []byte
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		transcript := struct {
+			Model    string      `json:"model"`
+			Provider string      `json:"provider,omitempty"`
+			Profile  string      `json:"profile,omitempty"`
+			SavedAt  time.Time   `json:"saved_at"`
+			Messages interface{} `json:"messages"`
+		}{
+			Model:    s.Model,
+			Provider: s.Provider,
+			Profile:  s.Profile,
+			SavedAt:  time.Now(),
+			Messages: s.Messages(),
+		}
+
+		var err error
+		data, err = json.MarshalIndent(transcript, "", "  ")
+		if err != nil {
+			return ActionNone, fmt.Errorf("failed to marshal conversation: %w", err)
+		}
+	} else {
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("# Chat with %s\n\n", s.Model))
+		for _, msg := range s.Messages() {
+			role := msg.Role
+			if role == "" {
+				role = "unknown"
+			}
+			sb.WriteString(fmt.Sprintf("## %s\n\n%s\n\n", strings.ToUpper(role[:1])+role[1:], msg.Content))
+		}
+		data = []byte(sb.String())
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return ActionNone, fmt.Errorf("failed to save conversation: %w", err)
+	}
+	s.Printf("Conversation saved to %s\n", path)
+	return ActionNone, nil
+}
+
+func tokensCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	s.Printf("Last turn: %d prompt + %d completion = %d tokens\n",
+		s.LastUsage.PromptTokens, s.LastUsage.CompletionTokens, s.LastUsage.TotalTokens)
+	s.Printf("Session:   %d prompt + %d completion = %d tokens\n",
+		s.Usage.PromptTokens, s.Usage.CompletionTokens, s.Usage.TotalTokens)
+	s.Printf("History:   %d messages\n", len(s.History))
+	if s.LastCompaction != nil {
+		s.Printf("Compacted: %d times, last: %s\n", s.Compactions, DescribeCompaction(s.LastCompaction))
+	}
+	return ActionNone, nil
+}
+
+func pinCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	pinned := s.PinLastTurn()
+	if pinned == 0 {
+		return ActionNone, fmt.Errorf("nothing to pin")
+	}
+	s.Printf("Pinned %d messages\n", pinned)
+	return ActionNone, nil
+}
+
+func unpinCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	unpinned := 0
+	for i := range s.History {
+		if s.History[i].Pinned {
+			s.History[i].Pinned = false
+			unpinned++
+		}
+	}
+	s.Printf("Unpinned %d messages\n", unpinned)
+	return ActionNone, nil
+}
+
+func editCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	if s.Compose == nil {
+		return ActionNone, fmt.Errorf("no editor available in this session")
+	}
+
+	switch args {
+	case "system":
+		text, err := s.Compose(s.SystemPrompt)
+		if err != nil {
+			return ActionNone, err
+		}
+		if text = strings.TrimSpace(text); text == "" {
+			return ActionNone, fmt.Errorf("system prompt cannot be empty")
+		}
+		s.SystemPrompt = text
+		s.Printf("System prompt updated\n")
+		return ActionNone, nil
+	case "":
+		text, err := s.Compose("")
+		if err != nil {
+			return ActionNone, err
+		}
+		if text = strings.TrimSpace(text); text == "" {
+			s.Printf("Empty message, nothing sent\n")
+			return ActionNone, nil
+		}
+		s.Draft = text
+		return ActionSubmit, nil
+	default:
+		return ActionNone, fmt.Errorf("usage: /edit [system]")
+	}
+}
+
+func multilineCommand(ctx context.Context, s *Session, args string) (Action, error) {
+	s.Multiline = !s.Multiline
+	if s.Multiline {
+		s.Printf("Multi-line input on: Enter adds a line, Alt-Enter or Ctrl-D sends\n")
+	} else {
+		s.Printf("Multi-line input off: Enter sends, Alt-Enter or Ctrl-J adds a line\n")
+	}
+	return ActionNone, nil
+}
@@ -0,0 +1,167 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Action tells the chat loop what to do after a slash command has run
+type Action int
+
+const (
+	// ActionNone continues reading input
+	ActionNone Action = iota
+	// ActionResend asks the model again for the last user message
+	ActionResend
+	// ActionExit ends the chat session
+	ActionExit
+	// ActionSubmit sends Session.Draft as the next user message
+	ActionSubmit
+)
+
+// Command is a slash command that can be executed inside a chat session.
+// Plugins can add their own commands by calling Register.
+type Command interface {
+	// Name returns the command name without the leading slash
+	Name() string
+
+	// Usage returns the command syntax, e.g. "/add <file|glob>"
+	Usage() string
+
+	// Description returns a one-line description shown by /help
+	Description() string
+
+	// Execute runs the command with the raw text following the command name
+	Execute(ctx context.Context, s *Session, args string) (Action, error)
+}
+
+// ExecuteFunc is the signature of a command's Execute method
+type ExecuteFunc func(ctx context.Context, s *Session, args string) (Action, error)
+
+// funcCommand implements Command on top of a plain function
+type funcCommand struct {
+	name        string
+	usage       string
+	description string
+	fn          ExecuteFunc
+}
+
+// NewCommand creates a Command from a function
+func NewCommand(name, usage, description string, fn ExecuteFunc) Command {
+	return &funcCommand{
+		name:        name,
+		usage:       usage,
+		description: description,
+		fn:          fn,
+	}
+}
+
+func (c *funcCommand) Name() string        { return c.name }
+func (c *funcCommand) Usage() string       { return c.usage }
+func (c *funcCommand) Description() string { return c.description }
+
+func (c *funcCommand) Execute(ctx context.Context, s *Session, args string) (Action, error) {
+	return c.fn(ctx, s, args)
+}
+
+// Registry holds the slash commands available in a chat session
+type Registry struct {
+	mutex    sync.RWMutex
+	commands map[string]Command
+}
+
+// NewRegistry creates an empty command registry
+func NewRegistry() *Registry {
+	return &Registry{
+		commands: make(map[string]Command),
+	}
+}
+
+// Register adds a command to the registry
+func (r *Registry) Register(cmd Command) error {
+	name := strings.TrimPrefix(cmd.Name(), "/")
+	if name == "" {
+		return fmt.Errorf("command name cannot be empty")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.commands[name]; exists {
+		return fmt.Errorf("command /%s is already registered", name)
+	}
+	r.commands[name] = cmd
+	return nil
+}
+
+// Lookup returns the command registered under the given name
+func (r *Registry) Lookup(name string) (Command, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	cmd, ok := r.commands[strings.TrimPrefix(name, "/")]
+	return cmd, ok
+}
+
+// Commands returns all registered commands sorted by name
+func (r *Registry) Commands() []Command {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	commands := make([]Command, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		commands = append(commands, cmd)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name() < commands[j].Name()
+	})
+	return commands
+}
+
+// Dispatch executes the slash command in the given input line.
+// It returns false if the line is not a slash command. A line is a command
+// when its first word is a registered command or when it is a single
+// "/word"; anything else, such as "/usr/bin is missing", is a message.
+// A leading "//" escapes the slash: the rest of the line is put in
+// Session.Draft and ActionSubmit is returned.
+func (r *Registry) Dispatch(ctx context.Context, s *Session, line string) (Action, bool, error) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "//") {
+		s.Draft = line[1:]
+		return ActionSubmit, true, nil
+	}
+	if !strings.HasPrefix(line, "/") {
+		return ActionNone, false, nil
+	}
+
+	name, args, hasArgs := strings.Cut(line[1:], " ")
+	cmd, ok := r.Lookup(name)
+	if !ok {
+		if hasArgs || name == "" || strings.Contains(name, "/") {
+			return ActionNone, false, nil
+		}
+		return ActionNone, true, fmt.Errorf("unknown command /%s, type /help for a list of commands", name)
+	}
+
+	action, err := cmd.Execute(ctx, s, strings.TrimSpace(args))
+	return action, true, err
+}
+
+// DefaultRegistry contains the built-in commands and those registered by plugins
+var DefaultRegistry = NewRegistry()
+
+// Register adds a command to the default registry
+func Register(cmd Command) error {
+	return DefaultRegistry.Register(cmd)
+}
+
+func init() {
+	for _, cmd := range builtinCommands(DefaultRegistry) {
+		if err := DefaultRegistry.Register(cmd); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+)
+
+// fakeEngine is an ai.AIEngine that echoes the last user message
+type fakeEngine struct {
+	requests   []ai.AIRequest
+	compaction *ai.Compaction
+}
+
+func (f *fakeEngine) Complete(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+	return f.Chat(ctx, req)
+}
+
+func (f *fakeEngine) Chat(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+	f.requests = append(f.requests, req)
+	last := req.Messages[len(req.Messages)-1]
+	resp := &ai.AIResponse{
+		Text:       "echo: " + last.Content,
+		Usage:      ai.AIUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		Compaction: f.compaction,
+	}
+	f.compaction = nil
+	return resp, nil
+}
+
+func (f *fakeEngine) StreamChat(ctx context.Context, req ai.AIRequest, callback func(chunk string) error) (*ai.AIResponse, error) {
+	resp, err := f.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp, callback(resp.Text)
+}
+
+func (f *fakeEngine) GetEmbedding(ctx context.Context, text string, model string) ([]float32, error) {
+	return nil, nil
+}
+
+func (f *fakeEngine) ListModels(ctx context.Context, provider string) ([]ai.ModelInfo, error) {
+	return nil, nil
+}
+
+func (f *fakeEngine) CheckModelAvailability(ctx context.Context, model string, provider string) (bool, error) {
+	return true, nil
+}
+
+func (f *fakeEngine) InstallModel(ctx context.Context, model string) error {
+	return nil
+}
+
+func newTestSession() (*Session, *fakeEngine) {
+	engine := &fakeEngine{}
+	s := NewSession(engine, "llama3.2")
+	s.Out = &bytes.Buffer{}
+	return s, engine
+}
+
+func discard(string) error { return nil }
+
+func TestRegistry_RegisterAndDispatch(t *testing.T) {
+	registry := NewRegistry()
+	called := ""
+	err := registry.Register(NewCommand("hello", "/hello <name>", "Say hello", func(ctx context.Context, s *Session, args string) (Action, error) {
+		called = args
+		return ActionNone, nil
+	}))
+	require.NoError(t, err)
+
+	// Duplicate registrations are rejected
+	assert.Error(t, registry.Register(NewCommand("hello", "/hello", "", nil)))
+
+	s, _ := newTestSession()
+	_, handled, err := registry.Dispatch(context.Background(), s, "/hello  world ")
+	assert.NoError(t, err)
+	assert.True(t, handled)
+	assert.Equal(t, "world", called)
+
+	_, handled, err = registry.Dispatch(context.Background(), s, "just a message")
+	assert.NoError(t, err)
+	assert.False(t, handled)
+
+	_, handled, err = registry.Dispatch(context.Background(), s, "/missing")
+	assert.Error(t, err)
+	assert.True(t, handled)
+}
+
+func TestRegistry_DispatchLeavesMessagesAlone(t *testing.T) {
+	registry := NewRegistry()
+	s, _ := newTestSession()
+	ctx := context.Background()
+
+	// Lines that only start with a slash are sent as messages
+	for _, line := range []string{"/usr/bin/env is missing", "/etc/hosts", "/ hello"} {
+		_, handled, err := registry.Dispatch(ctx, s, line)
+		assert.NoError(t, err, line)
+		assert.False(t, handled, line)
+	}
+
+	// A double slash escapes a literal leading slash
+	action, handled, err := registry.Dispatch(ctx, s, "//help is a command?")
+	assert.NoError(t, err)
+	assert.True(t, handled)
+	assert.Equal(t, ActionSubmit, action)
+	assert.Equal(t, "/help is a command?", s.Draft)
+}
+
+func TestBuiltin_RetryAndUndo(t *testing.T) {
+	s, engine := newTestSession()
+	ctx := context.Background()
+
+	_, err := s.Send(ctx, "first", discard)
+	require.NoError(t, err)
+	_, err = s.Send(ctx, "second", discard)
+	require.NoError(t, err)
+	assert.Len(t, s.History, 4)
+	assert.Equal(t, 30, s.Usage.TotalTokens)
+
+	action, _, err := DefaultRegistry.Dispatch(ctx, s, "/retry")
+	require.NoError(t, err)
+	assert.Equal(t, ActionResend, action)
+
+	_, err = s.Resend(ctx, discard)
+	require.NoError(t, err)
+	assert.Len(t, s.History, 4)
+	assert.Len(t, engine.requests, 3)
+	assert.Equal(t, "second", engine.requests[2].Messages[len(engine.requests[2].Messages)-1].Content)
+
+	_, _, err = DefaultRegistry.Dispatch(ctx, s, "/undo-turn")
+	require.NoError(t, err)
+	assert.Len(t, s.History, 2)
+	assert.Equal(t, "first", s.History[0].Content)
+
+	_, _, err = DefaultRegistry.Dispatch(ctx, s, "/clear")
+	require.NoError(t, err)
+	assert.Empty(t, s.History)
+
+	_, _, err = DefaultRegistry.Dispatch(ctx, s, "/undo-turn")
+	assert.Error(t, err)
+}
+
+func TestBuiltin_ModelProfileAndSystem(t *testing.T) {
+	s, engine := newTestSession()
+	ctx := context.Background()
+	s.Profiles["review"] = Profile{Model: "codellama", SystemPrompt: "You review code."}
+
+	_, _, err := DefaultRegistry.Dispatch(ctx, s, "/model phi3 ollama")
+	require.NoError(t, err)
+	assert.Equal(t, "phi3", s.Model)
+	assert.Equal(t, "ollama", s.Provider)
+
+	_, _, err = DefaultRegistry.Dispatch(ctx, s, "/profile review")
+	require.NoError(t, err)
+	assert.Equal(t, "codellama", s.Model)
+	assert.Equal(t, "review", s.Profile)
+
+	_, _, err = DefaultRegistry.Dispatch(ctx, s, "/profile nope")
+	assert.Error(t, err)
+
+	_, _, err = DefaultRegistry.Dispatch(ctx, s, "/system Answer in French.")
+	require.NoError(t, err)
+
+	_, err = s.Send(ctx, "hi", discard)
+	require.NoError(t, err)
+	req := engine.requests[0]
+	assert.Equal(t, "codellama", req.Model)
+	assert.Equal(t, ai.Message{Role: "system", Content: "Answer in French."}, req.Messages[0])
+}
+
+func TestBuiltin_AddAttachesFilesToNextMessage(t *testing.T) {
+	s, engine := newTestSession()
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(path, []byte("package main\n"), 0644))
+
+	_, _, err := DefaultRegistry.Dispatch(ctx, s, "/add "+filepath.Join(dir, "*.go"))
+	require.NoError(t, err)
+	assert.Len(t, s.Attachments, 1)
+
+	_, err = s.Send(ctx, "explain this", discard)
+	require.NoError(t, err)
+	assert.Empty(t, s.Attachments)
+
+	sent := engine.requests[0].Messages[len(engine.requests[0].Messages)-1].Content
+	assert.Contains(t, sent, "File: "+path)
+	assert.Contains(t, sent, "package main")
+	assert.Contains(t, sent, "explain this")
+}
+
+func TestSession_PinAndCompaction(t *testing.T) {
+	s, engine := newTestSession()
+	ctx := context.Background()
+
+	_, err := s.Send(ctx, "remember this", discard)
+	require.NoError(t, err)
+	_, _, err = DefaultRegistry.Dispatch(ctx, s, "/pin")
+	require.NoError(t, err)
+	assert.True(t, s.History[0].Pinned)
+	assert.True(t, s.History[1].Pinned)
+
+	// The engine reports that it folded earlier turns into a summary
+	engine.compaction = &ai.Compaction{
+		CompactedMessages: 4,
+		Summarized:        true,
+		Messages: []ai.Message{
+			{Role: "system", Content: DefaultSystemPrompt},
+			{Role: "system", Content: ai.MemoryPrefix + "- earlier notes"},
+			{Role: "user", Content: "remember this", Pinned: true},
+			{Role: "user", Content: "next"},
+		},
+	}
+	_, err = s.Send(ctx, "next", discard)
+	require.NoError(t, err)
+
+	require.Len(t, s.History, 4)
+	assert.Equal(t, ai.MemoryPrefix+"- earlier notes", s.History[0].Content)
+	assert.Equal(t, "echo: next", s.History[3].Content)
+	assert.Equal(t, 1, s.Compactions)
+	assert.Contains(t, DescribeCompaction(s.LastCompaction), "summarised 4 earlier messages")
+}
@@ -0,0 +1,240 @@
+// Package chat provides the interactive chat session and its slash commands
+package chat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+)
+
+// DefaultSystemPrompt is the system prompt used when no profile overrides it
+const DefaultSystemPrompt = "You are a helpful AI assistant for software development. Provide concise and accurate responses."
+
+// Profile is a named set of chat settings that can be switched mid-session
+type Profile struct {
+	Name         string  `json:"name" mapstructure:"name"`
+	Model        string  `json:"model" mapstructure:"model"`
+	Provider     string  `json:"provider" mapstructure:"provider"`
+	Temperature  float64 `json:"temperature" mapstructure:"temperature"`
+	SystemPrompt string  `json:"system_prompt" mapstructure:"system_prompt"`
+}
+
+// Attachment is a file attached to the next user message
+type Attachment struct {
+	Path    string
+	Content string
+}
+
+// ContextLoader loads the project context sent along with each turn
+type ContextLoader func(ctx context.Context, refresh bool) ([]byte, error)
+
+// ComposeFunc lets the user write text in an external editor, starting from initial
+type ComposeFunc func(initial string) (string, error)
+
+// Session holds the state of an interactive chat session
+type Session struct {
+	Engine       ai.AIEngine
+	Model        string
+	Provider     string
+	Profile      string
+	Temperature  float64
+	SystemPrompt string
+
+	// History contains the conversation turns, excluding the system prompt
+	History []ai.Message
+
+	// Attachments are prepended to the next user message and then cleared
+	Attachments []Attachment
+
+	Context       []byte
+	ContextLoader ContextLoader
+	Profiles      map[string]Profile
+
+	// Usage accumulates token usage across the session, LastUsage holds the latest turn
+	Usage     ai.AIUsage
+	LastUsage ai.AIUsage
+
+	// Compactions counts how often the engine summarised older turns, LastCompaction holds the latest
+	Compactions    int
+	LastCompaction *ai.Compaction
+
+	// Multiline asks the input reader to treat Enter as a line break
+	Multiline bool
+
+	// Compose opens an external editor, Draft holds text to send with ActionSubmit
+	Compose ComposeFunc
+	Draft   string
+
+	Out io.Writer
+}
+
+// NewSession creates a new chat session using the given engine and model
+func NewSession(engine ai.AIEngine, model string) *Session {
+	return &Session{
+		Engine:       engine,
+		Model:        model,
+		SystemPrompt: DefaultSystemPrompt,
+		Profiles:     make(map[string]Profile),
+		Out:          os.Stdout,
+	}
+}
+
+// Messages returns the full message list sent to the model, starting with the system prompt
+func (s *Session) Messages() []ai.Message {
+	messages := make([]ai.Message, 0, len(s.History)+1)
+	if s.SystemPrompt != "" {
+		messages = append(messages, ai.Message{Role: "system", Content: s.SystemPrompt})
+	}
+	return append(messages, s.History...)
+}
+
+// ApplyProfile switches the session to the given profile, keeping the history
+func (s *Session) ApplyProfile(p Profile) {
+	s.Profile = p.Name
+	if p.Model != "" {
+		s.Model = p.Model
+	}
+	if p.Provider != "" {
+		s.Provider = p.Provider
+	}
+	if p.Temperature != 0 {
+		s.Temperature = p.Temperature
+	}
+	if p.SystemPrompt != "" {
+		s.SystemPrompt = p.SystemPrompt
+	}
+}
+
+// Send adds the user input to the history and streams the model's reply
+func (s *Session) Send(ctx context.Context, input string, callback func(chunk string) error) (*ai.AIResponse, error) {
+	s.History = append(s.History, ai.Message{
+		Role:    "user",
+		Content: s.consumeAttachments(input),
+	})
+	return s.complete(ctx, callback)
+}
+
+// Resend discards the last reply, if any, and asks the model again for the last user message
+func (s *Session) Resend(ctx context.Context, callback func(chunk string) error) (*ai.AIResponse, error) {
+	if n := len(s.History); n > 0 && s.History[n-1].Role == "assistant" {
+		s.History = s.History[:n-1]
+	}
+	if len(s.History) == 0 || s.History[len(s.History)-1].Role != "user" {
+		return nil, fmt.Errorf("no previous message to retry")
+	}
+	return s.complete(ctx, callback)
+}
+
+// UndoTurn removes the last user message and the reply to it
+func (s *Session) UndoTurn() bool {
+	for i := len(s.History) - 1; i >= 0; i-- {
+		if s.History[i].Role == "user" {
+			s.History = s.History[:i]
+			return true
+		}
+	}
+	return false
+}
+
+// complete sends the current history to the engine and records the reply
+func (s *Session) complete(ctx context.Context, callback func(chunk string) error) (*ai.AIResponse, error) {
+	req := ai.AIRequest{
+		Model:       s.Model,
+		ModelType:   ai.ModelTypeChat,
+		Provider:    s.Provider,
+		Messages:    s.Messages(),
+		Temperature: s.Temperature,
+		Context:     s.Context,
+	}
+
+	var responseText strings.Builder
+	resp, err := s.Engine.StreamChat(ctx, req, func(chunk string) error {
+		responseText.WriteString(chunk)
+		return callback(chunk)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The engine may have summarised older turns, continue from the compacted history
+	if resp != nil && resp.Compaction != nil {
+		s.applyCompaction(resp.Compaction)
+	}
+
+	s.History = append(s.History, ai.Message{
+		Role:    "assistant",
+		Content: responseText.String(),
+	})
+
+	if resp != nil {
+		s.LastUsage = resp.Usage
+		s.Usage.PromptTokens += resp.Usage.PromptTokens
+		s.Usage.CompletionTokens += resp.Usage.CompletionTokens
+		s.Usage.TotalTokens += resp.Usage.TotalTokens
+	}
+
+	return resp, nil
+}
+
+// applyCompaction replaces the history with the compacted conversation returned by the engine
+func (s *Session) applyCompaction(c *ai.Compaction) {
+	history := make([]ai.Message, 0, len(c.Messages))
+	for _, msg := range c.Messages {
+		// The system prompt is kept separately and may change with /system or /profile
+		if msg.Role == "system" && msg.Content == s.SystemPrompt {
+			continue
+		}
+		history = append(history, msg)
+	}
+	s.History = history
+	s.Compactions++
+	s.LastCompaction = c
+}
+
+// PinLastTurn pins the last user message and the reply to it so they are never compacted
+func (s *Session) PinLastTurn() int {
+	pinned := 0
+	for i := len(s.History) - 1; i >= 0; i-- {
+		s.History[i].Pinned = true
+		pinned++
+		if s.History[i].Role == "user" {
+			break
+		}
+	}
+	return pinned
+}
+
+// DescribeCompaction returns a one-line summary of a compaction for the user
+func DescribeCompaction(c *ai.Compaction) string {
+	if !c.Summarized {
+		return fmt.Sprintf("Context window nearly full: dropped %d earlier messages (~%d -> ~%d of %d tokens)",
+			c.CompactedMessages, c.TokensBefore, c.TokensAfter, c.ContextWindow)
+	}
+	return fmt.Sprintf("Context window nearly full: summarised %d earlier messages (~%d -> ~%d of %d tokens)",
+		c.CompactedMessages, c.TokensBefore, c.TokensAfter, c.ContextWindow)
+}
+
+// consumeAttachments prepends pending attachments to the input and clears them
+func (s *Session) consumeAttachments(input string) string {
+	if len(s.Attachments) == 0 {
+		return input
+	}
+
+	var sb strings.Builder
+	for _, a := range s.Attachments {
+		sb.WriteString(fmt.Sprintf("File: %s\n```\n%s\n```\n\n", a.Path, strings.TrimRight(a.Content, "\n")))
+	}
+	sb.WriteString(input)
+	s.Attachments = nil
+
+	return sb.String()
+}
+
+// Printf writes formatted output to the session's output
+func (s *Session) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(s.Out, format, args...)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
 )
 
 // CloudClient implements the interface for interacting with cloud AI providers
@@ -29,7 +30,7 @@ func NewCloudClient(provider string) (*CloudClient, error) {
 	case ai.ProviderAnthropic:
 		apiKey = os.Getenv("CRAZY_ANTHROPIC_API_KEY")
 	default:
-		return nil, fmt.Errorf("unsupported provider: %s", provider)
+		return nil, types.NewProviderError(types.ErrInvalidRequest, provider, "", "unsupported provider")
 	}
 
 	return &CloudClient{
@@ -42,7 +43,7 @@ func NewCloudClient(provider string) (*CloudClient, error) {
 func (c *CloudClient) Complete(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
 	// Check if API key is set
 	if c.apiKey == "" {
-		return nil, fmt.Errorf("API key not set for provider %s", c.provider)
+		return nil, types.NewProviderError(types.ErrAuth, c.provider, "", "API key not set")
 	}
 
 	// Call the appropriate provider
@@ -52,7 +53,7 @@ func (c *CloudClient) Complete(ctx context.Context, req ai.AIRequest) (*ai.AIRes
 	case ai.ProviderAnthropic:
 		return c.anthropicComplete(ctx, req)
 	default:
-		return nil, fmt.Errorf("unsupported provider: %s", c.provider)
+		return nil, types.NewProviderError(types.ErrInvalidRequest, c.provider, "", "unsupported provider")
 	}
 }
 
@@ -60,7 +61,7 @@ func (c *CloudClient) Complete(ctx context.Context, req ai.AIRequest) (*ai.AIRes
 func (c *CloudClient) Chat(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
 	// Check if API key is set
 	if c.apiKey == "" {
-		return nil, fmt.Errorf("API key not set for provider %s", c.provider)
+		return nil, types.NewProviderError(types.ErrAuth, c.provider, "", "API key not set")
 	}
 
 	// Call the appropriate provider
@@ -70,7 +71,7 @@ func (c *CloudClient) Chat(ctx context.Context, req ai.AIRequest) (*ai.AIRespons
 	case ai.ProviderAnthropic:
 		return c.anthropicChat(ctx, req)
 	default:
-		return nil, fmt.Errorf("unsupported provider: %s", c.provider)
+		return nil, types.NewProviderError(types.ErrInvalidRequest, c.provider, "", "unsupported provider")
 	}
 }
 
@@ -78,7 +79,7 @@ func (c *CloudClient) Chat(ctx context.Context, req ai.AIRequest) (*ai.AIRespons
 func (c *CloudClient) StreamChat(ctx context.Context, req ai.AIRequest, callback func(chunk string) error) (*ai.AIResponse, error) {
 	// Check if API key is set
 	if c.apiKey == "" {
-		return nil, fmt.Errorf("API key not set for provider %s", c.provider)
+		return nil, types.NewProviderError(types.ErrAuth, c.provider, "", "API key not set")
 	}
 
 	// Call the appropriate provider
@@ -88,7 +89,7 @@ func (c *CloudClient) StreamChat(ctx context.Context, req ai.AIRequest, callback
 	case ai.ProviderAnthropic:
 		return c.anthropicStreamChat(ctx, req, callback)
 	default:
-		return nil, fmt.Errorf("unsupported provider: %s", c.provider)
+		return nil, types.NewProviderError(types.ErrInvalidRequest, c.provider, "", "unsupported provider")
 	}
 }
 
@@ -96,7 +97,7 @@ func (c *CloudClient) StreamChat(ctx context.Context, req ai.AIRequest, callback
 func (c *CloudClient) GetEmbedding(ctx context.Context, text string, model string) ([]float32, error) {
 	// Check if API key is set
 	if c.apiKey == "" {
-		return nil, fmt.Errorf("API key not set for provider %s", c.provider)
+		return nil, types.NewProviderError(types.ErrAuth, c.provider, "", "API key not set")
 	}
 
 	// Call the appropriate provider
@@ -104,9 +105,9 @@ func (c *CloudClient) GetEmbedding(ctx context.Context, text string, model strin
 	case ai.ProviderOpenAI:
 		return c.openAIGetEmbedding(ctx, text, model)
 	case ai.ProviderAnthropic:
-		return nil, fmt.Errorf("embeddings not supported by Anthropic")
+		return nil, types.NewProviderError(types.ErrInvalidRequest, c.provider, model, "embeddings are not supported")
 	default:
-		return nil, fmt.Errorf("unsupported provider: %s", c.provider)
+		return nil, types.NewProviderError(types.ErrInvalidRequest, c.provider, "", "unsupported provider")
 	}
 }
 
@@ -114,7 +115,7 @@ func (c *CloudClient) GetEmbedding(ctx context.Context, text string, model strin
 func (c *CloudClient) ListModels(ctx context.Context, provider string) ([]ai.ModelInfo, error) {
 	// Check if API key is set
 	if c.apiKey == "" {
-		return nil, fmt.Errorf("API key not set for provider %s", c.provider)
+		return nil, types.NewProviderError(types.ErrAuth, c.provider, "", "API key not set")
 	}
 
 	// Call the appropriate provider
@@ -124,7 +125,7 @@ func (c *CloudClient) ListModels(ctx context.Context, provider string) ([]ai.Mod
 	case ai.ProviderAnthropic:
 		return c.anthropicListModels(ctx)
 	default:
-		return nil, fmt.Errorf("unsupported provider: %s", provider)
+		return nil, types.NewProviderError(types.ErrInvalidRequest, provider, "", "unsupported provider")
 	}
 }
 
@@ -132,7 +133,7 @@ func (c *CloudClient) ListModels(ctx context.Context, provider string) ([]ai.Mod
 func (c *CloudClient) CheckModelAvailability(ctx context.Context, model string, provider string) (bool, error) {
 	// Check if API key is set
 	if c.apiKey == "" {
-		return false, fmt.Errorf("API key not set for provider %s", c.provider)
+		return false, types.NewProviderError(types.ErrAuth, c.provider, "", "API key not set")
 	}
 
 	// Call the appropriate provider
@@ -142,7 +143,7 @@ func (c *CloudClient) CheckModelAvailability(ctx context.Context, model string,
 	case ai.ProviderAnthropic:
 		return c.anthropicCheckModelAvailability(ctx, model)
 	default:
-		return false, fmt.Errorf("unsupported provider: %s", provider)
+		return false, types.NewProviderError(types.ErrInvalidRequest, provider, "", "unsupported provider")
 	}
 }
 
@@ -159,7 +160,7 @@ func (c *CloudClient) openAIComplete(ctx context.Context, req ai.AIRequest) (*ai
 	
 	return &ai.AIResponse{
 		Text:             "This is a placeholder response from OpenAI completion API.",
-		FinishReason:     "stop",
+		FinishReason:     types.FinishStop,
 		SelectedModel:    req.Model,
 		SelectedProvider: string(ai.ProviderOpenAI),
 		Latency:          time.Since(startTime),
@@ -182,7 +183,7 @@ func (c *CloudClient) openAIChat(ctx context.Context, req ai.AIRequest) (*ai.AIR
 	
 	return &ai.AIResponse{
 		Text:             "This is a placeholder response from OpenAI chat API.",
-		FinishReason:     "stop",
+		FinishReason:     types.FinishStop,
 		SelectedModel:    req.Model,
 		SelectedProvider: string(ai.ProviderOpenAI),
 		Latency:          time.Since(startTime),
@@ -211,7 +212,7 @@ func (c *CloudClient) openAIStreamChat(ctx context.Context, req ai.AIRequest, ca
 	
 	return &ai.AIResponse{
 		Text:             "This is a placeholder response from OpenAI streaming API.",
-		FinishReason:     "stop",
+		FinishReason:     types.FinishStop,
 		SelectedModel:    req.Model,
 		SelectedProvider: string(ai.ProviderOpenAI),
 		Latency:          time.Since(startTime),
@@ -306,7 +307,7 @@ func (c *CloudClient) anthropicComplete(ctx context.Context, req ai.AIRequest) (
 	
 	return &ai.AIResponse{
 		Text:             "This is a placeholder response from Anthropic completion API.",
-		FinishReason:     "stop",
+		FinishReason:     types.FinishStop,
 		SelectedModel:    req.Model,
 		SelectedProvider: string(ai.ProviderAnthropic),
 		Latency:          time.Since(startTime),
@@ -329,7 +330,7 @@ func (c *CloudClient) anthropicChat(ctx context.Context, req ai.AIRequest) (*ai.
 	
 	return &ai.AIResponse{
 		Text:             "This is a placeholder response from Anthropic chat API.",
-		FinishReason:     "stop",
+		FinishReason:     types.FinishStop,
 		SelectedModel:    req.Model,
 		SelectedProvider: string(ai.ProviderAnthropic),
 		Latency:          time.Since(startTime),
@@ -358,7 +359,7 @@ func (c *CloudClient) anthropicStreamChat(ctx context.Context, req ai.AIRequest,
 	
 	return &ai.AIResponse{
 		Text:             "This is a placeholder response from Anthropic streaming API.",
-		FinishReason:     "stop",
+		FinishReason:     types.FinishStop,
 		SelectedModel:    req.Model,
 		SelectedProvider: string(ai.ProviderAnthropic),
 		Latency:          time.Since(startTime),
@@ -0,0 +1,226 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
+)
+
+const (
+	// DefaultContextWindow is used for models without a configured context window
+	DefaultContextWindow = 4096
+
+	// DefaultCompactionThreshold is the fraction of the context window that triggers compaction
+	DefaultCompactionThreshold = 0.8
+
+	// DefaultCompactionKeepRecent is the number of most recent turns that are never summarised
+	DefaultCompactionKeepRecent = 4
+
+	// defaultResponseReserve is the number of tokens kept free for the reply when MaxTokens is unset
+	defaultResponseReserve = 512
+
+	// messageOverhead approximates the tokens used by role markers around each message
+	messageOverhead = 4
+)
+
+// MemoryPrefix starts the system message that holds the summary of compacted turns
+const MemoryPrefix = "Summary of the earlier conversation:\n"
+
+const summarizerPrompt = `You compress conversations between a developer and an AI assistant.
+Summarise the conversation below into a short memory note that lets the assistant continue it.
+Keep facts, decisions, file names, commands, code identifiers and open questions.
+Drop greetings and small talk. Answer with bullet points only.`
+
+// EstimateTokens returns a rough token count for text, assuming about four characters per token
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// estimateMessagesTokens returns a rough token count for a list of messages
+func estimateMessagesTokens(messages []types.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += EstimateTokens(msg.Content) + messageOverhead
+	}
+	return total
+}
+
+// ContextWindow returns the context window configured for the given model
+func (e *AIEngineImpl) ContextWindow(model string) int {
+	if window, ok := e.Config.ModelContextWindows[model]; ok && window > 0 {
+		return window
+	}
+	// Fall back to the model family, e.g. "llama3.2" for "llama3.2:3b"
+	if base, _, found := strings.Cut(model, ":"); found {
+		if window, ok := e.Config.ModelContextWindows[base]; ok && window > 0 {
+			return window
+		}
+	}
+	if e.Config.ContextWindow > 0 {
+		return e.Config.ContextWindow
+	}
+	return DefaultContextWindow
+}
+
+// compact summarises older turns of req.Messages when the conversation, including
+// the project context, no longer fits comfortably in the model's context window.
+// System messages and pinned messages are always kept.
+func (e *AIEngineImpl) compact(ctx context.Context, req *types.AIRequest) (*types.Compaction, error) {
+	if !e.Config.CompactionEnabled || len(req.Messages) == 0 {
+		return nil, nil
+	}
+
+	window := e.ContextWindow(req.Model)
+	threshold := e.Config.CompactionThreshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = DefaultCompactionThreshold
+	}
+	reserve := req.MaxTokens
+	if reserve <= 0 {
+		reserve = defaultResponseReserve
+	}
+
+	before, err := e.estimateRequestTokens(req.Messages, req.Context)
+	if err != nil {
+		return nil, err
+	}
+	if float64(before+reserve) < threshold*float64(window) {
+		return nil, nil
+	}
+
+	keepRecent := e.Config.CompactionKeepRecent
+	if keepRecent <= 0 {
+		keepRecent = DefaultCompactionKeepRecent
+	}
+	systems, memories, old, kept := planCompaction(req.Messages, keepRecent)
+	if len(old) == 0 || len(memories)+len(old) < 2 {
+		// Nothing left to fold, the recent turns alone fill the window
+		return nil, nil
+	}
+	// The system prompt, project context and recent turns never shrink, so
+	// once they reach the threshold every new turn would be summarised again.
+	// Older turns are only folded once they fill the headroom the threshold
+	// leaves, unless the request would overflow the window.
+	margin := int((1 - threshold) * float64(window))
+	if estimateMessagesTokens(old) < margin && before+reserve < window {
+		return nil, nil
+	}
+
+	compaction := &types.Compaction{
+		CompactedMessages: len(old),
+		TokensBefore:      before,
+		ContextWindow:     window,
+	}
+
+	compacted := append([]types.Message{}, systems...)
+	spanCtx, span := logging.StartSpan(ctx, "compaction", "messages", len(memories)+len(old), "tokens", before)
+	summary, err := e.summarize(spanCtx, *req, append(memories, old...), window)
+	span.End(err)
+	if err == nil && strings.TrimSpace(summary) != "" {
+		compaction.Summarized = true
+		compaction.CompactedMessages += len(memories)
+		compacted = append(compacted, types.Message{
+			Role:    "system",
+			Content: MemoryPrefix + strings.TrimSpace(summary),
+		})
+	} else {
+		// Without a summary the old turns are dropped, earlier summaries are kept
+		compacted = append(compacted, memories...)
+	}
+	compacted = append(compacted, kept...)
+
+	after, err := e.estimateRequestTokens(compacted, req.Context)
+	if err != nil {
+		return nil, err
+	}
+	compaction.TokensAfter = after
+	compaction.Messages = compacted
+	req.Messages = compacted
+
+	return compaction, nil
+}
+
+// estimateRequestTokens estimates the prompt size of messages once the project context is added
+func (e *AIEngineImpl) estimateRequestTokens(messages []types.Message, projectContext []byte) (int, error) {
+	processed, err := e.PromptEngine.ProcessMessages(messages, projectContext)
+	if err != nil {
+		return 0, fmt.Errorf("failed to process messages: %w", err)
+	}
+	return estimateMessagesTokens(processed), nil
+}
+
+// planCompaction splits messages into system prompts, earlier summaries, turns to
+// summarise and turns to keep verbatim. The last keepRecent non-system messages
+// and all pinned messages are kept in their original order.
+func planCompaction(messages []types.Message, keepRecent int) (systems, memories, old, kept []types.Message) {
+	conversational := 0
+	for _, msg := range messages {
+		if msg.Role != "system" {
+			conversational++
+		}
+	}
+
+	seen := 0
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "system" && strings.HasPrefix(msg.Content, MemoryPrefix):
+			memories = append(memories, msg)
+		case msg.Role == "system":
+			systems = append(systems, msg)
+		default:
+			seen++
+			if msg.Pinned || seen > conversational-keepRecent {
+				kept = append(kept, msg)
+			} else {
+				old = append(old, msg)
+			}
+		}
+	}
+	return systems, memories, old, kept
+}
+
+// summarize asks the model for a memory note covering the given messages
+func (e *AIEngineImpl) summarize(ctx context.Context, req types.AIRequest, messages []types.Message, window int) (string, error) {
+	var transcript strings.Builder
+	for _, msg := range messages {
+		role := "User"
+		switch msg.Role {
+		case "assistant":
+			role = "Assistant"
+		case "system":
+			role = "Earlier summary"
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n\n", role, strings.TrimPrefix(msg.Content, MemoryPrefix))
+	}
+
+	// The summary request must fit in the window itself, keep the most recent part
+	summaryTokens := window / 8
+	text := transcript.String()
+	maxChars := (window - summaryTokens - EstimateTokens(summarizerPrompt) - 64) * 4
+	if maxChars > 0 && len(text) > maxChars {
+		text = "...\n" + text[len(text)-maxChars:]
+	}
+
+	summaryReq := types.AIRequest{
+		Model:           req.Model,
+		ModelType:       types.ModelTypeChat,
+		Provider:        req.Provider,
+		MaxTokens:       summaryTokens,
+		Temperature:     0.2,
+		FallbackToCloud: req.FallbackToCloud,
+		Messages: []types.Message{
+			{Role: "system", Content: summarizerPrompt},
+			{Role: "user", Content: text},
+		},
+	}
+
+	resp, err := e.chatWithFallback(ctx, summaryReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarise conversation: %w", err)
+	}
+	return resp.Text, nil
+}
@@ -0,0 +1,171 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+)
+
+// fakeOllama records chat requests and answers summary requests with a fixed note
+type fakeOllama struct {
+	types.OllamaClient
+	chats        [][]types.Message
+	summaryError error
+}
+
+func (f *fakeOllama) Chat(ctx context.Context, model string, messages []types.Message, opts types.ChatOptions) (*types.AIResponse, error) {
+	f.chats = append(f.chats, messages)
+	if strings.HasPrefix(messages[0].Content, "You compress conversations") {
+		if f.summaryError != nil {
+			return nil, f.summaryError
+		}
+		return &types.AIResponse{Text: "- user is debugging a panic in main.go"}, nil
+	}
+	return &types.AIResponse{Text: "ok"}, nil
+}
+
+func (f *fakeOllama) StreamChat(ctx context.Context, model string, messages []types.Message, opts types.ChatOptions, callback func(chunk string) error) (*types.AIResponse, error) {
+	resp, err := f.Chat(ctx, model, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	return resp, callback(resp.Text)
+}
+
+// passthroughPrompts is a prompt engine that leaves prompts and messages unchanged
+type passthroughPrompts struct {
+	types.PromptEngine
+}
+
+func (passthroughPrompts) ProcessMessages(messages []types.Message, context []byte) ([]types.Message, error) {
+	return messages, nil
+}
+
+func (passthroughPrompts) ProcessPrompt(prompt string, context []byte) (string, error) {
+	return prompt, nil
+}
+
+func newCompactionEngine(ollama *fakeOllama) *AIEngineImpl {
+	return NewAIEngineImpl(ollama, nil, passthroughPrompts{}, types.AIConfig{
+		LocalEnabled:         true,
+		CompactionEnabled:    true,
+		ContextWindow:        1000,
+		CompactionThreshold:  0.8,
+		CompactionKeepRecent: 2,
+	})
+}
+
+func longConversation(turns int) []types.Message {
+	messages := []types.Message{{Role: "system", Content: "You are helpful."}}
+	for i := 0; i < turns; i++ {
+		messages = append(messages,
+			types.Message{Role: "user", Content: strings.Repeat("question ", 60)},
+			types.Message{Role: "assistant", Content: strings.Repeat("answer ", 60)},
+		)
+	}
+	return append(messages, types.Message{Role: "user", Content: "latest question"})
+}
+
+func TestStreamChat_CompactsNearContextLimit(t *testing.T) {
+	ollama := &fakeOllama{}
+	engine := newCompactionEngine(ollama)
+
+	messages := longConversation(6)
+	messages[3].Pinned = true
+	req := types.AIRequest{Model: "llama3.2", Messages: messages}
+
+	resp, err := engine.StreamChat(context.Background(), req, func(string) error { return nil })
+	require.NoError(t, err)
+	require.NotNil(t, resp.Compaction)
+
+	c := resp.Compaction
+	assert.True(t, c.Summarized)
+	assert.Equal(t, 1000, c.ContextWindow)
+	assert.Less(t, c.TokensAfter, c.TokensBefore)
+
+	// system prompt, summary, pinned message, then the two most recent messages
+	require.Len(t, c.Messages, 5)
+	assert.Equal(t, "You are helpful.", c.Messages[0].Content)
+	assert.Equal(t, MemoryPrefix+"- user is debugging a panic in main.go", c.Messages[1].Content)
+	assert.True(t, c.Messages[2].Pinned)
+	assert.Equal(t, "latest question", c.Messages[4].Content)
+	assert.Equal(t, 10, c.CompactedMessages)
+
+	// The compacted conversation is what the model finally sees
+	assert.Equal(t, c.Messages, ollama.chats[len(ollama.chats)-1])
+}
+
+func TestStreamChat_NoCompactionBelowThreshold(t *testing.T) {
+	ollama := &fakeOllama{}
+	engine := newCompactionEngine(ollama)
+
+	req := types.AIRequest{Model: "llama3.2", Messages: longConversation(1)}
+	resp, err := engine.StreamChat(context.Background(), req, func(string) error { return nil })
+	require.NoError(t, err)
+	assert.Nil(t, resp.Compaction)
+	assert.Len(t, ollama.chats, 1)
+}
+
+func TestStreamChat_DropsTurnsWhenSummaryFails(t *testing.T) {
+	ollama := &fakeOllama{summaryError: errors.New("model crashed")}
+	engine := newCompactionEngine(ollama)
+
+	req := types.AIRequest{Model: "llama3.2", Messages: longConversation(6)}
+	resp, err := engine.StreamChat(context.Background(), req, func(string) error { return nil })
+	require.NoError(t, err)
+	require.NotNil(t, resp.Compaction)
+	assert.False(t, resp.Compaction.Summarized)
+	assert.Len(t, resp.Compaction.Messages, 3)
+}
+
+func TestContextWindow_MatchesModelFamily(t *testing.T) {
+	engine := &AIEngineImpl{Config: types.AIConfig{
+		ContextWindow:       2048,
+		ModelContextWindows: map[string]int{"llama3.2": 8192},
+	}}
+
+	assert.Equal(t, 8192, engine.ContextWindow("llama3.2"))
+	assert.Equal(t, 8192, engine.ContextWindow("llama3.2:3b"))
+	assert.Equal(t, 2048, engine.ContextWindow("codellama"))
+	assert.Equal(t, DefaultContextWindow, (&AIEngineImpl{}).ContextWindow("codellama"))
+}
+
+func TestStreamChat_CompactsAgainOnlyAfterMargin(t *testing.T) {
+	ollama := &fakeOllama{}
+	engine := newCompactionEngine(ollama)
+	stream := func(messages []types.Message) *types.AIResponse {
+		resp, err := engine.StreamChat(context.Background(), types.AIRequest{Model: "llama3.2", Messages: messages}, func(string) error { return nil })
+		require.NoError(t, err)
+		return resp
+	}
+	nextTurn := func(messages []types.Message) []types.Message {
+		return append(messages,
+			types.Message{Role: "assistant", Content: strings.Repeat("answer ", 60)},
+			types.Message{Role: "user", Content: "next question"},
+		)
+	}
+
+	messages := longConversation(6)
+	messages[3].Pinned = true
+	resp := stream(messages)
+	require.NotNil(t, resp.Compaction)
+	messages = resp.Compaction.Messages
+
+	// Still above the threshold, but the turn that left the recent ones is
+	// too small to be worth another summary
+	messages = nextTurn(messages)
+	resp = stream(messages)
+	assert.Nil(t, resp.Compaction)
+	assert.Len(t, ollama.chats, 3)
+
+	messages = nextTurn(messages)
+	resp = stream(messages)
+	require.NotNil(t, resp.Compaction)
+	assert.True(t, resp.Compaction.Summarized)
+}
@@ -0,0 +1,200 @@
+package ai
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
+)
+
+// defaultMaxContinuations limits continuation requests when the config sets no limit
+const defaultMaxContinuations = 3
+
+// continuationPrompt asks the model to pick up a truncated answer
+const continuationPrompt = "Your previous answer was cut off. Continue exactly where it stopped, " +
+	"without repeating anything and without any introduction. If it stopped inside a code block, " +
+	"continue the code without opening a new block."
+
+// stitchWindow is how much of a continuation is examined for text repeating
+// the end of the previous part. Streamed continuations are held back until
+// this much has arrived.
+const stitchWindow = 256
+
+// minOverlap is the shortest repeated text removed when stitching, so a
+// continuation that happens to start like the previous part ended is kept
+const minOverlap = 12
+
+// autoContinue asks the model to continue an answer cut off at the length
+// limit and stitches the parts into one response. With a callback the
+// continuations are streamed to it. Messages must already be processed by the
+// prompt engine.
+func (e *AIEngineImpl) autoContinue(ctx context.Context, req types.AIRequest, response *types.AIResponse, callback func(chunk string) error) (*types.AIResponse, error) {
+	if response == nil || (!req.AutoContinue && !e.Config.AutoContinue) {
+		return response, nil
+	}
+	limit := e.Config.MaxContinuations
+	if limit <= 0 {
+		limit = defaultMaxContinuations
+	}
+
+	messages := req.Messages
+	if len(messages) == 0 {
+		messages = []types.Message{{Role: "user", Content: req.Prompt}}
+	}
+
+	for response.Continuations < limit && response.FinishReason == types.FinishLength {
+		logging.Note(ctx, "Answer cut off at the length limit, continuing (%d/%d)", response.Continuations+1, limit)
+
+		// Continue with the model that wrote the first part
+		next := req
+		next.Model = response.SelectedModel
+		next.Provider = response.SelectedProvider
+		next.Context = nil
+		next.Messages = append(append([]types.Message{}, messages...),
+			types.Message{Role: "assistant", Content: response.Text},
+			types.Message{Role: "user", Content: continuationPrompt},
+		)
+
+		var part *types.AIResponse
+		var err error
+		var drop int
+		var separator string
+		if callback != nil {
+			stitcher := &streamStitcher{previous: response.Text, callback: callback}
+			part, err = e.streamChatWithFallback(ctx, next, stitcher.write)
+			if err == nil {
+				err = stitcher.flush()
+				drop, separator = stitcher.drop, stitcher.separator
+			}
+		} else {
+			part, err = e.chatWithFallback(ctx, next)
+			if err == nil {
+				drop, separator = stitch(response.Text, part.Text)
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			// The part already received is still worth returning
+			logging.FromContext(ctx).Warn("continuation failed", "error", err)
+			logging.Note(ctx, "Could not continue the answer: %v", err)
+			break
+		}
+
+		response.Text += separator + part.Text[drop:]
+		response.FinishReason = part.FinishReason
+		response.Usage.PromptTokens += part.Usage.PromptTokens
+		response.Usage.CompletionTokens += part.Usage.CompletionTokens
+		response.Usage.TotalTokens += part.Usage.TotalTokens
+		response.Latency += part.Latency
+		response.Continuations++
+	}
+
+	if response.Messages != nil {
+		response.Messages = append(append([]types.Message{}, messages...), types.Message{Role: "assistant", Content: response.Text})
+	}
+	return response, nil
+}
+
+// stitch returns how many bytes to drop from the start of a continuation so
+// it joins the previous part seamlessly, and a separator to insert: a code
+// fence the model reopened although the previous part stopped inside one is
+// removed, as is text repeating the end of the previous part.
+func stitch(previous, continuation string) (drop int, separator string) {
+	window := continuation
+	if len(window) > stitchWindow {
+		window = window[:stitchWindow]
+	}
+
+	// A bare ``` closes the block, one naming the block's language reopens it
+	reopened := false
+	if fence := openCodeFence(previous); len(fence) > len("```") {
+		trimmed := strings.TrimLeft(window, " \t\r\n")
+		if end := strings.IndexByte(trimmed, '\n'); end >= 0 && strings.TrimSpace(trimmed[:end]) == fence {
+			drop = len(window) - len(trimmed) + end + 1
+			reopened = true
+		}
+	}
+
+	repeated := overlap(previous, window[drop:])
+	// The reopened fence put the code on a new line, which must be kept
+	// unless the model restarted the line the previous part stopped in
+	if reopened && repeated == 0 && !strings.HasSuffix(previous, "\n") {
+		separator = "\n"
+	}
+	return drop + repeated, separator
+}
+
+// overlap returns the length of the longest start of s that repeats the end
+// of previous. Short repeats only count when they restart the last line.
+func overlap(previous, s string) int {
+	lastLine := previous[strings.LastIndexByte(previous, '\n')+1:]
+	for n := min(len(s), len(previous)); n > 0; n-- {
+		if !strings.HasSuffix(previous, s[:n]) {
+			continue
+		}
+		if n >= minOverlap || (n == len(lastLine) && strings.TrimSpace(lastLine) != "") {
+			return n
+		}
+	}
+	return 0
+}
+
+// openCodeFence returns the opening line of the ``` block text ends in, or
+// "" when every block is closed
+func openCodeFence(text string) string {
+	fence := ""
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "```") {
+			continue
+		}
+		if fence == "" {
+			fence = line
+		} else {
+			fence = ""
+		}
+	}
+	return fence
+}
+
+// streamStitcher holds back the start of a streamed continuation until it
+// can be stitched to the previous part, then passes chunks through
+type streamStitcher struct {
+	previous string
+	callback func(chunk string) error
+	buffer   strings.Builder
+	started  bool
+
+	// drop and separator are how the continuation was stitched
+	drop      int
+	separator string
+}
+
+// write receives a chunk of the continuation
+func (s *streamStitcher) write(chunk string) error {
+	if s.started {
+		return s.callback(chunk)
+	}
+	s.buffer.WriteString(chunk)
+	if s.buffer.Len() < stitchWindow {
+		return nil
+	}
+	return s.flush()
+}
+
+// flush stitches and sends what was held back
+func (s *streamStitcher) flush() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	held := s.buffer.String()
+	s.drop, s.separator = stitch(s.previous, held)
+	if rest := s.separator + held[s.drop:]; rest != "" {
+		return s.callback(rest)
+	}
+	return nil
+}
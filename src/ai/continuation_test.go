@@ -0,0 +1,70 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/scripted"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+)
+
+const truncatingScript = `
+models: [{name: demo}]
+rules:
+  - contains: cut off
+    response: "` + "```go" + `\n\tfmt.Println(\"hi\")\n}\n` + "```" + `\n"
+  - contains: hello world
+    response: "Here it is:\n\n` + "```go" + `\nfunc main() {\n\tfmt.Println("
+    finish_reason: length
+`
+
+func TestEngine_AutoContinuesTruncatedAnswers(t *testing.T) {
+	script, err := scripted.Parse([]byte(truncatingScript))
+	require.NoError(t, err)
+	engine := NewAIEngineImpl(&fakeOllama{}, nil, passthroughPrompts{}, types.AIConfig{LocalEnabled: true})
+	engine.ScriptedClient = scripted.NewClient(script)
+	ctx := context.Background()
+	req := types.AIRequest{Model: "demo", Messages: []types.Message{{Role: "user", Content: "hello world in go"}}}
+	want := "Here it is:\n\n```go\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```\n"
+
+	// Off by default, the truncation is reported
+	resp, err := engine.Chat(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, types.FinishLength, resp.FinishReason)
+	assert.Zero(t, resp.Continuations)
+
+	req.AutoContinue = true
+	resp, err = engine.Chat(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, want, resp.Text)
+	assert.Equal(t, types.FinishStop, resp.FinishReason)
+	assert.Equal(t, 1, resp.Continuations)
+
+	var streamed strings.Builder
+	resp, err = engine.StreamChat(ctx, req, func(chunk string) error {
+		streamed.WriteString(chunk)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, want, resp.Text)
+	assert.Equal(t, want, streamed.String())
+}
+
+func TestStitch(t *testing.T) {
+	join := func(previous, continuation string) string {
+		drop, separator := stitch(previous, continuation)
+		return previous + separator + continuation[drop:]
+	}
+
+	// A closing fence is kept
+	assert.Equal(t, "```go\nx := 1\n```\ndone", join("```go\nx := 1\n", "```\ndone"))
+	// A reopened fence is dropped, keeping the line break it implied
+	assert.Equal(t, "```go\nx := 1\ny := 2\n```", join("```go\nx := 1", "```go\ny := 2\n```"))
+	// Repeated text is dropped, short coincidences are not
+	assert.Equal(t, "The quick brown fox jumps over", join("The quick brown fox", "quick brown fox jumps over"))
+	assert.Equal(t, "it is what it is", join("it is", " what it is"))
+}
@@ -5,8 +5,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/rrecio/crazy-dev-zsh/src/ai/redact"
 	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
+	"github.com/rrecio/crazy-dev-zsh/src/core/privacy"
 )
 
 // AIEngineImpl implements the types.AIEngine interface
@@ -15,6 +19,9 @@ type AIEngineImpl struct {
 	CloudClient  types.CloudClient
 	PromptEngine types.PromptEngine
 	Config       types.AIConfig
+
+	// ScriptedClient answers requests for the models declared by an offline script, if configured
+	ScriptedClient types.OllamaClient
 }
 
 // NewAIEngineImpl creates a new AIEngineImpl instance
@@ -36,39 +43,73 @@ func (e *AIEngineImpl) Complete(ctx context.Context, req types.AIRequest) (*type
 	}
 
 	// Process the prompt with the prompt engine
+	_, span := logging.StartSpan(ctx, "prompt.process")
 	processedPrompt, err := e.PromptEngine.ProcessPrompt(req.Prompt, req.Context)
+	span.End(err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to process prompt: %w", err)
 	}
 	req.Prompt = processedPrompt
 
+	response, err := e.completeWithFallback(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return e.autoContinue(ctx, req, response, nil)
+}
+
+// completeWithFallback sends a processed prompt to the local model, falling back to the cloud if enabled
+func (e *AIEngineImpl) completeWithFallback(ctx context.Context, req types.AIRequest) (*types.AIResponse, error) {
+	if e.useScripted(ctx, req) {
+		return attempt(ctx, "provider.attempt", string(types.ProviderScripted), req.Model, func(ctx context.Context) (*types.AIResponse, error) {
+			return e.ScriptedClient.Complete(ctx, req.Model, req.Prompt, types.CompletionOptions{
+				MaxTokens:     req.MaxTokens,
+				Temperature:   req.Temperature,
+				TopP:          req.TopP,
+				StopSequences: req.StopSequences,
+				Suffix:        req.Suffix,
+			})
+		})
+	}
+
 	// Try local model first if enabled
 	if e.Config.LocalEnabled && (req.Provider == string(types.ProviderOllama) || req.Provider == "") {
 		localReq := req
 		localReq.Provider = string(types.ProviderOllama)
 		
-		response, err := e.OllamaClient.Complete(ctx, localReq.Model, localReq.Prompt, types.CompletionOptions{
-			MaxTokens:     localReq.MaxTokens,
-			Temperature:   localReq.Temperature,
-			TopP:          localReq.TopP,
-			StopSequences: localReq.StopSequences,
+		response, err := attempt(ctx, "provider.attempt", localReq.Provider, localReq.Model, func(ctx context.Context) (*types.AIResponse, error) {
+			return e.OllamaClient.Complete(ctx, localReq.Model, localReq.Prompt, types.CompletionOptions{
+				MaxTokens:     localReq.MaxTokens,
+				Temperature:   localReq.Temperature,
+				TopP:          localReq.TopP,
+				StopSequences: localReq.StopSequences,
+				Suffix:        localReq.Suffix,
+			})
 		})
 		if err == nil {
 			return response, nil
 		}
 		
 		// If local fails and fallback is enabled, try cloud
-		if req.FallbackToCloud || e.Config.FallbackToCloud {
+		if (req.FallbackToCloud || e.Config.FallbackToCloud) && shouldFallBack(ctx, err) {
 			cloudReq := req
 			cloudReq.Provider = e.Config.CloudProvider
+			if blocked := e.allowCloud(ctx, cloudReq); blocked != nil {
+				return nil, fmt.Errorf("%w; cloud fallback %w", err, blocked)
+			}
+			logging.FromContext(ctx).Info("local model failed, falling back to cloud", "provider", e.Config.CloudProvider, "error", err)
 			cloudTimeout := e.Config.CloudAITimeout
 			cloudReq.Timeout = &cloudTimeout
+			e.redactForCloud(ctx, &cloudReq)
 			
-			return e.CloudClient.Complete(ctx, cloudReq.Model, cloudReq.Prompt, types.CompletionOptions{
-				MaxTokens:     cloudReq.MaxTokens,
-				Temperature:   cloudReq.Temperature,
-				TopP:          cloudReq.TopP,
-				StopSequences: cloudReq.StopSequences,
+			return attempt(ctx, "provider.attempt", cloudReq.Provider, cloudReq.Model, func(ctx context.Context) (*types.AIResponse, error) {
+				return e.CloudClient.Complete(ctx, cloudReq.Model, cloudReq.Prompt, types.CompletionOptions{
+					MaxTokens:     cloudReq.MaxTokens,
+					Temperature:   cloudReq.Temperature,
+					TopP:          cloudReq.TopP,
+					StopSequences: cloudReq.StopSequences,
+					Suffix:        cloudReq.Suffix,
+				})
 			})
 		}
 		
@@ -76,11 +117,18 @@ func (e *AIEngineImpl) Complete(ctx context.Context, req types.AIRequest) (*type
 	}
 	
 	// Use cloud directly if local is disabled or another provider is specified
-	return e.CloudClient.Complete(ctx, req.Model, req.Prompt, types.CompletionOptions{
-		MaxTokens:     req.MaxTokens,
-		Temperature:   req.Temperature,
-		TopP:          req.TopP,
-		StopSequences: req.StopSequences,
+	if err := e.allowCloud(ctx, req); err != nil {
+		return nil, err
+	}
+	e.redactForCloud(ctx, &req)
+	return attempt(ctx, "provider.attempt", e.cloudProviderName(req.Provider), req.Model, func(ctx context.Context) (*types.AIResponse, error) {
+		return e.CloudClient.Complete(ctx, req.Model, req.Prompt, types.CompletionOptions{
+			MaxTokens:     req.MaxTokens,
+			Temperature:   req.Temperature,
+			TopP:          req.TopP,
+			StopSequences: req.StopSequences,
+			Suffix:        req.Suffix,
+		})
 	})
 }
 
@@ -92,40 +140,83 @@ func (e *AIEngineImpl) Chat(ctx context.Context, req types.AIRequest) (*types.AI
 		req.Timeout = &timeout
 	}
 
+	// Summarise older turns if the conversation no longer fits the context window
+	compaction, err := e.compact(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+
 	// Process the messages with the prompt engine
+	_, span := logging.StartSpan(ctx, "prompt.process", "messages", len(req.Messages))
 	processedMessages, err := e.PromptEngine.ProcessMessages(req.Messages, req.Context)
+	span.End(err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to process messages: %w", err)
 	}
 	req.Messages = processedMessages
 
+	response, err := e.chatWithFallback(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if response, err = e.autoContinue(ctx, req, response, nil); err != nil {
+		return nil, err
+	}
+	if response != nil {
+		response.Compaction = compaction
+	}
+	return response, nil
+}
+
+// chatWithFallback sends processed messages to the local model, falling back to the cloud if enabled
+func (e *AIEngineImpl) chatWithFallback(ctx context.Context, req types.AIRequest) (*types.AIResponse, error) {
+	if e.useScripted(ctx, req) {
+		return attempt(ctx, "provider.attempt", string(types.ProviderScripted), req.Model, func(ctx context.Context) (*types.AIResponse, error) {
+			return e.ScriptedClient.Chat(ctx, req.Model, req.Messages, types.ChatOptions{
+				MaxTokens:     req.MaxTokens,
+				Temperature:   req.Temperature,
+				TopP:          req.TopP,
+				StopSequences: req.StopSequences,
+			})
+		})
+	}
+
 	// Try local model first if enabled
 	if e.Config.LocalEnabled && (req.Provider == string(types.ProviderOllama) || req.Provider == "") {
 		localReq := req
 		localReq.Provider = string(types.ProviderOllama)
 		
-		response, err := e.OllamaClient.Chat(ctx, localReq.Model, localReq.Messages, types.ChatOptions{
-			MaxTokens:     localReq.MaxTokens,
-			Temperature:   localReq.Temperature,
-			TopP:          localReq.TopP,
-			StopSequences: localReq.StopSequences,
+		response, err := attempt(ctx, "provider.attempt", localReq.Provider, localReq.Model, func(ctx context.Context) (*types.AIResponse, error) {
+			return e.OllamaClient.Chat(ctx, localReq.Model, localReq.Messages, types.ChatOptions{
+				MaxTokens:     localReq.MaxTokens,
+				Temperature:   localReq.Temperature,
+				TopP:          localReq.TopP,
+				StopSequences: localReq.StopSequences,
+			})
 		})
 		if err == nil {
 			return response, nil
 		}
 		
 		// If local fails and fallback is enabled, try cloud
-		if req.FallbackToCloud || e.Config.FallbackToCloud {
+		if (req.FallbackToCloud || e.Config.FallbackToCloud) && shouldFallBack(ctx, err) {
 			cloudReq := req
 			cloudReq.Provider = e.Config.CloudProvider
+			if blocked := e.allowCloud(ctx, cloudReq); blocked != nil {
+				return nil, fmt.Errorf("%w; cloud fallback %w", err, blocked)
+			}
+			logging.FromContext(ctx).Info("local model failed, falling back to cloud", "provider", e.Config.CloudProvider, "error", err)
 			cloudTimeout := e.Config.CloudAITimeout
 			cloudReq.Timeout = &cloudTimeout
+			e.redactForCloud(ctx, &cloudReq)
 			
-			return e.CloudClient.Chat(ctx, cloudReq.Model, cloudReq.Messages, types.ChatOptions{
-				MaxTokens:     cloudReq.MaxTokens,
-				Temperature:   cloudReq.Temperature,
-				TopP:          cloudReq.TopP,
-				StopSequences: cloudReq.StopSequences,
+			return attempt(ctx, "provider.attempt", cloudReq.Provider, cloudReq.Model, func(ctx context.Context) (*types.AIResponse, error) {
+				return e.CloudClient.Chat(ctx, cloudReq.Model, cloudReq.Messages, types.ChatOptions{
+					MaxTokens:     cloudReq.MaxTokens,
+					Temperature:   cloudReq.Temperature,
+					TopP:          cloudReq.TopP,
+					StopSequences: cloudReq.StopSequences,
+				})
 			})
 		}
 		
@@ -133,11 +224,17 @@ func (e *AIEngineImpl) Chat(ctx context.Context, req types.AIRequest) (*types.AI
 	}
 	
 	// Use cloud directly if local is disabled or another provider is specified
-	return e.CloudClient.Chat(ctx, req.Model, req.Messages, types.ChatOptions{
-		MaxTokens:     req.MaxTokens,
-		Temperature:   req.Temperature,
-		TopP:          req.TopP,
-		StopSequences: req.StopSequences,
+	if err := e.allowCloud(ctx, req); err != nil {
+		return nil, err
+	}
+	e.redactForCloud(ctx, &req)
+	return attempt(ctx, "provider.attempt", e.cloudProviderName(req.Provider), req.Model, func(ctx context.Context) (*types.AIResponse, error) {
+		return e.CloudClient.Chat(ctx, req.Model, req.Messages, types.ChatOptions{
+			MaxTokens:     req.MaxTokens,
+			Temperature:   req.Temperature,
+			TopP:          req.TopP,
+			StopSequences: req.StopSequences,
+		})
 	})
 }
 
@@ -149,53 +246,224 @@ func (e *AIEngineImpl) StreamChat(ctx context.Context, req types.AIRequest, call
 		req.Timeout = &timeout
 	}
 
+	// Summarise older turns if the conversation no longer fits the context window
+	compaction, err := e.compact(ctx, &req)
+	if err != nil {
+		return nil, err
+	}
+
 	// Process the messages with the prompt engine
+	_, span := logging.StartSpan(ctx, "prompt.process", "messages", len(req.Messages))
 	processedMessages, err := e.PromptEngine.ProcessMessages(req.Messages, req.Context)
+	span.End(err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to process messages: %w", err)
 	}
 	req.Messages = processedMessages
 
+	response, err := e.streamChatWithFallback(ctx, req, callback)
+	if err != nil {
+		return nil, err
+	}
+	if response, err = e.autoContinue(ctx, req, response, callback); err != nil {
+		return nil, err
+	}
+	if response != nil {
+		response.Compaction = compaction
+	}
+	return response, nil
+}
+
+// streamChatWithFallback streams processed messages from the local model, falling back to the cloud if enabled
+func (e *AIEngineImpl) streamChatWithFallback(ctx context.Context, req types.AIRequest, callback func(chunk string) error) (*types.AIResponse, error) {
+	if e.useScripted(ctx, req) {
+		return attempt(ctx, "provider.stream", string(types.ProviderScripted), req.Model, func(ctx context.Context) (*types.AIResponse, error) {
+			return e.ScriptedClient.StreamChat(ctx, req.Model, req.Messages, types.ChatOptions{
+				MaxTokens:     req.MaxTokens,
+				Temperature:   req.Temperature,
+				TopP:          req.TopP,
+				StopSequences: req.StopSequences,
+			}, markFirstToken(ctx, callback))
+		})
+	}
+
 	// Try local model first if enabled
 	if e.Config.LocalEnabled && (req.Provider == string(types.ProviderOllama) || req.Provider == "") {
 		localReq := req
 		localReq.Provider = string(types.ProviderOllama)
 		
-		response, err := e.OllamaClient.StreamChat(ctx, localReq.Model, localReq.Messages, types.ChatOptions{
-			MaxTokens:     localReq.MaxTokens,
-			Temperature:   localReq.Temperature,
-			TopP:          localReq.TopP,
-			StopSequences: localReq.StopSequences,
-		}, callback)
+		response, err := attempt(ctx, "provider.stream", localReq.Provider, localReq.Model, func(ctx context.Context) (*types.AIResponse, error) {
+			return e.OllamaClient.StreamChat(ctx, localReq.Model, localReq.Messages, types.ChatOptions{
+				MaxTokens:     localReq.MaxTokens,
+				Temperature:   localReq.Temperature,
+				TopP:          localReq.TopP,
+				StopSequences: localReq.StopSequences,
+			}, markFirstToken(ctx, callback))
+		})
 		if err == nil {
 			return response, nil
 		}
 		
 		// If local fails and fallback is enabled, try cloud
-		if req.FallbackToCloud || e.Config.FallbackToCloud {
+		if (req.FallbackToCloud || e.Config.FallbackToCloud) && shouldFallBack(ctx, err) {
 			cloudReq := req
 			cloudReq.Provider = e.Config.CloudProvider
+			if blocked := e.allowCloud(ctx, cloudReq); blocked != nil {
+				return nil, fmt.Errorf("%w; cloud fallback %w", err, blocked)
+			}
+			logging.FromContext(ctx).Info("local model failed, falling back to cloud", "provider", e.Config.CloudProvider, "error", err)
 			cloudTimeout := e.Config.CloudAITimeout
 			cloudReq.Timeout = &cloudTimeout
+			e.redactForCloud(ctx, &cloudReq)
 			
-			return e.CloudClient.StreamChat(ctx, cloudReq.Model, cloudReq.Messages, types.ChatOptions{
-				MaxTokens:     cloudReq.MaxTokens,
-				Temperature:   cloudReq.Temperature,
-				TopP:          cloudReq.TopP,
-				StopSequences: cloudReq.StopSequences,
-			}, callback)
+			return attempt(ctx, "provider.stream", cloudReq.Provider, cloudReq.Model, func(ctx context.Context) (*types.AIResponse, error) {
+				return e.CloudClient.StreamChat(ctx, cloudReq.Model, cloudReq.Messages, types.ChatOptions{
+					MaxTokens:     cloudReq.MaxTokens,
+					Temperature:   cloudReq.Temperature,
+					TopP:          cloudReq.TopP,
+					StopSequences: cloudReq.StopSequences,
+				}, markFirstToken(ctx, callback))
+			})
 		}
 		
 		return nil, err
 	}
 	
 	// Use cloud directly if local is disabled or another provider is specified
-	return e.CloudClient.StreamChat(ctx, req.Model, req.Messages, types.ChatOptions{
-		MaxTokens:     req.MaxTokens,
-		Temperature:   req.Temperature,
-		TopP:          req.TopP,
-		StopSequences: req.StopSequences,
-	}, callback)
+	if err := e.allowCloud(ctx, req); err != nil {
+		return nil, err
+	}
+	e.redactForCloud(ctx, &req)
+	return attempt(ctx, "provider.stream", e.cloudProviderName(req.Provider), req.Model, func(ctx context.Context) (*types.AIResponse, error) {
+		return e.CloudClient.StreamChat(ctx, req.Model, req.Messages, types.ChatOptions{
+			MaxTokens:     req.MaxTokens,
+			Temperature:   req.Temperature,
+			TopP:          req.TopP,
+			StopSequences: req.StopSequences,
+		}, markFirstToken(ctx, callback))
+	})
+}
+
+// shouldFallBack reports whether a failed local request is worth retrying in
+// the cloud. Interrupted requests and requests rejected as invalid are not.
+func shouldFallBack(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && !errors.Is(err, types.ErrInvalidRequest)
+}
+
+// allowCloud enforces the project's privacy policy before a request leaves
+// the machine. It fails closed: local-only projects, requests mentioning or
+// carrying a denied path and unconfirmed requests in ask mode are refused.
+func (e *AIEngineImpl) allowCloud(ctx context.Context, req types.AIRequest) error {
+	policy := e.Config.Privacy
+	if policy == nil {
+		return nil
+	}
+	provider := e.cloudProviderName(req.Provider)
+
+	var err error
+	texts := []string{req.Prompt, req.Suffix, string(req.Context)}
+	for _, msg := range req.Messages {
+		texts = append(texts, msg.Content)
+	}
+	denied := policy.MentionedDenied(texts...)
+	for _, file := range req.Files {
+		if policy.Denied(file) {
+			denied = append(denied, file)
+		}
+	}
+	if len(denied) > 0 {
+		err = fmt.Errorf("%w: the request mentions %s, which must stay on this machine", privacy.ErrCloudBlocked, strings.Join(denied, ", "))
+	} else {
+		switch policy.Mode {
+		case privacy.ModeCloudAllowed:
+			return nil
+		case privacy.ModeAsk:
+			if e.Config.ConfirmCloud != nil && e.Config.ConfirmCloud(provider) {
+				return nil
+			}
+			err = fmt.Errorf("%w: sending to %s was not confirmed", privacy.ErrCloudBlocked, provider)
+		default:
+			err = fmt.Errorf("%w: the project is %s", privacy.ErrCloudBlocked, privacy.ModeLocalOnly)
+		}
+	}
+
+	logging.FromContext(ctx).Info("cloud request blocked", "provider", provider, "policy", policy.Source, "error", err)
+	logging.Note(ctx, "Kept on this machine instead of sending to %s (%s)", provider, policy.Mode)
+	return err
+}
+
+// redactForCloud replaces secrets in the prompt, suffix and messages with
+// placeholders before they leave the machine
+func (e *AIEngineImpl) redactForCloud(ctx context.Context, req *types.AIRequest) {
+	if !e.Config.RedactionEnabled {
+		return
+	}
+
+	var findings []redact.Finding
+	prompt, found := redact.Text(req.Prompt)
+	req.Prompt = prompt
+	findings = append(findings, found...)
+	suffix, found := redact.Text(req.Suffix)
+	req.Suffix = suffix
+	findings = append(findings, found...)
+	messages := make([]types.Message, len(req.Messages))
+	for i, msg := range req.Messages {
+		msg.Content, found = redact.Text(msg.Content)
+		findings = append(findings, found...)
+		messages[i] = msg
+	}
+	req.Messages = messages
+	if len(findings) == 0 {
+		return
+	}
+
+	provider := e.cloudProviderName(req.Provider)
+	summary := redact.Summarize(findings)
+	logging.FromContext(ctx).Info("redacted secrets before cloud request", "provider", provider, "secrets", len(findings), "kinds", summary.String())
+	logging.Note(ctx, "Redacted before sending to %s: %s", provider, summary)
+}
+
+// attempt calls a provider inside a span, so every try shows up in the logs and timing breakdown
+func attempt(ctx context.Context, name, provider, model string, call func(ctx context.Context) (*types.AIResponse, error)) (*types.AIResponse, error) {
+	ctx, span := logging.StartSpan(ctx, name, "provider", provider, "model", model)
+	response, err := call(ctx)
+	span.End(err)
+	return response, err
+}
+
+// markFirstToken wraps a stream callback to record when the first chunk arrives
+func markFirstToken(ctx context.Context, callback func(chunk string) error) func(chunk string) error {
+	span := logging.SpanFromContext(ctx)
+	return func(chunk string) error {
+		if span != nil {
+			span.Mark("first_token")
+		}
+		return callback(chunk)
+	}
+}
+
+// useScripted reports whether req is answered by the scripted provider, either
+// because it asks for it explicitly or because the script declares the model
+func (e *AIEngineImpl) useScripted(ctx context.Context, req types.AIRequest) bool {
+	if e.ScriptedClient == nil {
+		return false
+	}
+	if req.Provider == string(types.ProviderScripted) {
+		return true
+	}
+	if req.Provider != "" {
+		return false
+	}
+	available, err := e.ScriptedClient.CheckModelAvailability(ctx, req.Model)
+	return err == nil && available
+}
+
+// cloudProviderName returns the provider a cloud request is sent to
+func (e *AIEngineImpl) cloudProviderName(provider string) string {
+	if provider != "" {
+		return provider
+	}
+	return e.Config.CloudProvider
 }
 
 // GetEmbedding generates embeddings for the given text
@@ -208,15 +476,28 @@ func (e *AIEngineImpl) GetEmbedding(ctx context.Context, text string, model stri
 		}
 		
 		// If local fails and fallback is enabled, try cloud
-		if e.Config.FallbackToCloud {
-			return e.CloudClient.GetEmbedding(ctx, text, model)
+		if e.Config.FallbackToCloud && shouldFallBack(ctx, err) {
+			if blocked := e.allowCloud(ctx, types.AIRequest{Prompt: text}); blocked != nil {
+				return nil, fmt.Errorf("%w; cloud fallback %w", err, blocked)
+			}
+			return e.CloudClient.GetEmbedding(ctx, e.redactTextForCloud(ctx, text), model)
 		}
 		
 		return nil, err
 	}
 	
 	// Use cloud directly if local is disabled
-	return e.CloudClient.GetEmbedding(ctx, text, model)
+	if err := e.allowCloud(ctx, types.AIRequest{Prompt: text}); err != nil {
+		return nil, err
+	}
+	return e.CloudClient.GetEmbedding(ctx, e.redactTextForCloud(ctx, text), model)
+}
+
+// redactTextForCloud replaces secrets in text before it is sent to the cloud
+func (e *AIEngineImpl) redactTextForCloud(ctx context.Context, text string) string {
+	req := types.AIRequest{Prompt: text}
+	e.redactForCloud(ctx, &req)
+	return req.Prompt
 }
 
 // ListModels lists available models
@@ -229,6 +510,11 @@ func (e *AIEngineImpl) ListModels(ctx context.Context, provider string) ([]types
 		switch types.ModelProvider(provider) {
 		case types.ProviderOllama:
 			return e.OllamaClient.ListModels(ctx)
+		case types.ProviderScripted:
+			if e.ScriptedClient == nil {
+				return nil, errors.New("no scripted provider configured")
+			}
+			return e.ScriptedClient.ListModels(ctx)
 		case types.ProviderOpenAI, types.ProviderAnthropic:
 			models, err := e.CloudClient.ListModels(ctx)
 			// Filter models by provider
@@ -245,6 +531,13 @@ func (e *AIEngineImpl) ListModels(ctx context.Context, provider string) ([]types
 	}
 
 	// Otherwise, list models from all configured providers
+	if e.ScriptedClient != nil {
+		scriptedModels, scriptedErr := e.ScriptedClient.ListModels(ctx)
+		if scriptedErr == nil {
+			models = append(models, scriptedModels...)
+		}
+	}
+
 	ollamaModels, ollamaErr := e.OllamaClient.ListModels(ctx)
 	if ollamaErr == nil {
 		models = append(models, ollamaModels...)
@@ -284,6 +577,11 @@ func (e *AIEngineImpl) CheckModelAvailability(ctx context.Context, model string,
 	switch types.ModelProvider(provider) {
 	case types.ProviderOllama:
 		return e.OllamaClient.CheckModelAvailability(ctx, model)
+	case types.ProviderScripted:
+		if e.ScriptedClient == nil {
+			return false, nil
+		}
+		return e.ScriptedClient.CheckModelAvailability(ctx, model)
 	case types.ProviderOpenAI, types.ProviderAnthropic:
 		return e.CloudClient.CheckModelAvailability(ctx, model, provider)
 	default:
@@ -293,6 +591,11 @@ func (e *AIEngineImpl) CheckModelAvailability(ctx context.Context, model string,
 
 // InstallModel installs a model (for local providers like Ollama)
 func (e *AIEngineImpl) InstallModel(ctx context.Context, model string) error {
+	// Scripted models need no installation
+	if e.useScripted(ctx, types.AIRequest{Model: model}) {
+		return e.ScriptedClient.InstallModel(ctx, model)
+	}
+
 	// Only Ollama supports model installation
 	return e.OllamaClient.InstallModel(ctx, model)
 }
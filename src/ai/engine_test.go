@@ -0,0 +1,126 @@
+package ai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/redact"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/scripted"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+	"github.com/rrecio/crazy-dev-zsh/src/core/privacy"
+)
+
+func TestEngine_RoutesScriptedModels(t *testing.T) {
+	script, err := scripted.Parse([]byte("models: [{name: demo}]\nfallback: {response: scripted answer}\n"))
+	require.NoError(t, err)
+
+	ollama := &fakeOllama{}
+	engine := NewAIEngineImpl(ollama, nil, passthroughPrompts{}, types.AIConfig{LocalEnabled: true})
+	engine.ScriptedClient = scripted.NewClient(script)
+	ctx := context.Background()
+	messages := []types.Message{{Role: "user", Content: "hi"}}
+
+	resp, err := engine.Chat(ctx, types.AIRequest{Model: "demo", Messages: messages})
+	require.NoError(t, err)
+	assert.Equal(t, "scripted answer", resp.Text)
+	assert.Empty(t, ollama.chats)
+
+	// Models the script does not declare still go to the local client
+	resp, err = engine.Chat(ctx, types.AIRequest{Model: "llama3.2", Messages: messages})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.Text)
+	assert.Len(t, ollama.chats, 1)
+
+	models, err := engine.ListModels(ctx, string(types.ProviderScripted))
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, "demo", models[0].Name)
+}
+
+// fakeCloud records the messages and completion suffixes sent to the cloud
+type fakeCloud struct {
+	types.CloudClient
+	chats       [][]types.Message
+	completions []string
+}
+
+func (f *fakeCloud) Chat(ctx context.Context, model string, messages []types.Message, opts types.ChatOptions) (*types.AIResponse, error) {
+	f.chats = append(f.chats, messages)
+	return &types.AIResponse{Text: "cloud"}, nil
+}
+
+func (f *fakeCloud) Complete(ctx context.Context, model string, prompt string, opts types.CompletionOptions) (*types.AIResponse, error) {
+	f.completions = append(f.completions, opts.Suffix)
+	return &types.AIResponse{Text: "cloud"}, nil
+}
+
+func TestEngine_RedactsCloudRequests(t *testing.T) {
+	ollama := &fakeOllama{}
+	cloud := &fakeCloud{}
+	engine := NewAIEngineImpl(ollama, cloud, passthroughPrompts{}, types.AIConfig{LocalEnabled: true, RedactionEnabled: true})
+	messages := []types.Message{{Role: "user", Content: "why does DB_PASSWORD=correct-horse-battery fail?"}}
+
+	// Local models see the original text
+	_, err := engine.Chat(context.Background(), types.AIRequest{Model: "llama3.2", Messages: messages})
+	require.NoError(t, err)
+	assert.Contains(t, ollama.chats[0][0].Content, "correct-horse-battery")
+
+	_, err = engine.Chat(context.Background(), types.AIRequest{Model: "gpt-4", Provider: "openai", Messages: messages})
+	require.NoError(t, err)
+	require.Len(t, cloud.chats, 1)
+	assert.Equal(t, "why does DB_PASSWORD="+redact.Placeholder(redact.KindAssignment, "correct-horse-battery")+" fail?", cloud.chats[0][0].Content)
+	assert.Contains(t, messages[0].Content, "correct-horse-battery", "the caller's messages are not modified")
+}
+
+func TestEngine_EnforcesPrivacyPolicy(t *testing.T) {
+	ollama := &fakeOllama{}
+	cloud := &fakeCloud{}
+	config := types.AIConfig{LocalEnabled: true, FallbackToCloud: true}
+	engine := NewAIEngineImpl(ollama, cloud, passthroughPrompts{}, config)
+	messages := []types.Message{{Role: "user", Content: "review secrets/prod.env"}}
+	req := types.AIRequest{Model: "gpt-4", Provider: "openai", Messages: messages, FallbackToCloud: true}
+
+	engine.Config.Privacy = &privacy.Policy{Mode: privacy.ModeLocalOnly}
+	_, err := engine.Chat(context.Background(), req)
+	assert.ErrorIs(t, err, privacy.ErrCloudBlocked)
+
+	// Ask mode refuses unless the user confirms
+	engine.Config.Privacy = &privacy.Policy{Mode: privacy.ModeAsk}
+	_, err = engine.Chat(context.Background(), req)
+	assert.ErrorIs(t, err, privacy.ErrCloudBlocked)
+	engine.Config.ConfirmCloud = func(provider string) bool { return provider == "openai" }
+	_, err = engine.Chat(context.Background(), req)
+	assert.NoError(t, err)
+
+	// Deny paths apply even when the cloud is allowed
+	engine.Config.Privacy = &privacy.Policy{Mode: privacy.ModeCloudAllowed, DenyPaths: []string{"secrets/"}}
+	_, err = engine.Chat(context.Background(), req)
+	assert.ErrorIs(t, err, privacy.ErrCloudBlocked)
+	assert.Len(t, cloud.chats, 1)
+}
+
+func TestEngine_GuardsCompletionSuffix(t *testing.T) {
+	cloud := &fakeCloud{}
+	engine := NewAIEngineImpl(&fakeOllama{}, cloud, passthroughPrompts{}, types.AIConfig{RedactionEnabled: true})
+	req := types.AIRequest{Model: "gpt-4", Provider: "openai", Prompt: "func main() {", Suffix: "\tdb := \"postgres://app:hunter2-hunter2@db/prod\"\n}"}
+
+	_, err := engine.Complete(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, cloud.completions, 1)
+	assert.NotContains(t, cloud.completions[0], "hunter2")
+
+	// Code from a denied file stays local even when nothing in it names the file
+	engine.Config.Privacy = &privacy.Policy{Mode: privacy.ModeCloudAllowed, Root: "/src/app", DenyPaths: []string{"internal/billing/"}}
+	req.Files = []string{"/src/app/internal/billing/main.go"}
+	_, err = engine.Complete(context.Background(), req)
+	assert.ErrorIs(t, err, privacy.ErrCloudBlocked)
+
+	req.Files = nil
+	req.Suffix = "// see internal/billing/rates.go\n}"
+	_, err = engine.Complete(context.Background(), req)
+	assert.ErrorIs(t, err, privacy.ErrCloudBlocked)
+	assert.Len(t, cloud.completions, 1)
+}
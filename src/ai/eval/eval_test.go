@@ -0,0 +1,139 @@
+package eval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/prompt"
+)
+
+const testSuite = `name: smoke
+models: [good, bad]
+judge_model: judge
+cases:
+  - name: explain
+    template: code_explanation
+    vars: {Code: "@loop.go"}
+    assert:
+      - contains: LOOP
+      - regex: "three (times|iterations)"
+      - rubric: Mentions the loop count
+  - name: json
+    prompt: Describe the loop as JSON
+    assert:
+      - json_schema:
+          type: object
+          required: [iterations]
+          properties:
+            iterations: {type: integer, minimum: 1}
+`
+
+// fakeEngine answers well as model "good" and badly as any other model
+type fakeEngine struct {
+	ai.AIEngine
+	prompts []string
+}
+
+func (f *fakeEngine) Chat(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+	content := req.Messages[len(req.Messages)-1].Content
+	f.prompts = append(f.prompts, content)
+	text := "no idea"
+	switch {
+	case req.Model == "judge":
+		text = `Verdict: {"pass": false, "reason": "no count"}`
+		if strings.Contains(content, "three times") {
+			text = `{"pass": true, "reason": "count given"}`
+		}
+	case req.Model == "good" && strings.Contains(content, "JSON"):
+		text = "```json\n{\"iterations\": 3}\n```"
+	case req.Model == "good":
+		text = "This loop runs three times."
+	}
+	return &ai.AIResponse{Text: text, Usage: ai.AIUsage{TotalTokens: 10}}, nil
+}
+
+func TestRunner_Run(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "suite.yaml"), []byte(testSuite), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "loop.go"), []byte("for i := 0; i < 3; i++ {}"), 0644))
+
+	suite, err := LoadSuite(filepath.Join(dir, "suite.yaml"))
+	require.NoError(t, err)
+	lib, err := prompt.NewLibrary()
+	require.NoError(t, err)
+	engine := &fakeEngine{}
+
+	run, err := (&Runner{Engine: engine, Library: lib}).Run(context.Background(), suite)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"explain", "json"}, run.Cases())
+	assert.Contains(t, engine.prompts[0], "for i := 0; i < 3; i++ {}")
+
+	good, _ := run.Get("explain", "good")
+	assert.True(t, good.Passed, good.Assertions)
+	assert.Equal(t, 10, good.Usage.TotalTokens)
+	good, _ = run.Get("json", "good")
+	assert.True(t, good.Passed, good.Assertions)
+
+	bad, _ := run.Get("explain", "bad")
+	assert.False(t, bad.Passed)
+	require.Len(t, bad.Assertions, 3)
+	assert.Equal(t, "no count", bad.Assertions[2].Message)
+	bad, _ = run.Get("json", "bad")
+	assert.Contains(t, bad.Assertions[0].Message, "not JSON")
+
+	passed, failed := run.Counts()
+	assert.Equal(t, 2, passed)
+	assert.Equal(t, 2, failed)
+
+	// Saved results are compared with the next run
+	resultsDir := t.TempDir()
+	_, err = run.Save(resultsDir)
+	require.NoError(t, err)
+	previous, err := LatestResult(resultsDir, "smoke")
+	require.NoError(t, err)
+	require.NotNil(t, previous)
+
+	next, err := (&Runner{Engine: engine, Library: lib, Models: []string{"bad"}}).Run(context.Background(), suite)
+	require.NoError(t, err)
+	assert.Empty(t, next.Compare(previous))
+	rerun, err := (&Runner{Engine: engine, Library: lib, Models: []string{"good"}}).Run(context.Background(), suite)
+	require.NoError(t, err)
+	previous.Results[0].Passed = false
+	changes := rerun.Compare(previous)
+	require.Len(t, changes, 1)
+	assert.False(t, changes[0].Regression())
+}
+
+func TestValidateSchema(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"required":             []interface{}{"name"},
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string", "pattern": "^[a-z]+$"},
+			"tags": map[string]interface{}{"type": "array", "items": map[string]interface{}{"enum": []interface{}{"a", "b"}}},
+		},
+	}
+	value, err := parseJSONAnswer(`{"name": "ok", "tags": ["a"]}`)
+	require.NoError(t, err)
+	assert.NoError(t, validateSchema(schema, value, ""))
+
+	for answer, violation := range map[string]string{
+		`{"tags": []}`:                  "missing property name",
+		`{"name": "Upper"}`:             "does not match",
+		`{"name": "ok", "tags": ["c"]}`: "$.tags[0]",
+		`{"name": "ok", "extra": 1}`:    "unexpected property extra",
+		`["name"]`:                      "expected object",
+	} {
+		value, err := parseJSONAnswer(answer)
+		require.NoError(t, err)
+		assert.ErrorContains(t, validateSchema(schema, value, ""), violation, answer)
+	}
+}
@@ -0,0 +1,184 @@
+package eval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+)
+
+// resultTimeFormat names saved results so they sort chronologically
+const resultTimeFormat = "20060102-150405.000"
+
+// unsafeNameChars are replaced in suite names used as directory names
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// RunResult is the outcome of evaluating a suite
+type RunResult struct {
+	Suite      string       `json:"suite" yaml:"suite"`
+	SuitePath  string       `json:"suite_path,omitempty" yaml:"suite_path,omitempty"`
+	Models     []string     `json:"models" yaml:"models"`
+	StartedAt  time.Time    `json:"started_at" yaml:"started_at"`
+	DurationMs int64        `json:"duration_ms" yaml:"duration_ms"`
+	Results    []CaseResult `json:"results" yaml:"results"`
+}
+
+// CaseResult is the outcome of one case on one model
+type CaseResult struct {
+	Case       string            `json:"case" yaml:"case"`
+	Model      string            `json:"model" yaml:"model"`
+	Passed     bool              `json:"passed" yaml:"passed"`
+	Error      string            `json:"error,omitempty" yaml:"error,omitempty"`
+	Output     string            `json:"output" yaml:"output"`
+	LatencyMs  int64             `json:"latency_ms" yaml:"latency_ms"`
+	Usage      ai.AIUsage        `json:"usage" yaml:"usage"`
+	Assertions []AssertionResult `json:"assertions,omitempty" yaml:"assertions,omitempty"`
+}
+
+// AssertionResult is the outcome of one assertion
+type AssertionResult struct {
+	Assertion string `json:"assertion" yaml:"assertion"`
+	Passed    bool   `json:"passed" yaml:"passed"`
+	Message   string `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// Change is a case whose outcome differs from a previous run
+type Change struct {
+	Case   string `json:"case" yaml:"case"`
+	Model  string `json:"model" yaml:"model"`
+	Before bool   `json:"before" yaml:"before"`
+	After  bool   `json:"after" yaml:"after"`
+}
+
+// Regression reports whether a passing case now fails
+func (c Change) Regression() bool {
+	return c.Before && !c.After
+}
+
+// Passed reports whether every case passed
+func (r *RunResult) Passed() bool {
+	for _, result := range r.Results {
+		if !result.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// Counts returns the number of passed and failed results
+func (r *RunResult) Counts() (passed, failed int) {
+	for _, result := range r.Results {
+		if result.Passed {
+			passed++
+		} else {
+			failed++
+		}
+	}
+	return passed, failed
+}
+
+// Cases returns the case names in the order they ran
+func (r *RunResult) Cases() []string {
+	var cases []string
+	seen := make(map[string]bool)
+	for _, result := range r.Results {
+		if !seen[result.Case] {
+			seen[result.Case] = true
+			cases = append(cases, result.Case)
+		}
+	}
+	return cases
+}
+
+// Get returns the result of a case on a model
+func (r *RunResult) Get(caseName, model string) (CaseResult, bool) {
+	for _, result := range r.Results {
+		if result.Case == caseName && result.Model == model {
+			return result, true
+		}
+	}
+	return CaseResult{}, false
+}
+
+// Compare lists the cases and models whose outcome changed since previous,
+// regressions first
+func (r *RunResult) Compare(previous *RunResult) []Change {
+	var changes []Change
+	for _, result := range r.Results {
+		before, ok := previous.Get(result.Case, result.Model)
+		if ok && before.Passed != result.Passed {
+			changes = append(changes, Change{Case: result.Case, Model: result.Model, Before: before.Passed, After: result.Passed})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Regression() && !changes[j].Regression()
+	})
+	return changes
+}
+
+// Save writes the result to dir/<suite>/<time>.json and returns the path
+func (r *RunResult) Save(dir string) (string, error) {
+	suiteDir := filepath.Join(dir, suiteDirName(r.Suite))
+	if err := os.MkdirAll(suiteDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create results directory: %w", err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(suiteDir, r.StartedAt.Format(resultTimeFormat)+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save results: %w", err)
+	}
+	return path, nil
+}
+
+// LoadResult reads a saved result
+func LoadResult(path string) (*RunResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results: %w", err)
+	}
+	var result RunResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid results %s: %w", path, err)
+	}
+	return &result, nil
+}
+
+// LatestResult returns the most recent saved result of a suite, or nil when there is none
+func LatestResult(dir, suite string) (*RunResult, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, suiteDirName(suite)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results directory: %w", err)
+	}
+	var latest string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") && entry.Name() > latest {
+			latest = entry.Name()
+		}
+	}
+	if latest == "" {
+		return nil, nil
+	}
+	return LoadResult(filepath.Join(dir, suiteDirName(suite), latest))
+}
+
+// suiteDirName turns a suite name into a directory name
+func suiteDirName(suite string) string {
+	name := unsafeNameChars.ReplaceAllString(suite, "_")
+	if name == "" || name == "." || name == ".." {
+		return "suite"
+	}
+	return name
+}
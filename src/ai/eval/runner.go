@@ -0,0 +1,236 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/prompt"
+)
+
+// judgeSystemPrompt instructs the judge model to grade an answer against a rubric
+const judgeSystemPrompt = `You are a strict evaluator. Decide whether the response satisfies the rubric.
+Reply with a single JSON object and nothing else: {"pass": true or false, "reason": "one short sentence"}`
+
+// judgeVerdictPattern finds the JSON verdict in the judge's answer
+var judgeVerdictPattern = regexp.MustCompile(`(?s)\{.*\}`)
+
+// Runner evaluates suites with an AI engine
+type Runner struct {
+	Engine  ai.AIEngine
+	Library *prompt.Library
+
+	// Models override the suite's models, JudgeModel its judge model
+	Models     []string
+	JudgeModel string
+
+	// Timeout limits each request, including judge requests
+	Timeout time.Duration
+
+	// Progress is called after each case and model, if set
+	Progress func(CaseResult)
+}
+
+// Run evaluates every case of the suite on every model
+func (r *Runner) Run(ctx context.Context, suite *Suite) (*RunResult, error) {
+	models := r.Models
+	if len(models) == 0 {
+		models = suite.Models
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no models to evaluate, list them in the suite or pass -m")
+	}
+
+	run := &RunResult{
+		Suite:     suite.Name,
+		SuitePath: suite.Path,
+		Models:    models,
+		StartedAt: time.Now(),
+	}
+	for _, c := range suite.Cases {
+		for _, model := range models {
+			if err := ctx.Err(); err != nil {
+				return run, err
+			}
+			result := r.runCase(ctx, suite, c, model)
+			run.Results = append(run.Results, result)
+			if r.Progress != nil {
+				r.Progress(result)
+			}
+		}
+	}
+	run.DurationMs = time.Since(run.StartedAt).Milliseconds()
+	return run, nil
+}
+
+// runCase sends one case to one model and checks its assertions
+func (r *Runner) runCase(ctx context.Context, suite *Suite, c Case, model string) CaseResult {
+	result := CaseResult{Case: c.Name, Model: model}
+
+	req, err := r.buildRequest(suite, c, model)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	reqCtx, cancel := r.withTimeout(ctx)
+	start := time.Now()
+	resp, err := r.Engine.Chat(reqCtx, req)
+	cancel()
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Output = resp.Text
+	result.Usage = resp.Usage
+
+	judge := r.JudgeModel
+	if judge == "" {
+		judge = suite.JudgeModel
+	}
+	if judge == "" {
+		judge = model
+	}
+	userPrompt := req.Messages[len(req.Messages)-1].Content
+
+	result.Passed = true
+	for _, assertion := range c.Assert {
+		check := r.check(ctx, assertion, judge, userPrompt, resp.Text)
+		result.Assertions = append(result.Assertions, check)
+		if !check.Passed {
+			result.Passed = false
+		}
+	}
+	return result
+}
+
+// buildRequest renders the case's prompt. Case parameters win over the
+// template's, which win over the suite's.
+func (r *Runner) buildRequest(suite *Suite, c Case, model string) (ai.AIRequest, error) {
+	userPrompt := c.Prompt
+	system := c.System
+	params := suite.Params
+	if c.Template != "" {
+		if r.Library == nil {
+			return ai.AIRequest{}, fmt.Errorf("no prompt library to render template %s", c.Template)
+		}
+		tmpl, ok := r.Library.Get(c.Template)
+		if !ok {
+			return ai.AIRequest{}, fmt.Errorf("template not found: %s", c.Template)
+		}
+		rendered, err := r.Library.Render(c.Template, c.Vars, nil)
+		if err != nil {
+			return ai.AIRequest{}, err
+		}
+		userPrompt = rendered
+		if system == "" {
+			system = tmpl.System
+		}
+		params = mergeParams(params, tmpl.Params)
+	}
+	params = mergeParams(params, c.Params)
+
+	var messages []ai.Message
+	if system != "" {
+		messages = append(messages, ai.Message{Role: "system", Content: system})
+	}
+	messages = append(messages, ai.Message{Role: "user", Content: userPrompt})
+	req := ai.AIRequest{
+		Model:     model,
+		ModelType: ai.ModelTypeChat,
+		Messages:  messages,
+		MaxTokens: params.MaxTokens,
+	}
+	if params.Temperature != nil {
+		req.Temperature = *params.Temperature
+	}
+	if params.TopP != nil {
+		req.TopP = *params.TopP
+	}
+	return req, nil
+}
+
+// mergeParams returns base with the parameters set in override replaced
+func mergeParams(base, override prompt.Params) prompt.Params {
+	if override.Temperature != nil {
+		base.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		base.TopP = override.TopP
+	}
+	if override.MaxTokens > 0 {
+		base.MaxTokens = override.MaxTokens
+	}
+	return base
+}
+
+// check evaluates one assertion on an answer
+func (r *Runner) check(ctx context.Context, a Assertion, judge, userPrompt, answer string) AssertionResult {
+	result := AssertionResult{Assertion: a.String()}
+	switch {
+	case a.Contains != "":
+		result.Passed = strings.Contains(strings.ToLower(answer), strings.ToLower(a.Contains))
+	case a.NotContains != "":
+		result.Passed = !strings.Contains(strings.ToLower(answer), strings.ToLower(a.NotContains))
+	case a.regex != nil:
+		result.Passed = a.regex.MatchString(answer)
+	case a.schema != nil:
+		value, err := parseJSONAnswer(answer)
+		if err == nil {
+			err = validateSchema(a.schema.(map[string]interface{}), value, "")
+		}
+		result.Passed = err == nil
+		if err != nil {
+			result.Message = err.Error()
+		}
+	case a.Rubric != "":
+		passed, reason, err := r.judge(ctx, judge, a.Rubric, userPrompt, answer)
+		result.Passed = passed
+		result.Message = reason
+		if err != nil {
+			result.Message = err.Error()
+		}
+	}
+	return result
+}
+
+// judge asks the judge model whether the answer satisfies the rubric
+func (r *Runner) judge(ctx context.Context, model, rubric, userPrompt, answer string) (bool, string, error) {
+	content := fmt.Sprintf("Rubric:\n%s\n\nPrompt:\n%s\n\nResponse:\n%s", rubric, userPrompt, answer)
+	reqCtx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	resp, err := r.Engine.Chat(reqCtx, ai.AIRequest{
+		Model:     model,
+		ModelType: ai.ModelTypeChat,
+		Messages: []ai.Message{
+			{Role: "system", Content: judgeSystemPrompt},
+			{Role: "user", Content: content},
+		},
+	})
+	if err != nil {
+		return false, "", fmt.Errorf("judge failed: %w", err)
+	}
+
+	var verdict struct {
+		Pass   bool   `json:"pass"`
+		Reason string `json:"reason"`
+	}
+	match := judgeVerdictPattern.FindString(resp.Text)
+	if match == "" || json.Unmarshal([]byte(match), &verdict) != nil {
+		return false, "", fmt.Errorf("judge gave no verdict: %q", strings.TrimSpace(resp.Text))
+	}
+	return verdict.Pass, verdict.Reason, nil
+}
+
+// withTimeout applies the runner's request timeout, if any
+func (r *Runner) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.Timeout)
+}
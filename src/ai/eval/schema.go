@@ -0,0 +1,176 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// jsonFencePattern matches a fenced code block, models often wrap JSON in one
+var jsonFencePattern = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*\\n(.*?)```")
+
+// parseJSONAnswer decodes the JSON in an answer, unwrapping a code fence
+func parseJSONAnswer(text string) (interface{}, error) {
+	text = strings.TrimSpace(text)
+	if match := jsonFencePattern.FindStringSubmatch(text); match != nil {
+		text = strings.TrimSpace(match[1])
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return nil, fmt.Errorf("answer is not JSON: %w", err)
+	}
+	return value, nil
+}
+
+// validateSchema checks value against the supported subset of JSON Schema:
+// type, enum, const, properties, required, additionalProperties, items,
+// minItems, maxItems, minLength, maxLength, pattern, minimum and maximum.
+// It returns the first violation.
+func validateSchema(schema map[string]interface{}, value interface{}, path string) error {
+	if path == "" {
+		path = "$"
+	}
+
+	if types, ok := schema["type"]; ok {
+		if !matchesType(types, value) {
+			return fmt.Errorf("%s: expected %v, got %s", path, types, jsonType(value))
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, option := range enum {
+			if equalJSON(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+		}
+	}
+	if constant, ok := schema["const"]; ok && !equalJSON(constant, value) {
+		return fmt.Errorf("%s: expected %v", path, constant)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := v[fmt.Sprint(name)]; !ok {
+					return fmt.Errorf("%s: missing property %v", path, name)
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			propertySchema, declared := properties[name].(map[string]interface{})
+			if !declared {
+				if allowed, ok := schema["additionalProperties"].(bool); ok && !allowed {
+					return fmt.Errorf("%s: unexpected property %s", path, name)
+				}
+				continue
+			}
+			if err := validateSchema(propertySchema, v[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if min, ok := schemaNumber(schema, "minItems"); ok && float64(len(v)) < min {
+			return fmt.Errorf("%s: expected at least %g items, got %d", path, min, len(v))
+		}
+		if max, ok := schemaNumber(schema, "maxItems"); ok && float64(len(v)) > max {
+			return fmt.Errorf("%s: expected at most %g items, got %d", path, max, len(v))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := float64(len([]rune(v)))
+		if min, ok := schemaNumber(schema, "minLength"); ok && length < min {
+			return fmt.Errorf("%s: shorter than %g characters", path, min)
+		}
+		if max, ok := schemaNumber(schema, "maxLength"); ok && length > max {
+			return fmt.Errorf("%s: longer than %g characters", path, max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern: %w", path, err)
+			}
+			if !re.MatchString(v) {
+				return fmt.Errorf("%s: %q does not match %s", path, v, pattern)
+			}
+		}
+	case float64:
+		if min, ok := schemaNumber(schema, "minimum"); ok && v < min {
+			return fmt.Errorf("%s: %g is less than %g", path, v, min)
+		}
+		if max, ok := schemaNumber(schema, "maximum"); ok && v > max {
+			return fmt.Errorf("%s: %g is greater than %g", path, v, max)
+		}
+	}
+	return nil
+}
+
+// matchesType reports whether value has the schema type, or one of a list of types
+func matchesType(types interface{}, value interface{}) bool {
+	if list, ok := types.([]interface{}); ok {
+		for _, t := range list {
+			if matchesType(t, value) {
+				return true
+			}
+		}
+		return false
+	}
+	want := fmt.Sprint(types)
+	got := jsonType(value)
+	if want == "integer" {
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	}
+	return want == got
+}
+
+// jsonType returns the JSON Schema type name of a decoded value
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// schemaNumber returns a numeric schema keyword
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	n, ok := schema[key].(float64)
+	return n, ok
+}
+
+// equalJSON compares two decoded JSON values
+func equalJSON(a, b interface{}) bool {
+	left, _ := json.Marshal(a)
+	right, _ := json.Marshal(b)
+	return string(left) == string(right)
+}
@@ -0,0 +1,228 @@
+// Package eval runs prompt templates against fixture inputs on one or more
+// models and checks assertions on the outputs, to catch prompt regressions
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/prompt"
+)
+
+// Suite is a set of evaluation cases loaded from a YAML file
+type Suite struct {
+	// Name identifies the suite in saved results, the file name by default
+	Name string `yaml:"name" json:"name"`
+
+	// Models are evaluated side by side, unless overridden on the command line
+	Models []string `yaml:"models" json:"models"`
+
+	// JudgeModel grades rubric assertions, the evaluated model by default
+	JudgeModel string `yaml:"judge_model" json:"judge_model,omitempty"`
+
+	// Params are the request defaults of every case
+	Params prompt.Params `yaml:"params" json:"params"`
+
+	Cases []Case `yaml:"cases" json:"cases"`
+
+	// Path is the file the suite was loaded from
+	Path string `yaml:"-" json:"path,omitempty"`
+}
+
+// Case renders a template, or an inline prompt, and checks the answer
+type Case struct {
+	Name string `yaml:"name" json:"name"`
+
+	// Template is the name of a prompt template, Prompt an inline prompt
+	Template string `yaml:"template" json:"template,omitempty"`
+	Prompt   string `yaml:"prompt" json:"prompt,omitempty"`
+
+	// System overrides the template's system prompt
+	System string `yaml:"system" json:"system,omitempty"`
+
+	// Vars are the template variables, @path reads a fixture file relative to the suite
+	Vars map[string]string `yaml:"vars" json:"vars,omitempty"`
+
+	// Params override the suite's and the template's request parameters
+	Params prompt.Params `yaml:"params" json:"params"`
+
+	Assert []Assertion `yaml:"assert" json:"assert"`
+}
+
+// Assertion is one check on an answer, exactly one field is set. Contains and
+// NotContains ignore case.
+type Assertion struct {
+	Contains    string `yaml:"contains" json:"contains,omitempty"`
+	NotContains string `yaml:"not_contains" json:"not_contains,omitempty"`
+	Regex       string `yaml:"regex" json:"regex,omitempty"`
+
+	// JSONSchema is an inline schema or the path of a schema file
+	JSONSchema interface{} `yaml:"json_schema" json:"json_schema,omitempty"`
+
+	// Rubric is graded by the judge model
+	Rubric string `yaml:"rubric" json:"rubric,omitempty"`
+
+	regex  *regexp.Regexp
+	schema interface{}
+}
+
+// LoadSuite reads a suite and its fixture files
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suite: %w", err)
+	}
+	var suite Suite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("invalid suite %s: %w", path, err)
+	}
+	suite.Path = path
+	if suite.Name == "" {
+		suite.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := suite.prepare(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("invalid suite %s: %w", path, err)
+	}
+	return &suite, nil
+}
+
+// prepare validates the cases, reads fixtures and compiles assertions
+func (s *Suite) prepare(dir string) error {
+	if len(s.Cases) == 0 {
+		return fmt.Errorf("no cases")
+	}
+	seen := make(map[string]bool, len(s.Cases))
+	for i := range s.Cases {
+		c := &s.Cases[i]
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("case-%d", i+1)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("duplicate case %s", c.Name)
+		}
+		seen[c.Name] = true
+		if (c.Template == "") == (c.Prompt == "") {
+			return fmt.Errorf("case %s: set either template or prompt", c.Name)
+		}
+		for key, value := range c.Vars {
+			if path, ok := strings.CutPrefix(value, "@"); ok {
+				fixture, err := os.ReadFile(resolvePath(dir, path))
+				if err != nil {
+					return fmt.Errorf("case %s: failed to read fixture: %w", c.Name, err)
+				}
+				c.Vars[key] = string(fixture)
+			}
+		}
+		if len(c.Assert) == 0 {
+			return fmt.Errorf("case %s: no assertions", c.Name)
+		}
+		for j := range c.Assert {
+			if err := c.Assert[j].prepare(dir); err != nil {
+				return fmt.Errorf("case %s, assertion %d: %w", c.Name, j+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// prepare checks that exactly one kind is set and compiles it
+func (a *Assertion) prepare(dir string) error {
+	kinds := 0
+	for _, set := range []bool{a.Contains != "", a.NotContains != "", a.Regex != "", a.JSONSchema != nil, a.Rubric != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("set exactly one of contains, not_contains, regex, json_schema or rubric")
+	}
+
+	switch {
+	case a.Regex != "":
+		re, err := regexp.Compile(a.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+		a.regex = re
+	case a.JSONSchema != nil:
+		schema := a.JSONSchema
+		if path, ok := schema.(string); ok {
+			data, err := os.ReadFile(resolvePath(dir, path))
+			if err != nil {
+				return fmt.Errorf("failed to read schema: %w", err)
+			}
+			if err := yaml.Unmarshal(data, &schema); err != nil {
+				return fmt.Errorf("invalid schema %s: %w", path, err)
+			}
+		}
+		// Round-trip through JSON so the schema has the same types as the answers
+		data, err := json.Marshal(normalizeYAML(schema))
+		if err != nil {
+			return fmt.Errorf("invalid schema: %w", err)
+		}
+		if err := json.Unmarshal(data, &a.schema); err != nil {
+			return fmt.Errorf("invalid schema: %w", err)
+		}
+		if _, ok := a.schema.(map[string]interface{}); !ok {
+			return fmt.Errorf("schema must be an object")
+		}
+	}
+	return nil
+}
+
+// String describes the assertion for reports
+func (a Assertion) String() string {
+	switch {
+	case a.Contains != "":
+		return fmt.Sprintf("contains %q", a.Contains)
+	case a.NotContains != "":
+		return fmt.Sprintf("not_contains %q", a.NotContains)
+	case a.Regex != "":
+		return fmt.Sprintf("regex %s", a.Regex)
+	case a.JSONSchema != nil:
+		if path, ok := a.JSONSchema.(string); ok {
+			return "json_schema " + path
+		}
+		return "json_schema"
+	default:
+		return fmt.Sprintf("rubric %q", a.Rubric)
+	}
+}
+
+// resolvePath resolves path relative to the suite's directory
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values some YAML
+// documents decode to into JSON compatible maps
+func normalizeYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalizeYAML(item)
+		}
+		return m
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeYAML(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeYAML(item)
+		}
+		return v
+	}
+	return value
+}
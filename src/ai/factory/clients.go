@@ -7,215 +7,182 @@ import (
 	"strings"
 	"time"
 
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/ollama"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/transport"
 	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
 )
 
 // ollamaClientAdapter adapts the internal Ollama client to the types.OllamaClient interface
 type ollamaClientAdapter struct {
-	endpoint string
+	client *ollama.Pool
 }
 
-// NewOllamaClient creates a new Ollama client adapter that implements types.OllamaClient
-func NewOllamaClient(endpoint string) (types.OllamaClient, error) {
-	if endpoint == "" {
-		endpoint = "http://localhost:11434" // Default Ollama endpoint
+// NewOllamaClient creates a new Ollama client adapter that implements
+// types.OllamaClient, routing requests across the given endpoints
+func NewOllamaClient(endpoints []types.LocalEndpoint, cfg transport.Config) (types.OllamaClient, error) {
+	client, err := ollama.NewPool(endpoints, cfg)
+	if err != nil {
+		return nil, err
 	}
-	return &ollamaClientAdapter{endpoint: endpoint}, nil
+	return &ollamaClientAdapter{client: client}, nil
 }
 
 // Complete generates a completion for the given prompt
 func (c *ollamaClientAdapter) Complete(ctx context.Context, model string, prompt string, opts types.CompletionOptions) (*types.AIResponse, error) {
 	start := time.Now()
 	
-	// Mock response for completion
-	mockResponse := "This is a mock completion response from Ollama."
-	
-	response := &types.AIResponse{
-		Text:             mockResponse,
-		FinishReason:     "stop",
-		SelectedModel:    model,
-		SelectedProvider: string(types.ProviderOllama),
-		Model:            model,
-		Provider:         string(types.ProviderOllama),
-		Usage: types.AIUsage{
-			PromptTokens:     50, // Mocked token count
-			CompletionTokens: 10,
-			TotalTokens:      60,
-		},
-		Latency: time.Since(start),
+	resp, err := c.client.Complete(ctx, ai.AIRequest{
+		Model:         model,
+		ModelType:     ai.ModelTypeCompletion,
+		Prompt:        prompt,
+		MaxTokens:     opts.MaxTokens,
+		Temperature:   opts.Temperature,
+		TopP:          opts.TopP,
+		StopSequences: opts.StopSequences,
+		Suffix:        opts.Suffix,
+	})
+	LogWithLatency(ctx, start, "ollama.complete", err)
+	if err != nil {
+		return nil, err
 	}
 	
-	return response, nil
+	return convertOllamaResponse(resp, model, nil), nil
 }
 
 // Chat generates a response for the given chat messages
 func (c *ollamaClientAdapter) Chat(ctx context.Context, model string, messages []types.Message, opts types.ChatOptions) (*types.AIResponse, error) {
 	start := time.Now()
 	
-	// Construct response message from the last user message
-	var lastUserMessage string
-	for i := len(messages) - 1; i >= 0; i-- {
-		if messages[i].Role == "user" {
-			lastUserMessage = messages[i].Content
-			break
-		}
-	}
-	
-	responseMessage := types.Message{
-		Role:    "assistant",
-		Content: "This is a mock chat response from Ollama for: " + lastUserMessage,
-	}
-	
-	// Mock implementation - in real code this would call the actual Ollama client
-	response := &types.AIResponse{
-		Text:             responseMessage.Content,
-		FinishReason:     "stop",
-		SelectedModel:    model,
-		SelectedProvider: string(types.ProviderOllama),
-		Model:            model,
-		Provider:         string(types.ProviderOllama),
-		Messages:         append(messages, responseMessage),
-		Usage: types.AIUsage{
-			PromptTokens:     100, // Mocked token count
-			CompletionTokens: 20,
-			TotalTokens:      120,
-		},
-		Latency: time.Since(start),
+	resp, err := c.client.Chat(ctx, ollamaChatRequest(model, messages, opts))
+	LogWithLatency(ctx, start, "ollama.chat", err)
+	if err != nil {
+		return nil, err
 	}
 	
-	return response, nil
+	return convertOllamaResponse(resp, model, messages), nil
 }
 
 // StreamChat streams a chat response token by token
 func (c *ollamaClientAdapter) StreamChat(ctx context.Context, model string, messages []types.Message, opts types.ChatOptions, callback func(chunk string) error) (*types.AIResponse, error) {
 	start := time.Now()
 	
-	// Simulate streaming by breaking response into chunks
-	mockResponse := "This is a mock streaming response from Ollama. The response is chunked into multiple callbacks."
-	chunks := []string{"This is ", "a mock ", "streaming ", "response ", "from Ollama. ", "The response ", "is chunked ", "into multiple ", "callbacks."}
-	
-	// Call callback with each chunk
-	for _, chunk := range chunks {
-		select {
-		case <-ctx.Done():
-			return nil, fmt.Errorf("context canceled during streaming: %w", ctx.Err())
-		default:
-			if err := callback(chunk); err != nil {
-				return nil, fmt.Errorf("streaming callback failed: %w", err)
-			}
-			time.Sleep(50 * time.Millisecond) // Simulate processing time between chunks
-		}
-	}
-	
-	// Construct final response after streaming
-	responseMessage := types.Message{
-		Role:    "assistant",
-		Content: mockResponse,
+	req := ollamaChatRequest(model, messages, opts)
+	req.Stream = true
+	resp, err := c.client.StreamChat(ctx, req, callback)
+	LogWithLatency(ctx, start, "ollama.stream_chat", err)
+	if err != nil {
+		return nil, err
 	}
 	
-	response := &types.AIResponse{
-		Text:             mockResponse,
-		FinishReason:     "stop",
-		SelectedModel:    model,
-		SelectedProvider: string(types.ProviderOllama),
-		Model:            model,
-		Provider:         string(types.ProviderOllama),
-		Messages:         append(messages, responseMessage),
-		Usage: types.AIUsage{
-			PromptTokens:     100, // Mocked token count
-			CompletionTokens: 20,
-			TotalTokens:      120,
-		},
-		Latency: time.Since(start),
-	}
-	
-	return response, nil
+	return convertOllamaResponse(resp, model, messages), nil
 }
 
 // GetEmbedding generates embeddings for the given text
 func (c *ollamaClientAdapter) GetEmbedding(ctx context.Context, text string, model string) ([]float32, error) {
-	// Mock implementation - in real code this would call the actual Ollama client
-	// Return a mock embedding with 10 dimensions
-	mockEmbedding := make([]float32, 10)
-	for i := range mockEmbedding {
-		mockEmbedding[i] = float32(i) * 0.1
-	}
-	return mockEmbedding, nil
+	return c.client.GetEmbedding(ctx, text, model)
 }
 
 // ListModels lists available models
 func (c *ollamaClientAdapter) ListModels(ctx context.Context) ([]types.ModelInfo, error) {
-	// Mock implementation - in real code this would call the actual Ollama client
-	models := []types.ModelInfo{
-		{
-			Name:        "llama2",
-			Provider:    types.ProviderOllama,
-			Type:        types.ModelTypeChat,
-			Description: "Llama 2 7B by Meta",
-			SizeBytes:   4000000000,
-			Installed:   true,
-			Default:     true,
-		},
-		{
-			Name:        "codellama",
-			Provider:    types.ProviderOllama,
-			Type:        types.ModelTypeChat,
-			Description: "Code Llama 7B by Meta",
-			SizeBytes:   4100000000,
-			Installed:   true,
-			Default:     false,
-		},
+	start := time.Now()
+	
+	models, err := c.client.ListModels(ctx)
+	LogWithLatency(ctx, start, "ollama.list_models", err)
+	if err != nil {
+		return nil, err
 	}
-	return models, nil
+	
+	result := make([]types.ModelInfo, 0, len(models))
+	for _, model := range models {
+		result = append(result, types.ModelInfo{
+			Name:        model.Name,
+			Provider:    types.ModelProvider(model.Provider),
+			Type:        types.ModelType(model.Type),
+			Description: model.Description,
+			SizeBytes:   model.SizeBytes,
+			Installed:   model.Installed,
+			Default:     model.Default,
+			Endpoints:   model.Endpoints,
+		})
+	}
+	return result, nil
 }
 
 // GetModelInfo gets detailed information about a model
 func (c *ollamaClientAdapter) GetModelInfo(ctx context.Context, model string) (*types.ModelInfo, error) {
-	// Mock implementation - in real code this would call the actual Ollama client
-	models := map[string]*types.ModelInfo{
-		"llama2": {
-			Name:        "llama2",
-			Provider:    types.ProviderOllama,
-			Type:        types.ModelTypeChat,
-			Description: "Llama 2 7B by Meta",
-			SizeBytes:   4000000000,
-			Installed:   true,
-			Default:     true,
-		},
-		"codellama": {
-			Name:        "codellama",
-			Provider:    types.ProviderOllama,
-			Type:        types.ModelTypeChat,
-			Description: "Code Llama 7B by Meta",
-			SizeBytes:   4100000000,
-			Installed:   true,
-			Default:     false,
-		},
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		return nil, err
 	}
 	
-	if info, ok := models[model]; ok {
-		return info, nil
+	for _, info := range models {
+		if info.Name == model {
+			return &info, nil
+		}
 	}
-	return nil, fmt.Errorf("model %s not found", model)
+	return nil, types.NewProviderError(types.ErrModelNotFound, string(types.ProviderOllama), model, "")
 }
 
 // CheckModelAvailability checks if a model is available
 func (c *ollamaClientAdapter) CheckModelAvailability(ctx context.Context, model string) (bool, error) {
-	// Mock implementation - in real code this would call the actual Ollama client
-	// Consider mock models "llama2" and "codellama" as available
-	availableModels := map[string]bool{
-		"llama2":    true,
-		"codellama": true,
-		"phi3":      true,
-	}
-	return availableModels[model], nil
+	return c.client.CheckModelAvailability(ctx, model)
 }
 
 // InstallModel installs a model
 func (o *ollamaClientAdapter) InstallModel(ctx context.Context, model string) error {
-	// Mock implementation - in real code this would call the actual Ollama API
-	time.Sleep(100 * time.Millisecond) // Simulate API call latency
-	return nil
+	start := time.Now()
+	err := o.client.InstallModel(ctx, model)
+	LogWithLatency(ctx, start, "ollama.install_model", err)
+	return err
+}
+
+// ollamaChatRequest builds the internal chat request sent to Ollama
+func ollamaChatRequest(model string, messages []types.Message, opts types.ChatOptions) ai.AIRequest {
+	aiMessages := make([]ai.Message, len(messages))
+	for i, msg := range messages {
+		aiMessages[i] = ai.Message{
+			Role:    msg.Role,
+			Content: msg.Content,
+			Pinned:  msg.Pinned,
+		}
+	}
+	
+	return ai.AIRequest{
+		Model:         model,
+		ModelType:     ai.ModelTypeChat,
+		Provider:      string(ai.ProviderOllama),
+		Messages:      aiMessages,
+		MaxTokens:     opts.MaxTokens,
+		Temperature:   opts.Temperature,
+		TopP:          opts.TopP,
+		StopSequences: opts.StopSequences,
+	}
+}
+
+// convertOllamaResponse converts an internal Ollama response, appending the reply to messages
+func convertOllamaResponse(resp *ai.AIResponse, model string, messages []types.Message) *types.AIResponse {
+	response := &types.AIResponse{
+		Text:             resp.Text,
+		FinishReason:     resp.FinishReason,
+		SelectedModel:    model,
+		SelectedProvider: string(types.ProviderOllama),
+		Model:            model,
+		Provider:         string(types.ProviderOllama),
+		Usage: types.AIUsage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+		Latency: resp.Latency,
+	}
+	if messages != nil {
+		response.Messages = append(append([]types.Message{}, messages...), types.Message{
+			Role:    "assistant",
+			Content: resp.Text,
+		})
+	}
+	return response
 }
 
 // cloudClientAdapter adapts cloud AI providers to the types.CloudClient interface
@@ -238,7 +205,7 @@ func (c *cloudClientAdapter) Complete(ctx context.Context, model string, prompt
 	// Mock implementation - in real code this would call the actual cloud API
 	response := &types.AIResponse{
 		Text:             "This is a mock response from " + c.provider + " Complete API for: " + prompt,
-		FinishReason:     "stop",
+		FinishReason:     types.FinishStop,
 		SelectedModel:    model,
 		SelectedProvider: c.provider,
 		Model:            model,
@@ -251,6 +218,7 @@ func (c *cloudClientAdapter) Complete(ctx context.Context, model string, prompt
 		Latency: time.Since(start),
 	}
 	
+	LogWithLatency(ctx, start, "cloud.complete", nil)
 	return response, nil
 }
 
@@ -275,7 +243,7 @@ func (c *cloudClientAdapter) Chat(ctx context.Context, model string, messages []
 	// Mock implementation - in real code this would call the actual cloud API
 	response := &types.AIResponse{
 		Text:             responseMessage.Content,
-		FinishReason:     "stop",
+		FinishReason:     types.FinishStop,
 		SelectedModel:    model,
 		SelectedProvider: c.provider,
 		Model:            model,
@@ -289,6 +257,7 @@ func (c *cloudClientAdapter) Chat(ctx context.Context, model string, messages []
 		Latency: time.Since(start),
 	}
 	
+	LogWithLatency(ctx, start, "cloud.chat", nil)
 	return response, nil
 }
 
@@ -321,7 +290,7 @@ func (c *cloudClientAdapter) StreamChat(ctx context.Context, model string, messa
 	
 	response := &types.AIResponse{
 		Text:             mockResponse,
-		FinishReason:     "stop",
+		FinishReason:     types.FinishStop,
 		SelectedModel:    model,
 		SelectedProvider: c.provider,
 		Model:            model,
@@ -335,6 +304,7 @@ func (c *cloudClientAdapter) StreamChat(ctx context.Context, model string, messa
 		Latency: time.Since(start),
 	}
 	
+	LogWithLatency(ctx, start, "cloud.stream_chat", nil)
 	return response, nil
 }
 
@@ -386,7 +356,7 @@ func (c *cloudClientAdapter) ListModels(ctx context.Context) ([]types.ModelInfo,
 			Default:     false,
 		})
 	default:
-		return nil, fmt.Errorf("unsupported provider: %s", c.provider)
+		return nil, types.NewProviderError(types.ErrInvalidRequest, c.provider, "", "unsupported provider")
 	}
 	
 	return models, nil
@@ -18,6 +18,25 @@ func ConvertAIConfigToTypes(internalConfig ai.AIConfig) types.AIConfig {
 		CacheTTL:          internalConfig.CacheTTL,
 		AIResponseTimeout: internalConfig.AIResponseTimeout,
 		CloudAITimeout:    internalConfig.CloudAITimeout,
+		LocalEndpoints:    internalConfig.LocalEndpoints,
+
+		CompactionEnabled:    internalConfig.CompactionEnabled,
+		ContextWindow:        internalConfig.ContextWindow,
+		ModelContextWindows:  internalConfig.ModelContextWindows,
+		CompactionThreshold:  internalConfig.CompactionThreshold,
+		CompactionKeepRecent: internalConfig.CompactionKeepRecent,
+
+		ScriptedFile: internalConfig.ScriptedFile,
+
+		RedactionEnabled: internalConfig.RedactionEnabled,
+
+		AutoContinue:     internalConfig.AutoContinue,
+		MaxContinuations: internalConfig.MaxContinuations,
+
+		Transports: internalConfig.Transports,
+
+		Privacy:      internalConfig.Privacy,
+		ConfirmCloud: internalConfig.ConfirmCloud,
 	}
 }
 
@@ -33,5 +52,24 @@ func ConvertTypesToAIConfig(typesConfig types.AIConfig) ai.AIConfig {
 		CacheTTL:          typesConfig.CacheTTL,
 		AIResponseTimeout: typesConfig.AIResponseTimeout,
 		CloudAITimeout:    typesConfig.CloudAITimeout,
+		LocalEndpoints:    typesConfig.LocalEndpoints,
+
+		CompactionEnabled:    typesConfig.CompactionEnabled,
+		ContextWindow:        typesConfig.ContextWindow,
+		ModelContextWindows:  typesConfig.ModelContextWindows,
+		CompactionThreshold:  typesConfig.CompactionThreshold,
+		CompactionKeepRecent: typesConfig.CompactionKeepRecent,
+
+		ScriptedFile: typesConfig.ScriptedFile,
+
+		RedactionEnabled: typesConfig.RedactionEnabled,
+
+		AutoContinue:     typesConfig.AutoContinue,
+		MaxContinuations: typesConfig.MaxContinuations,
+
+		Transports: typesConfig.Transports,
+
+		Privacy:      typesConfig.Privacy,
+		ConfirmCloud: typesConfig.ConfirmCloud,
 	}
 }
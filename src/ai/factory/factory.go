@@ -6,13 +6,21 @@ import (
 	"fmt"
 	"time"
 	
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/scripted"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/transport"
 	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
 )
 
 // NewAIEngine creates a new AI engine with the given configuration
 func NewAIEngine(config types.AIConfig) (types.AIEngine, error) {
 	// Create Ollama client
-	ollamaClient, err := NewOllamaClient(config.LocalEndpoint)
+	endpoints := config.LocalEndpoints
+	if len(endpoints) == 0 {
+		endpoints = []types.LocalEndpoint{{URL: config.LocalEndpoint}}
+	}
+	ollamaClient, err := NewOllamaClient(endpoints, transport.For(config.Transports, string(types.ProviderOllama)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Ollama client: %w", err)
 	}
@@ -26,23 +34,28 @@ func NewAIEngine(config types.AIConfig) (types.AIEngine, error) {
 	// Create Prompt engine
 	promptEngine := NewPromptEngine()
 
-	// Create AI engine adapter
-	return &aiEngineAdapter{
-		ollamaClient: ollamaClient,
-		cloudClient:  cloudClient,
-		promptEngine: promptEngine,
-		config:       config,
-	}, nil
+	// Create the AI engine, which handles local/cloud fallback and compaction
+	engine := ai.NewAIEngineImpl(ollamaClient, cloudClient, promptEngine, config)
+
+	// Serve the models declared by an offline script, if configured
+	if config.ScriptedFile != "" {
+		scriptedClient, err := scripted.NewClientFromFile(logging.ExpandHome(config.ScriptedFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create scripted client: %w", err)
+		}
+		engine.ScriptedClient = scriptedClient
+	}
+	return engine, nil
 }
 
-// LogWithLatency logs a message with latency information
+// LogWithLatency logs the outcome of a client operation with its latency,
+// tagged with the request ID carried by ctx
 func LogWithLatency(ctx context.Context, start time.Time, operation string, err error) {
+	logger := logging.FromContext(ctx)
 	latency := time.Since(start)
 	if err != nil {
-		// Use structured logging with error
-		fmt.Printf("Operation: %s, Latency: %s, Error: %v\n", operation, latency, err)
-	} else {
-		// Use structured logging for success
-		fmt.Printf("Operation: %s, Latency: %s\n", operation, latency)
+		logger.Warn("operation failed", "operation", operation, "latency", latency, "error", err)
+		return
 	}
+	logger.Debug("operation completed", "operation", operation, "latency", latency)
 }
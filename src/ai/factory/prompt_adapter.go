@@ -0,0 +1,60 @@
+// Package factory provides factory functions for creating AI components
+package factory
+
+import (
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/prompt"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+)
+
+// promptEngineAdapter adapts the prompt.PromptEngine to the types.PromptEngine interface
+type promptEngineAdapter struct {
+	engine *prompt.PromptEngine
+}
+
+// NewPromptEngine creates a new prompt engine adapter that implements types.PromptEngine
+func NewPromptEngine() types.PromptEngine {
+	engine := prompt.NewPromptEngine()
+	// The default templates are static and known to parse
+	_ = engine.LoadDefaultTemplates()
+	return &promptEngineAdapter{engine: engine}
+}
+
+// ProcessPrompt processes a prompt with the given context
+func (p *promptEngineAdapter) ProcessPrompt(promptStr string, context []byte) (string, error) {
+	return p.engine.ProcessPrompt(promptStr, context)
+}
+
+// ProcessMessages processes chat messages with the given context
+func (p *promptEngineAdapter) ProcessMessages(messages []types.Message, context []byte) ([]types.Message, error) {
+	aiMessages := make([]ai.Message, 0, len(messages))
+	for _, msg := range messages {
+		aiMessages = append(aiMessages, ai.Message{Role: msg.Role, Content: msg.Content, Pinned: msg.Pinned})
+	}
+
+	processed, err := p.engine.ProcessMessages(aiMessages, context)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.Message, 0, len(processed))
+	for _, msg := range processed {
+		result = append(result, types.Message{Role: msg.Role, Content: msg.Content, Pinned: msg.Pinned})
+	}
+	return result, nil
+}
+
+// ExecuteTemplate executes a template with the given data
+func (p *promptEngineAdapter) ExecuteTemplate(name string, data interface{}) (string, error) {
+	return p.engine.ExecuteTemplate(name, data)
+}
+
+// RegisterTemplate registers a template with the prompt engine
+func (p *promptEngineAdapter) RegisterTemplate(name string, templateStr string) error {
+	return p.engine.RegisterTemplate(name, templateStr)
+}
+
+// LoadDefaultTemplates loads the default templates into the prompt engine
+func (p *promptEngineAdapter) LoadDefaultTemplates() error {
+	return p.engine.LoadDefaultTemplates()
+}
@@ -0,0 +1,196 @@
+// Package fim prepares fill-in-the-middle code completion: the code around a
+// cursor, the symbols defined nearby and the cleanup of model candidates
+package fim
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Default limits of the code sent around the cursor
+const (
+	DefaultMaxPrefix = 8000
+	DefaultMaxSuffix = 2000
+)
+
+// Input is the code around the cursor
+type Input struct {
+	// Prefix is the code before the cursor, Suffix the code after it
+	Prefix string
+	Suffix string
+
+	// Language is derived from the file extension, "" when unknown
+	Language string
+
+	// Symbols are declarations the model cannot see in Prefix and Suffix
+	Symbols []Symbol
+}
+
+// Split cuts content at a 1-based line and column. The column counts
+// characters, a column past the end of the line is the end of the line.
+func Split(content string, line, col int) (prefix, suffix string, err error) {
+	if line < 1 || col < 1 {
+		return "", "", fmt.Errorf("invalid position %d:%d, lines and columns start at 1", line, col)
+	}
+
+	offset := 0
+	for i := 1; i < line; i++ {
+		next := strings.IndexByte(content[offset:], '\n')
+		if next < 0 {
+			return "", "", fmt.Errorf("line %d is past the end of the file", line)
+		}
+		offset += next + 1
+	}
+
+	lineText := content[offset:]
+	if end := strings.IndexByte(lineText, '\n'); end >= 0 {
+		lineText = lineText[:end]
+	}
+	chars := 0
+	for i := range lineText {
+		if chars == col-1 {
+			offset += i
+			return content[:offset], content[offset:], nil
+		}
+		chars++
+	}
+	offset += len(lineText)
+	return content[:offset], content[offset:], nil
+}
+
+// Prepare builds the input for a cursor in a file. Prefix and suffix are
+// trimmed to whole lines within the limits, and the symbols of the file and
+// its sibling files that fall outside them are collected, nearest first.
+func Prepare(path, content string, line, col, maxPrefix, maxSuffix, maxSymbols int) (*Input, error) {
+	prefix, suffix, err := Split(content, line, col)
+	if err != nil {
+		return nil, err
+	}
+	input := &Input{Language: LanguageOf(path)}
+	input.Prefix, input.Suffix = trimWindow(prefix, suffix, maxPrefix, maxSuffix)
+
+	// Lines of the file visible to the model
+	firstVisible := line - strings.Count(input.Prefix, "\n")
+	lastVisible := line + strings.Count(input.Suffix, "\n")
+
+	var symbols []Symbol
+	for _, symbol := range Symbols(content, input.Language) {
+		if symbol.Line < firstVisible || symbol.Line > lastVisible {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return distance(symbols[i].Line, line) < distance(symbols[j].Line, line)
+	})
+	symbols = append(symbols, siblingSymbols(path, input.Language)...)
+	if maxSymbols >= 0 && len(symbols) > maxSymbols {
+		symbols = symbols[:maxSymbols]
+	}
+	input.Symbols = symbols
+	return input, nil
+}
+
+// trimWindow keeps the end of prefix and the start of suffix within the
+// limits, cut at line breaks. A limit of 0 or less keeps everything.
+func trimWindow(prefix, suffix string, maxPrefix, maxSuffix int) (string, string) {
+	if maxPrefix > 0 && len(prefix) > maxPrefix {
+		cut := len(prefix) - maxPrefix
+		if nl := strings.IndexByte(prefix[cut:], '\n'); nl >= 0 {
+			cut += nl + 1
+		}
+		prefix = prefix[cut:]
+	}
+	if maxSuffix > 0 && len(suffix) > maxSuffix {
+		cut := maxSuffix
+		if nl := strings.LastIndexByte(suffix[:cut], '\n'); nl >= 0 {
+			cut = nl + 1
+		}
+		suffix = suffix[:cut]
+	}
+	return prefix, suffix
+}
+
+// siblingSymbols returns the declarations of the other files of the same
+// language in the directory of path, which are often in the same package
+func siblingSymbols(path, language string) []Symbol {
+	if path == "" || language == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil
+	}
+
+	var symbols []Symbol
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == filepath.Base(path) || LanguageOf(name) != language || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if err != nil || len(data) > maxSiblingSize {
+			continue
+		}
+		for _, symbol := range Symbols(string(data), language) {
+			symbol.File = name
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+// maxSiblingSize skips large generated files when collecting sibling symbols
+const maxSiblingSize = 256 * 1024
+
+// distance is the number of lines between a and b
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// Clean turns a candidate into the text to insert: code fences some models
+// wrap around it are removed, and so is text repeating the code after the
+// cursor, which models without fill-in-the-middle support tend to write.
+func Clean(candidate, suffix string) string {
+	text := candidate
+	trimmed := strings.TrimSpace(text)
+	if strings.HasPrefix(trimmed, "```") && strings.HasSuffix(trimmed, "```") && len(trimmed) > 6 {
+		body := strings.TrimSuffix(trimmed, "```")
+		if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+			text = strings.TrimRight(body[nl+1:], "\n")
+		}
+	}
+
+	// Drop the longest end of the candidate that starts the suffix. It must
+	// span a line break or be whole lines, so a closing bracket the candidate
+	// needs is not mistaken for the one after the cursor.
+	start := strings.TrimLeft(suffix, " \t")
+	for n := min(len(text), len(start)); n > 0; n-- {
+		tail := text[len(text)-n:]
+		whole := n == len(text) || text[len(text)-n-1] == '\n' || strings.Contains(strings.TrimRight(tail, "\n"), "\n")
+		if whole && strings.TrimSpace(tail) != "" && strings.HasPrefix(start, strings.TrimLeft(tail, " \t")) {
+			text = text[:len(text)-n]
+			break
+		}
+	}
+	return text
+}
+
+// Unique returns the non-empty candidates without duplicates, in order
+func Unique(candidates []string) []string {
+	seen := make(map[string]bool, len(candidates))
+	var unique []string
+	for _, candidate := range candidates {
+		if strings.TrimSpace(candidate) == "" || seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		unique = append(unique, candidate)
+	}
+	return unique
+}
@@ -0,0 +1,70 @@
+package fim
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const source = `package main
+
+import "fmt"
+
+type Greeter struct{ name string }
+
+func (g Greeter) Greet() string {
+	return "hi " + g.name
+}
+
+func main() {
+	g := Greeter{name: "héllo"}
+	fmt.Println(g.)
+}
+`
+
+func TestSplit(t *testing.T) {
+	prefix, suffix, err := Split(source, 13, 16)
+	require.NoError(t, err)
+	assert.Equal(t, "\tfmt.Println(g.", prefix[len(prefix)-15:])
+	assert.Equal(t, ")\n}\n", suffix)
+
+	// Columns count characters, not bytes
+	prefix, _, err = Split(source, 12, 24)
+	require.NoError(t, err)
+	assert.Equal(t, `name: "hé`, prefix[len(prefix)-10:])
+
+	_, _, err = Split(source, 40, 1)
+	assert.Error(t, err)
+}
+
+func TestPrepare_CollectsSymbolsOutsideTheWindow(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(path, []byte(source), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "util.go"), []byte("package main\n\nfunc shout(s string) string {\n\treturn s\n}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "util_test.go"), []byte("package main\n\nfunc TestShout() {}\n"), 0644))
+
+	input, err := Prepare(path, source, 13, 16, 50, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, "go", input.Language)
+	assert.Equal(t, "\tg := Greeter{name: \"héllo\"}\n\tfmt.Println(g.", input.Prefix)
+
+	var names []string
+	for _, symbol := range input.Symbols {
+		names = append(names, symbol.Name)
+	}
+	assert.Equal(t, []string{"main", "Greet", "Greeter", "shout"}, names)
+	assert.Contains(t, Comment(input.Symbols, input.Language), "//   func shout(s string) string (util.go)\n")
+}
+
+func TestClean(t *testing.T) {
+	assert.Equal(t, "Greet()", Clean("Greet()", ")\n}\n"))
+	assert.Equal(t, "Greet()", Clean("Greet())\n}\n", ")\n}\n"))
+	assert.Equal(t, "x := 1", Clean("```go\nx := 1\n```", ""))
+	// Lines repeating the code after the cursor are dropped
+	assert.Equal(t, "\treturn nil\n", Clean("\treturn nil\n}\n", "}\n"))
+	assert.Equal(t, []string{"a", "b"}, Unique([]string{"a", "", "b", "a"}))
+}
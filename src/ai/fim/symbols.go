@@ -0,0 +1,169 @@
+package fim
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Symbol is a declaration found in source code
+type Symbol struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+
+	// File is set for symbols of sibling files, Line is 1-based
+	File string `json:"file,omitempty"`
+	Line int    `json:"line"`
+
+	// Signature is the declaration line without its body
+	Signature string `json:"signature"`
+}
+
+// languages maps file extensions to language names
+var languages = map[string]string{
+	".go":   "go",
+	".py":   "python",
+	".js":   "javascript",
+	".jsx":  "javascript",
+	".mjs":  "javascript",
+	".ts":   "typescript",
+	".tsx":  "typescript",
+	".rs":   "rust",
+	".rb":   "ruby",
+	".java": "java",
+	".kt":   "kotlin",
+	".c":    "c",
+	".h":    "c",
+	".cpp":  "cpp",
+	".hpp":  "cpp",
+	".cs":   "csharp",
+	".php":  "php",
+	".sh":   "shell",
+	".bash": "shell",
+	".zsh":  "shell",
+}
+
+// LanguageOf returns the language of a file from its extension, "" when unknown
+func LanguageOf(path string) string {
+	return languages[strings.ToLower(filepath.Ext(path))]
+}
+
+// declaration matches one kind of declaration; the name is the first group
+type declaration struct {
+	kind    string
+	pattern *regexp.Regexp
+}
+
+// declarations lists the patterns recognised for each language
+var declarations = map[string][]declaration{
+	"go": {
+		{"func", regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?(\w+)`)},
+		{"type", regexp.MustCompile(`^type\s+(\w+)`)},
+		{"const", regexp.MustCompile(`^const\s+(\w+)`)},
+		{"var", regexp.MustCompile(`^var\s+(\w+)`)},
+	},
+	"python": {
+		{"def", regexp.MustCompile(`^\s*(?:async\s+)?def\s+(\w+)`)},
+		{"class", regexp.MustCompile(`^\s*class\s+(\w+)`)},
+	},
+	"javascript": jsDeclarations,
+	"typescript": append([]declaration{
+		{"interface", regexp.MustCompile(`^(?:export\s+)?interface\s+(\w+)`)},
+		{"type", regexp.MustCompile(`^(?:export\s+)?type\s+(\w+)\s*=`)},
+	}, jsDeclarations...),
+	"rust": {
+		{"fn", regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?fn\s+(\w+)`)},
+		{"struct", regexp.MustCompile(`^(?:pub\s+)?struct\s+(\w+)`)},
+		{"enum", regexp.MustCompile(`^(?:pub\s+)?enum\s+(\w+)`)},
+		{"trait", regexp.MustCompile(`^(?:pub\s+)?trait\s+(\w+)`)},
+	},
+	"ruby": {
+		{"def", regexp.MustCompile(`^\s*def\s+((?:self\.)?\w+[?!]?)`)},
+		{"class", regexp.MustCompile(`^\s*class\s+(\w+)`)},
+		{"module", regexp.MustCompile(`^\s*module\s+(\w+)`)},
+	},
+	"java":   classDeclarations,
+	"kotlin": append([]declaration{{"fun", regexp.MustCompile(`^\s*(?:\w+\s+)*fun\s+(?:<[^>]*>\s*)?(\w+)`)}}, classDeclarations...),
+	"csharp": classDeclarations,
+	"shell": {
+		{"function", regexp.MustCompile(`^\s*(?:function\s+)?([\w-]+)\s*\(\)`)},
+		{"function", regexp.MustCompile(`^\s*function\s+([\w-]+)`)},
+	},
+}
+
+var jsDeclarations = []declaration{
+	{"function", regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?function\*?\s+(\w+)`)},
+	{"class", regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?class\s+(\w+)`)},
+	{"const", regexp.MustCompile(`^(?:export\s+)?(?:const|let)\s+(\w+)\s*=\s*(?:async\s*)?(?:\([^)]*\)|\w+)\s*=>`)},
+}
+
+var classDeclarations = []declaration{
+	{"class", regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|abstract|final|static|sealed|data|open)\s+)*(?:class|interface|enum|record)\s+(\w+)`)},
+}
+
+// Symbols returns the declarations of content for a language, nil for
+// languages without patterns
+func Symbols(content, language string) []Symbol {
+	patterns := declarations[language]
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	var symbols []Symbol
+	for i, line := range strings.Split(content, "\n") {
+		for _, decl := range patterns {
+			match := decl.pattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			symbols = append(symbols, Symbol{
+				Name:      match[1],
+				Kind:      decl.kind,
+				Line:      i + 1,
+				Signature: signature(line),
+			})
+			break
+		}
+	}
+	return symbols
+}
+
+// signature trims the body from a declaration line
+func signature(line string) string {
+	line = strings.TrimSpace(line)
+	if i := strings.Index(line, "{"); i > 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+	return strings.TrimSuffix(line, ":")
+}
+
+// commentPrefixes are the line comment markers of each language
+var commentPrefixes = map[string]string{
+	"python": "#",
+	"ruby":   "#",
+	"shell":  "#",
+}
+
+// Comment formats symbols as line comments of the language, to show the
+// model what the surrounding code defines
+func Comment(symbols []Symbol, language string) string {
+	if len(symbols) == 0 {
+		return ""
+	}
+	marker, ok := commentPrefixes[language]
+	if !ok {
+		marker = "//"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s Symbols defined nearby:\n", marker)
+	for _, symbol := range symbols {
+		location := fmt.Sprintf("line %d", symbol.Line)
+		if symbol.File != "" {
+			location = symbol.File
+		}
+		fmt.Fprintf(&sb, "%s   %s (%s)\n", marker, symbol.Signature, location)
+	}
+	return sb.String()
+}
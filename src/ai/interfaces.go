@@ -3,6 +3,10 @@ package ai
 import (
 	"context"
 	"time"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/transport"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+	"github.com/rrecio/crazy-dev-zsh/src/core/privacy"
 )
 
 // ModelProvider represents the source of AI models
@@ -15,6 +19,8 @@ const (
 	ProviderOpenAI ModelProvider = "openai"
 	// ProviderAnthropic represents Anthropic cloud models
 	ProviderAnthropic ModelProvider = "anthropic"
+	// ProviderScripted represents offline models answering from a script
+	ProviderScripted ModelProvider = "scripted"
 )
 
 // ModelType represents the type of AI model
@@ -33,6 +39,7 @@ const (
 type Message struct {
 	Role    string `json:"role"`    // Role can be "system", "user", or "assistant"
 	Content string `json:"content"` // Content is the message text
+	Pinned  bool   `json:"pinned,omitempty"` // Pinned messages are never compacted away
 }
 
 // AIRequest represents a request to the AI engine
@@ -50,6 +57,9 @@ type AIRequest struct {
 	Timeout         *time.Duration `json:"timeout"`      // Timeout for the request
 	Stream          bool       `json:"stream"`           // Whether to stream the response
 	FallbackToCloud bool       `json:"fallback_to_cloud"` // Whether to fallback to cloud if local fails
+	AutoContinue    bool       `json:"auto_continue"`    // Whether to continue answers cut off at the length limit
+	Suffix          string     `json:"suffix,omitempty"` // Text after the insertion point, for fill-in-the-middle completion
+	Files           []string   `json:"files,omitempty"`  // Files whose content the request carries, checked against the privacy deny paths
 }
 
 // AIResponse represents a response from the AI engine
@@ -61,13 +71,25 @@ type AIResponse struct {
 	SelectedProvider string  `json:"selected_provider"` // Provider that was actually used
 	Latency        time.Duration `json:"latency"`     // Time taken to generate the response
 	Error          error     `json:"error"`           // Error if any
+	Compaction     *Compaction `json:"compaction,omitempty"` // Set when older messages were summarised to fit the context window
+	Continuations  int       `json:"continuations,omitempty"` // Number of continuation requests stitched into Text
+}
+
+// Compaction describes how the conversation was shortened to fit the model's context window
+type Compaction struct {
+	CompactedMessages int       `json:"compacted_messages"` // Number of messages folded into the summary
+	TokensBefore      int       `json:"tokens_before"`      // Estimated prompt tokens before compaction
+	TokensAfter       int       `json:"tokens_after"`       // Estimated prompt tokens after compaction
+	ContextWindow     int       `json:"context_window"`     // Context window of the model
+	Summarized        bool      `json:"summarized"`         // False if the model could not summarise and old turns were dropped
+	Messages          []Message `json:"messages"`           // The compacted conversation, without project context
 }
 
 // AIUsage represents token usage information
 type AIUsage struct {
-	PromptTokens     int `json:"prompt_tokens"`      // Tokens in the prompt
-	CompletionTokens int `json:"completion_tokens"`  // Tokens in the completion
-	TotalTokens      int `json:"total_tokens"`       // Total tokens used
+	PromptTokens     int `json:"prompt_tokens" yaml:"prompt_tokens"`         // Tokens in the prompt
+	CompletionTokens int `json:"completion_tokens" yaml:"completion_tokens"` // Tokens in the completion
+	TotalTokens      int `json:"total_tokens" yaml:"total_tokens"`           // Total tokens used
 }
 
 // ModelInfo represents information about an AI model
@@ -79,6 +101,9 @@ type ModelInfo struct {
 	SizeBytes   int64        `json:"size_bytes"`   // Model size in bytes
 	Installed   bool         `json:"installed"`    // Whether the model is installed locally
 	Default     bool         `json:"default"`      // Whether this is a default model
+
+	// Endpoints names the Ollama servers that have the model installed
+	Endpoints []string `json:"endpoints,omitempty"`
 }
 
 // AIEngine is the interface for interacting with AI models
@@ -116,4 +141,37 @@ type AIConfig struct {
 	CacheTTL          time.Duration `json:"cache_ttl"`
 	AIResponseTimeout time.Duration `json:"ai_response_timeout"`
 	CloudAITimeout    time.Duration `json:"cloud_ai_timeout"`
+
+	// LocalEndpoints are the Ollama servers requests are routed across.
+	// When empty, LocalEndpoint is the only one.
+	LocalEndpoints []types.LocalEndpoint `json:"local_endpoints,omitempty"`
+
+	// Compaction settings: the conversation is summarised once its estimated
+	// size reaches CompactionThreshold of the model's context window
+	CompactionEnabled    bool           `json:"compaction_enabled"`
+	ContextWindow        int            `json:"context_window"`
+	ModelContextWindows  map[string]int `json:"model_context_windows"`
+	CompactionThreshold  float64        `json:"compaction_threshold"`
+	CompactionKeepRecent int            `json:"compaction_keep_recent"`
+
+	// ScriptedFile is a YAML or JSON script served by the offline scripted provider
+	ScriptedFile string `json:"scripted_file"`
+
+	// RedactionEnabled replaces secrets with placeholders before requests to cloud providers
+	RedactionEnabled bool `json:"redaction_enabled"`
+
+	// AutoContinue asks the model to go on when an answer is cut off at the
+	// length limit, up to MaxContinuations times
+	AutoContinue     bool `json:"auto_continue"`
+	MaxContinuations int  `json:"max_continuations"`
+
+	// Transports holds the HTTP settings of each provider, by provider
+	// name, with transport.DefaultKey applying to all of them
+	Transports map[string]transport.Config `json:"transports,omitempty"`
+
+	// Privacy is the policy of the current project, enforced before every
+	// cloud request. ConfirmCloud asks the user in ask mode; without it the
+	// request is refused.
+	Privacy      *privacy.Policy            `json:"privacy,omitempty"`
+	ConfirmCloud func(provider string) bool `json:"-"`
 }
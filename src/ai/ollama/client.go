@@ -10,25 +10,35 @@ import (
 	"time"
 
 	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/transport"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
 )
 
+// maxErrorBodySize limits how much of an error response is read
+const maxErrorBodySize = 64 * 1024
+
 // OllamaClient implements the interface for interacting with Ollama API
 type OllamaClient struct {
 	endpoint string
 	client   *http.Client
 }
 
-// NewClient creates a new Ollama client
-func NewClient(endpoint string) (*OllamaClient, error) {
+// NewClient creates a new Ollama client using the given HTTP transport
+// settings. Requests have no overall timeout, their context bounds them.
+func NewClient(endpoint string, cfg transport.Config) (*OllamaClient, error) {
 	if endpoint == "" {
 		endpoint = "http://localhost:11434"
 	}
-	
+
+	client, err := transport.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transport for ollama: %w", err)
+	}
+
 	return &OllamaClient{
 		endpoint: endpoint,
-		client: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		client:   client,
 	}, nil
 }
 
@@ -36,6 +46,7 @@ func NewClient(endpoint string) (*OllamaClient, error) {
 type OllamaGenerateRequest struct {
 	Model       string   `json:"model"`
 	Prompt      string   `json:"prompt"`
+	Suffix      string   `json:"suffix,omitempty"`
 	System      string   `json:"system,omitempty"`
 	Template    string   `json:"template,omitempty"`
 	Context     []int    `json:"context,omitempty"`
@@ -91,6 +102,7 @@ type OllamaGenerateResponse struct {
 	Response  string  `json:"response"`
 	Context   []int   `json:"context,omitempty"`
 	Done      bool    `json:"done"`
+	DoneReason string `json:"done_reason,omitempty"`
 	TotalDuration int64 `json:"total_duration,omitempty"`
 	LoadDuration   int64 `json:"load_duration,omitempty"`
 	PromptEvalCount int  `json:"prompt_eval_count,omitempty"`
@@ -103,11 +115,13 @@ type OllamaChatResponse struct {
 	Model     string  `json:"model"`
 	Message   Message `json:"message"`
 	Done      bool    `json:"done"`
+	DoneReason string `json:"done_reason,omitempty"`
 	TotalDuration int64 `json:"total_duration,omitempty"`
 	LoadDuration   int64 `json:"load_duration,omitempty"`
 	PromptEvalCount int  `json:"prompt_eval_count,omitempty"`
 	EvalCount      int   `json:"eval_count,omitempty"`
 	EvalDuration   int64 `json:"eval_duration,omitempty"`
+	Error          string `json:"error,omitempty"`
 }
 
 // OllamaEmbeddingResponse represents a response from the Ollama embedding API
@@ -138,18 +152,23 @@ type OllamaListModelsResponse struct {
 	Models []OllamaModelInfo `json:"models"`
 }
 
-// NewOllamaClient creates a new Ollama client
-func NewOllamaClient(endpoint string) (*OllamaClient, error) {
-	if endpoint == "" {
-		endpoint = "http://localhost:11434"
-	}
+// OllamaRunningModel is a model loaded in memory, as listed by /api/ps
+type OllamaRunningModel struct {
+	Name      string    `json:"name"`
+	Model     string    `json:"model"`
+	Size      int64     `json:"size"`
+	SizeVRAM  int64     `json:"size_vram"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
 
-	return &OllamaClient{
-		endpoint: endpoint,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-	}, nil
+// OllamaProcessResponse represents the response from the Ollama ps API
+type OllamaProcessResponse struct {
+	Models []OllamaRunningModel `json:"models"`
+}
+
+// NewOllamaClient creates a new Ollama client with the default transport
+func NewOllamaClient(endpoint string) (*OllamaClient, error) {
+	return NewClient(endpoint, transport.Config{})
 }
 
 // Complete generates a completion for the given prompt
@@ -160,6 +179,7 @@ func (c *OllamaClient) Complete(ctx context.Context, req ai.AIRequest) (*ai.AIRe
 	ollamaReq := OllamaGenerateRequest{
 		Model:  req.Model,
 		Prompt: req.Prompt,
+		Suffix: req.Suffix,
 		Stream: false,
 		Options: Options{
 			Temperature: req.Temperature,
@@ -195,16 +215,16 @@ func (c *OllamaClient) Complete(ctx context.Context, req ai.AIRequest) (*ai.AIRe
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	setRequestID(ctx, httpReq)
 
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", types.ErrorFromTransport(string(ai.ProviderOllama), req.Model, err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s, %s", resp.Status, string(body))
+		return nil, apiError(resp, req.Model)
 	}
 
 	// Parse response
@@ -216,7 +236,7 @@ func (c *OllamaClient) Complete(ctx context.Context, req ai.AIRequest) (*ai.AIRe
 	// Create AI response
 	aiResp := &ai.AIResponse{
 		Text:            ollamaResp.Response,
-		FinishReason:    "stop",
+		FinishReason:    types.NormalizeFinishReason(ollamaResp.DoneReason),
 		SelectedModel:   req.Model,
 		SelectedProvider: string(ai.ProviderOllama),
 		Latency:         time.Since(startTime),
@@ -274,16 +294,16 @@ func (c *OllamaClient) Chat(ctx context.Context, req ai.AIRequest) (*ai.AIRespon
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	setRequestID(ctx, httpReq)
 
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", types.ErrorFromTransport(string(ai.ProviderOllama), req.Model, err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s, %s", resp.Status, string(body))
+		return nil, apiError(resp, req.Model)
 	}
 
 	// Parse response
@@ -295,7 +315,7 @@ func (c *OllamaClient) Chat(ctx context.Context, req ai.AIRequest) (*ai.AIRespon
 	// Create AI response
 	aiResp := &ai.AIResponse{
 		Text:            ollamaResp.Message.Content,
-		FinishReason:    "stop",
+		FinishReason:    types.NormalizeFinishReason(ollamaResp.DoneReason),
 		SelectedModel:   req.Model,
 		SelectedProvider: string(ai.ProviderOllama),
 		Latency:         time.Since(startTime),
@@ -353,22 +373,23 @@ func (c *OllamaClient) StreamChat(ctx context.Context, req ai.AIRequest, callbac
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	setRequestID(ctx, httpReq)
 
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", types.ErrorFromTransport(string(ai.ProviderOllama), req.Model, err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s, %s", resp.Status, string(body))
+		return nil, apiError(resp, req.Model)
 	}
 
 	// Process streaming response
 	decoder := json.NewDecoder(resp.Body)
 	var fullText string
 	var promptEvalCount, evalCount int
+	var doneReason string
 
 	for {
 		var ollamaResp OllamaChatResponse
@@ -378,6 +399,9 @@ func (c *OllamaClient) StreamChat(ctx context.Context, req ai.AIRequest, callbac
 			}
 			return nil, fmt.Errorf("failed to decode response: %w", err)
 		}
+		if ollamaResp.Error != "" {
+			return nil, types.ErrorFromStatus(string(ai.ProviderOllama), req.Model, 0, nil, []byte(ollamaResp.Error))
+		}
 
 		// Update counts
 		promptEvalCount = ollamaResp.PromptEvalCount
@@ -391,6 +415,7 @@ func (c *OllamaClient) StreamChat(ctx context.Context, req ai.AIRequest, callbac
 		fullText += ollamaResp.Message.Content
 
 		if ollamaResp.Done {
+			doneReason = ollamaResp.DoneReason
 			break
 		}
 	}
@@ -398,7 +423,7 @@ func (c *OllamaClient) StreamChat(ctx context.Context, req ai.AIRequest, callbac
 	// Create AI response
 	aiResp := &ai.AIResponse{
 		Text:            fullText,
-		FinishReason:    "stop",
+		FinishReason:    types.NormalizeFinishReason(doneReason),
 		SelectedModel:   req.Model,
 		SelectedProvider: string(ai.ProviderOllama),
 		Latency:         time.Since(startTime),
@@ -431,16 +456,16 @@ func (c *OllamaClient) GetEmbedding(ctx context.Context, text string, model stri
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	setRequestID(ctx, httpReq)
 
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", types.ErrorFromTransport(string(ai.ProviderOllama), model, err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s, %s", resp.Status, string(body))
+		return nil, apiError(resp, model)
 	}
 
 	// Parse response
@@ -459,16 +484,16 @@ func (c *OllamaClient) ListModels(ctx context.Context) ([]ai.ModelInfo, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	setRequestID(ctx, httpReq)
 
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", types.ErrorFromTransport(string(ai.ProviderOllama), "", err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s, %s", resp.Status, string(body))
+		return nil, apiError(resp, "")
 	}
 
 	// Parse response
@@ -499,6 +524,31 @@ func (c *OllamaClient) ListModels(ctx context.Context) ([]ai.ModelInfo, error) {
 	return models, nil
 }
 
+// RunningModels lists the models Ollama has loaded in memory
+func (c *OllamaClient) RunningModels(ctx context.Context) ([]OllamaRunningModel, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.endpoint+"/api/ps", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	setRequestID(ctx, httpReq)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", types.ErrorFromTransport(string(ai.ProviderOllama), "", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp, "")
+	}
+
+	var ollamaResp OllamaProcessResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return ollamaResp.Models, nil
+}
+
 // CheckModelAvailability checks if a model is available in Ollama
 func (c *OllamaClient) CheckModelAvailability(ctx context.Context, model string) (bool, error) {
 	models, err := c.ListModels(ctx)
@@ -529,17 +579,30 @@ func (c *OllamaClient) InstallModel(ctx context.Context, model string) error {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	setRequestID(ctx, httpReq)
 
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return fmt.Errorf("failed to send request: %w", types.ErrorFromTransport(string(ai.ProviderOllama), model, err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %s, %s", resp.Status, string(body))
+		return apiError(resp, model)
 	}
 
 	return nil
 }
+
+// apiError classifies an error response from Ollama
+func apiError(resp *http.Response, model string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return types.ErrorFromStatus(string(ai.ProviderOllama), model, resp.StatusCode, resp.Header, body)
+}
+
+// setRequestID tags an outgoing request with the invocation's request ID
+func setRequestID(ctx context.Context, req *http.Request) {
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+}
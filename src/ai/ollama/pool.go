@@ -0,0 +1,386 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/transport"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
+)
+
+// Health checks of pool endpoints
+const (
+	// healthTTL is how long the result of a health check is trusted
+	healthTTL = 15 * time.Second
+
+	// probeTimeout bounds a health check, so a dead endpoint does not hold
+	// up routing
+	probeTimeout = 3 * time.Second
+)
+
+// Pool routes requests across Ollama servers. A request goes to a healthy
+// endpoint that has the model loaded, then to one that has it installed, the
+// least busy first. When an endpoint is unreachable the next one is tried.
+type Pool struct {
+	endpoints []*endpoint
+}
+
+// endpoint is a server of the pool and what its last health check found
+type endpoint struct {
+	name     string
+	client   *OllamaClient
+	inFlight atomic.Int64
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+	loaded    []string
+	installed []string
+}
+
+// EndpointStatus is the health of a pool endpoint
+type EndpointStatus struct {
+	Name      string   `json:"name"`
+	URL       string   `json:"url"`
+	Healthy   bool     `json:"healthy"`
+	Error     string   `json:"error,omitempty"`
+	Err       error    `json:"-"`
+	Loaded    []string `json:"loaded,omitempty"`
+	Installed []string `json:"installed,omitempty"`
+}
+
+// NewPool creates a pool of the given endpoints, all using the transport
+// settings cfg. Without endpoints the pool has the default local one.
+func NewPool(endpoints []types.LocalEndpoint, cfg transport.Config) (*Pool, error) {
+	if len(endpoints) == 0 {
+		endpoints = []types.LocalEndpoint{{}}
+	}
+
+	pool := &Pool{}
+	seen := make(map[string]bool)
+	for _, ep := range endpoints {
+		epCfg := cfg
+		if ep.Token != "" {
+			epCfg = cfg.Merge(transport.Config{Headers: map[string]string{"Authorization": "Bearer " + ep.Token}})
+		}
+		client, err := NewClient(strings.TrimRight(ep.URL, "/"), epCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		name := ep.Name
+		if name == "" {
+			name = hostOf(client.endpoint)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate ollama endpoint %q, give each endpoint a distinct name", name)
+		}
+		seen[name] = true
+		pool.endpoints = append(pool.endpoints, &endpoint{name: name, client: client})
+	}
+	return pool, nil
+}
+
+// hostOf returns the host of an endpoint URL, the URL itself if it has none
+func hostOf(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return endpoint
+}
+
+// Complete generates a completion on the best endpoint for the model
+func (p *Pool) Complete(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+	var resp *ai.AIResponse
+	err := p.do(ctx, req.Model, func(c *OllamaClient) (err error) {
+		resp, err = c.Complete(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// Chat generates a chat response on the best endpoint for the model
+func (p *Pool) Chat(ctx context.Context, req ai.AIRequest) (*ai.AIResponse, error) {
+	var resp *ai.AIResponse
+	err := p.do(ctx, req.Model, func(c *OllamaClient) (err error) {
+		resp, err = c.Chat(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// StreamChat streams a chat response from the best endpoint for the model.
+// Once part of the answer has been streamed, a failure is not retried
+// elsewhere.
+func (p *Pool) StreamChat(ctx context.Context, req ai.AIRequest, callback func(chunk string) error) (*ai.AIResponse, error) {
+	var resp *ai.AIResponse
+	streamed := false
+	err := p.do(ctx, req.Model, func(c *OllamaClient) (err error) {
+		resp, err = c.StreamChat(ctx, req, func(chunk string) error {
+			streamed = true
+			return callback(chunk)
+		})
+		if err != nil && streamed {
+			return &partialError{err}
+		}
+		return err
+	})
+	return resp, err
+}
+
+// GetEmbedding generates embeddings on the best endpoint for the model
+func (p *Pool) GetEmbedding(ctx context.Context, text string, model string) ([]float32, error) {
+	var embedding []float32
+	err := p.do(ctx, model, func(c *OllamaClient) (err error) {
+		embedding, err = c.GetEmbedding(ctx, text, model)
+		return err
+	})
+	return embedding, err
+}
+
+// ListModels lists the models of every reachable endpoint, each with the
+// endpoints that have it installed. It fails only if no endpoint answers.
+func (p *Pool) ListModels(ctx context.Context) ([]ai.ModelInfo, error) {
+	lists := make([][]ai.ModelInfo, len(p.endpoints))
+	errs := make([]error, len(p.endpoints))
+	var wg sync.WaitGroup
+	for i, ep := range p.endpoints {
+		wg.Add(1)
+		go func(i int, ep *endpoint) {
+			defer wg.Done()
+			lists[i], errs[i] = ep.client.ListModels(ctx)
+		}(i, ep)
+	}
+	wg.Wait()
+
+	var models []ai.ModelInfo
+	index := make(map[string]int)
+	answered := 0
+	for i, list := range lists {
+		if errs[i] != nil {
+			if len(p.endpoints) > 1 {
+				logging.Note(ctx, "Ollama endpoint %s did not list its models: %v", p.endpoints[i].name, errs[i])
+			}
+			continue
+		}
+		answered++
+		for _, model := range list {
+			j, ok := index[model.Name]
+			if !ok {
+				j = len(models)
+				index[model.Name] = j
+				models = append(models, model)
+			}
+			models[j].Endpoints = append(models[j].Endpoints, p.endpoints[i].name)
+		}
+	}
+	if answered == 0 {
+		return nil, errs[0]
+	}
+	return models, nil
+}
+
+// CheckModelAvailability checks if any endpoint has the model installed
+func (p *Pool) CheckModelAvailability(ctx context.Context, model string) (bool, error) {
+	models, err := p.ListModels(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to list models: %w", err)
+	}
+	for _, m := range models {
+		if m.Name == model {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// InstallModel pulls the model on the least busy healthy endpoint
+func (p *Pool) InstallModel(ctx context.Context, model string) error {
+	return p.do(ctx, model, func(c *OllamaClient) error {
+		return c.InstallModel(ctx, model)
+	})
+}
+
+// Status checks every endpoint now and reports its health
+func (p *Pool) Status(ctx context.Context) []EndpointStatus {
+	p.refresh(ctx, true)
+
+	statuses := make([]EndpointStatus, 0, len(p.endpoints))
+	for _, ep := range p.endpoints {
+		ep.mu.Lock()
+		status := EndpointStatus{
+			Name:      ep.name,
+			URL:       ep.client.endpoint,
+			Healthy:   ep.err == nil,
+			Loaded:    ep.loaded,
+			Installed: ep.installed,
+		}
+		if ep.err != nil {
+			status.Error = ep.err.Error()
+			status.Err = ep.err
+		}
+		ep.mu.Unlock()
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// do runs fn on the endpoints in order of preference for the model until one
+// succeeds or fails for a reason other than being unreachable
+func (p *Pool) do(ctx context.Context, model string, fn func(c *OllamaClient) error) error {
+	var err error
+	for _, ep := range p.candidates(ctx, model) {
+		ep.inFlight.Add(1)
+		err = fn(ep.client)
+		ep.inFlight.Add(-1)
+
+		var partial *partialError
+		if errors.As(err, &partial) {
+			return partial.err
+		}
+		if err == nil || !errors.Is(err, types.ErrUnavailable) || ctx.Err() != nil {
+			return err
+		}
+		ep.markDown(err)
+		if len(p.endpoints) > 1 {
+			logging.Note(ctx, "Ollama endpoint %s is unavailable, trying the next one: %v", ep.name, err)
+		}
+	}
+	return err
+}
+
+// candidates orders the endpoints for a model: healthy endpoints with the
+// model loaded, then with it installed, then the other healthy ones, each
+// group least busy first. Unhealthy endpoints come last, in case they are
+// back.
+func (p *Pool) candidates(ctx context.Context, model string) []*endpoint {
+	if len(p.endpoints) == 1 {
+		return p.endpoints
+	}
+	p.refresh(ctx, false)
+
+	type candidate struct {
+		ep       *endpoint
+		rank     int
+		inFlight int64
+	}
+	ranked := make([]candidate, len(p.endpoints))
+	for i, ep := range p.endpoints {
+		ranked[i] = candidate{ep: ep, rank: ep.rank(model), inFlight: ep.inFlight.Load()}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].rank != ranked[j].rank {
+			return ranked[i].rank < ranked[j].rank
+		}
+		return ranked[i].inFlight < ranked[j].inFlight
+	})
+
+	endpoints := make([]*endpoint, len(ranked))
+	for i, c := range ranked {
+		endpoints[i] = c.ep
+	}
+	return endpoints
+}
+
+// refresh health checks the endpoints in parallel, only those whose last
+// check is older than healthTTL unless force is set
+func (p *Pool) refresh(ctx context.Context, force bool) {
+	var wg sync.WaitGroup
+	for _, ep := range p.endpoints {
+		ep.mu.Lock()
+		stale := force || time.Since(ep.checkedAt) > healthTTL
+		ep.mu.Unlock()
+		if !stale {
+			continue
+		}
+		wg.Add(1)
+		go func(ep *endpoint) {
+			defer wg.Done()
+			ep.probe(ctx)
+		}(ep)
+	}
+	wg.Wait()
+}
+
+// probe asks the endpoint which models it has loaded and installed
+func (ep *endpoint) probe(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	var loaded, installed []string
+	running, err := ep.client.RunningModels(ctx)
+	if errors.Is(err, types.ErrModelNotFound) {
+		// Servers older than /api/ps are healthy, just without load information
+		err = nil
+	}
+	if err == nil {
+		for _, model := range running {
+			loaded = append(loaded, model.Name)
+		}
+		var models []ai.ModelInfo
+		models, err = ep.client.ListModels(ctx)
+		for _, model := range models {
+			installed = append(installed, model.Name)
+		}
+	}
+
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	ep.checkedAt = time.Now()
+	ep.err = err
+	ep.loaded = loaded
+	ep.installed = installed
+}
+
+// markDown records that a request to the endpoint could not get through
+func (ep *endpoint) markDown(err error) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	ep.checkedAt = time.Now()
+	ep.err = err
+}
+
+// rank is the preference group of the endpoint for a model, lower first
+func (ep *endpoint) rank(model string) int {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	switch {
+	case ep.err != nil:
+		return 3
+	case hasModel(ep.loaded, model):
+		return 0
+	case hasModel(ep.installed, model):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// hasModel reports whether names contains model, which may leave out the
+// ":latest" tag
+func hasModel(names []string, model string) bool {
+	for _, name := range names {
+		if name == model || name == model+":latest" {
+			return true
+		}
+	}
+	return false
+}
+
+// partialError is a failure after part of a streamed answer was delivered,
+// which must not be retried on another endpoint
+type partialError struct {
+	err error
+}
+
+func (e *partialError) Error() string { return e.err.Error() }
+func (e *partialError) Unwrap() error { return e.err }
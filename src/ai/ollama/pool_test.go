@@ -0,0 +1,105 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/transport"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+)
+
+// fakeServer is an Ollama server with the given models installed and loaded
+// that answers chats with its name
+func fakeServer(t *testing.T, name string, installed, loaded []string) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	var chats atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			var resp OllamaListModelsResponse
+			for _, model := range installed {
+				resp.Models = append(resp.Models, OllamaModelInfo{Name: model})
+			}
+			json.NewEncoder(w).Encode(resp)
+		case "/api/ps":
+			var resp OllamaProcessResponse
+			for _, model := range loaded {
+				resp.Models = append(resp.Models, OllamaRunningModel{Name: model})
+			}
+			json.NewEncoder(w).Encode(resp)
+		case "/api/chat":
+			if r.Header.Get("Authorization") != "Bearer "+name {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			chats.Add(1)
+			json.NewEncoder(w).Encode(OllamaChatResponse{Message: Message{Role: "assistant", Content: name}, Done: true})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &chats
+}
+
+func TestPool_PrefersEndpointWithModelLoaded(t *testing.T) {
+	idle, _ := fakeServer(t, "idle", []string{"llama3.2:latest"}, nil)
+	busy, _ := fakeServer(t, "busy", []string{"llama3.2:latest", "codellama:latest"}, []string{"llama3.2:latest"})
+
+	pool, err := NewPool([]types.LocalEndpoint{
+		{Name: "idle", URL: idle.URL, Token: "idle"},
+		{Name: "busy", URL: busy.URL + "/", Token: "busy"},
+	}, transport.Config{})
+	require.NoError(t, err)
+
+	resp, err := pool.Chat(context.Background(), ai.AIRequest{Model: "llama3.2"})
+	require.NoError(t, err)
+	assert.Equal(t, "busy", resp.Text)
+
+	models, err := pool.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+	assert.Equal(t, []string{"idle", "busy"}, models[0].Endpoints)
+	assert.Equal(t, []string{"busy"}, models[1].Endpoints)
+}
+
+func TestPool_FailsOverOnConnectionErrors(t *testing.T) {
+	down, _ := fakeServer(t, "down", []string{"llama3.2:latest"}, []string{"llama3.2:latest"})
+	up, chats := fakeServer(t, "up", []string{"llama3.2:latest"}, nil)
+
+	pool, err := NewPool([]types.LocalEndpoint{
+		{Name: "down", URL: down.URL, Token: "down"},
+		{Name: "up", URL: up.URL, Token: "up"},
+	}, transport.Config{})
+	require.NoError(t, err)
+
+	// The first endpoint passes its health check, then goes away
+	pool.refresh(context.Background(), true)
+	down.Close()
+
+	resp, err := pool.Chat(context.Background(), ai.AIRequest{Model: "llama3.2"})
+	require.NoError(t, err)
+	assert.Equal(t, "up", resp.Text)
+	assert.Equal(t, int64(1), chats.Load())
+
+	statuses := pool.Status(context.Background())
+	assert.False(t, statuses[0].Healthy)
+	assert.True(t, statuses[1].Healthy)
+
+	// Errors other than an unreachable endpoint are not retried elsewhere
+	pool, err = NewPool([]types.LocalEndpoint{
+		{Name: "a", URL: up.URL, Token: "wrong"},
+		{Name: "b", URL: up.URL, Token: "up"},
+	}, transport.Config{})
+	require.NoError(t, err)
+	_, err = pool.Chat(context.Background(), ai.AIRequest{Model: "llama3.2"})
+	assert.ErrorIs(t, err, types.ErrAuth)
+}
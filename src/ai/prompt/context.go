@@ -0,0 +1,236 @@
+package prompt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	ctxanalyzer "github.com/rrecio/crazy-dev-zsh/src/core/context"
+)
+
+// Limits that keep the rendered context concise
+const (
+	maxContextFileTypes    = 8
+	maxContextDependencies = 20
+)
+
+// ContextData is the project context available to prompts. Templates reference
+// the fields directly, e.g. {{.Git.CurrentBranch}} or {{.Project.Name}}.
+type ContextData struct {
+	Project       ProjectInfo
+	Git           ctxanalyzer.GitInfo
+	TechStacks    []ctxanalyzer.TechStack
+	FileStats     ctxanalyzer.FileStats
+	Dependencies  map[string]string
+	CurrentFile   string
+	CurrentBranch string
+	CurrentDir    string
+	UserQuery     string
+}
+
+// ProjectInfo identifies the analyzed project
+type ProjectInfo struct {
+	Name      string
+	Path      string
+	IsGitRepo bool
+}
+
+// contextPayload is the wire format of the context passed to ProcessMessages:
+// the analysis result plus where the user currently is
+type contextPayload struct {
+	ctxanalyzer.AnalysisResult
+	CurrentFile   string `json:"current_file,omitempty"`
+	CurrentDir    string `json:"current_dir,omitempty"`
+	CurrentBranch string `json:"current_branch,omitempty"`
+}
+
+// EncodeContext serializes an analysis result together with the current
+// directory, file and branch for ProcessMessages
+func EncodeContext(result *ctxanalyzer.AnalysisResult, currentDir, currentFile, currentBranch string) ([]byte, error) {
+	if result == nil {
+		return nil, errors.New("no analysis result")
+	}
+	return json.Marshal(contextPayload{
+		AnalysisResult: *result,
+		CurrentFile:    currentFile,
+		CurrentDir:     currentDir,
+		CurrentBranch:  currentBranch,
+	})
+}
+
+// DecodeContextData decodes the context produced by EncodeContext, or a plain
+// analysis result. The current branch defaults to the analyzed branch and the
+// current directory to the working directory.
+func DecodeContextData(data []byte) (*ContextData, error) {
+	var payload contextPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode context: %w", err)
+	}
+	if payload.ProjectPath == "" && payload.ProjectName == "" {
+		return nil, errors.New("context is not a project analysis")
+	}
+
+	ctxData := &ContextData{
+		Project: ProjectInfo{
+			Name:      payload.ProjectName,
+			Path:      payload.ProjectPath,
+			IsGitRepo: payload.IsGitRepo,
+		},
+		Git:           payload.GitInfo,
+		TechStacks:    payload.TechStacks,
+		FileStats:     payload.FileStats,
+		Dependencies:  payload.Dependencies,
+		CurrentFile:   payload.CurrentFile,
+		CurrentBranch: payload.CurrentBranch,
+		CurrentDir:    payload.CurrentDir,
+	}
+	if ctxData.CurrentBranch == "" {
+		ctxData.CurrentBranch = payload.GitInfo.CurrentBranch
+	}
+	if ctxData.CurrentDir == "" {
+		ctxData.CurrentDir, _ = os.Getwd()
+	}
+	return ctxData, nil
+}
+
+// fields returns the context as template data. Empty strings are left out so
+// they do not satisfy required variables.
+func (c *ContextData) fields() map[string]interface{} {
+	if c == nil {
+		return nil
+	}
+	fields := map[string]interface{}{
+		"Project":      c.Project,
+		"Git":          c.Git,
+		"TechStacks":   c.TechStacks,
+		"FileStats":    c.FileStats,
+		"Dependencies": c.Dependencies,
+	}
+	for key, value := range map[string]string{
+		"CurrentFile":   c.CurrentFile,
+		"CurrentBranch": c.CurrentBranch,
+		"CurrentDir":    c.CurrentDir,
+		"UserQuery":     c.UserQuery,
+	} {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// FormatContextData renders context data as concise Markdown sections for inclusion in prompts
+func FormatContextData(data ContextData) string {
+	var sb strings.Builder
+	sb.WriteString("# Project context\n\n")
+
+	// Project info
+	sb.WriteString("## Project\n")
+	if data.Project.Name != "" {
+		sb.WriteString(fmt.Sprintf("- Name: %s\n", data.Project.Name))
+	}
+	if data.Project.Path != "" {
+		sb.WriteString(fmt.Sprintf("- Path: %s\n", data.Project.Path))
+	}
+	sb.WriteString("\n")
+
+	// Git info
+	if data.Project.IsGitRepo {
+		sb.WriteString("## Git\n")
+		if data.Git.CurrentBranch != "" {
+			sb.WriteString(fmt.Sprintf("- Branch: %s\n", data.Git.CurrentBranch))
+		}
+		if data.Git.DefaultBranch != "" && data.Git.DefaultBranch != data.Git.CurrentBranch {
+			sb.WriteString(fmt.Sprintf("- Default branch: %s\n", data.Git.DefaultBranch))
+		}
+		if data.Git.LastCommit != "" {
+			sb.WriteString(fmt.Sprintf("- Last commit: %s\n", data.Git.LastCommit))
+		}
+		if data.Git.RemoteURL != "" {
+			sb.WriteString(fmt.Sprintf("- Remote: %s\n", data.Git.RemoteURL))
+		}
+		sb.WriteString("\n")
+	}
+
+	// Tech stacks
+	if len(data.TechStacks) > 0 {
+		sb.WriteString("## Tech Stacks\n")
+		for _, stack := range data.TechStacks {
+			name := stack.Name
+			if stack.Framework != "" {
+				name += " (" + stack.Framework + ")"
+			}
+			if stack.Version != "" {
+				name += " " + stack.Version
+			}
+			sb.WriteString(fmt.Sprintf("- %s (confidence %.2f)\n", name, stack.ConfidenceScore))
+		}
+		sb.WriteString("\n")
+	}
+
+	// File stats, most common types first
+	if data.FileStats.TotalFiles > 0 {
+		sb.WriteString("## Files\n")
+		sb.WriteString(fmt.Sprintf("- %d files\n", data.FileStats.TotalFiles))
+		types := make([]string, 0, len(data.FileStats.FilesByType))
+		for fileType := range data.FileStats.FilesByType {
+			types = append(types, fileType)
+		}
+		sort.Slice(types, func(i, j int) bool {
+			ci, cj := data.FileStats.FilesByType[types[i]], data.FileStats.FilesByType[types[j]]
+			if ci != cj {
+				return ci > cj
+			}
+			return types[i] < types[j]
+		})
+		if len(types) > maxContextFileTypes {
+			types = types[:maxContextFileTypes]
+		}
+		for _, fileType := range types {
+			sb.WriteString(fmt.Sprintf("- %s: %d\n", fileType, data.FileStats.FilesByType[fileType]))
+		}
+		sb.WriteString("\n")
+	}
+
+	// Dependencies
+	if len(data.Dependencies) > 0 {
+		sb.WriteString("## Dependencies\n")
+		names := make([]string, 0, len(data.Dependencies))
+		for name := range data.Dependencies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			if i == maxContextDependencies {
+				sb.WriteString(fmt.Sprintf("- ... and %d more\n", len(names)-i))
+				break
+			}
+			if version := data.Dependencies[name]; version != "" {
+				sb.WriteString(fmt.Sprintf("- %s %s\n", name, version))
+			} else {
+				sb.WriteString(fmt.Sprintf("- %s\n", name))
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	// Current context
+	if data.CurrentFile != "" || data.CurrentBranch != "" || data.CurrentDir != "" {
+		sb.WriteString("## Current Context\n")
+		if data.CurrentDir != "" {
+			sb.WriteString(fmt.Sprintf("- Directory: %s\n", data.CurrentDir))
+		}
+		if data.CurrentBranch != "" {
+			sb.WriteString(fmt.Sprintf("- Branch: %s\n", data.CurrentBranch))
+		}
+		if data.CurrentFile != "" {
+			sb.WriteString(fmt.Sprintf("- File: %s\n", data.CurrentFile))
+		}
+		sb.WriteString("\n")
+	}
+
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
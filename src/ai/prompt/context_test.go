@@ -0,0 +1,74 @@
+package prompt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	ctxanalyzer "github.com/rrecio/crazy-dev-zsh/src/core/context"
+)
+
+func testContext(t *testing.T) []byte {
+	result := &ctxanalyzer.AnalysisResult{
+		ProjectPath:  "/work/demo",
+		ProjectName:  "demo",
+		IsGitRepo:    true,
+		GitInfo:      ctxanalyzer.GitInfo{CurrentBranch: "main", DefaultBranch: "main"},
+		TechStacks:   []ctxanalyzer.TechStack{{Name: "Go", ConfidenceScore: 2}},
+		FileStats:    ctxanalyzer.FileStats{TotalFiles: 3, FilesByType: map[string]int{".go": 2, ".md": 1}},
+		Dependencies: map[string]string{"github.com/spf13/cobra": "v1.8.0"},
+	}
+	data, err := EncodeContext(result, "/work/demo/cmd", "main.go", "feature/x")
+	require.NoError(t, err)
+	return data
+}
+
+func TestProcessMessages_StructuredContext(t *testing.T) {
+	engine := NewPromptEngine()
+	payload := testContext(t)
+
+	messages, err := engine.ProcessMessages([]ai.Message{
+		{Role: "system", Content: "You are helpful."},
+		{Role: "user", Content: "hi"},
+	}, payload)
+	require.NoError(t, err)
+	system := messages[0].Content
+	assert.Contains(t, system, "You are helpful.\n\n# Project context")
+	assert.Contains(t, system, "- Go (confidence 2.00)")
+	assert.Contains(t, system, "- .go: 2\n- .md: 1")
+	assert.Contains(t, system, "- Branch: feature/x")
+	assert.Contains(t, system, "- File: main.go")
+	assert.NotContains(t, system, "project_path")
+	assert.Equal(t, "hi", messages[1].Content)
+
+	// Templates place the fields themselves
+	messages, err = engine.ProcessMessages([]ai.Message{
+		{Role: "system", Content: "Working on {{.Project.Name}} at {{.Git.CurrentBranch}} from {{.CurrentBranch}}."},
+	}, payload)
+	require.NoError(t, err)
+	assert.Equal(t, "Working on demo at main from feature/x.", messages[0].Content)
+
+	// Anything that is not an analysis is included verbatim
+	messages, err = engine.ProcessMessages([]ai.Message{{Role: "system", Content: "sys"}}, []byte("notes"))
+	require.NoError(t, err)
+	assert.Equal(t, "Context:\nnotes\n\nPrompt:\nsys", messages[0].Content)
+}
+
+func TestLibrary_RenderWithContext(t *testing.T) {
+	lib, err := NewLibrary()
+	require.NoError(t, err)
+	ctxData, err := DecodeContextData(testContext(t))
+	require.NoError(t, err)
+
+	// CurrentBranch is required by git_help and filled from the context
+	out, err := lib.Render("git_help", map[string]string{"query": "undo", "GitStatus": "clean"}, ctxData)
+	require.NoError(t, err)
+	assert.Contains(t, out, "feature/x")
+
+	out, err = lib.Render("git_help", map[string]string{"query": "undo", "GitStatus": "clean", "CurrentBranch": "dev"}, ctxData)
+	require.NoError(t, err)
+	assert.Contains(t, out, "dev")
+	assert.NotContains(t, out, "feature/x")
+}
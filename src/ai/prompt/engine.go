@@ -12,6 +12,7 @@ import (
 // PromptEngine handles prompt processing and template management
 type PromptEngine struct {
 	templates map[string]*template.Template
+	funcs     *templateFuncs
 }
 
 // PromptTemplate represents a template for generating prompts
@@ -22,29 +23,23 @@ type PromptTemplate struct {
 	Variables   map[string]string
 }
 
-// ContextData represents context data to be included in prompts
-type ContextData struct {
-	ProjectInfo   map[string]interface{}
-	GitInfo       map[string]interface{}
-	TechStacks    []map[string]interface{}
-	FileStats     map[string]interface{}
-	Dependencies  map[string]interface{}
-	CurrentFile   string
-	CurrentBranch string
-	CurrentDir    string
-	UserQuery     string
-}
-
 // NewPromptEngine creates a new prompt engine
 func NewPromptEngine() *PromptEngine {
 	return &PromptEngine{
 		templates: make(map[string]*template.Template),
+		funcs:     &templateFuncs{},
 	}
 }
 
+// SetFuncOptions configures the template functions such as readFile and shell,
+// including for templates that are already registered
+func (e *PromptEngine) SetFuncOptions(opts FuncOptions) {
+	e.funcs.opts = opts
+}
+
 // RegisterTemplate registers a template with the prompt engine
 func (e *PromptEngine) RegisterTemplate(name string, templateStr string) error {
-	tmpl, err := template.New(name).Parse(templateStr)
+	tmpl, err := template.New(name).Funcs(e.funcs.funcMap()).Parse(templateStr)
 	if err != nil {
 		return fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -71,19 +66,20 @@ func (e *PromptEngine) ProcessMessages(messages []ai.Message, context []byte) ([
 		return messages, nil
 	}
 
-	// Process each message with the context
+	// Add the context to the first system message only, later system
+	// messages such as conversation summaries are left unchanged
 	processedMessages := make([]ai.Message, len(messages))
+	contextAdded := false
 	for i, msg := range messages {
-		if msg.Role == "system" {
-			// Add context to system messages
+		if msg.Role == "system" && !contextAdded {
+			contextAdded = true
+			// Add context to the system prompt
 			processedContent, err := e.addContextToPrompt(msg.Content, context)
 			if err != nil {
 				return nil, fmt.Errorf("failed to process system message: %w", err)
 			}
-			processedMessages[i] = ai.Message{
-				Role:    msg.Role,
-				Content: processedContent,
-			}
+			processedMessages[i] = msg
+			processedMessages[i].Content = processedContent
 		} else {
 			// Leave other messages unchanged
 			processedMessages[i] = msg
@@ -108,19 +104,48 @@ func (e *PromptEngine) ExecuteTemplate(name string, data interface{}) (string, e
 	return buf.String(), nil
 }
 
-// addContextToPrompt adds context to a prompt
+// addContextToPrompt adds context to a prompt. A project analysis is rendered
+// as Markdown sections after the prompt, unless the prompt is a template that
+// places the fields itself, e.g. {{.Git.CurrentBranch}}. Any other context is
+// included verbatim.
 func (e *PromptEngine) addContextToPrompt(prompt string, context []byte) (string, error) {
-	// Simple concatenation for now
-	// In a more advanced implementation, this would parse the context and
-	// intelligently integrate it with the prompt
-	contextStr := string(context)
-	
-	// Limit context size to avoid token limits
-	if len(contextStr) > 4000 {
-		contextStr = contextStr[:4000] + "...[truncated]"
+	data, err := DecodeContextData(context)
+	if err != nil {
+		contextStr := string(context)
+
+		// Limit context size to avoid token limits
+		if len(contextStr) > 4000 {
+			contextStr = contextStr[:4000] + "...[truncated]"
+		}
+
+		return fmt.Sprintf("Context:\n%s\n\nPrompt:\n%s", contextStr, prompt), nil
 	}
-	
-	return fmt.Sprintf("Context:\n%s\n\nPrompt:\n%s", contextStr, prompt), nil
+
+	if strings.Contains(prompt, "{{") {
+		if rendered, err := e.renderContextTemplate(prompt, data); err == nil {
+			return rendered, nil
+		}
+		// Not a valid template, braces are part of the text
+	}
+
+	sections := FormatContextData(*data)
+	if strings.TrimSpace(prompt) == "" {
+		return sections, nil
+	}
+	return strings.TrimRight(prompt, "\n") + "\n\n" + sections, nil
+}
+
+// renderContextTemplate executes prompt as a template over the context data
+func (e *PromptEngine) renderContextTemplate(prompt string, data *ContextData) (string, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=zero").Funcs(e.funcs.funcMap()).Parse(prompt)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
 }
 
 // DefaultTemplates returns a map of default templates
@@ -129,13 +154,20 @@ func DefaultTemplates() map[string]PromptTemplate {
 		"code_completion": {
 			Name:        "Code Completion",
 			Description: "Template for code completion",
-			Template: `You are an AI coding assistant. Complete the following code:
-
-{{.Code}}
+			Template: `You are an AI coding assistant. Complete the following {{if .Language}}{{.Language}} {{end}}code{{if .Suffix}} at <CURSOR>{{end}}.
+Reply with only the code to insert, without explanations or code fences.
+{{if .Symbols}}
+Symbols defined nearby:
+{{.Symbols}}
+{{end}}
+{{.Code}}{{if .Suffix}}<CURSOR>{{.Suffix}}{{end}}
 
 `,
 			Variables: map[string]string{
-				"Code": "The code to complete",
+				"Code":     "The code to complete, or the code before the cursor",
+				"Suffix":   "The code after the cursor",
+				"Language": "The language of the code",
+				"Symbols":  "Declarations defined outside the code shown",
 			},
 		},
 		"code_explanation": {
@@ -154,16 +186,30 @@ func DefaultTemplates() map[string]PromptTemplate {
 			Name:        "Error Diagnosis",
 			Description: "Template for diagnosing errors",
 			Template: `You are an AI coding assistant. Diagnose the following error:
-
+{{if .Command}}
+Command{{if .Shell}} in {{.Shell}}{{end}}{{if .OS}} on {{.OS}}{{end}}: {{.Command}}
+{{if .ExitCode}}Exit status: {{.ExitCode}}
+{{end}}{{if .CurrentDir}}Directory: {{.CurrentDir}}
+{{end}}{{end}}
 Error: {{.Error}}
-
+{{if .Code}}
 Code:
 {{.Code}}
-
-`,
+{{else if .File}}
+Code from {{.File}}:
+{{readFile .File | truncateTokens 2000}}
+{{end}}{{if .Command}}
+Explain the cause briefly, then give the corrected command alone in a fenced code block.
+{{end}}`,
 			Variables: map[string]string{
-				"Error": "The error message",
-				"Code":  "The code that produced the error",
+				"Error":      "The error message",
+				"Code":       "The code that produced the error",
+				"File":       "The file that produced the error, read when Code is not set",
+				"Command":    "The command that failed",
+				"ExitCode":   "The exit status of the command",
+				"CurrentDir": "The directory the command ran in",
+				"Shell":      "The shell the command ran in",
+				"OS":         "The operating system",
 			},
 		},
 		"git_help": {
@@ -173,15 +219,14 @@ Code:
 
 {{.Query}}
 
-Current branch: {{.CurrentBranch}}
-Git status:
-{{.GitStatus}}
-
+{{if .CurrentBranch}}Current branch: {{.CurrentBranch}}
+{{end}}Git status:
+{{if .GitStatus}}{{.GitStatus}}{{else}}{{gitStatus}}{{end}}
 `,
 			Variables: map[string]string{
 				"Query":         "The user's query",
 				"CurrentBranch": "The current Git branch",
-				"GitStatus":     "The output of git status",
+				"GitStatus":     "The output of git status, run when not set",
 			},
 		},
 		"project_context": {
@@ -210,19 +255,24 @@ User query: {{.Query}}
 		"command_suggestion": {
 			Name:        "Command Suggestion",
 			Description: "Template for suggesting commands",
-			Template: `You are an AI assistant for the terminal. Suggest commands for the following task:
+			Template: `You are an AI assistant for the terminal. Write a command{{if .Shell}} for {{.Shell}}{{end}}{{if .OS}} on {{.OS}}{{end}} for the following task:
 
 {{.Query}}
 
 Current directory: {{.CurrentDir}}
-{{if .FileList}}Files in directory:
-{{.FileList}}{{end}}
-
+{{if .TechStacks}}Detected stacks: {{range $i, $stack := .TechStacks}}{{if $i}}, {{end}}{{$stack.Name}}{{end}}
+{{end}}{{if .FileList}}Files in directory:
+{{.FileList}}
+{{end}}
+Reply with the command alone in a fenced code block, then explain briefly what it does and what each option is for.
 `,
 			Variables: map[string]string{
 				"Query":      "The user's query",
 				"CurrentDir": "The current directory",
 				"FileList":   "List of files in the directory",
+				"Shell":      "The shell the command runs in",
+				"OS":         "The operating system",
+				"TechStacks": "The detected tech stacks",
 			},
 		},
 	}
@@ -238,72 +288,3 @@ func (e *PromptEngine) LoadDefaultTemplates() error {
 	}
 	return nil
 }
-
-// FormatContextData formats context data for inclusion in prompts
-func FormatContextData(data ContextData) string {
-	var sb strings.Builder
-
-	// Project info
-	if len(data.ProjectInfo) > 0 {
-		sb.WriteString("## Project Info\n")
-		for k, v := range data.ProjectInfo {
-			sb.WriteString(fmt.Sprintf("%s: %v\n", k, v))
-		}
-		sb.WriteString("\n")
-	}
-
-	// Git info
-	if len(data.GitInfo) > 0 {
-		sb.WriteString("## Git Info\n")
-		for k, v := range data.GitInfo {
-			sb.WriteString(fmt.Sprintf("%s: %v\n", k, v))
-		}
-		sb.WriteString("\n")
-	}
-
-	// Tech stacks
-	if len(data.TechStacks) > 0 {
-		sb.WriteString("## Tech Stacks\n")
-		for _, stack := range data.TechStacks {
-			name, _ := stack["name"].(string)
-			confidence, _ := stack["confidence"].(float64)
-			sb.WriteString(fmt.Sprintf("- %s (%.1f%%)\n", name, confidence))
-		}
-		sb.WriteString("\n")
-	}
-
-	// File stats
-	if len(data.FileStats) > 0 {
-		sb.WriteString("## File Stats\n")
-		for k, v := range data.FileStats {
-			sb.WriteString(fmt.Sprintf("%s: %v\n", k, v))
-		}
-		sb.WriteString("\n")
-	}
-
-	// Dependencies
-	if len(data.Dependencies) > 0 {
-		sb.WriteString("## Dependencies\n")
-		for k, v := range data.Dependencies {
-			sb.WriteString(fmt.Sprintf("- %s: %v\n", k, v))
-		}
-		sb.WriteString("\n")
-	}
-
-	// Current context
-	if data.CurrentFile != "" || data.CurrentBranch != "" || data.CurrentDir != "" {
-		sb.WriteString("## Current Context\n")
-		if data.CurrentFile != "" {
-			sb.WriteString(fmt.Sprintf("File: %s\n", data.CurrentFile))
-		}
-		if data.CurrentBranch != "" {
-			sb.WriteString(fmt.Sprintf("Branch: %s\n", data.CurrentBranch))
-		}
-		if data.CurrentDir != "" {
-			sb.WriteString(fmt.Sprintf("Directory: %s\n", data.CurrentDir))
-		}
-		sb.WriteString("\n")
-	}
-
-	return sb.String()
-}
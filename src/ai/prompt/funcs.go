@@ -0,0 +1,472 @@
+package prompt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+)
+
+// Limits on what template functions read and run
+const (
+	maxTemplateFileSize  = 1 << 20
+	maxShellOutput       = 64 << 10
+	defaultShellTimeout  = 10 * time.Second
+	truncatedTokenMarker = "\n...[truncated]"
+)
+
+// shellMetacharacters are rejected in shell commands, which are not run by a shell
+const shellMetacharacters = ";|&`$()<>\n"
+
+// gitDiffFlags are the only options gitDiff passes on to git
+var gitDiffFlags = map[string]bool{
+	"--staged": true,
+	"--cached": true,
+	"--stat":   true,
+}
+
+// FuncOptions configures the template functions
+type FuncOptions struct {
+	// Root confines file access, an empty root is the current directory
+	Root string
+
+	// AllowShell enables the shell function, limited to the commands in
+	// ShellAllow, which must not be empty
+	AllowShell   bool
+	ShellAllow   []string
+	ShellTimeout time.Duration
+}
+
+// templateFuncs implements the functions available to prompt templates. The
+// options are read on every call, so templates parsed earlier see changes.
+type templateFuncs struct {
+	opts FuncOptions
+}
+
+// funcMap returns the functions to register with a template
+func (f *templateFuncs) funcMap() template.FuncMap {
+	return template.FuncMap{
+		"readFile":       f.readFile,
+		"lines":          f.lines,
+		"gitDiff":        f.gitDiff,
+		"gitStatus":      f.gitStatus,
+		"glob":           f.glob,
+		"truncateTokens": truncateTokens,
+		"symbol":         f.symbol,
+		"env":            env,
+		"shell":          f.shell,
+	}
+}
+
+// root returns the absolute project root with symlinks resolved
+func (f *templateFuncs) root() (string, error) {
+	root := f.opts.Root
+	if root == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("could not get current directory: %w", err)
+		}
+		root = cwd
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	return root, nil
+}
+
+// resolve returns the absolute path of name, relative to the project root,
+// failing when it resolves to a location outside the root
+func (f *templateFuncs) resolve(name string) (string, error) {
+	root, err := f.root()
+	if err != nil {
+		return "", err
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if !within(root, path) {
+		return "", fmt.Errorf("%s is outside the project root", name)
+	}
+	return path, nil
+}
+
+// within reports whether path is root or inside it
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// readFile returns the contents of a file in the project
+func (f *templateFuncs) readFile(name string) (string, error) {
+	path, err := f.resolve(name)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > maxTemplateFileSize {
+		return "", fmt.Errorf("%s is too large (%d bytes), use lines to select a range", name, info.Size())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// lines returns lines from through to of a file in the project, counting from 1
+func (f *templateFuncs) lines(name string, from, to int) (string, error) {
+	if from < 1 || to < from {
+		return "", fmt.Errorf("invalid line range %d-%d", from, to)
+	}
+	content, err := f.readFile(name)
+	if err != nil {
+		return "", err
+	}
+	all := strings.Split(content, "\n")
+	if from > len(all) {
+		return "", nil
+	}
+	if to > len(all) {
+		to = len(all)
+	}
+	return strings.Join(all[from-1:to], "\n"), nil
+}
+
+// gitDiff returns git diff for the given revisions or paths, e.g. gitDiff "HEAD~1"
+func (f *templateFuncs) gitDiff(args ...string) (string, error) {
+	gitArgs := []string{"diff", "--no-color", "--no-ext-diff"}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && !gitDiffFlags[arg] {
+			return "", fmt.Errorf("gitDiff does not accept %s", arg)
+		}
+		gitArgs = append(gitArgs, arg)
+	}
+	return f.git(gitArgs...)
+}
+
+// gitStatus returns the short git status with the branch
+func (f *templateFuncs) gitStatus() (string, error) {
+	return f.git("status", "--short", "--branch")
+}
+
+// git runs git in the project root
+func (f *templateFuncs) git(args ...string) (string, error) {
+	root, err := f.root()
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", root}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// glob returns the project files matching pattern, relative to the project root
+func (f *templateFuncs) glob(pattern string) ([]string, error) {
+	root, err := f.root()
+	if err != nil {
+		return nil, err
+	}
+	if filepath.IsAbs(pattern) {
+		return nil, fmt.Errorf("glob pattern %s must be relative to the project root", pattern)
+	}
+	matches, err := filepath.Glob(filepath.Join(root, pattern))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if !within(root, match) {
+			continue
+		}
+		rel, _ := filepath.Rel(root, match)
+		files = append(files, rel)
+	}
+	return files, nil
+}
+
+// truncateTokens shortens text to about n tokens. The text comes last so it
+// works in pipelines, e.g. {{readFile "main.go" | truncateTokens 500}}.
+func truncateTokens(n int, text string) string {
+	if ai.EstimateTokens(text) <= n {
+		return text
+	}
+	limit := n * 4
+	if limit <= 0 {
+		return ""
+	}
+	// Cut at the last line break to keep whole lines, or at least whole runes
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	cut := text[:limit]
+	if i := strings.LastIndex(cut, "\n"); i >= limit/2 {
+		cut = cut[:i]
+	}
+	return cut + truncatedTokenMarker
+}
+
+// env returns an environment variable. Variables that look like credentials are empty.
+func env(name string) string {
+	upper := strings.ToUpper(name)
+	for _, secret := range []string{"KEY", "TOKEN", "SECRET", "PASSWORD", "CREDENTIAL"} {
+		if strings.Contains(upper, secret) {
+			return ""
+		}
+	}
+	return os.Getenv(name)
+}
+
+// shell runs an allowed command in the project root, when enabled. The
+// command is split on spaces and run without a shell, its arguments must stay
+// inside the project. Commands get a minimal environment, no input, a timeout
+// and limited output.
+func (f *templateFuncs) shell(command string) (string, error) {
+	if !f.opts.AllowShell {
+		return "", errors.New("shell is disabled, set prompts.allow_shell to enable it")
+	}
+	if len(f.opts.ShellAllow) == 0 {
+		return "", errors.New("shell needs prompts.shell_allow to list the allowed commands")
+	}
+	if strings.ContainsAny(command, shellMetacharacters) {
+		return "", fmt.Errorf("shell command %q uses shell syntax, only plain commands are allowed", command)
+	}
+	fields := strings.Fields(command)
+	if len(fields) == 0 || !contains(f.opts.ShellAllow, fields[0]) {
+		return "", fmt.Errorf("shell command %q is not in prompts.shell_allow", command)
+	}
+	for _, arg := range fields[1:] {
+		if strings.HasPrefix(arg, "-") {
+			// Check values given as --flag=path, skip plain flags
+			_, value, ok := strings.Cut(arg, "=")
+			if !ok || value == "" {
+				continue
+			}
+			arg = value
+		}
+		if _, err := f.resolve(arg); err != nil {
+			return "", fmt.Errorf("shell command %q: %w", command, err)
+		}
+	}
+	root, err := f.root()
+	if err != nil {
+		return "", err
+	}
+	timeout := f.opts.ShellTimeout
+	if timeout <= 0 {
+		timeout = defaultShellTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	output := &limitedBuffer{max: maxShellOutput}
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	cmd.Dir = root
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + os.Getenv("HOME"), "LANG=C.UTF-8", "TERM=dumb"}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("shell command %q timed out after %s", command, timeout)
+		}
+		return "", fmt.Errorf("shell command %q failed: %w: %s", command, err, strings.TrimSpace(output.String()))
+	}
+	return output.String(), nil
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedBuffer keeps the first max bytes written to it and discards the rest
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n...[truncated]"
+	}
+	return b.buf.String()
+}
+
+// symbol returns the source of a Go declaration in the project with its doc
+// comment. The name is Func, pkg.Func, Type.Method or pkg.Type.Method.
+func (f *templateFuncs) symbol(name string) (string, error) {
+	root, err := f.root()
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(name, ".")
+	if len(parts) > 3 {
+		return "", fmt.Errorf("invalid symbol %s", name)
+	}
+
+	// Both readings of a two part name are tried, package first
+	type query struct{ pkg, recv, name string }
+	var queries []query
+	switch len(parts) {
+	case 1:
+		queries = []query{{name: parts[0]}}
+	case 2:
+		queries = []query{{pkg: parts[0], name: parts[1]}, {recv: parts[0], name: parts[1]}}
+	case 3:
+		queries = []query{{pkg: parts[0], recv: parts[1], name: parts[2]}}
+	}
+
+	var found string
+	errFound := errors.New("found")
+	for _, q := range queries {
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				base := d.Name()
+				if path != root && (strings.HasPrefix(base, ".") || base == "vendor" || base == "node_modules" || base == "testdata") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+				return nil
+			}
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+			if err != nil || (q.pkg != "" && file.Name.Name != q.pkg) {
+				return nil
+			}
+			if start, end, ok := findDecl(fset, file, q.recv, q.name); ok {
+				found = string(src[start:end])
+				return errFound
+			}
+			return nil
+		})
+		if errors.Is(err, errFound) {
+			return found, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("symbol %s not found", name)
+}
+
+// findDecl returns the byte offsets of the declaration of name, a method of
+// recv when recv is set, including its doc comment
+func findDecl(fset *token.FileSet, file *ast.File, recv, name string) (int, int, bool) {
+	span := func(doc *ast.CommentGroup, node ast.Node) (int, int, bool) {
+		start := node.Pos()
+		if doc != nil {
+			start = doc.Pos()
+		}
+		return fset.Position(start).Offset, fset.Position(node.End()).Offset, true
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Name.Name != name || (decl.Recv != nil) != (recv != "") {
+				continue
+			}
+			if recv != "" && receiverName(decl.Recv.List[0].Type) != recv {
+				continue
+			}
+			return span(decl.Doc, decl)
+		case *ast.GenDecl:
+			if recv != "" {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.Name != name {
+						continue
+					}
+					if len(decl.Specs) == 1 {
+						return span(decl.Doc, decl)
+					}
+					return span(spec.Doc, spec)
+				case *ast.ValueSpec:
+					for _, ident := range spec.Names {
+						if ident.Name != name {
+							continue
+						}
+						if len(decl.Specs) == 1 {
+							return span(decl.Doc, decl)
+						}
+						return span(spec.Doc, spec)
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// receiverName returns the type name of a method receiver
+func receiverName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(expr.X)
+	case *ast.IndexExpr:
+		return receiverName(expr.X)
+	case *ast.IndexListExpr:
+		return receiverName(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const symbolSource = `package shapes
+
+// Area returns the area of a square
+func Area(side int) int {
+	return side * side
+}
+
+// Square is a square
+type Square struct{ Side int }
+
+// Scale grows the square
+func (s *Square) Scale(n int) { s.Side *= n }
+`
+
+func TestTemplateFuncs(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "shapes"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "shapes", "shapes.go"), []byte(symbolSource), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "notes.txt"), []byte("one\ntwo\nthree\nfour\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "link.txt")))
+
+	engine := NewPromptEngine()
+	engine.SetFuncOptions(FuncOptions{Root: root})
+	render := func(text string) (string, error) {
+		require.NoError(t, engine.RegisterTemplate("t", text))
+		return engine.ExecuteTemplate("t", nil)
+	}
+
+	out, err := render(`{{lines "notes.txt" 2 3}}|{{range glob "shapes/*.go"}}{{.}}{{end}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "two\nthree|shapes/shapes.go", out)
+
+	out, err = render(`{{symbol "shapes.Area"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "// Area returns the area of a square\nfunc Area(side int) int {\n\treturn side * side\n}", out)
+	out, err = render(`{{symbol "Square.Scale"}}`)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(out, "// Scale grows the square\nfunc (s *Square) Scale"))
+
+	// Files outside the root are refused, including through symlinks
+	for _, text := range []string{
+		`{{readFile "../secret.txt"}}`,
+		`{{readFile "` + filepath.Join(outside, "secret.txt") + `"}}`,
+		`{{readFile "link.txt"}}`,
+	} {
+		_, err = render(text)
+		assert.ErrorContains(t, err, "outside the project root", text)
+	}
+
+	// The shell is opt-in and limited to allowed commands
+	_, err = render(`{{shell "echo hi"}}`)
+	assert.ErrorContains(t, err, "shell is disabled")
+	engine.SetFuncOptions(FuncOptions{Root: root, AllowShell: true})
+	_, err = render(`{{shell "echo hi"}}`)
+	assert.ErrorContains(t, err, "prompts.shell_allow")
+	engine.SetFuncOptions(FuncOptions{Root: root, AllowShell: true, ShellAllow: []string{"echo", "cat"}})
+	out, err = render(`{{shell "echo hi"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "hi\n", out)
+	out, err = render(`{{shell "cat -n notes.txt"}}`)
+	require.NoError(t, err)
+	assert.Contains(t, out, "two")
+	_, err = render(`{{shell "ls"}}`)
+	assert.ErrorContains(t, err, "not in prompts.shell_allow")
+	_, err = render(`{{shell "echo hi; cat ../secret.txt"}}`)
+	assert.ErrorContains(t, err, "shell syntax")
+
+	// Arguments cannot reach outside the root
+	for _, text := range []string{
+		`{{shell "cat ../secret.txt"}}`,
+		`{{shell "cat /etc/passwd"}}`,
+		`{{shell "cat link.txt"}}`,
+		`{{shell "cat --file=../secret.txt"}}`,
+	} {
+		_, err = render(text)
+		assert.ErrorContains(t, err, "outside the project root", text)
+	}
+}
+
+func TestTruncateTokens(t *testing.T) {
+	text := strings.Repeat("0123456789\n", 10)
+	assert.Equal(t, text, truncateTokens(100, text))
+
+	short := truncateTokens(5, text)
+	assert.Equal(t, "0123456789"+truncatedTokenMarker, short)
+}
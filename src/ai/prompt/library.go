@@ -0,0 +1,352 @@
+package prompt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template sources reported by Library
+const (
+	SourceBuiltin = "builtin"
+	SourceUser    = "user"
+	SourceProject = "project"
+)
+
+// templateExtensions are the file extensions loaded as prompt templates
+var templateExtensions = map[string]bool{
+	".md":     true,
+	".tmpl":   true,
+	".txt":    true,
+	".prompt": true,
+}
+
+// Template is a named prompt template with its metadata
+type Template struct {
+	Name        string     `yaml:"-" json:"name"`
+	Description string     `yaml:"description,omitempty" json:"description,omitempty"`
+	Model       string     `yaml:"model,omitempty" json:"model,omitempty"`
+	System      string     `yaml:"system,omitempty" json:"system,omitempty"`
+	Params      Params     `yaml:"params,omitempty" json:"params"`
+	Variables   []Variable `yaml:"variables,omitempty" json:"variables,omitempty"`
+	Body        string     `yaml:"-" json:"body"`
+
+	// Source is builtin, user or project, Path is the file the template was loaded from
+	Source string `yaml:"-" json:"source"`
+	Path   string `yaml:"-" json:"path,omitempty"`
+}
+
+// Params are the default request parameters of a template
+type Params struct {
+	Temperature *float64 `yaml:"temperature,omitempty" json:"temperature,omitempty"`
+	TopP        *float64 `yaml:"top_p,omitempty" json:"top_p,omitempty"`
+	MaxTokens   int      `yaml:"max_tokens,omitempty" json:"max_tokens,omitempty"`
+}
+
+// Variable is a template input
+type Variable struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Required    bool   `yaml:"required,omitempty" json:"required"`
+	Default     string `yaml:"default,omitempty" json:"default,omitempty"`
+}
+
+// MissingVariablesError is returned when required template variables are not set
+type MissingVariablesError struct {
+	Template string
+	Missing  []string
+}
+
+func (e *MissingVariablesError) Error() string {
+	return fmt.Sprintf("template %s is missing required variables: %s", e.Template, strings.Join(e.Missing, ", "))
+}
+
+// ParseTemplate parses a template file with optional YAML frontmatter between "---" lines
+func ParseTemplate(name string, data []byte) (*Template, error) {
+	tmpl := &Template{Name: name}
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		frontmatter, body, found := strings.Cut(rest, "\n---\n")
+		if !found {
+			frontmatter, found = strings.CutSuffix(rest, "\n---")
+			body = ""
+		}
+		if !found {
+			return nil, fmt.Errorf("unterminated frontmatter")
+		}
+		if err := yaml.Unmarshal([]byte(frontmatter), tmpl); err != nil {
+			return nil, fmt.Errorf("invalid frontmatter: %w", err)
+		}
+		text = body
+	}
+	tmpl.Body = text
+
+	for i, v := range tmpl.Variables {
+		if v.Name == "" {
+			return nil, fmt.Errorf("variable %d has no name", i+1)
+		}
+	}
+	return tmpl, nil
+}
+
+// Library holds the prompt templates available by name
+type Library struct {
+	engine    *PromptEngine
+	templates map[string]*Template
+}
+
+// NewLibrary creates a library holding the built-in templates
+func NewLibrary() (*Library, error) {
+	lib := &Library{
+		engine:    NewPromptEngine(),
+		templates: make(map[string]*Template),
+	}
+	for name, def := range DefaultTemplates() {
+		if err := lib.Add(builtinTemplate(name, def)); err != nil {
+			return nil, err
+		}
+	}
+	return lib, nil
+}
+
+// SetFuncOptions configures the functions available to the templates
+func (l *Library) SetFuncOptions(opts FuncOptions) {
+	l.engine.SetFuncOptions(opts)
+}
+
+// LoadLibrary creates a library with the built-in templates, overridden by the
+// user's templates in userDir and then the project's templates in projectDir
+func LoadLibrary(userDir, projectDir string) (*Library, error) {
+	lib, err := NewLibrary()
+	if err != nil {
+		return nil, err
+	}
+	if err := lib.LoadDir(userDir, SourceUser); err != nil {
+		return nil, err
+	}
+	if err := lib.LoadDir(projectDir, SourceProject); err != nil {
+		return nil, err
+	}
+	return lib, nil
+}
+
+// LoadDir adds every template file in dir, replacing templates with the same name.
+// A missing directory is not an error.
+func (l *Library) LoadDir(dir, source string) error {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read prompt directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || !templateExtensions[ext] {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read template: %w", err)
+		}
+		tmpl, err := ParseTemplate(strings.TrimSuffix(entry.Name(), ext), data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		tmpl.Source = source
+		tmpl.Path = path
+		if err := l.Add(tmpl); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// Add registers a template, replacing any template with the same name
+func (l *Library) Add(tmpl *Template) error {
+	if err := l.engine.RegisterTemplate(tmpl.Name, tmpl.Body); err != nil {
+		return err
+	}
+	l.templates[tmpl.Name] = tmpl
+	return nil
+}
+
+// Get returns the template with the given name
+func (l *Library) Get(name string) (*Template, bool) {
+	tmpl, ok := l.templates[name]
+	return tmpl, ok
+}
+
+// Templates returns all templates sorted by name
+func (l *Library) Templates() []*Template {
+	templates := make([]*Template, 0, len(l.templates))
+	for _, tmpl := range l.templates {
+		templates = append(templates, tmpl)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates
+}
+
+// Render validates vars against the template's variables and executes it.
+// Declared variables that are not set take their default, or are empty. When
+// ctxData is not nil its fields, e.g. .Git or .CurrentBranch, are available to
+// the template and satisfy variables of the same name.
+func (l *Library) Render(name string, vars map[string]string, ctxData *ContextData) (string, error) {
+	tmpl, ok := l.templates[name]
+	if !ok {
+		return "", fmt.Errorf("template not found: %s", name)
+	}
+
+	data, err := tmpl.Bind(vars, ctxData)
+	if err != nil {
+		return "", err
+	}
+	return l.engine.ExecuteTemplate(name, data)
+}
+
+// Bind resolves vars against the declared variables, matching names case-insensitively,
+// and returns the template data or a MissingVariablesError. Explicit vars win over
+// the fields of ctxData, which may be nil.
+func (t *Template) Bind(vars map[string]string, ctxData *ContextData) (map[string]interface{}, error) {
+	fields := ctxData.fields()
+	data := make(map[string]interface{}, len(fields)+len(vars)+len(t.Variables))
+	for key, value := range fields {
+		data[key] = value
+	}
+	for key, value := range vars {
+		data[key] = value
+	}
+
+	var missing []string
+	for _, v := range t.Variables {
+		value, set := lookupFold(vars, v.Name)
+		field, inContext := lookupFieldFold(fields, v.Name)
+		switch {
+		case set && value != "":
+			data[v.Name] = value
+		case inContext:
+			data[v.Name] = field
+		case v.Default != "":
+			data[v.Name] = v.Default
+		case v.Required:
+			missing = append(missing, v.Name)
+		default:
+			data[v.Name] = ""
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &MissingVariablesError{Template: t.Name, Missing: missing}
+	}
+	return data, nil
+}
+
+// lookupFieldFold finds key in the context fields, ignoring case
+func lookupFieldFold(fields map[string]interface{}, key string) (interface{}, bool) {
+	for k, value := range fields {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// lookupFold finds key in vars, ignoring case
+func lookupFold(vars map[string]string, key string) (string, bool) {
+	if value, ok := vars[key]; ok {
+		return value, true
+	}
+	for k, value := range vars {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// builtinTemplate converts a default template. Variables only used inside an
+// {{if}} or {{else if}} block are optional, all others are required.
+func builtinTemplate(name string, def PromptTemplate) *Template {
+	tmpl := &Template{
+		Name:        name,
+		Description: def.Description,
+		Body:        def.Template,
+		Source:      SourceBuiltin,
+	}
+	names := make([]string, 0, len(def.Variables))
+	for variable := range def.Variables {
+		names = append(names, variable)
+	}
+	sort.Strings(names)
+	for _, variable := range names {
+		tmpl.Variables = append(tmpl.Variables, Variable{
+			Name:        variable,
+			Description: def.Variables[variable],
+			Required:    !strings.Contains(def.Template, "{{if ."+variable) && !strings.Contains(def.Template, "{{else if ."+variable),
+		})
+	}
+	return tmpl
+}
+
+// FindProjectDir returns the project's .crazy/prompts directory, searching
+// upwards from start, or an empty string when there is none
+func FindProjectDir(start string) string {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return ""
+	}
+	for {
+		candidate := filepath.Join(dir, ".crazy", "prompts")
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// FindProjectRoot returns the closest directory above start holding a .crazy
+// directory or a Git repository, or start itself when there is none
+func FindProjectRoot(start string) string {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return start
+	}
+	for current := dir; ; {
+		for _, marker := range []string{".crazy", ".git"} {
+			if _, err := os.Stat(filepath.Join(current, marker)); err == nil {
+				return current
+			}
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+// Frontmatter renders the template's metadata as YAML, for display
+func (t *Template) Frontmatter() (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(t); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
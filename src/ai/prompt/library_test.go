@@ -0,0 +1,77 @@
+package prompt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reviewTemplate = `---
+description: Review a diff
+model: codellama
+params:
+  temperature: 0.2
+  max_tokens: 400
+variables:
+  - name: Diff
+    required: true
+  - name: Focus
+    default: correctness
+---
+Review this diff for {{.Focus}}:
+{{.Diff}}
+`
+
+func TestParseTemplate_Frontmatter(t *testing.T) {
+	tmpl, err := ParseTemplate("review", []byte(reviewTemplate))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Review a diff", tmpl.Description)
+	assert.Equal(t, "codellama", tmpl.Model)
+	require.NotNil(t, tmpl.Params.Temperature)
+	assert.Equal(t, 0.2, *tmpl.Params.Temperature)
+	assert.Equal(t, 400, tmpl.Params.MaxTokens)
+	assert.Equal(t, "Review this diff for {{.Focus}}:\n{{.Diff}}\n", tmpl.Body)
+
+	_, err = ParseTemplate("broken", []byte("---\ndescription: x\n"))
+	assert.Error(t, err)
+}
+
+func TestLibrary_OverridesAndValidation(t *testing.T) {
+	userDir := t.TempDir()
+	projectDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(userDir, "review.md"), []byte(reviewTemplate), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(userDir, "git_help.md"), []byte("user git help {{.Query}}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "git_help.md"), []byte("project git help {{.Query}}"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "notes.json"), []byte("{}"), 0644))
+
+	lib, err := LoadLibrary(userDir, projectDir)
+	require.NoError(t, err)
+
+	tmpl, ok := lib.Get("git_help")
+	require.True(t, ok)
+	assert.Equal(t, SourceProject, tmpl.Source)
+	_, ok = lib.Get("notes")
+	assert.False(t, ok)
+
+	builtin, ok := lib.Get("code_completion")
+	require.True(t, ok)
+	assert.Equal(t, SourceBuiltin, builtin.Source)
+
+	// File is only read in an {{else if}} branch
+	_, err = lib.Render("error_diagnosis", map[string]string{"Error": "exit status 1"}, nil)
+	require.NoError(t, err)
+
+	_, err = lib.Render("review", map[string]string{}, nil)
+	var missing *MissingVariablesError
+	require.True(t, errors.As(err, &missing))
+	assert.Equal(t, []string{"Diff"}, missing.Missing)
+
+	out, err := lib.Render("review", map[string]string{"diff": "+ fix"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Review this diff for correctness:\n+ fix\n", out)
+}
@@ -0,0 +1,401 @@
+// Package recorder records AI provider HTTP traffic to fixture files and replays it offline
+package recorder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
+)
+
+// Environment variables that enable recording or replaying provider traffic
+const (
+	RecordEnv = "CRAZY_AI_RECORD"
+	ReplayEnv = "CRAZY_AI_REPLAY"
+)
+
+// Redacted replaces secrets in recorded fixtures
+const Redacted = "REDACTED"
+
+// ErrNoFixture is returned in replay mode when no fixture matches a request
+var ErrNoFixture = errors.New("no recorded fixture")
+
+// Mode selects whether the transport records or replays traffic
+type Mode int
+
+const (
+	// ModeRecord forwards requests and saves each exchange as a fixture
+	ModeRecord Mode = iota + 1
+
+	// ModeReplay serves responses from fixtures without touching the network
+	ModeReplay
+)
+
+// Fixture is a recorded HTTP exchange
+type Fixture struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the request half of a fixture
+type RecordedRequest struct {
+	Method string            `json:"method"`
+	URL    string            `json:"url"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+}
+
+// RecordedResponse is the response half of a fixture. Streamed responses
+// (NDJSON or SSE) are kept as one chunk per line or event in Chunks.
+type RecordedResponse struct {
+	Status int               `json:"status"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+	Chunks []string          `json:"chunks,omitempty"`
+}
+
+// sensitiveHeaders are recorded with their value redacted
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"x-api-key":           true,
+	"api-key":             true,
+	"cookie":              true,
+	"set-cookie":          true,
+}
+
+// volatileHeaders change on every run and are left out of fixtures
+var volatileHeaders = map[string]bool{
+	"date":           true,
+	"x-request-id":   true,
+	"content-length": true,
+	"user-agent":     true,
+}
+
+// sensitiveParams are query parameters that carry credentials
+var sensitiveParams = map[string]bool{
+	"key":          true,
+	"api_key":      true,
+	"apikey":       true,
+	"access_token": true,
+	"token":        true,
+}
+
+// Transport is an http.RoundTripper that records or replays provider traffic
+type Transport struct {
+	Mode Mode
+	Dir  string
+	Base http.RoundTripper
+
+	mutex sync.Mutex
+}
+
+// New creates a transport that records to or replays from dir
+func New(mode Mode, dir string, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Mode: mode, Dir: logging.ExpandHome(dir), Base: base}
+}
+
+// FromEnv wraps base in a recording or replaying transport when CRAZY_AI_RECORD
+// or CRAZY_AI_REPLAY is set, and returns base unchanged otherwise
+func FromEnv(base http.RoundTripper) (http.RoundTripper, error) {
+	record := os.Getenv(RecordEnv)
+	replay := os.Getenv(ReplayEnv)
+	switch {
+	case record != "" && replay != "":
+		return nil, fmt.Errorf("%s and %s cannot be used together", RecordEnv, ReplayEnv)
+	case record != "":
+		return New(ModeRecord, record, base), nil
+	case replay != "":
+		return New(ModeReplay, replay, base), nil
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+	path := filepath.Join(t.Dir, FixtureName(req, body))
+
+	if t.Mode == ModeReplay {
+		return t.replay(req, path)
+	}
+
+	// Forward a copy of the request with the buffered body
+	forward := req.Clone(req.Context())
+	forward.Body = io.NopCloser(bytes.NewReader(body))
+	forward.ContentLength = int64(len(body))
+	resp, err := t.Base.RoundTrip(forward)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := Fixture{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    redactURL(req.URL),
+			Header: recordHeader(req.Header),
+			Body:   encodeBody(body),
+		},
+		Response: RecordedResponse{
+			Status: resp.StatusCode,
+			Header: recordHeader(resp.Header),
+		},
+	}
+	resp.Body = &recordingBody{
+		ReadCloser: resp.Body,
+		done: func(data []byte) {
+			if isStream(resp.Header) {
+				fixture.Response.Chunks = splitStream(data, resp.Header)
+			} else {
+				fixture.Response.Body = encodeBody(data)
+			}
+			if err := t.save(path, fixture); err != nil {
+				logging.FromContext(req.Context()).Warn("failed to save fixture", "path", path, "error", err)
+			}
+		},
+	}
+	return resp, nil
+}
+
+// replay serves the fixture at path
+func (t *Transport) replay(req *http.Request, path string) (*http.Response, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w for %s %s (expected %s)", ErrNoFixture, req.Method, req.URL.Path, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+
+	header := make(http.Header, len(fixture.Response.Header))
+	for name, value := range fixture.Response.Header {
+		header.Set(name, value)
+	}
+
+	var body io.ReadCloser
+	if fixture.Response.Chunks != nil {
+		body = &chunkReader{chunks: fixture.Response.Chunks}
+	} else {
+		body = io.NopCloser(bytes.NewReader(decodeBody(fixture.Response.Body)))
+	}
+
+	logging.FromContext(req.Context()).Debug("replaying fixture", "path", path)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.Response.Status, http.StatusText(fixture.Response.Status)),
+		StatusCode:    fixture.Response.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// save writes a fixture with secrets redacted
+func (t *Transport) save(path string, fixture Fixture) error {
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+	data = redactSecrets(data)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// FixtureName returns the file name a request is recorded under. It depends on
+// the method, path, query and body only, so credentials and volatile headers
+// never affect matching.
+func FixtureName(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s?%s\n", req.Method, req.URL.Path, redactQuery(req.URL.Query()).Encode())
+	var compact bytes.Buffer
+	if json.Compact(&compact, body) == nil {
+		hash.Write(compact.Bytes())
+	} else {
+		hash.Write(body)
+	}
+
+	slug := strings.ToLower(req.Method) + strings.ReplaceAll(req.URL.Path, "/", "-")
+	return fmt.Sprintf("%s-%s.json", strings.Trim(slug, "-"), hex.EncodeToString(hash.Sum(nil))[:12])
+}
+
+// recordHeader flattens headers for a fixture, redacting credentials and dropping volatile values
+func recordHeader(h http.Header) map[string]string {
+	header := make(map[string]string)
+	for name, values := range h {
+		lower := strings.ToLower(name)
+		switch {
+		case volatileHeaders[lower]:
+		case sensitiveHeaders[lower]:
+			header[name] = Redacted
+		default:
+			header[name] = strings.Join(values, ", ")
+		}
+	}
+	if len(header) == 0 {
+		return nil
+	}
+	return header
+}
+
+// redactURL returns u as a string with credentials in the query replaced
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = redactQuery(u.Query()).Encode()
+	return redacted.String()
+}
+
+// redactQuery replaces credential query parameters
+func redactQuery(query url.Values) url.Values {
+	for name := range query {
+		if sensitiveParams[strings.ToLower(name)] {
+			query[name] = []string{Redacted}
+		}
+	}
+	return query
+}
+
+// redactSecrets replaces the values of API key and token environment variables anywhere in data
+func redactSecrets(data []byte) []byte {
+	var secrets []string
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		upper := strings.ToUpper(name)
+		if len(value) >= 8 && (strings.HasSuffix(upper, "_API_KEY") || strings.HasSuffix(upper, "_TOKEN")) {
+			secrets = append(secrets, value)
+		}
+	}
+	// Longest first, so a secret containing another is replaced whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, secret := range secrets {
+		data = bytes.ReplaceAll(data, []byte(secret), []byte(Redacted))
+	}
+	return data
+}
+
+// encodeBody keeps JSON bodies readable in fixtures and stores anything else as a JSON string
+func encodeBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	encoded, _ := json.Marshal(string(body))
+	return encoded
+}
+
+// decodeBody reverses encodeBody
+func decodeBody(body json.RawMessage) []byte {
+	var text string
+	if len(body) > 0 && body[0] == '"' && json.Unmarshal(body, &text) == nil {
+		return []byte(text)
+	}
+	return body
+}
+
+// isStream reports whether a response is streamed as NDJSON or server-sent events
+func isStream(h http.Header) bool {
+	contentType := strings.ToLower(h.Get("Content-Type"))
+	return strings.Contains(contentType, "ndjson") || strings.Contains(contentType, "event-stream")
+}
+
+// splitStream splits a streamed body into lines (NDJSON) or events (SSE), keeping separators
+func splitStream(data []byte, h http.Header) []string {
+	separator := "\n"
+	if strings.Contains(strings.ToLower(h.Get("Content-Type")), "event-stream") {
+		separator = "\n\n"
+	}
+	chunks := strings.SplitAfter(string(data), separator)
+	if chunks[len(chunks)-1] == "" {
+		chunks = chunks[:len(chunks)-1]
+	}
+	return chunks
+}
+
+// recordingBody captures a response body as it is read and hands it over once when done
+type recordingBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	done func([]byte)
+	once sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	// Drain what the caller left unread, e.g. after the final stream message
+	io.Copy(&b.buf, b.ReadCloser)
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+func (b *recordingBody) finish() {
+	b.once.Do(func() { b.done(b.buf.Bytes()) })
+}
+
+// chunkReader replays a recorded stream one chunk per read, like a live stream
+type chunkReader struct {
+	chunks  []string
+	pending string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.pending == "" {
+		if len(r.chunks) == 0 {
+			return 0, io.EOF
+		}
+		r.pending, r.chunks = r.chunks[0], r.chunks[1:]
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *chunkReader) Close() error { return nil }
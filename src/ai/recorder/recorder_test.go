@@ -0,0 +1,86 @@
+package recorder
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const streamBody = "{\"message\":{\"content\":\"Hel\"},\"done\":false}\n" +
+	"{\"message\":{\"content\":\"lo\"},\"done\":true}\n"
+
+func post(t *testing.T, client *http.Client, url string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest("POST", url+"/api/chat?key=sk-query-secret", bytes.NewBufferString(`{"model": "llama3.2", "stream": true}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer sk-test-secret-123")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestTransport_RecordAndReplayStream(t *testing.T) {
+	t.Setenv("CRAZY_TEST_API_KEY", "sk-test-secret-123")
+	dir := t.TempDir()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, streamBody)
+	}))
+
+	recording := &http.Client{Transport: New(ModeRecord, dir, nil)}
+	_, body := post(t, recording, server.URL)
+	assert.Equal(t, streamBody, body)
+	server.Close()
+
+	files, err := filepath.Glob(filepath.Join(dir, "post-api-chat-*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	fixture, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(fixture), "sk-test-secret-123")
+	assert.NotContains(t, string(fixture), "sk-query-secret")
+	assert.Contains(t, string(fixture), `"chunks"`)
+
+	// The server is gone, the response now comes from the fixture
+	replaying := &http.Client{Transport: New(ModeReplay, dir, nil)}
+	resp, body := post(t, replaying, server.URL)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	assert.Equal(t, streamBody, body)
+}
+
+func TestTransport_ReplayMiss(t *testing.T) {
+	client := &http.Client{Transport: New(ModeReplay, t.TempDir(), nil)}
+	_, err := client.Get("http://localhost:11434/api/tags")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrNoFixture))
+}
+
+func TestFromEnv(t *testing.T) {
+	base := http.DefaultTransport
+
+	transport, err := FromEnv(base)
+	require.NoError(t, err)
+	assert.Equal(t, base, transport)
+
+	t.Setenv(ReplayEnv, t.TempDir())
+	transport, err = FromEnv(base)
+	require.NoError(t, err)
+	assert.Equal(t, ModeReplay, transport.(*Transport).Mode)
+
+	t.Setenv(RecordEnv, t.TempDir())
+	_, err = FromEnv(base)
+	assert.Error(t, err)
+}
@@ -0,0 +1,223 @@
+// Package redact replaces secrets in text with stable placeholders before it
+// is sent to a provider outside the machine
+package redact
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Kinds of secrets found by the redactor
+const (
+	KindPrivateKey  = "private_key"
+	KindAWSKey      = "aws_access_key"
+	KindGitHubToken = "github_token"
+	KindSlackToken  = "slack_token"
+	KindGoogleKey   = "google_api_key"
+	KindStripeKey   = "stripe_key"
+	KindAPIKey      = "api_key"
+	KindJWT         = "jwt"
+	KindBearer      = "bearer_token"
+	KindURLPassword = "url_password"
+	KindAssignment  = "secret_value"
+	KindHighEntropy = "high_entropy"
+)
+
+// Thresholds for strings that match no known format
+const (
+	minEntropyLength = 24
+	minEntropy       = 4.0
+)
+
+// rule finds one kind of secret. When the pattern has a group named "secret"
+// only that part of the match is replaced, e.g. the password of a URL. Matches
+// for which skip returns true are left alone.
+type rule struct {
+	kind    string
+	pattern *regexp.Regexp
+	skip    func(secret string) bool
+}
+
+// rules are applied in order, specific formats before generic ones
+var rules = []rule{
+	{KindPrivateKey, regexp.MustCompile(`-----BEGIN[A-Z ]*PRIVATE KEY-----[\s\S]*?-----END[A-Z ]*PRIVATE KEY-----`), nil},
+	{KindAWSKey, regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), nil},
+	{KindGitHubToken, regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`), nil},
+	{KindSlackToken, regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`), nil},
+	{KindGoogleKey, regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`), nil},
+	{KindStripeKey, regexp.MustCompile(`\b[rs]k_(?:live|test)_[0-9A-Za-z]{16,}`), nil},
+	{KindAPIKey, regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`), nil},
+	{KindJWT, regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`), nil},
+	{KindBearer, regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/-]{16,}=*)`), nil},
+	{KindURLPassword, regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]*:(?P<secret>[^/\s@]+)@`), nil},
+	{KindAssignment, regexp.MustCompile(`(?i)(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|auth[_-]?key|credentials?)[A-Za-z0-9_]*["']?\s*[:=]\s*["']?(?P<secret>[^\s"',;]{6,})`), notASecretValue},
+}
+
+// candidatePattern finds strings that may be secrets in no known format
+var candidatePattern = regexp.MustCompile(`[A-Za-z0-9+_=-]{24,}`)
+
+// Finding is a secret that was replaced
+type Finding struct {
+	Kind        string `json:"kind"`
+	Placeholder string `json:"placeholder"`
+}
+
+// Summary counts findings by kind
+type Summary map[string]int
+
+// placeholders numbers the secrets seen by this process, per kind. Secrets
+// are keyed by their digest so the map holds no plaintext.
+var placeholders = struct {
+	sync.Mutex
+	numbers map[[sha256.Size]byte]int
+	counts  map[string]int
+}{
+	numbers: make(map[[sha256.Size]byte]int),
+	counts:  make(map[string]int),
+}
+
+// Placeholder returns the placeholder of a secret. Secrets are numbered in the
+// order they are first seen, so the same value reads the same across messages
+// and requests of a process while the placeholder reveals nothing about it.
+func Placeholder(kind, secret string) string {
+	key := sha256.Sum256([]byte(kind + "\x00" + secret))
+
+	placeholders.Lock()
+	defer placeholders.Unlock()
+	n, ok := placeholders.numbers[key]
+	if !ok {
+		placeholders.counts[kind]++
+		n = placeholders.counts[kind]
+		placeholders.numbers[key] = n
+	}
+	return fmt.Sprintf("[REDACTED:%s:%d]", kind, n)
+}
+
+// Text replaces the secrets in text and returns what was found
+func Text(text string) (string, []Finding) {
+	var findings []Finding
+	for _, r := range rules {
+		text = replace(text, r, &findings)
+	}
+	text = candidatePattern.ReplaceAllStringFunc(text, func(candidate string) string {
+		if !looksRandom(candidate) {
+			return candidate
+		}
+		placeholder := Placeholder(KindHighEntropy, candidate)
+		findings = append(findings, Finding{Kind: KindHighEntropy, Placeholder: placeholder})
+		return placeholder
+	})
+	return text, findings
+}
+
+// replace applies one rule, replacing either the whole match or its secret group
+func replace(text string, r rule, findings *[]Finding) string {
+	group := r.pattern.SubexpIndex("secret")
+	matches := r.pattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if group > 0 {
+			start, end = m[2*group], m[2*group+1]
+		}
+		secret := text[start:end]
+		if start < last || insidePlaceholder(text, m[0]) || strings.HasPrefix(secret, "[REDACTED:") || (r.skip != nil && r.skip(secret)) {
+			continue
+		}
+		placeholder := Placeholder(r.kind, secret)
+		*findings = append(*findings, Finding{Kind: r.kind, Placeholder: placeholder})
+		sb.WriteString(text[last:start])
+		sb.WriteString(placeholder)
+		last = end
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// insidePlaceholder reports whether offset falls inside a placeholder of an
+// earlier rule, whose kind would otherwise look like an assignment
+func insidePlaceholder(text string, offset int) bool {
+	open := strings.LastIndex(text[:offset], "[REDACTED:")
+	return open >= 0 && !strings.Contains(text[open:offset], "]")
+}
+
+// notASecretValue reports whether an assigned value is a number, a literal
+// or an expression such as a function call or variable reference
+func notASecretValue(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "false", "null", "none", "nil", "undefined":
+		return true
+	}
+	if strings.ContainsAny(value, "()$") {
+		return true
+	}
+	return strings.Trim(value, "0123456789.") == ""
+}
+
+// looksRandom reports whether s mixes letter cases and digits with high
+// entropy, like a generated key. Words, identifiers and hex hashes do not.
+func looksRandom(s string) bool {
+	if len(s) < minEntropyLength {
+		return false
+	}
+	var upper, lower, digit bool
+	for _, c := range s {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+	}
+	return upper && lower && digit && entropy(s) >= minEntropy
+}
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+	}
+	var bits float64
+	n := float64(len(s))
+	for _, count := range counts {
+		p := float64(count) / n
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// Summarize counts findings by kind
+func Summarize(findings []Finding) Summary {
+	summary := make(Summary)
+	for _, f := range findings {
+		summary[f.Kind]++
+	}
+	return summary
+}
+
+// String renders the summary as "2 api_key, 1 url_password"
+func (s Summary) String() string {
+	kinds := make([]string, 0, len(s))
+	for kind := range s {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		parts[i] = fmt.Sprintf("%d %s", s[kind], kind)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,204 @@
+package scripted
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+)
+
+// ErrNoRule is returned when no rule matches a request and the script has no fallback
+var ErrNoRule = errors.New("no scripted response")
+
+// Client answers requests from a script. It implements types.OllamaClient.
+type Client struct {
+	script *Script
+}
+
+// NewClient creates a client serving the given script
+func NewClient(script *Script) *Client {
+	return &Client{script: script}
+}
+
+// NewClientFromFile creates a client serving the script at path
+func NewClientFromFile(path string) (*Client, error) {
+	script, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(script), nil
+}
+
+// Complete answers a completion prompt
+func (c *Client) Complete(ctx context.Context, model string, prompt string, opts types.CompletionOptions) (*types.AIResponse, error) {
+	return c.respond(ctx, model, prompt, estimateTokens(prompt), nil)
+}
+
+// Chat answers the last user message
+func (c *Client) Chat(ctx context.Context, model string, messages []types.Message, opts types.ChatOptions) (*types.AIResponse, error) {
+	resp, err := c.respond(ctx, model, lastUserMessage(messages), estimateMessages(messages), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Messages = append(append([]types.Message{}, messages...), types.Message{Role: "assistant", Content: resp.Text})
+	return resp, nil
+}
+
+// StreamChat answers the last user message, streaming the response in chunks
+func (c *Client) StreamChat(ctx context.Context, model string, messages []types.Message, opts types.ChatOptions, callback func(chunk string) error) (*types.AIResponse, error) {
+	resp, err := c.respond(ctx, model, lastUserMessage(messages), estimateMessages(messages), callback)
+	if err != nil {
+		return nil, err
+	}
+	resp.Messages = append(append([]types.Message{}, messages...), types.Message{Role: "assistant", Content: resp.Text})
+	return resp, nil
+}
+
+// GetEmbedding is not supported by scripts
+func (c *Client) GetEmbedding(ctx context.Context, text string, model string) ([]float32, error) {
+	return nil, fmt.Errorf("embeddings are not supported by the scripted provider")
+}
+
+// ListModels lists the models declared by the script
+func (c *Client) ListModels(ctx context.Context) ([]types.ModelInfo, error) {
+	models := make([]types.ModelInfo, 0, len(c.script.Models))
+	for i, m := range c.script.Models {
+		models = append(models, types.ModelInfo{
+			Name:        m.Name,
+			Provider:    types.ProviderScripted,
+			Type:        types.ModelTypeChat,
+			Description: m.Description,
+			Installed:   true,
+			Default:     i == 0,
+		})
+	}
+	return models, nil
+}
+
+// CheckModelAvailability reports whether the script serves model
+func (c *Client) CheckModelAvailability(ctx context.Context, model string) (bool, error) {
+	return c.script.HasModel(model), nil
+}
+
+// InstallModel is a no-op, scripted models are always available
+func (c *Client) InstallModel(ctx context.Context, model string) error {
+	if !c.script.HasModel(model) {
+		return types.NewProviderError(types.ErrModelNotFound, string(types.ProviderScripted), model, "not declared by the script")
+	}
+	return nil
+}
+
+// respond finds the rule for input and plays it back, streaming to callback when set
+func (c *Client) respond(ctx context.Context, model, input string, promptTokens int, callback func(chunk string) error) (*types.AIResponse, error) {
+	start := time.Now()
+
+	rule, ok := c.script.Find(model, input)
+	if !ok {
+		return nil, fmt.Errorf("%w for %q", ErrNoRule, truncate(input, 60))
+	}
+
+	delay := c.script.Delay
+	if rule.Delay != nil {
+		delay = *rule.Delay
+	}
+	firstTokenDelay := c.script.FirstTokenDelay
+	if rule.FirstTokenDelay != nil {
+		firstTokenDelay = *rule.FirstTokenDelay
+	}
+
+	if err := sleep(ctx, firstTokenDelay); err != nil {
+		return nil, err
+	}
+	if rule.Error != "" && (callback == nil || rule.ErrorAfter <= 0) {
+		return nil, rule.err(model)
+	}
+
+	if callback != nil {
+		for i, chunk := range rule.chunks() {
+			if rule.Error != "" && i == rule.ErrorAfter {
+				return nil, rule.err(model)
+			}
+			if i > 0 {
+				if err := sleep(ctx, delay); err != nil {
+					return nil, err
+				}
+			}
+			if err := callback(chunk); err != nil {
+				return nil, fmt.Errorf("streaming callback failed: %w", err)
+			}
+		}
+		if rule.Error != "" {
+			return nil, rule.err(model)
+		}
+	}
+
+	text := rule.text()
+	usage := types.AIUsage{PromptTokens: promptTokens, CompletionTokens: estimateTokens(text)}
+	if rule.Usage != nil {
+		usage.PromptTokens = rule.Usage.PromptTokens
+		usage.CompletionTokens = rule.Usage.CompletionTokens
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	return &types.AIResponse{
+		Text:             text,
+		FinishReason:     types.NormalizeFinishReason(rule.FinishReason),
+		Usage:            usage,
+		SelectedModel:    model,
+		SelectedProvider: string(types.ProviderScripted),
+		Model:            model,
+		Provider:         string(types.ProviderScripted),
+		Latency:          time.Since(start),
+	}, nil
+}
+
+// sleep waits for d unless ctx is done first
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// lastUserMessage returns the content of the most recent user message
+func lastUserMessage(messages []types.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// estimateMessages estimates the prompt tokens of a conversation
+func estimateMessages(messages []types.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += estimateTokens(msg.Content)
+	}
+	return total
+}
+
+// estimateTokens assumes about four characters per token
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// truncate shortens s to at most n runes for error messages
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
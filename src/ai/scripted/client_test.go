@@ -0,0 +1,94 @@
+package scripted
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+)
+
+const testScript = `
+models:
+  - name: demo
+    description: Demo model
+delay: 1ms
+rules:
+  - match: "(?i)^list files"
+    response: "ls -la"
+    usage: {prompt_tokens: 7, completion_tokens: 2}
+  - contains: "deploy"
+    chunks: ["Deploying ", "now"]
+    error: "rate limited"
+    error_after: 1
+  - contains: "broken"
+    error: "model unavailable"
+fallback:
+  response: "I have no scripted answer for that."
+`
+
+func chat(content string) []types.Message {
+	return []types.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: content},
+	}
+}
+
+func TestClient_ChatMatchesRules(t *testing.T) {
+	script, err := Parse([]byte(testScript))
+	require.NoError(t, err)
+	client := NewClient(script)
+	ctx := context.Background()
+
+	resp, err := client.Chat(ctx, "demo", chat("List files in this dir"), types.ChatOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "ls -la", resp.Text)
+	assert.Equal(t, string(types.ProviderScripted), resp.SelectedProvider)
+	assert.Equal(t, 9, resp.Usage.TotalTokens)
+
+	resp, err = client.Chat(ctx, "demo", chat("something else"), types.ChatOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "I have no scripted answer for that.", resp.Text)
+
+	_, err = client.Chat(ctx, "demo", chat("it is broken"), types.ChatOptions{})
+	assert.EqualError(t, err, "model unavailable")
+}
+
+func TestClient_StreamInjectsErrorMidStream(t *testing.T) {
+	script, err := Parse([]byte(testScript))
+	require.NoError(t, err)
+
+	var chunks []string
+	_, err = NewClient(script).StreamChat(context.Background(), "demo", chat("deploy please"), types.ChatOptions{}, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	assert.EqualError(t, err, "rate limited")
+	assert.Equal(t, []string{"Deploying "}, chunks)
+}
+
+func TestClient_StreamHonoursCancellation(t *testing.T) {
+	script, err := Parse([]byte(`{"delay": "1s", "rules": [{"contains": "hi", "response": "a b c"}]}`))
+	require.NoError(t, err)
+	assert.True(t, script.HasModel(DefaultModel))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = NewClient(script).StreamChat(ctx, DefaultModel, chat("hi"), types.ChatOptions{}, func(string) error { return nil })
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	_, err = NewClient(script).Chat(context.Background(), DefaultModel, chat("bye"), types.ChatOptions{})
+	assert.True(t, errors.Is(err, ErrNoRule))
+}
+
+func TestParse_RejectsInvalidRules(t *testing.T) {
+	_, err := Parse([]byte("rules:\n  - response: no pattern\n"))
+	assert.Error(t, err)
+
+	_, err = Parse([]byte("rules:\n  - match: \"(\"\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,198 @@
+// Package scripted provides an offline AI provider that answers from a script of
+// pattern → response rules, for demos, shell integration tests and CI
+package scripted
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+)
+
+// DefaultModel is the model served when a script declares none
+const DefaultModel = "scripted"
+
+// Script is a set of rules loaded from a YAML or JSON file
+type Script struct {
+	// Models are the model names served by the script
+	Models []Model `yaml:"models" json:"models"`
+
+	// Delay is the default pause between streamed chunks
+	Delay time.Duration `yaml:"delay" json:"delay"`
+
+	// FirstTokenDelay is the default pause before the first chunk
+	FirstTokenDelay time.Duration `yaml:"first_token_delay" json:"first_token_delay"`
+
+	// Rules are tried in order, the first matching rule answers
+	Rules []Rule `yaml:"rules" json:"rules"`
+
+	// Fallback answers requests no rule matches. Without it such requests fail.
+	Fallback *Rule `yaml:"fallback" json:"fallback"`
+}
+
+// Model is a model advertised by the script
+type Model struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+}
+
+// Rule maps a request pattern to a scripted response
+type Rule struct {
+	// Match is a regular expression tested against the last user message or prompt
+	Match string `yaml:"match" json:"match"`
+
+	// Contains is a case-insensitive substring tested against the same input
+	Contains string `yaml:"contains" json:"contains"`
+
+	// Model restricts the rule to one model
+	Model string `yaml:"model" json:"model"`
+
+	// Response is the reply text
+	Response string `yaml:"response" json:"response"`
+
+	// Chunks overrides how the response is split when streaming
+	Chunks []string `yaml:"chunks" json:"chunks"`
+
+	// Delay and FirstTokenDelay override the script defaults
+	Delay           *time.Duration `yaml:"delay" json:"delay"`
+	FirstTokenDelay *time.Duration `yaml:"first_token_delay" json:"first_token_delay"`
+
+	// FinishReason is reported with the response, "stop" by default
+	FinishReason string `yaml:"finish_reason" json:"finish_reason"`
+
+	// Usage overrides the estimated token counts
+	Usage *Usage `yaml:"usage" json:"usage"`
+
+	// Error makes the request fail with this message
+	Error string `yaml:"error" json:"error"`
+
+	// ErrorAfter streams this many chunks before failing with Error
+	ErrorAfter int `yaml:"error_after" json:"error_after"`
+
+	// Status classifies Error like an HTTP status of a real provider, e.g.
+	// 429 for a rate limit or 404 for a missing model
+	Status int `yaml:"status" json:"status"`
+
+	pattern *regexp.Regexp
+}
+
+// Usage is the token usage reported for a rule
+type Usage struct {
+	PromptTokens     int `yaml:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int `yaml:"completion_tokens" json:"completion_tokens"`
+}
+
+// Load reads a script from a YAML or JSON file
+func Load(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	script, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid script %s: %w", path, err)
+	}
+	return script, nil
+}
+
+// Parse parses a script. JSON is accepted as it is a subset of YAML.
+func Parse(data []byte) (*Script, error) {
+	var script Script
+	if err := yaml.Unmarshal(data, &script); err != nil {
+		return nil, err
+	}
+
+	if len(script.Models) == 0 {
+		script.Models = []Model{{Name: DefaultModel}}
+	}
+	for i := range script.Models {
+		if script.Models[i].Description == "" {
+			script.Models[i].Description = "Scripted offline responses"
+		}
+	}
+
+	for i := range script.Rules {
+		rule := &script.Rules[i]
+		if rule.Match == "" && rule.Contains == "" {
+			return nil, fmt.Errorf("rule %d has neither match nor contains", i+1)
+		}
+		if rule.Match != "" {
+			pattern, err := regexp.Compile(rule.Match)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid match pattern: %w", i+1, err)
+			}
+			rule.pattern = pattern
+		}
+	}
+	return &script, nil
+}
+
+// HasModel reports whether the script serves model
+func (s *Script) HasModel(model string) bool {
+	for _, m := range s.Models {
+		if m.Name == model {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the rule answering input for model, or the fallback rule
+func (s *Script) Find(model, input string) (*Rule, bool) {
+	for i := range s.Rules {
+		if s.Rules[i].matches(model, input) {
+			return &s.Rules[i], true
+		}
+	}
+	if s.Fallback != nil {
+		return s.Fallback, true
+	}
+	return nil, false
+}
+
+// matches reports whether the rule applies to input sent to model
+func (r *Rule) matches(model, input string) bool {
+	if r.Model != "" && r.Model != model {
+		return false
+	}
+	if r.pattern != nil && !r.pattern.MatchString(input) {
+		return false
+	}
+	if r.Contains != "" && !strings.Contains(strings.ToLower(input), strings.ToLower(r.Contains)) {
+		return false
+	}
+	return true
+}
+
+// chunks returns the pieces the response is streamed in, one word each by default
+func (r *Rule) chunks() []string {
+	if len(r.Chunks) > 0 {
+		return r.Chunks
+	}
+	if r.Response == "" {
+		return nil
+	}
+	return strings.SplitAfter(r.Response, " ")
+}
+
+// text returns the full response text
+func (r *Rule) text() string {
+	if len(r.Chunks) > 0 {
+		return strings.Join(r.Chunks, "")
+	}
+	return r.Response
+}
+
+// err returns the rule's error, classified when it declares a status
+func (r *Rule) err(model string) error {
+	if r.Status == 0 {
+		return errors.New(r.Error)
+	}
+	return types.ErrorFromStatus(string(types.ProviderScripted), model, r.Status, nil, []byte(r.Error))
+}
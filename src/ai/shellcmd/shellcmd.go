@@ -0,0 +1,235 @@
+// Package shellcmd extracts shell commands suggested by models and describes
+// the environment they will run in, so the model writes commands for the
+// right shell and tools.
+package shellcmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Suggestion is a command proposed by a model with its explanation
+type Suggestion struct {
+	Command     string `json:"command" yaml:"command"`
+	Explanation string `json:"explanation" yaml:"explanation"`
+}
+
+// Parse extracts the command from an answer. The command is the first fenced
+// code block, or else the first line that is not prose; the rest of the
+// answer is the explanation.
+func Parse(answer string) Suggestion {
+	answer = strings.TrimSpace(strings.ReplaceAll(answer, "\r\n", "\n"))
+
+	if start := strings.Index(answer, "```"); start >= 0 {
+		body := answer[start+3:]
+		// Skip the language of the block
+		if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+			body = body[nl+1:]
+		}
+		end := strings.Index(body, "```")
+		if end < 0 {
+			end = len(body)
+		}
+		command := stripPrompts(body[:end])
+		rest := ""
+		if end < len(body) {
+			rest = body[end+3:]
+		}
+		return Suggestion{
+			Command:     command,
+			Explanation: strings.TrimSpace(strings.TrimSpace(answer[:start]) + "\n\n" + strings.TrimSpace(rest)),
+		}
+	}
+
+	// Without a block, a first line in backquotes, after a "$ " prompt or
+	// set apart from the rest is the command
+	first, rest, _ := strings.Cut(answer, "\n")
+	first = strings.TrimSpace(first)
+	switch {
+	case len(first) > 2 && strings.HasPrefix(first, "`") && strings.HasSuffix(first, "`"):
+		first = strings.Trim(first, "`")
+	case strings.HasPrefix(first, "$ "):
+	case rest == "" || strings.HasPrefix(rest, "\n"):
+	default:
+		return Suggestion{Explanation: answer}
+	}
+	return Suggestion{Command: stripPrompts(first), Explanation: strings.TrimSpace(rest)}
+}
+
+// stripPrompts removes "$ " prompts the model may put before command lines
+func stripPrompts(command string) string {
+	lines := strings.Split(strings.TrimSpace(command), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, "$ ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// Shell returns the name of the user's shell: name when set, else the one in
+// $SHELL, else sh
+func Shell(name string) string {
+	if name != "" {
+		return name
+	}
+	if shell := filepath.Base(os.Getenv("SHELL")); shell != "." && shell != "/" {
+		return shell
+	}
+	return "sh"
+}
+
+// OS describes the operating system, with the distribution on Linux since it
+// decides which tools are installed
+func OS() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "macOS (BSD userland)"
+	case "linux":
+		if distro := osRelease("/etc/os-release"); distro != "" {
+			return "Linux (" + distro + ")"
+		}
+		return "Linux"
+	default:
+		return runtime.GOOS
+	}
+}
+
+// osRelease returns the PRETTY_NAME of an os-release file
+func osRelease(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "PRETTY_NAME="); ok {
+			return strings.Trim(value, `"'`)
+		}
+	}
+	return ""
+}
+
+// ListDir lists the entries of dir, directories with a trailing slash, up to
+// max entries
+func ListDir(dir string, max int) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	var sb strings.Builder
+	for i, entry := range entries {
+		if i == max {
+			fmt.Fprintf(&sb, "... and %d more\n", len(entries)-max)
+			break
+		}
+		sb.WriteString(entry.Name())
+		if entry.IsDir() {
+			sb.WriteString("/")
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+// maxMentionedSize skips mentioned files too large to be source code
+const maxMentionedSize = 1024 * 1024
+
+// FileRef is a file mentioned by a command or its error output, with the
+// line the error points at when there is one
+type FileRef struct {
+	Path string
+	Line int
+}
+
+// Patterns of file mentions: "path/file.ext:12:5" as printed by compilers
+// and linters, and File "file.py", line 12 in Python tracebacks
+var (
+	pathMention   = regexp.MustCompile(`(?:^|[\s'"(\[=])((?:~|\.{1,2})?[\w@%+\-./]*[\w\-]\.[A-Za-z][\w]{0,9})(?::(\d+))?`)
+	pythonMention = regexp.MustCompile(`File "([^"]+)", line (\d+)`)
+)
+
+// MentionedFiles returns up to max existing regular files mentioned in
+// text, resolved against dir, in the order they first appear. Files outside
+// root, directly or through a symlink, are left out, so an error about
+// /etc/hosts or a key file elsewhere is not sent along.
+func MentionedFiles(dir, root, text string, max int) []FileRef {
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	root = filepath.Clean(root)
+
+	var refs []FileRef
+	seen := make(map[string]bool)
+	add := func(path, line string) {
+		if len(refs) == max {
+			return
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		path = filepath.Clean(path)
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil || !within(root, real) {
+			return
+		}
+		if info, err := os.Stat(real); err != nil || !info.Mode().IsRegular() || info.Size() > maxMentionedSize {
+			return
+		}
+		n, _ := strconv.Atoi(line)
+		refs = append(refs, FileRef{Path: path, Line: n})
+	}
+
+	for _, m := range pythonMention.FindAllStringSubmatch(text, -1) {
+		add(m[1], m[2])
+	}
+	for _, m := range pathMention.FindAllStringSubmatch(text, -1) {
+		add(m[1], m[2])
+	}
+	return refs
+}
+
+// within reports whether path is root or inside it
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Snippet returns the lines of a file around ref.Line, or its first lines
+// when no line is known, numbered for the model
+func Snippet(ref FileRef, context int) (string, error) {
+	data, err := os.ReadFile(ref.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", ref.Path, err)
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return "", fmt.Errorf("%s is not a text file", ref.Path)
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+
+	first, last := 1, min(len(lines), 2*context)
+	if ref.Line > 0 && ref.Line <= len(lines) {
+		first, last = max(ref.Line-context, 1), min(ref.Line+context, len(lines))
+	}
+	var sb strings.Builder
+	for n := first; n <= last; n++ {
+		marker := " "
+		if n == ref.Line {
+			marker = ">"
+		}
+		fmt.Fprintf(&sb, "%s%5d  %s\n", marker, n, lines[n-1])
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,230 @@
+// Package transport builds the HTTP clients used to reach AI providers from
+// per-provider settings: proxy, TLS, extra headers, timeouts and pool sizes
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/recorder"
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
+)
+
+// DefaultKey names the settings applied to every provider
+const DefaultKey = "default"
+
+// ProxyDirect disables the proxy, including one set in the environment
+const ProxyDirect = "direct"
+
+// Config is the HTTP transport of a provider. Zero values keep the defaults
+// of Go's http.DefaultTransport.
+//
+// The timeouts cover connecting and waiting for the response headers only:
+// how long a streamed answer may take is up to the request's context.
+type Config struct {
+	// Proxy is the URL of the proxy, ProxyDirect for none. When empty the
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY variables apply.
+	Proxy string `mapstructure:"proxy" yaml:"proxy,omitempty" json:"proxy,omitempty"`
+
+	// CABundle is a PEM file of certificates trusted besides the system's
+	CABundle string `mapstructure:"ca_bundle" yaml:"ca_bundle,omitempty" json:"ca_bundle,omitempty"`
+
+	// ClientCert and ClientKey are PEM files for mutual TLS. The key may be
+	// in the certificate file.
+	ClientCert string `mapstructure:"client_cert" yaml:"client_cert,omitempty" json:"client_cert,omitempty"`
+	ClientKey  string `mapstructure:"client_key" yaml:"client_key,omitempty" json:"client_key,omitempty"`
+
+	// Headers are added to every request. Values may reference environment
+	// variables, e.g. "Bearer ${OLLAMA_TOKEN}".
+	Headers map[string]string `mapstructure:"headers" yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	ConnectTimeout        time.Duration `mapstructure:"connect_timeout" yaml:"connect_timeout,omitempty" json:"connect_timeout,omitempty"`
+	TLSHandshakeTimeout   time.Duration `mapstructure:"tls_handshake_timeout" yaml:"tls_handshake_timeout,omitempty" json:"tls_handshake_timeout,omitempty"`
+	ResponseHeaderTimeout time.Duration `mapstructure:"response_header_timeout" yaml:"response_header_timeout,omitempty" json:"response_header_timeout,omitempty"`
+
+	// IdleTimeout closes keep-alive connections unused for this long
+	IdleTimeout time.Duration `mapstructure:"idle_timeout" yaml:"idle_timeout,omitempty" json:"idle_timeout,omitempty"`
+
+	// Keep-alive pool sizes, MaxConnsPerHost 0 is unlimited
+	MaxIdleConns        int `mapstructure:"max_idle_conns" yaml:"max_idle_conns,omitempty" json:"max_idle_conns,omitempty"`
+	MaxIdleConnsPerHost int `mapstructure:"max_idle_conns_per_host" yaml:"max_idle_conns_per_host,omitempty" json:"max_idle_conns_per_host,omitempty"`
+	MaxConnsPerHost     int `mapstructure:"max_conns_per_host" yaml:"max_conns_per_host,omitempty" json:"max_conns_per_host,omitempty"`
+}
+
+// For returns the settings of a provider: its own on top of the defaults
+func For(configs map[string]Config, provider string) Config {
+	return configs[DefaultKey].Merge(configs[provider])
+}
+
+// Merge returns c with the fields set in override replaced. Headers are
+// combined, override winning.
+func (c Config) Merge(override Config) Config {
+	merged := c
+	if override.Proxy != "" {
+		merged.Proxy = override.Proxy
+	}
+	if override.CABundle != "" {
+		merged.CABundle = override.CABundle
+	}
+	if override.ClientCert != "" {
+		merged.ClientCert = override.ClientCert
+		merged.ClientKey = override.ClientKey
+	}
+	if len(override.Headers) > 0 {
+		merged.Headers = make(map[string]string, len(c.Headers)+len(override.Headers))
+		for name, value := range c.Headers {
+			merged.Headers[name] = value
+		}
+		for name, value := range override.Headers {
+			merged.Headers[name] = value
+		}
+	}
+	if override.ConnectTimeout != 0 {
+		merged.ConnectTimeout = override.ConnectTimeout
+	}
+	if override.TLSHandshakeTimeout != 0 {
+		merged.TLSHandshakeTimeout = override.TLSHandshakeTimeout
+	}
+	if override.ResponseHeaderTimeout != 0 {
+		merged.ResponseHeaderTimeout = override.ResponseHeaderTimeout
+	}
+	if override.IdleTimeout != 0 {
+		merged.IdleTimeout = override.IdleTimeout
+	}
+	if override.MaxIdleConns != 0 {
+		merged.MaxIdleConns = override.MaxIdleConns
+	}
+	if override.MaxIdleConnsPerHost != 0 {
+		merged.MaxIdleConnsPerHost = override.MaxIdleConnsPerHost
+	}
+	if override.MaxConnsPerHost != 0 {
+		merged.MaxConnsPerHost = override.MaxConnsPerHost
+	}
+	return merged
+}
+
+// NewClient returns an HTTP client for a provider. It has no overall
+// timeout, so streams last as long as the request's context allows.
+// Traffic is recorded or replayed when the recorder's variables are set.
+func NewClient(cfg Config) (*http.Client, error) {
+	base, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := recorder.FromEnv(base)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+// NewTransport builds the round tripper described by cfg
+func NewTransport(cfg Config) (http.RoundTripper, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	switch cfg.Proxy {
+	case "":
+	case ProxyDirect:
+		t.Proxy = nil
+	default:
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", cfg.Proxy)
+		}
+		t.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if cfg.CABundle != "" || cfg.ClientCert != "" {
+		tlsConfig, err := tlsConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = tlsConfig
+	}
+
+	if cfg.ConnectTimeout > 0 {
+		dialer := &net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: 30 * time.Second}
+		t.DialContext = dialer.DialContext
+	}
+	if cfg.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	}
+	if cfg.ResponseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	}
+	if cfg.IdleTimeout > 0 {
+		t.IdleConnTimeout = cfg.IdleTimeout
+	}
+	if cfg.MaxIdleConns > 0 {
+		t.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = cfg.MaxConnsPerHost
+	}
+
+	if len(cfg.Headers) == 0 {
+		return t, nil
+	}
+	return &headerTransport{base: t, headers: cfg.Headers}, nil
+}
+
+// tlsConfig loads the CA bundle and client certificate of cfg
+func tlsConfig(cfg Config) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CABundle != "" {
+		path := logging.ExpandHome(cfg.CABundle)
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+		}
+		config.RootCAs = pool
+	}
+
+	if cfg.ClientCert != "" {
+		certFile := logging.ExpandHome(cfg.ClientCert)
+		keyFile := certFile
+		if cfg.ClientKey != "" {
+			keyFile = logging.ExpandHome(cfg.ClientKey)
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// headerTransport adds configured headers to requests that do not set them
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		if req.Header.Get(name) == "" {
+			req.Header.Set(name, os.ExpandEnv(value))
+		}
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFor_MergesProviderOverDefault(t *testing.T) {
+	configs := map[string]Config{
+		DefaultKey: {
+			ConnectTimeout: 10 * time.Second,
+			IdleTimeout:    90 * time.Second,
+			Headers:        map[string]string{"X-Team": "platform", "X-Env": "dev"},
+		},
+		"ollama": {
+			ConnectTimeout: 2 * time.Second,
+			Headers:        map[string]string{"X-Env": "prod"},
+		},
+	}
+
+	cfg := For(configs, "ollama")
+	assert.Equal(t, 2*time.Second, cfg.ConnectTimeout)
+	assert.Equal(t, 90*time.Second, cfg.IdleTimeout)
+	assert.Equal(t, map[string]string{"X-Team": "platform", "X-Env": "prod"}, cfg.Headers)
+	assert.Equal(t, "dev", configs[DefaultKey].Headers["X-Env"], "the defaults must not change")
+
+	assert.Equal(t, configs[DefaultKey], For(configs, "openai"))
+	assert.Equal(t, Config{}, For(nil, "openai"))
+}
+
+func TestNewClient_AddsHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	t.Setenv("CRAZY_TEST_TOKEN", "s3cret")
+	client, err := NewClient(Config{Headers: map[string]string{
+		"Authorization": "Bearer ${CRAZY_TEST_TOKEN}",
+		"X-Trace":       "configured",
+	}})
+	require.NoError(t, err)
+	assert.Zero(t, client.Timeout, "streams must not be cut by a client timeout")
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Trace", "request")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "Bearer s3cret", got.Get("Authorization"))
+	assert.Equal(t, "request", got.Get("X-Trace"), "headers set by the request win")
+}
+
+func TestNewTransport_InvalidSettings(t *testing.T) {
+	_, err := NewTransport(Config{Proxy: "not a url"})
+	assert.ErrorContains(t, err, "invalid proxy URL")
+
+	_, err = NewTransport(Config{CABundle: "/nonexistent/ca.pem"})
+	assert.ErrorContains(t, err, "failed to read CA bundle")
+
+	rt, err := NewTransport(Config{Proxy: ProxyDirect, ResponseHeaderTimeout: time.Minute, MaxConnsPerHost: 4})
+	require.NoError(t, err)
+	ht := rt.(*http.Transport)
+	assert.Nil(t, ht.Proxy)
+	assert.Equal(t, time.Minute, ht.ResponseHeaderTimeout)
+	assert.Equal(t, 4, ht.MaxConnsPerHost)
+}
@@ -0,0 +1,185 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of provider failures, matched with errors.Is
+var (
+	ErrModelNotFound  = errors.New("model not found")
+	ErrRateLimited    = errors.New("rate limited")
+	ErrContextTooLong = errors.New("context too long")
+	ErrAuth           = errors.New("authentication failed")
+	ErrUnavailable    = errors.New("provider unavailable")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// contextTooLongMarkers are phrases providers use when a prompt does not fit
+var contextTooLongMarkers = []string{
+	"context length", "context window", "maximum context", "context_length_exceeded",
+	"too many tokens", "prompt is too long", "input is too long", "exceeds the context",
+}
+
+// ProviderError is a failed request to a provider, classified by Kind
+type ProviderError struct {
+	// Kind is one of the Err* values above
+	Kind     error
+	Provider string
+	Model    string
+
+	// StatusCode is the HTTP status, 0 when there was none
+	StatusCode int
+
+	// Message is the provider's explanation
+	Message string
+
+	// RetryAfter is how long a rate-limited caller should wait, 0 if unknown
+	RetryAfter time.Duration
+
+	// Err is the underlying error, e.g. a connection failure
+	Err error
+}
+
+// Error describes the failure with the provider's message
+func (e *ProviderError) Error() string {
+	var sb strings.Builder
+	if e.Provider != "" {
+		sb.WriteString(e.Provider + ": ")
+	}
+	sb.WriteString(e.Kind.Error())
+	if e.Model != "" && (e.Kind == ErrModelNotFound || e.Kind == ErrContextTooLong) {
+		fmt.Fprintf(&sb, " (%s)", e.Model)
+	}
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&sb, ", HTTP %d", e.StatusCode)
+	}
+	if e.Message != "" {
+		sb.WriteString(": " + e.Message)
+	} else if e.Err != nil {
+		sb.WriteString(": " + e.Err.Error())
+	}
+	return sb.String()
+}
+
+// Is matches the error's kind
+func (e *ProviderError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the underlying error
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// NewProviderError classifies an error that has no HTTP status
+func NewProviderError(kind error, provider, model, message string) *ProviderError {
+	return &ProviderError{Kind: kind, Provider: provider, Model: model, Message: message}
+}
+
+// ErrorFromStatus classifies an HTTP error response from its status, headers
+// and body. Both Ollama's {"error": "..."} and the {"error": {"message": ...}}
+// bodies of cloud APIs are understood. A status of 0 classifies an error
+// reported in the middle of a stream.
+func ErrorFromStatus(provider, model string, status int, header http.Header, body []byte) *ProviderError {
+	message := errorMessage(body)
+	lower := strings.ToLower(message)
+	e := &ProviderError{Provider: provider, Model: model, StatusCode: status, Message: message}
+
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		e.Kind = ErrAuth
+	case status == http.StatusTooManyRequests:
+		e.Kind = ErrRateLimited
+		e.RetryAfter = parseRetryAfter(header)
+	case status == http.StatusRequestEntityTooLarge || containsAny(lower, contextTooLongMarkers):
+		e.Kind = ErrContextTooLong
+	case status == http.StatusNotFound || (strings.Contains(lower, "model") && strings.Contains(lower, "not found")):
+		e.Kind = ErrModelNotFound
+	case status == 0 || status == http.StatusRequestTimeout || status >= 500:
+		e.Kind = ErrUnavailable
+	default:
+		e.Kind = ErrInvalidRequest
+	}
+	return e
+}
+
+// ErrorFromTransport classifies a request that got no answer, such as a
+// refused connection. Cancellation and deadlines are returned unchanged, so
+// callers can still tell an interrupt from an outage.
+func ErrorFromTransport(provider, model string, err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return err
+	}
+	return &ProviderError{Kind: ErrUnavailable, Provider: provider, Model: model, Err: err}
+}
+
+// RetryAfter returns how long to wait before retrying a rate-limited request
+func RetryAfter(err error) (time.Duration, bool) {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) && providerErr.Kind == ErrRateLimited && providerErr.RetryAfter > 0 {
+		return providerErr.RetryAfter, true
+	}
+	return 0, false
+}
+
+// errorMessage extracts the provider's message from an error body
+func errorMessage(body []byte) string {
+	var parsed struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if json.Unmarshal(body, &parsed) == nil {
+		var text string
+		if json.Unmarshal(parsed.Error, &text) == nil && text != "" {
+			return text
+		}
+		var nested struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(parsed.Error, &nested) == nil && nested.Message != "" {
+			return nested.Message
+		}
+		if parsed.Message != "" {
+			return parsed.Message
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait.Round(time.Second)
+		}
+	}
+	return 0
+}
+
+// containsAny reports whether s contains one of the substrings
+func containsAny(s string, substrings []string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorFromStatus(t *testing.T) {
+	for name, tc := range map[string]struct {
+		status int
+		body   string
+		kind   error
+	}{
+		"ollama missing model": {404, `{"error":"model \"codellama\" not found, try pulling it first"}`, ErrModelNotFound},
+		"openai context":       {400, `{"error":{"message":"This model's maximum context length is 8192 tokens","code":"context_length_exceeded"}}`, ErrContextTooLong},
+		"auth":                 {401, `{"error":{"message":"Incorrect API key provided"}}`, ErrAuth},
+		"rate limit":           {429, `{"error":{"message":"Rate limit reached"}}`, ErrRateLimited},
+		"overloaded":           {529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, ErrUnavailable},
+		"bad request":          {400, `{"error":"invalid temperature"}`, ErrInvalidRequest},
+		"stream error":         {0, `llama runner process has terminated`, ErrUnavailable},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := fmt.Errorf("chat failed: %w", ErrorFromStatus("ollama", "codellama", tc.status, nil, []byte(tc.body)))
+			assert.ErrorIs(t, err, tc.kind)
+		})
+	}
+
+	err := ErrorFromStatus("ollama", "codellama", 404, nil, []byte(`{"error":"model \"codellama\" not found"}`))
+	assert.Equal(t, `ollama: model not found (codellama), HTTP 404: model "codellama" not found`, err.Error())
+}
+
+func TestRetryAfter(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"12"}}
+	err := fmt.Errorf("wrapped: %w", ErrorFromStatus("openai", "gpt-4", 429, header, nil))
+	wait, ok := RetryAfter(err)
+	assert.True(t, ok)
+	assert.Equal(t, 12*time.Second, wait)
+
+	_, ok = RetryAfter(errors.New("other"))
+	assert.False(t, ok)
+}
+
+func TestErrorFromTransport(t *testing.T) {
+	refused := errors.New("dial tcp 127.0.0.1:11434: connect: connection refused")
+	assert.ErrorIs(t, ErrorFromTransport("ollama", "llama3.2", refused), ErrUnavailable)
+	assert.ErrorIs(t, ErrorFromTransport("ollama", "llama3.2", refused), refused)
+	assert.NotErrorIs(t, ErrorFromTransport("ollama", "", context.Canceled), ErrUnavailable)
+}
@@ -0,0 +1,29 @@
+package types
+
+import "strings"
+
+// Finish reasons reported in AIResponse.FinishReason
+const (
+	FinishStop          = "stop"           // The model ended its answer or hit a stop sequence
+	FinishLength        = "length"         // The answer was cut off at the token limit
+	FinishContentFilter = "content_filter" // The provider withheld the rest of the answer
+	FinishToolCalls     = "tool_calls"     // The model stopped to call a tool
+)
+
+// NormalizeFinishReason maps the finish reason of a provider to one of the
+// Finish* values: Ollama's done_reason, OpenAI's finish_reason and
+// Anthropic's stop_reason. An empty reason means the answer completed.
+// Unknown reasons are returned in lower case.
+func NormalizeFinishReason(reason string) string {
+	switch reason = strings.ToLower(strings.TrimSpace(reason)); reason {
+	case "", "stop", "end_turn", "stop_sequence", "eos":
+		return FinishStop
+	case "length", "max_tokens", "model_length":
+		return FinishLength
+	case "content_filter", "safety", "refusal":
+		return FinishContentFilter
+	case "tool_calls", "tool_use", "function_call":
+		return FinishToolCalls
+	}
+	return reason
+}
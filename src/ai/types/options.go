@@ -7,6 +7,7 @@ type CompletionOptions struct {
 	Temperature float64 `json:"temperature"`  // Temperature for sampling (0.0-2.0)
 	TopP        float64 `json:"top_p"`        // Top-p sampling (0.0-1.0)
 	StopSequences []string `json:"stop_sequences"` // Sequences that stop generation
+	Suffix      string  `json:"suffix,omitempty"` // Code after the cursor, for fill-in-the-middle models
 }
 
 // ChatOptions contains options for chat requests
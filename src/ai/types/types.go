@@ -3,6 +3,9 @@ package types
 
 import (
 	"time"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/transport"
+	"github.com/rrecio/crazy-dev-zsh/src/core/privacy"
 )
 
 // ModelProvider represents the source of AI models
@@ -15,6 +18,8 @@ const (
 	ProviderOpenAI ModelProvider = "openai"
 	// ProviderAnthropic represents Anthropic cloud models
 	ProviderAnthropic ModelProvider = "anthropic"
+	// ProviderScripted represents offline models answering from a script
+	ProviderScripted ModelProvider = "scripted"
 )
 
 // ModelType represents the type of AI model
@@ -33,6 +38,7 @@ const (
 type Message struct {
 	Role    string `json:"role"`    // Role can be "system", "user", or "assistant"
 	Content string `json:"content"` // Content is the message text
+	Pinned  bool   `json:"pinned,omitempty"` // Pinned messages are never compacted away
 }
 
 // AIRequest represents a request to the AI engine
@@ -50,6 +56,9 @@ type AIRequest struct {
 	Timeout         *time.Duration `json:"timeout"`      // Timeout for the request
 	Stream          bool       `json:"stream"`           // Whether to stream the response
 	FallbackToCloud bool       `json:"fallback_to_cloud"` // Whether to fallback to cloud if local fails
+	AutoContinue    bool       `json:"auto_continue"`    // Whether to continue answers cut off at the length limit
+	Suffix          string     `json:"suffix,omitempty"` // Text after the insertion point, for fill-in-the-middle completion
+	Files           []string   `json:"files,omitempty"`  // Files whose content the request carries, checked against the privacy deny paths
 }
 
 // AIResponse represents a response from the AI engine
@@ -64,6 +73,18 @@ type AIResponse struct {
 	Messages       []Message `json:"messages"`        // Messages in the response (for chat models)
 	Model          string    `json:"model"`           // Model used for generation
 	Provider       string    `json:"provider"`        // Provider used for generation
+	Compaction     *Compaction `json:"compaction,omitempty"` // Set when older messages were summarised to fit the context window
+	Continuations  int       `json:"continuations,omitempty"` // Number of continuation requests stitched into Text
+}
+
+// Compaction describes how the conversation was shortened to fit the model's context window
+type Compaction struct {
+	CompactedMessages int       `json:"compacted_messages"` // Number of messages folded into the summary
+	TokensBefore      int       `json:"tokens_before"`      // Estimated prompt tokens before compaction
+	TokensAfter       int       `json:"tokens_after"`       // Estimated prompt tokens after compaction
+	ContextWindow     int       `json:"context_window"`     // Context window of the model
+	Summarized        bool      `json:"summarized"`         // False if the model could not summarise and old turns were dropped
+	Messages          []Message `json:"messages"`           // The compacted conversation, without project context
 }
 
 // AIUsage represents token usage information
@@ -82,6 +103,20 @@ type ModelInfo struct {
 	SizeBytes   int64        `json:"size_bytes"`   // Model size in bytes
 	Installed   bool         `json:"installed"`    // Whether the model is installed locally
 	Default     bool         `json:"default"`      // Whether this is a default model
+
+	// Endpoints names the Ollama servers that have the model installed
+	Endpoints []string `json:"endpoints,omitempty"`
+}
+
+// LocalEndpoint is an Ollama server local models are served from
+type LocalEndpoint struct {
+	// Name identifies the endpoint in listings, the URL's host by default
+	Name string `mapstructure:"name" json:"name,omitempty"`
+	URL  string `mapstructure:"url" json:"url"`
+
+	// Token is sent as a bearer token and may reference environment
+	// variables, e.g. "${GPU1_TOKEN}"
+	Token string `mapstructure:"token" json:"token,omitempty"`
 }
 
 // AIConfig represents the configuration for the AI engine
@@ -95,6 +130,39 @@ type AIConfig struct {
 	CacheTTL          time.Duration `json:"cache_ttl"`
 	AIResponseTimeout time.Duration `json:"ai_response_timeout"`
 	CloudAITimeout    time.Duration `json:"cloud_ai_timeout"`
+
+	// LocalEndpoints are the Ollama servers requests are routed across.
+	// When empty, LocalEndpoint is the only one.
+	LocalEndpoints []LocalEndpoint `json:"local_endpoints,omitempty"`
+
+	// Compaction settings: the conversation is summarised once its estimated
+	// size reaches CompactionThreshold of the model's context window
+	CompactionEnabled    bool           `json:"compaction_enabled"`
+	ContextWindow        int            `json:"context_window"`
+	ModelContextWindows  map[string]int `json:"model_context_windows"`
+	CompactionThreshold  float64        `json:"compaction_threshold"`
+	CompactionKeepRecent int            `json:"compaction_keep_recent"`
+
+	// ScriptedFile is a YAML or JSON script served by the offline scripted provider
+	ScriptedFile string `json:"scripted_file"`
+
+	// RedactionEnabled replaces secrets with placeholders before requests to cloud providers
+	RedactionEnabled bool `json:"redaction_enabled"`
+
+	// AutoContinue asks the model to go on when an answer is cut off at the
+	// length limit, up to MaxContinuations times
+	AutoContinue     bool `json:"auto_continue"`
+	MaxContinuations int  `json:"max_continuations"`
+
+	// Transports holds the HTTP settings of each provider, by provider
+	// name, with transport.DefaultKey applying to all of them
+	Transports map[string]transport.Config `json:"transports,omitempty"`
+
+	// Privacy is the policy of the current project, enforced before every
+	// cloud request. ConfirmCloud asks the user in ask mode; without it the
+	// request is refused.
+	Privacy      *privacy.Policy            `json:"privacy,omitempty"`
+	ConfirmCloud func(provider string) bool `json:"-"`
 }
 
 
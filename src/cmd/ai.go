@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"time"
+
 	"github.com/spf13/cobra"
 )
 
@@ -19,10 +21,45 @@ The AI engine can use local models via Ollama or cloud-based models.`,
 var chatCmd = &cobra.Command{
 	Use:   "chat",
 	Short: "Start an AI chat session",
-	Long:  `Start an interactive chat session with the AI assistant.`,
+	Long: `Start an interactive chat session with the AI assistant.
+
+Inside the session, lines starting with a slash are commands, e.g.
+/model, /profile, /system, /clear, /retry, /undo-turn, /add, /context,
+/save and /tokens. Type /help for the full list. Other lines that start
+with a slash, such as paths, are sent as messages; start a line with //
+to send a leading slash explicitly.
+
+Input supports line editing and history (up arrow recalls earlier prompts).
+Pasted text arrives as a single message. Alt-Enter or Ctrl-J adds a line
+break, /multiline makes Enter add line breaks (Alt-Enter or Ctrl-D sends),
+and Ctrl-X Ctrl-E or /edit composes the message in $EDITOR.
+
+When the conversation nears the model's context window (ai.compaction),
+older turns are summarised into a short memory note. The system prompt and
+turns pinned with /pin are always kept verbatim.`,
 	Run:   runChatCommand,
 }
 
+// askCmd represents the ai ask subcommand
+var askCmd = &cobra.Command{
+	Use:   "ask [question]",
+	Short: "Ask a one-off question",
+	Long: `Ask the AI a single question and print the answer.
+
+Input piped or redirected from a file on stdin is sent along with the
+question, so command output can be explained directly. Inside a
+'while read' loop, redirect stdin from /dev/null to leave the loop's input
+alone. With --output json or yaml the answer is printed as a
+structured document including the model, provider and token usage.
+
+Exit codes: 0 on success, 1 if the request fails, 2 if no question is given
+and 130 if interrupted.`,
+	Example: `  crazy ai ask "how do I squash the last 3 commits"
+  go test ./... 2>&1 | crazy ai ask "why does this fail"
+  git diff | crazy ai ask -c "write a commit message" -o json`,
+	Run: runAskCommand,
+}
+
 // suggestCmd represents the ai suggest subcommand
 var suggestCmd = &cobra.Command{
 	Use:   "suggest",
@@ -52,6 +89,7 @@ func init() {
 	
 	// Add subcommands
 	aiCmd.AddCommand(chatCmd)
+	aiCmd.AddCommand(askCmd)
 	aiCmd.AddCommand(suggestCmd)
 	aiCmd.AddCommand(modelsCmd)
 	aiCmd.AddCommand(installCmd)
@@ -63,10 +101,18 @@ func init() {
 	// Flags for the chat subcommand
 	chatCmd.Flags().BoolP("context", "c", true, "Include project context in chat")
 	chatCmd.Flags().Float64P("temperature", "t", 0.7, "Temperature for response generation (0.0-1.0)")
+	chatCmd.Flags().StringP("profile", "p", "", "Chat profile to start with (from ai.profiles)")
+	
+	// Flags for the ask subcommand
+	askCmd.Flags().BoolP("context", "c", false, "Include project context")
+	askCmd.Flags().Float64P("temperature", "t", 0.7, "Temperature for response generation (0.0-1.0)")
+	askCmd.Flags().StringP("system", "s", "You are a helpful AI assistant for software development. Answer concisely.", "System prompt")
+	askCmd.Flags().Duration("timeout", 2*time.Minute, "Maximum time to wait for the answer")
+	askCmd.Flags().Bool("auto-continue", false, "Continue answers cut off at the length limit (default: ai.auto_continue.enabled)")
 	
 	// Flags for the suggest subcommand
 	suggestCmd.Flags().StringP("type", "t", "code", "Type of suggestion (code, refactor, test)")
 	
 	// Flags for the models subcommand
-	modelsCmd.Flags().StringP("provider", "p", "", "Filter models by provider (ollama, openai, anthropic)")
+	modelsCmd.Flags().StringP("provider", "p", "", "Filter models by provider (ollama, openai, anthropic, scripted)")
 }
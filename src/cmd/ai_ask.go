@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+)
+
+// Exit codes used by commands meant for scripting
+const (
+	exitCodeError       = 1
+	exitCodeUsage       = 2
+	exitCodeInterrupted = 130
+)
+
+// exitWith ends the command with the given exit code. os.Exit skips
+// PersistentPostRun, so the verbose timing summary and the log file are
+// flushed here first.
+func exitWith(code int) {
+	if runningCmd != nil {
+		finishLogging(runningCmd, nil)
+	}
+	os.Exit(code)
+}
+
+// maxStdinSize is the largest amount of piped input sent along with a question
+const maxStdinSize = 256 * 1024
+
+// askResult is the structured output of ai ask
+type askResult struct {
+	Text         string `json:"text" yaml:"text"`
+	Model        string `json:"model" yaml:"model"`
+	Provider     string `json:"provider" yaml:"provider"`
+	FinishReason string `json:"finish_reason,omitempty" yaml:"finish_reason,omitempty"`
+	// Continuations counts the requests that continued a truncated answer
+	Continuations int        `json:"continuations,omitempty" yaml:"continuations,omitempty"`
+	Usage         ai.AIUsage `json:"usage" yaml:"usage"`
+	LatencyMs     int64      `json:"latency_ms" yaml:"latency_ms"`
+}
+
+// runAskCommand executes the AI ask subcommand
+func runAskCommand(cmd *cobra.Command, args []string) {
+	model, _ := cmd.Flags().GetString("model")
+	includeContext, _ := cmd.Flags().GetBool("context")
+	temperature, _ := cmd.Flags().GetFloat64("temperature")
+	systemPrompt, _ := cmd.Flags().GetString("system")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	autoContinue, _ := cmd.Flags().GetBool("auto-continue")
+	output := viper.GetString("output")
+
+	question := strings.TrimSpace(strings.Join(args, " "))
+	input, truncated, err := readStdinInput(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+		exitWith(exitCodeError)
+	}
+	if truncated {
+		fmt.Fprintf(os.Stderr, "Warning: stdin truncated to %d KB\n", maxStdinSize/1024)
+	}
+	if question == "" && input == "" {
+		fmt.Fprintln(os.Stderr, "Error: no question given, pass it as an argument or pipe it on stdin")
+		exitWith(exitCodeUsage)
+	}
+
+	if aiEngine == nil {
+		if err := initAIEngine(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing AI engine: %v\n", err)
+			exitWith(exitCodeError)
+		}
+	}
+
+	req := ai.AIRequest{
+		Model:     model,
+		ModelType: ai.ModelTypeChat,
+		Messages: []ai.Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: buildAskPrompt(question, input)},
+		},
+		Temperature:  temperature,
+		AutoContinue: autoContinue,
+	}
+	if includeContext {
+		projectContext, err := loadProjectContext(cmd.Context(), false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not analyze project context: %v\n", err)
+		}
+		req.Context = projectContext
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := sendAIRequest(ctx, req, output)
+	if err != nil {
+		printAIError(os.Stderr, err)
+		if errors.Is(err, context.Canceled) {
+			exitWith(exitCodeInterrupted)
+		}
+		exitWith(exitCodeError)
+	}
+	warnIfTruncated(resp)
+	printAskResult(resp, model, start, output)
+}
+
+// warnIfTruncated tells the user when an answer was cut off at the length limit
+func warnIfTruncated(resp *ai.AIResponse) {
+	if resp == nil || resp.FinishReason != types.FinishLength {
+		return
+	}
+	if resp.Continuations > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: the answer is still cut off after %d continuations, raise ai.auto_continue.max_continuations to get the rest\n", resp.Continuations)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Warning: the answer was cut off at the length limit, rerun with --auto-continue to get the rest")
+}
+
+// sendAIRequest sends a chat request. For text output the answer is streamed
+// through the markdown renderer, structured output waits for the full answer.
+func sendAIRequest(ctx context.Context, req ai.AIRequest, output string) (*ai.AIResponse, error) {
+	if output == "json" || output == "yaml" {
+		return aiEngine.Chat(ctx, req)
+	}
+
+	renderer := newMarkdownRenderer()
+	var text strings.Builder
+	resp, err := aiEngine.StreamChat(ctx, req, func(chunk string) error {
+		text.WriteString(chunk)
+		_, err := renderer.WriteString(chunk)
+		return err
+	})
+	renderer.Flush()
+	if text.Len() > 0 && !strings.HasSuffix(text.String(), "\n") {
+		fmt.Println()
+	}
+	return resp, err
+}
+
+// printAskResult prints a response as JSON or YAML, text output was already streamed
+func printAskResult(resp *ai.AIResponse, model string, start time.Time, output string) {
+	if output != "json" && output != "yaml" {
+		return
+	}
+
+	result := askResult{
+		Text:          resp.Text,
+		Model:         resp.SelectedModel,
+		Provider:      resp.SelectedProvider,
+		FinishReason:  resp.FinishReason,
+		Continuations: resp.Continuations,
+		Usage:         resp.Usage,
+		LatencyMs:     time.Since(start).Milliseconds(),
+	}
+	if result.Model == "" {
+		result.Model = model
+	}
+
+	var data []byte
+	var err error
+	if output == "json" {
+		data, err = json.MarshalIndent(result, "", "  ")
+	} else {
+		data, err = yaml.Marshal(result)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling output: %v\n", err)
+		exitWith(exitCodeError)
+	}
+	fmt.Println(strings.TrimRight(string(data), "\n"))
+}
+
+// buildAskPrompt combines the question with input piped on stdin
+func buildAskPrompt(question, input string) string {
+	if input == "" {
+		return question
+	}
+	if question == "" {
+		return input
+	}
+	return fmt.Sprintf("%s\n\nInput:\n```\n%s\n```", question, strings.TrimRight(input, "\n"))
+}
+
+// readStdinInput reads input piped or redirected from a file on stdin. A
+// terminal, /dev/null or a socket left open by cron or an IDE is not read,
+// as it may never reach end of file.
+func readStdinInput(f *os.File) (string, bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", false, nil
+	}
+	if mode := info.Mode(); mode&os.ModeNamedPipe == 0 && !mode.IsRegular() {
+		return "", false, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(f, maxStdinSize+1))
+	if err != nil {
+		return "", false, err
+	}
+	truncated := len(data) > maxStdinSize
+	if truncated {
+		data = data[:maxStdinSize]
+	}
+	return strings.TrimSpace(string(data)), truncated, nil
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/prompt"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/shellcmd"
+)
+
+// maxListedFiles is the number of directory entries sent with a command request
+const maxListedFiles = 60
+
+// commandResult is the structured output of ai cmd
+type commandResult struct {
+	shellcmd.Suggestion `yaml:",inline"`
+	Shell               string `json:"shell" yaml:"shell"`
+	Model               string `json:"model" yaml:"model"`
+	Provider            string `json:"provider" yaml:"provider"`
+	LatencyMs           int64  `json:"latency_ms" yaml:"latency_ms"`
+}
+
+// cmdCmd represents the ai cmd subcommand
+var cmdCmd = &cobra.Command{
+	Use:   "cmd <description>",
+	Short: "Turn a description into a shell command",
+	Long: `Describe a task in plain words and get a shell command for it, with an
+explanation. The command is printed, never run.
+
+The model is told your shell and operating system, the files in the current
+directory and the detected tech stacks, through the command_suggestion
+template.
+
+With --command-only the command alone goes to stdout and the explanation to
+stderr, for shell widgets. The zsh, bash and fish scripts of 'crazy init'
+define one that replaces the description typed on the command line with the
+command, for review before you press Enter. 'crazy init zsh --bind-ai'
+binds it to Ctrl-X Ctrl-A.
+
+Exit codes: 0 on success, 1 if the request fails or no command could be
+found in the answer, 2 if no description is given and 130 if interrupted.`,
+	Example: `  crazy ai cmd "find go files changed this week larger than 1MB"
+  crazy ai cmd --shell fish "kill whatever listens on port 8080"
+  crazy ai cmd -o json "compress the logs directory"`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runCmdCommand,
+}
+
+func init() {
+	aiCmd.AddCommand(cmdCmd)
+
+	cmdCmd.Flags().String("shell", "", "Shell to write the command for (default: from $SHELL)")
+	cmdCmd.Flags().BoolP("context", "c", true, "Send the detected tech stacks")
+	cmdCmd.Flags().Float64P("temperature", "t", 0.2, "Temperature for response generation (0.0-1.0)")
+	cmdCmd.Flags().Duration("timeout", time.Minute, "Maximum time to wait for the command")
+	cmdCmd.Flags().Bool("command-only", false, "Print only the command on stdout, the explanation on stderr")
+}
+
+// runCmdCommand executes the ai cmd subcommand
+func runCmdCommand(cmd *cobra.Command, args []string) {
+	query := strings.TrimSpace(strings.Join(args, " "))
+	if query == "" {
+		fmt.Fprintln(os.Stderr, "Error: no description given")
+		exitWith(exitCodeUsage)
+	}
+	shellName, _ := cmd.Flags().GetString("shell")
+	shell := shellcmd.Shell(shellName)
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	commandOnly, _ := cmd.Flags().GetBool("command-only")
+	output := viper.GetString("output")
+
+	lib, tmpl := mustLoadPromptTemplate("command_suggestion")
+	cwd, _ := os.Getwd()
+	files, err := shellcmd.ListDir(cwd, maxListedFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	rendered, err := lib.Render(tmpl.Name, map[string]string{
+		"Query":      query,
+		"CurrentDir": cwd,
+		"FileList":   files,
+		"Shell":      shell,
+		"OS":         shellcmd.OS(),
+	}, loadPromptContext(cmd))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering template: %v\n", err)
+		exitWith(exitCodeError)
+	}
+
+	if aiEngine == nil {
+		if err := initAIEngine(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing AI engine: %v\n", err)
+			exitWith(exitCodeError)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	req := templateRequest(cmd, tmpl, rendered)
+	resp, err := aiEngine.Chat(ctx, req)
+	if err != nil {
+		printAIError(os.Stderr, err)
+		if errors.Is(err, context.Canceled) {
+			exitWith(exitCodeInterrupted)
+		}
+		exitWith(exitCodeError)
+	}
+
+	result := commandResult{
+		Suggestion: shellcmd.Parse(resp.Text),
+		Shell:      shell,
+		Model:      resp.SelectedModel,
+		Provider:   resp.SelectedProvider,
+		LatencyMs:  time.Since(start).Milliseconds(),
+	}
+	if result.Model == "" {
+		result.Model = req.Model
+	}
+	printSuggestion(result.Suggestion, output, commandOnly, result)
+}
+
+// templateRequest builds a chat request from a rendered template. Flags set
+// on the command line win over the template's frontmatter, which wins over
+// flag defaults. Commands without a --max-tokens flag use the template's.
+func templateRequest(cmd *cobra.Command, tmpl *prompt.Template, rendered string) ai.AIRequest {
+	model, _ := cmd.Flags().GetString("model")
+	if !cmd.Flags().Changed("model") && tmpl.Model != "" {
+		model = tmpl.Model
+	}
+	if model == "" {
+		model = defaultPromptModel
+	}
+	temperature, _ := cmd.Flags().GetFloat64("temperature")
+	if !cmd.Flags().Changed("temperature") && tmpl.Params.Temperature != nil {
+		temperature = *tmpl.Params.Temperature
+	}
+	maxTokens := tmpl.Params.MaxTokens
+	if cmd.Flags().Changed("max-tokens") {
+		maxTokens, _ = cmd.Flags().GetInt("max-tokens")
+	}
+
+	var messages []ai.Message
+	if tmpl.System != "" {
+		messages = append(messages, ai.Message{Role: "system", Content: tmpl.System})
+	}
+	messages = append(messages, ai.Message{Role: "user", Content: rendered})
+	req := ai.AIRequest{
+		Model:       model,
+		ModelType:   ai.ModelTypeChat,
+		Messages:    messages,
+		Temperature: temperature,
+		MaxTokens:   maxTokens,
+	}
+	if tmpl.Params.TopP != nil {
+		req.TopP = *tmpl.Params.TopP
+	}
+	return req
+}
+
+// printSuggestion prints a suggested command and its explanation, or result
+// as JSON or YAML. It exits with an error when the answer held no command.
+func printSuggestion(suggestion shellcmd.Suggestion, output string, commandOnly bool, result any) {
+	switch {
+	case output == "json":
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+	case output == "yaml":
+		data, _ := yaml.Marshal(result)
+		fmt.Print(string(data))
+	case commandOnly:
+		if suggestion.Explanation != "" {
+			fmt.Fprintln(os.Stderr, suggestion.Explanation)
+		}
+		if suggestion.Command != "" {
+			fmt.Println(suggestion.Command)
+		}
+	default:
+		if suggestion.Command != "" {
+			fmt.Printf("$ %s\n\n", suggestion.Command)
+		}
+		renderer := newMarkdownRenderer()
+		renderer.WriteString(suggestion.Explanation + "\n")
+		renderer.Flush()
+	}
+
+	if suggestion.Command == "" {
+		fmt.Fprintln(os.Stderr, "Error: no command found in the answer")
+		exitWith(exitCodeError)
+	}
+}
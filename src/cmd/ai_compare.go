@@ -0,0 +1,573 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+)
+
+// minSidePaneWidth is the narrowest pane shown side by side in auto layout
+const minSidePaneWidth = 30
+
+// compareCmd represents the ai compare subcommand
+var compareCmd = &cobra.Command{
+	Use:   "compare [prompt]",
+	Short: "Send one prompt to several models and compare the answers",
+	Long: `Send one prompt to several models at once and compare the answers.
+
+The requests run concurrently. Answers stream into side-by-side panes when
+the terminal is wide enough, or one after the other (--layout stack). A table
+of latency, time to first token, tokens and cost follows.
+
+Prefix a model with its provider to pick a cloud model, e.g. openai/gpt-4.
+Costs use the prices per million tokens in ai.cloud.pricing; local models
+cost nothing. With --output json or yaml the answers and measurements are
+printed as a structured document.
+
+Exit codes: 0 if every model answered, 1 if one failed, 2 on usage errors
+and 130 if interrupted.`,
+	Example: `  crazy ai compare -m codellama -m llama3.2 -m openai/gpt-4 "write a Go LRU cache"
+  git diff | crazy ai compare -m codellama -m llama3.2 "review this diff"
+  crazy ai compare -m codellama -m llama3.2 "explain channels" -o json | jq '.results[].latency_ms'`,
+	Run: runCompareCommand,
+}
+
+func init() {
+	aiCmd.AddCommand(compareCmd)
+
+	compareCmd.Flags().StringArrayP("model", "m", nil, "Model to compare, repeat for each model")
+	compareCmd.Flags().BoolP("context", "c", false, "Include project context")
+	compareCmd.Flags().Float64P("temperature", "t", 0.7, "Temperature for response generation (0.0-1.0)")
+	compareCmd.Flags().StringP("system", "s", "You are a helpful AI assistant for software development. Answer concisely.", "System prompt")
+	compareCmd.Flags().Duration("timeout", 2*time.Minute, "Maximum time to wait for the answers")
+	compareCmd.Flags().String("layout", "auto", "Pane layout: auto, side or stack")
+}
+
+// modelPrice is the price of a cloud model in US dollars per million tokens
+type modelPrice struct {
+	Input  float64 `mapstructure:"input"`
+	Output float64 `mapstructure:"output"`
+}
+
+// compareReport is the structured output of ai compare
+type compareReport struct {
+	Prompt  string          `json:"prompt" yaml:"prompt"`
+	Results []compareResult `json:"results" yaml:"results"`
+}
+
+// compareResult is the answer and measurements of one model
+type compareResult struct {
+	Model           string     `json:"model" yaml:"model"`
+	Provider        string     `json:"provider" yaml:"provider"`
+	Text            string     `json:"text" yaml:"text"`
+	Error           string     `json:"error,omitempty" yaml:"error,omitempty"`
+	FinishReason    string     `json:"finish_reason,omitempty" yaml:"finish_reason,omitempty"`
+	LatencyMs       int64      `json:"latency_ms" yaml:"latency_ms"`
+	TTFTMs          int64      `json:"ttft_ms" yaml:"ttft_ms"`
+	Usage           ai.AIUsage `json:"usage" yaml:"usage"`
+	TokensEstimated bool       `json:"tokens_estimated,omitempty" yaml:"tokens_estimated,omitempty"`
+	CostUSD         *float64   `json:"cost_usd,omitempty" yaml:"cost_usd,omitempty"`
+
+	err error
+}
+
+// comparePane collects the streamed answer of one model
+type comparePane struct {
+	model   string
+	mutex   sync.Mutex
+	text    strings.Builder
+	done    bool
+	err     error
+	changed chan struct{}
+}
+
+// append adds a streamed chunk
+func (p *comparePane) append(chunk string) {
+	p.mutex.Lock()
+	p.text.WriteString(chunk)
+	p.mutex.Unlock()
+	p.notify()
+}
+
+// finish marks the answer as complete
+func (p *comparePane) finish(err error) {
+	p.mutex.Lock()
+	p.done = true
+	p.err = err
+	p.mutex.Unlock()
+	p.notify()
+}
+
+// snapshot returns the answer so far
+func (p *comparePane) snapshot() (string, bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.text.String(), p.done, p.err
+}
+
+// notify wakes up a view waiting for the pane without blocking the stream
+func (p *comparePane) notify() {
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+}
+
+// runCompareCommand executes the ai compare subcommand
+func runCompareCommand(cmd *cobra.Command, args []string) {
+	models, _ := cmd.Flags().GetStringArray("model")
+	includeContext, _ := cmd.Flags().GetBool("context")
+	temperature, _ := cmd.Flags().GetFloat64("temperature")
+	systemPrompt, _ := cmd.Flags().GetString("system")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	layout, _ := cmd.Flags().GetString("layout")
+	output := viper.GetString("output")
+
+	if len(models) < 2 {
+		fmt.Fprintln(os.Stderr, "Error: pass at least two models to compare with -m")
+		exitWith(exitCodeUsage)
+	}
+	if layout != "auto" && layout != "side" && layout != "stack" {
+		fmt.Fprintf(os.Stderr, "Error: unknown layout %q, expected auto, side or stack\n", layout)
+		exitWith(exitCodeUsage)
+	}
+
+	question := strings.TrimSpace(strings.Join(args, " "))
+	input, truncated, err := readStdinInput(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+		exitWith(exitCodeError)
+	}
+	if truncated {
+		fmt.Fprintf(os.Stderr, "Warning: stdin truncated to %d KB\n", maxStdinSize/1024)
+	}
+	if question == "" && input == "" {
+		fmt.Fprintln(os.Stderr, "Error: no prompt given, pass it as an argument or pipe it on stdin")
+		exitWith(exitCodeUsage)
+	}
+
+	if aiEngine == nil {
+		if err := initAIEngine(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing AI engine: %v\n", err)
+			exitWith(exitCodeError)
+		}
+	}
+
+	prompt := buildAskPrompt(question, input)
+	req := ai.AIRequest{
+		ModelType: ai.ModelTypeChat,
+		Messages: []ai.Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: prompt},
+		},
+		Temperature: temperature,
+	}
+	if includeContext {
+		projectContext, err := loadProjectContext(cmd.Context(), false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not analyze project context: %v\n", err)
+		}
+		req.Context = projectContext
+	}
+
+	var prices map[string]modelPrice
+	if err := viper.UnmarshalKey("ai.cloud.pricing", &prices); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: invalid ai.cloud.pricing: %v\n", err)
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	panes := make([]*comparePane, len(models))
+	for i, model := range models {
+		panes[i] = &comparePane{model: model, changed: make(chan struct{}, 1)}
+	}
+
+	// Structured output waits for every answer, text output streams into panes
+	viewDone := make(chan struct{})
+	if output == "json" || output == "yaml" {
+		close(viewDone)
+	} else {
+		go func() {
+			defer close(viewDone)
+			if useSideLayout(layout, len(panes)) {
+				runSideView(panes)
+			} else {
+				runStackView(panes)
+			}
+		}()
+	}
+
+	results := compareModels(ctx, req, panes, prices)
+	<-viewDone
+
+	if output == "json" || output == "yaml" {
+		printCompareReport(compareReport{Prompt: prompt, Results: results}, output)
+	} else {
+		fmt.Println()
+		printCompareTable(results)
+		for _, result := range results {
+			if hint := aiErrorHint(result.err); hint != "" {
+				fmt.Printf("Hint for %s: %s\n", result.Model, hint)
+			}
+		}
+	}
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		exitWith(exitCodeInterrupted)
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			exitWith(exitCodeError)
+		}
+	}
+}
+
+// compareModels sends the request to every pane's model concurrently and
+// measures each answer
+func compareModels(ctx context.Context, req ai.AIRequest, panes []*comparePane, prices map[string]modelPrice) []compareResult {
+	results := make([]compareResult, len(panes))
+	var wg sync.WaitGroup
+	for i, pane := range panes {
+		wg.Add(1)
+		go func(i int, pane *comparePane) {
+			defer wg.Done()
+			modelReq := req
+			modelReq.Provider, modelReq.Model = splitProviderModel(pane.model)
+
+			start := time.Now()
+			var firstToken time.Duration
+			resp, err := aiEngine.StreamChat(ctx, modelReq, func(chunk string) error {
+				if firstToken == 0 {
+					firstToken = time.Since(start)
+				}
+				pane.append(chunk)
+				return nil
+			})
+			pane.finish(err)
+
+			text, _, _ := pane.snapshot()
+			result := compareResult{
+				Model:     pane.model,
+				Provider:  modelReq.Provider,
+				Text:      text,
+				LatencyMs: time.Since(start).Milliseconds(),
+				TTFTMs:    firstToken.Milliseconds(),
+			}
+			if err != nil {
+				result.Error = err.Error()
+				result.err = err
+			} else {
+				if resp.SelectedProvider != "" {
+					result.Provider = resp.SelectedProvider
+				}
+				result.FinishReason = resp.FinishReason
+				result.Usage = resp.Usage
+			}
+			if result.Usage.TotalTokens == 0 && text != "" {
+				result.Usage.PromptTokens = estimateRequestTokens(modelReq)
+				result.Usage.CompletionTokens = ai.EstimateTokens(text)
+				result.Usage.TotalTokens = result.Usage.PromptTokens + result.Usage.CompletionTokens
+				result.TokensEstimated = true
+			}
+			if result.Provider == "" {
+				result.Provider = string(ai.ProviderOllama)
+			}
+			result.CostUSD = compareCost(result.Provider, modelReq.Model, result.Usage, prices)
+			results[i] = result
+		}(i, pane)
+	}
+	wg.Wait()
+	return results
+}
+
+// splitProviderModel splits "openai/gpt-4" into its provider and model. Only
+// known providers are split off, since Ollama model names may contain slashes.
+func splitProviderModel(name string) (string, string) {
+	provider, model, found := strings.Cut(name, "/")
+	if !found {
+		return "", name
+	}
+	switch ai.ModelProvider(provider) {
+	case ai.ProviderOllama, ai.ProviderOpenAI, ai.ProviderAnthropic, ai.ProviderScripted:
+		return provider, model
+	}
+	return "", name
+}
+
+// estimateRequestTokens estimates the prompt tokens of a request whose
+// provider reported no usage
+func estimateRequestTokens(req ai.AIRequest) int {
+	total := ai.EstimateTokens(string(req.Context))
+	for _, msg := range req.Messages {
+		total += ai.EstimateTokens(msg.Content)
+	}
+	return total
+}
+
+// compareCost returns the cost of an answer, or nil when the price of a
+// cloud model is unknown. Local models cost nothing.
+func compareCost(provider, model string, usage ai.AIUsage, prices map[string]modelPrice) *float64 {
+	cost := 0.0
+	switch ai.ModelProvider(provider) {
+	case ai.ProviderOllama, ai.ProviderScripted:
+	default:
+		price, ok := prices[model]
+		if !ok {
+			return nil
+		}
+		cost = (float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output) / 1e6
+	}
+	return &cost
+}
+
+// useSideLayout reports whether panes are shown side by side
+func useSideLayout(layout string, panes int) bool {
+	if layout == "stack" || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return false
+	}
+	if layout == "side" {
+		return true
+	}
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	return err == nil && width/panes >= minSidePaneWidth
+}
+
+// runStackView streams the answers one after the other. Answers that arrive
+// while an earlier one streams are buffered and shown when their turn comes.
+func runStackView(panes []*comparePane) {
+	for i, pane := range panes {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(color.New(color.Bold).Sprintf("── %s ──", pane.model))
+		renderer := newMarkdownRenderer()
+		written := 0
+		for {
+			text, done, err := pane.snapshot()
+			if len(text) > written {
+				renderer.WriteString(text[written:])
+				written = len(text)
+			}
+			if done {
+				renderer.Flush()
+				if written > 0 && !strings.HasSuffix(text, "\n") {
+					fmt.Println()
+				}
+				if err != nil {
+					fmt.Println(color.New(color.FgRed).Sprintf("Error: %v", err))
+				}
+				break
+			}
+			<-pane.changed
+		}
+	}
+}
+
+// runSideView streams the answers into columns, redrawing the tail of each
+// answer while they arrive and the full answers once every model is done
+func runSideView(panes []*comparePane) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 120, 40
+	}
+	liveHeight := height - 4
+	if liveHeight < 5 {
+		liveHeight = 5
+	}
+
+	drawn := 0
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		lines, allDone := sidePaneLines(panes, width, liveHeight)
+		if allDone {
+			lines, _ = sidePaneLines(panes, width, 0)
+		}
+		if drawn > 0 {
+			fmt.Printf("\033[%dF", drawn)
+		}
+		for _, line := range lines {
+			fmt.Printf("%s\033[K\n", line)
+		}
+		fmt.Print("\033[J")
+		drawn = len(lines)
+		if allDone {
+			return
+		}
+		<-ticker.C
+	}
+}
+
+// sidePaneLines lays the answers out in columns, keeping the last maxLines
+// lines of each answer when maxLines is positive
+func sidePaneLines(panes []*comparePane, width, maxLines int) ([]string, bool) {
+	const separator = " │ "
+	columnWidth := (width - (len(panes)-1)*len([]rune(separator))) / len(panes)
+	if columnWidth < 10 {
+		columnWidth = 10
+	}
+
+	allDone := true
+	headers := make([]string, len(panes))
+	columns := make([][]string, len(panes))
+	rows := 0
+	for i, pane := range panes {
+		text, done, err := pane.snapshot()
+		allDone = allDone && done
+		status := "streaming"
+		switch {
+		case err != nil:
+			status = "failed"
+		case done:
+			status = "done"
+		}
+		headers[i] = fitColumn(pane.model+" ("+status+")", columnWidth)
+
+		lines := wrapColumn(text, columnWidth)
+		if err != nil {
+			lines = append(lines, wrapColumn("Error: "+err.Error(), columnWidth)...)
+		}
+		if maxLines > 0 && len(lines) > maxLines {
+			lines = lines[len(lines)-maxLines:]
+		}
+		columns[i] = lines
+		if len(lines) > rows {
+			rows = len(lines)
+		}
+	}
+
+	bold := color.New(color.Bold)
+	var header []string
+	for _, h := range headers {
+		header = append(header, bold.Sprint(h))
+	}
+	out := []string{strings.Join(header, separator), strings.Repeat("─", width)}
+	for row := 0; row < rows; row++ {
+		cells := make([]string, len(columns))
+		for i, lines := range columns {
+			cell := ""
+			if row < len(lines) {
+				cell = lines[row]
+			}
+			cells[i] = fitColumn(cell, columnWidth)
+		}
+		out = append(out, strings.TrimRight(strings.Join(cells, separator), " "))
+	}
+	return out, allDone
+}
+
+// wrapColumn breaks text into lines of at most width runes, preferring spaces
+func wrapColumn(text string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		runes := []rune(strings.ReplaceAll(line, "\t", "    "))
+		for len(runes) > width {
+			cut := width
+			for i := width; i > width/2; i-- {
+				if runes[i] == ' ' {
+					cut = i
+					break
+				}
+			}
+			lines = append(lines, string(runes[:cut]))
+			runes = []rune(strings.TrimLeft(string(runes[cut:]), " "))
+		}
+		lines = append(lines, string(runes))
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	return lines
+}
+
+// fitColumn pads or truncates s to width runes
+func fitColumn(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-len(runes))
+}
+
+// printCompareTable prints the measurements of every model
+func printCompareTable(results []compareResult) {
+	rows := [][]string{{"MODEL", "PROVIDER", "LATENCY", "TTFT", "TOKENS", "COST", "STATUS"}}
+	for _, r := range results {
+		tokens := fmt.Sprintf("%d", r.Usage.TotalTokens)
+		if r.TokensEstimated {
+			tokens = "~" + tokens
+		}
+		cost := "-"
+		if r.CostUSD != nil {
+			cost = fmt.Sprintf("$%.4f", *r.CostUSD)
+		}
+		ttft := "-"
+		if r.Text != "" {
+			ttft = formatMs(r.TTFTMs)
+		}
+		status := "ok"
+		if r.Error != "" {
+			status = "failed"
+		} else if r.FinishReason != "" && r.FinishReason != "stop" {
+			status = r.FinishReason
+		}
+		rows = append(rows, []string{r.Model, r.Provider, formatMs(r.LatencyMs), ttft, tokens, cost, status})
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	for _, row := range rows {
+		var line strings.Builder
+		for i, cell := range row {
+			if i > 0 {
+				line.WriteString("  ")
+			}
+			fmt.Fprintf(&line, "%-*s", widths[i], cell)
+		}
+		fmt.Println(strings.TrimRight(line.String(), " "))
+	}
+}
+
+// formatMs formats milliseconds as seconds, e.g. "1.25s"
+func formatMs(ms int64) string {
+	return fmt.Sprintf("%.2fs", float64(ms)/1000)
+}
+
+// printCompareReport prints the answers and measurements as JSON or YAML
+func printCompareReport(report compareReport, output string) {
+	var data []byte
+	var err error
+	if output == "json" {
+		data, err = json.MarshalIndent(report, "", "  ")
+	} else {
+		data, err = yaml.Marshal(report)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling output: %v\n", err)
+		exitWith(exitCodeError)
+	}
+	fmt.Println(strings.TrimRight(string(data), "\n"))
+}
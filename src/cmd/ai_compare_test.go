@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+)
+
+func TestSplitProviderModel(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		model    string
+	}{
+		{name: "codellama", model: "codellama"},
+		{name: "openai/gpt-4", provider: "openai", model: "gpt-4"},
+		{name: "anthropic/claude-3-opus", provider: "anthropic", model: "claude-3-opus"},
+		{name: "ollama/llama3.2:3b", provider: "ollama", model: "llama3.2:3b"},
+		// Ollama names with a namespace or registry keep their slashes
+		{name: "bartowski/qwen2.5-coder", model: "bartowski/qwen2.5-coder"},
+		{name: "hf.co/bartowski/Llama-3.2-1B-GGUF:Q4_K_M", model: "hf.co/bartowski/Llama-3.2-1B-GGUF:Q4_K_M"},
+		{name: "ollama/hf.co/bartowski/Llama-3.2-1B-GGUF", provider: "ollama", model: "hf.co/bartowski/Llama-3.2-1B-GGUF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, model := splitProviderModel(tt.name)
+			assert.Equal(t, tt.provider, provider)
+			assert.Equal(t, tt.model, model)
+		})
+	}
+}
+
+func TestCompareCost(t *testing.T) {
+	prices := map[string]modelPrice{"gpt-4": {Input: 30, Output: 60}}
+	usage := ai.AIUsage{PromptTokens: 1000, CompletionTokens: 500}
+
+	tests := []struct {
+		name     string
+		provider string
+		model    string
+		want     *float64
+	}{
+		{name: "priced cloud model", provider: "openai", model: "gpt-4", want: floatPtr(0.06)},
+		{name: "unknown price", provider: "openai", model: "gpt-4o", want: nil},
+		{name: "local model", provider: "ollama", model: "codellama", want: floatPtr(0.0)},
+		{name: "scripted model", provider: "scripted", model: "demo", want: floatPtr(0.0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost := compareCost(tt.provider, tt.model, usage, prices)
+			if tt.want == nil {
+				assert.Nil(t, cost)
+				return
+			}
+			require.NotNil(t, cost)
+			assert.InDelta(t, *tt.want, *cost, 1e-9)
+		})
+	}
+}
+
+func TestWrapColumn(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  []string
+	}{
+		{name: "empty", text: "", width: 10, want: nil},
+		{name: "fits", text: "short\n", width: 10, want: []string{"short"}},
+		{name: "breaks at a space", text: "the quick brown fox", width: 10, want: []string{"the quick", "brown fox"}},
+		{name: "cuts a long word", text: "abcdefghijklmno", width: 10, want: []string{"abcdefghij", "klmno"}},
+		{name: "keeps lines and expands tabs", text: "a\n\tb", width: 10, want: []string{"a", "    b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, wrapColumn(tt.text, tt.width))
+		})
+	}
+}
+
+func TestFitColumn(t *testing.T) {
+	assert.Equal(t, "abc   ", fitColumn("abc", 6))
+	assert.Equal(t, "abcdef", fitColumn("abcdef", 6))
+	assert.Equal(t, "abcde…", fitColumn("abcdefgh", 6))
+	assert.Equal(t, "héllo…", fitColumn("héllo wörld", 6))
+}
+
+func TestEstimateRequestTokens(t *testing.T) {
+	req := ai.AIRequest{
+		Context: []byte("12345678"),
+		Messages: []ai.Message{
+			{Role: "system", Content: "1234"},
+			{Role: "user", Content: "12345"},
+		},
+	}
+	assert.Equal(t, 2+1+2, estimateRequestTokens(req))
+	assert.Zero(t, estimateRequestTokens(ai.AIRequest{}))
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/fim"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+)
+
+// Defaults of the ai complete subcommand
+const (
+	defaultCompleteMaxTokens  = 128
+	defaultCompleteMaxSymbols = 20
+	defaultCompleteTimeout    = 30 * time.Second
+)
+
+// completeRequest is a completion request read from stdin by editors. Fields
+// left out take the value of the matching flag.
+type completeRequest struct {
+	File string `json:"file"`
+
+	// Content is the unsaved buffer, the file is read when it is empty
+	Content string `json:"content"`
+
+	Line        int      `json:"line"`
+	Col         int      `json:"col"`
+	Model       string   `json:"model"`
+	Candidates  int      `json:"candidates"`
+	MaxTokens   int      `json:"max_tokens"`
+	Temperature *float64 `json:"temperature"`
+}
+
+// completeResult is the structured output of ai complete
+type completeResult struct {
+	Candidates []string `json:"candidates" yaml:"candidates"`
+	Model      string   `json:"model" yaml:"model"`
+	Provider   string   `json:"provider" yaml:"provider"`
+
+	// FIM reports whether the model filled in the middle natively, rather
+	// than from the code_completion template
+	FIM       bool  `json:"fim" yaml:"fim"`
+	LatencyMs int64 `json:"latency_ms" yaml:"latency_ms"`
+}
+
+// completeCmd represents the ai complete subcommand
+var completeCmd = &cobra.Command{
+	Use:   "complete",
+	Short: "Complete code at a cursor position",
+	Long: `Complete code at a cursor position and print only the text to insert.
+
+The code before and after the cursor is sent to the model as a
+fill-in-the-middle request, with the declarations of the file and its sibling
+files the model would not otherwise see. Models with fill-in-the-middle
+support, such as codellama:code or qwen2.5-coder, complete natively; other
+models are prompted with the code_completion template.
+
+With --candidates several completions are generated and duplicates dropped.
+Text output separates candidates with a NUL byte.
+
+With --stdin a JSON request is read from stdin and the result is printed as
+JSON, for editor integrations:
+
+  {"file": "main.go", "content": "<unsaved buffer>", "line": 42, "col": 10,
+   "candidates": 3, "model": "qwen2.5-coder"}
+
+The model defaults to ai.complete.model, then to --model.`,
+	Example: `  crazy ai complete --file main.go --line 42 --col 10
+  crazy ai complete --file app.py --line 7 --col 1 -n 3 -o json
+  echo '{"file":"main.go","line":42,"col":10}' | crazy ai complete --stdin`,
+	Args: cobra.NoArgs,
+	Run:  runCompleteCommand,
+}
+
+func init() {
+	aiCmd.AddCommand(completeCmd)
+
+	completeCmd.Flags().String("file", "", "File to complete")
+	completeCmd.Flags().Int("line", 0, "Cursor line, starting at 1")
+	completeCmd.Flags().Int("col", 0, "Cursor column in characters, starting at 1")
+	completeCmd.Flags().IntP("candidates", "n", 1, "Number of candidates to generate")
+	completeCmd.Flags().Int("max-tokens", defaultCompleteMaxTokens, "Maximum tokens per candidate")
+	completeCmd.Flags().Float64P("temperature", "t", 0.2, "Temperature for response generation (0.0-1.0)")
+	completeCmd.Flags().Int("max-symbols", defaultCompleteMaxSymbols, "Maximum nearby declarations sent with the code")
+	completeCmd.Flags().Duration("timeout", defaultCompleteTimeout, "Maximum time to wait for the candidates")
+	completeCmd.Flags().Bool("stdin", false, "Read a JSON request from stdin and print JSON")
+}
+
+// runCompleteCommand executes the ai complete subcommand
+func runCompleteCommand(cmd *cobra.Command, args []string) {
+	maxSymbols, _ := cmd.Flags().GetInt("max-symbols")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	fromStdin, _ := cmd.Flags().GetBool("stdin")
+	output := viper.GetString("output")
+
+	req, err := completeRequestFromFlags(cmd)
+	if err == nil && fromStdin {
+		err = readCompleteRequest(os.Stdin, &req)
+		output = "json"
+	}
+	if err == nil && req.Content == "" && req.File != "" {
+		var data []byte
+		if data, err = os.ReadFile(req.File); err != nil {
+			err = fmt.Errorf("could not read %s: %w", req.File, err)
+		}
+		req.Content = string(data)
+	}
+	if err == nil && (req.File == "" && req.Content == "" || req.Line == 0 || req.Col == 0) {
+		err = errors.New("--file, --line and --col are required")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeUsage)
+	}
+
+	input, err := fim.Prepare(req.File, req.Content, req.Line, req.Col, fim.DefaultMaxPrefix, fim.DefaultMaxSuffix, maxSymbols)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeUsage)
+	}
+
+	if aiEngine == nil {
+		if err := initAIEngine(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing AI engine: %v\n", err)
+			exitWith(exitCodeError)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	result, err := completeCode(ctx, req, input)
+	if err != nil {
+		printAIError(os.Stderr, err)
+		if errors.Is(err, context.Canceled) {
+			exitWith(exitCodeInterrupted)
+		}
+		exitWith(exitCodeError)
+	}
+	result.LatencyMs = time.Since(start).Milliseconds()
+
+	switch output {
+	case "json":
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+	case "yaml":
+		data, _ := yaml.Marshal(result)
+		fmt.Print(string(data))
+	default:
+		fmt.Print(strings.Join(result.Candidates, "\x00"))
+	}
+}
+
+// completeRequestFromFlags reads the request given on the command line
+func completeRequestFromFlags(cmd *cobra.Command) (completeRequest, error) {
+	var req completeRequest
+	req.File, _ = cmd.Flags().GetString("file")
+	req.Line, _ = cmd.Flags().GetInt("line")
+	req.Col, _ = cmd.Flags().GetInt("col")
+	req.Candidates, _ = cmd.Flags().GetInt("candidates")
+	req.MaxTokens, _ = cmd.Flags().GetInt("max-tokens")
+	temperature, _ := cmd.Flags().GetFloat64("temperature")
+	req.Temperature = &temperature
+
+	req.Model, _ = cmd.Flags().GetString("model")
+	if model := viper.GetString("ai.complete.model"); model != "" && !cmd.Flags().Changed("model") {
+		req.Model = model
+	}
+	if req.Candidates < 1 {
+		return req, errors.New("--candidates must be at least 1")
+	}
+	return req, nil
+}
+
+// readCompleteRequest overrides req with the fields of a JSON request
+func readCompleteRequest(r io.Reader, req *completeRequest) error {
+	if err := json.NewDecoder(io.LimitReader(r, maxStdinSize)).Decode(req); err != nil {
+		return fmt.Errorf("invalid request on stdin: %w", err)
+	}
+	if req.Candidates < 1 {
+		req.Candidates = 1
+	}
+	return nil
+}
+
+// completeCode generates the candidates, natively when the model supports
+// fill-in-the-middle and from the code_completion template otherwise
+func completeCode(ctx context.Context, req completeRequest, input *fim.Input) (*completeResult, error) {
+	prompt := fim.Comment(input.Symbols, input.Language) + input.Prefix
+	result, err := generateCandidates(ctx, req, ai.AIRequest{Prompt: prompt, Suffix: input.Suffix})
+	if err == nil {
+		result.FIM = true
+		return result, nil
+	}
+	if !fimUnsupported(err) {
+		return nil, err
+	}
+
+	lib, err := loadPromptLibrary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt templates: %w", err)
+	}
+	prompt, err = lib.Render("code_completion", map[string]string{
+		"Code":     input.Prefix,
+		"Suffix":   input.Suffix,
+		"Language": input.Language,
+		"Symbols":  strings.TrimRight(fim.Comment(input.Symbols, input.Language), "\n"),
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render code_completion: %w", err)
+	}
+	return generateCandidates(ctx, req, ai.AIRequest{Prompt: prompt})
+}
+
+// generateCandidates sends base the requested number of times in parallel
+// and returns the distinct, cleaned answers. It fails only if every request
+// fails.
+func generateCandidates(ctx context.Context, req completeRequest, base ai.AIRequest) (*completeResult, error) {
+	base.Model = req.Model
+	base.ModelType = ai.ModelTypeCompletion
+	base.MaxTokens = req.MaxTokens
+	if req.File != "" {
+		// The code around the cursor need not name its file, so the engine is
+		// told which file it comes from to apply the privacy deny paths
+		file := req.File
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		base.Files = []string{file}
+	}
+	if req.Temperature != nil {
+		base.Temperature = *req.Temperature
+	}
+
+	texts := make([]string, req.Candidates)
+	responses := make([]*ai.AIResponse, req.Candidates)
+	errs := make([]error, req.Candidates)
+	var wg sync.WaitGroup
+	for i := range texts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i], errs[i] = aiEngine.Complete(ctx, base)
+			if errs[i] == nil {
+				texts[i] = fim.Clean(responses[i].Text, base.Suffix)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	result := &completeResult{Candidates: []string{}, Model: req.Model}
+	var firstErr error
+	for i, resp := range responses {
+		if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		if resp.SelectedModel != "" {
+			result.Model = resp.SelectedModel
+		}
+		result.Provider = resp.SelectedProvider
+	}
+	if result.Provider == "" && firstErr != nil {
+		return nil, firstErr
+	}
+	if unique := fim.Unique(texts); unique != nil {
+		result.Candidates = unique
+	}
+	return result, nil
+}
+
+// fimUnsupported reports whether a request failed because the model cannot
+// fill in the middle
+func fimUnsupported(err error) bool {
+	return errors.Is(err, types.ErrInvalidRequest) && strings.Contains(err.Error(), "does not support insert")
+}
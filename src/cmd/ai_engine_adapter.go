@@ -25,6 +25,7 @@ func (a *aiEngineCompatAdapter) convertRequest(req ai.AIRequest) types.AIRequest
 		messages = append(messages, types.Message{
 			Role:    msg.Role,
 			Content: msg.Content,
+			Pinned:  msg.Pinned,
 		})
 	}
 	
@@ -42,6 +43,9 @@ func (a *aiEngineCompatAdapter) convertRequest(req ai.AIRequest) types.AIRequest
 		Timeout:         req.Timeout,
 		Stream:          req.Stream,
 		FallbackToCloud: req.FallbackToCloud,
+		AutoContinue:    req.AutoContinue,
+		Suffix:          req.Suffix,
+		Files:           req.Files,
 	}
 }
 
@@ -63,6 +67,33 @@ func (a *aiEngineCompatAdapter) convertResponse(resp *types.AIResponse) *ai.AIRe
 		SelectedProvider:resp.SelectedProvider,
 		Latency:         resp.Latency,
 		Error:           resp.Error,
+		Compaction:      a.convertCompaction(resp.Compaction),
+		Continuations:   resp.Continuations,
+	}
+}
+
+// convertCompaction converts from types.Compaction to ai.Compaction
+func (a *aiEngineCompatAdapter) convertCompaction(c *types.Compaction) *ai.Compaction {
+	if c == nil {
+		return nil
+	}
+	
+	messages := make([]ai.Message, 0, len(c.Messages))
+	for _, msg := range c.Messages {
+		messages = append(messages, ai.Message{
+			Role:    msg.Role,
+			Content: msg.Content,
+			Pinned:  msg.Pinned,
+		})
+	}
+	
+	return &ai.Compaction{
+		CompactedMessages: c.CompactedMessages,
+		TokensBefore:      c.TokensBefore,
+		TokensAfter:       c.TokensAfter,
+		ContextWindow:     c.ContextWindow,
+		Summarized:        c.Summarized,
+		Messages:          messages,
 	}
 }
 
@@ -109,7 +140,7 @@ func (a *aiEngineCompatAdapter) GetEmbedding(ctx context.Context, text string, m
 // ListModels implements the ai.AIEngine interface by wrapping types.AIEngine 
 func (a *aiEngineCompatAdapter) ListModels(ctx context.Context, provider string) ([]ai.ModelInfo, error) {
 	typesModels, err := a.typesEngine.ListModels(ctx, provider)
-	if err != nil {
+	if err != nil && len(typesModels) == 0 {
 		return nil, fmt.Errorf("list models failed: %w", err)
 	}
 	
@@ -128,6 +159,8 @@ func (a *aiEngineCompatAdapter) ListModels(ctx context.Context, provider string)
 			providerType = ai.ProviderOpenAI
 		case types.ProviderAnthropic:
 			providerType = ai.ProviderAnthropic
+		case types.ProviderScripted:
+			providerType = ai.ProviderScripted
 		default:
 			// Default to Ollama if unknown
 			providerType = ai.ProviderOllama
@@ -154,9 +187,14 @@ func (a *aiEngineCompatAdapter) ListModels(ctx context.Context, provider string)
 			SizeBytes:   m.SizeBytes,
 			Installed:   m.Installed,
 			Default:     m.Default,
+			Endpoints:   m.Endpoints,
 		})
 	}
 	
+	// Models from the providers that answered are returned along with the error
+	if err != nil {
+		return models, fmt.Errorf("list models failed: %w", err)
+	}
 	return models, nil
 }
 
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/viper"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+)
+
+// printAIError prints a failed AI request followed by a hint on how to fix it
+func printAIError(w io.Writer, err error) {
+	fmt.Fprintf(w, "Error: %v\n", err)
+	if hint := aiErrorHint(err); hint != "" {
+		fmt.Fprintf(w, "Hint: %s\n", hint)
+	}
+}
+
+// aiErrorHint returns advice for a failed AI request, or "" when there is none
+func aiErrorHint(err error) string {
+	var providerErr *types.ProviderError
+	provider, model := "", ""
+	if errors.As(err, &providerErr) {
+		provider, model = providerErr.Provider, providerErr.Model
+	}
+
+	switch {
+	case errors.Is(err, types.ErrModelNotFound):
+		if provider == string(types.ProviderOllama) && model != "" {
+			return fmt.Sprintf("run `crazy ai install %s` to download it", model)
+		}
+		return "run `crazy ai models` to list the available models"
+	case errors.Is(err, types.ErrRateLimited):
+		if wait, ok := types.RetryAfter(err); ok {
+			return fmt.Sprintf("the provider asks to retry in %s", wait)
+		}
+		return "wait a moment and retry, or pick a local model with -m"
+	case errors.Is(err, types.ErrContextTooLong):
+		return "shorten the input or leave out project context; ai.compaction.model_context_windows sets the window compaction aims for"
+	case errors.Is(err, types.ErrAuth):
+		switch provider {
+		case string(types.ProviderOpenAI):
+			return "set CRAZY_OPENAI_API_KEY to a valid key"
+		case string(types.ProviderAnthropic):
+			return "set CRAZY_ANTHROPIC_API_KEY to a valid key"
+		case string(types.ProviderOllama):
+			if viper.IsSet("ai.local.endpoints") {
+				return "check the tokens of ai.local.endpoints"
+			}
+			return "check the credentials of ai.local.endpoint"
+		}
+		return "check the provider's API key"
+	case errors.Is(err, types.ErrUnavailable):
+		if provider == string(types.ProviderOllama) && viper.IsSet("ai.local.endpoints") {
+			return "no endpoint of ai.local.endpoints is reachable, `crazy doctor` checks each of them"
+		}
+		if provider == string(types.ProviderOllama) {
+			return fmt.Sprintf("start Ollama with `ollama serve`, or check ai.local.endpoint (%s)", viper.GetString("ai.local.endpoint"))
+		}
+		return "the provider is unavailable, try again later or pick a local model with -m"
+	}
+	return ""
+}
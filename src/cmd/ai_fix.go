@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/shellcmd"
+	"github.com/rrecio/crazy-dev-zsh/src/core/history"
+	"github.com/rrecio/crazy-dev-zsh/src/core/privacy"
+	"github.com/rrecio/crazy-dev-zsh/src/core/shellhook"
+)
+
+// Limits on the files sent with a failed command
+const (
+	defaultFixMaxFiles = 3
+	fixSnippetContext  = 15
+)
+
+// failedRun is the failed command in the output of ai fix
+type failedRun struct {
+	Command  string    `json:"command" yaml:"command"`
+	ExitCode int       `json:"exit_code" yaml:"exit_code"`
+	Cwd      string    `json:"cwd" yaml:"cwd"`
+	Time     time.Time `json:"time" yaml:"time"`
+	Stderr   string    `json:"stderr,omitempty" yaml:"stderr,omitempty"`
+}
+
+// fixResult is the structured output of ai fix
+type fixResult struct {
+	Failed              failedRun `json:"failed" yaml:"failed"`
+	shellcmd.Suggestion `yaml:",inline"`
+	Files               []string `json:"files" yaml:"files"`
+	Model               string   `json:"model" yaml:"model"`
+	Provider            string   `json:"provider" yaml:"provider"`
+	LatencyMs           int64    `json:"latency_ms" yaml:"latency_ms"`
+}
+
+// fixCmd represents the ai fix subcommand
+var fixCmd = &cobra.Command{
+	Use:   "fix",
+	Short: "Diagnose the last failed command and propose a fix",
+	Long: `Diagnose the last command that failed in this shell and propose a
+corrected command. The command is printed, never run.
+
+The failed command comes from the history recorded by the hooks of
+'crazy init', with its exit status and directory. Its error output is sent
+too when the hooks capture it ('crazy init --capture-stderr'), along with
+the lines of the files it mentions, such as main.go:42 in a compiler error.
+Only files inside the project are read, and not those its privacy policy
+denies. The request uses the error_diagnosis template.
+
+Only the commands of the current shell are considered, as identified by the
+CRAZY_SESSION variable the hooks export; --session picks another one.
+
+With --command-only the corrected command alone goes to stdout and the
+diagnosis to stderr, for shell widgets. 'crazy init --bind-ai' binds one
+that puts the fix on the command line to Ctrl-X Ctrl-F.
+
+Exit codes: 0 on success, 1 if no failed command is recorded, the request
+fails or the answer holds no command, and 130 if interrupted.`,
+	Example: `  crazy ai fix
+  crazy ai fix -o json`,
+	Args: cobra.NoArgs,
+	Run:  runFixCommand,
+}
+
+func init() {
+	aiCmd.AddCommand(fixCmd)
+
+	fixCmd.Flags().String("session", "", "Shell session to diagnose (default: $CRAZY_SESSION)")
+	fixCmd.Flags().Int("max-files", defaultFixMaxFiles, "Maximum mentioned files sent with the error")
+	fixCmd.Flags().Float64P("temperature", "t", 0.2, "Temperature for response generation (0.0-1.0)")
+	fixCmd.Flags().Duration("timeout", 2*time.Minute, "Maximum time to wait for the diagnosis")
+	fixCmd.Flags().Bool("command-only", false, "Print only the corrected command on stdout, the diagnosis on stderr")
+}
+
+// runFixCommand executes the ai fix subcommand
+func runFixCommand(cmd *cobra.Command, args []string) {
+	session, _ := cmd.Flags().GetString("session")
+	if session == "" {
+		session = os.Getenv("CRAZY_SESSION")
+	}
+	maxFiles, _ := cmd.Flags().GetInt("max-files")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	commandOnly, _ := cmd.Flags().GetBool("command-only")
+	output := viper.GetString("output")
+	structured := output == "json" || output == "yaml"
+
+	store := mustOpenHistory()
+	run, err := store.Last(history.Filter{Failed: true, Session: session})
+	store.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeError)
+	}
+	if run == nil {
+		if session != "" {
+			fmt.Fprintln(os.Stderr, "Error: no failed command recorded in this shell")
+		} else {
+			fmt.Fprintln(os.Stderr, "Error: no failed command recorded, set up the shell hooks with 'crazy init'")
+		}
+		exitWith(exitCodeError)
+	}
+
+	if !structured {
+		fmt.Fprintf(os.Stderr, "✗ %s (exit %d)\n", run.Command, run.ExitCode)
+		if run.Stderr == "" {
+			fmt.Fprintln(os.Stderr, "  its error output was not captured, see --capture-stderr in 'crazy init --help'")
+		}
+	}
+
+	lib, tmpl := mustLoadPromptTemplate("error_diagnosis")
+	code, files := fixSnippets(run, maxFiles)
+	errorText := run.Stderr
+	if errorText == "" {
+		errorText = fmt.Sprintf("exit status %d, the error output was not captured", run.ExitCode)
+	}
+	rendered, err := lib.Render(tmpl.Name, map[string]string{
+		"Error":      errorText,
+		"Code":       code,
+		"Command":    run.Command,
+		"ExitCode":   strconv.Itoa(run.ExitCode),
+		"CurrentDir": run.Cwd,
+		"Shell":      run.Shell,
+		"OS":         shellcmd.OS(),
+	}, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering template: %v\n", err)
+		exitWith(exitCodeError)
+	}
+
+	if aiEngine == nil {
+		if err := initAIEngine(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing AI engine: %v\n", err)
+			exitWith(exitCodeError)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	req := templateRequest(cmd, tmpl, rendered)
+	resp, err := aiEngine.Chat(ctx, req)
+	if err != nil {
+		printAIError(os.Stderr, err)
+		if errors.Is(err, context.Canceled) {
+			exitWith(exitCodeInterrupted)
+		}
+		exitWith(exitCodeError)
+	}
+
+	result := fixResult{
+		Failed: failedRun{
+			Command:  run.Command,
+			ExitCode: run.ExitCode,
+			Cwd:      run.Cwd,
+			Time:     run.Time,
+			Stderr:   run.Stderr,
+		},
+		Suggestion: shellcmd.Parse(resp.Text),
+		Files:      files,
+		Model:      resp.SelectedModel,
+		Provider:   resp.SelectedProvider,
+		LatencyMs:  time.Since(start).Milliseconds(),
+	}
+	if result.Model == "" {
+		result.Model = req.Model
+	}
+	printSuggestion(result.Suggestion, output, commandOnly, result)
+}
+
+// fixSnippets returns the lines of the files mentioned by a failed command
+// and its error output, and the paths of those files relative to where it
+// ran. Only files in the project, or in the directory when it is in none,
+// are read, and not those the project's privacy policy denies.
+func fixSnippets(run *shellhook.Event, maxFiles int) (string, []string) {
+	files := []string{}
+	if maxFiles <= 0 {
+		return "", files
+	}
+	root := run.Project
+	if root == "" {
+		root = run.Cwd
+	}
+	policy := privacy.Load(run.Cwd)
+
+	var sb strings.Builder
+	for _, ref := range shellcmd.MentionedFiles(run.Cwd, root, run.Command+"\n"+run.Stderr, maxFiles) {
+		if policy.Denied(ref.Path) {
+			continue
+		}
+		snippet, err := shellcmd.Snippet(ref, fixSnippetContext)
+		if err != nil {
+			continue
+		}
+		name := ref.Path
+		if rel, err := filepath.Rel(run.Cwd, ref.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		files = append(files, name)
+		fmt.Fprintf(&sb, "--- %s ---\n%s\n", name, snippet)
+	}
+	return strings.TrimRight(sb.String(), "\n"), files
+}
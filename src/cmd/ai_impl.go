@@ -1,22 +1,28 @@
 package cmd
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 	
 	"github.com/rrecio/crazy-dev-zsh/src/ai"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/chat"
 	"github.com/rrecio/crazy-dev-zsh/src/ai/factory"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/prompt"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/transport"
 	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
 	ctxanalyzer "github.com/rrecio/crazy-dev-zsh/src/core/context"
+	"github.com/rrecio/crazy-dev-zsh/src/ui/lineedit"
+	"github.com/rrecio/crazy-dev-zsh/src/ui/markdown"
 )
 
 var (
@@ -37,6 +43,32 @@ func initAIEngine() error {
 		CacheTTL:          viper.GetDuration("ai.cloud.cache_ttl"),
 		AIResponseTimeout: viper.GetDuration("core.ai_response_timeout"),
 		CloudAITimeout:    viper.GetDuration("core.cloud_ai_timeout"),
+
+		CompactionEnabled:    viper.GetBool("ai.compaction.enabled"),
+		ContextWindow:        viper.GetInt("ai.compaction.context_window"),
+		CompactionThreshold:  viper.GetFloat64("ai.compaction.threshold"),
+		CompactionKeepRecent: viper.GetInt("ai.compaction.keep_recent"),
+
+		ScriptedFile: viper.GetString("ai.scripted.file"),
+
+		RedactionEnabled: viper.GetBool("ai.redaction.enabled"),
+
+		AutoContinue:     viper.GetBool("ai.auto_continue.enabled"),
+		MaxContinuations: viper.GetInt("ai.auto_continue.max_continuations"),
+
+		Privacy:      loadPrivacyPolicy(),
+		ConfirmCloud: confirmCloudRequest,
+	}
+	if err := viper.UnmarshalKey("ai.compaction.model_context_windows", &config.ModelContextWindows); err != nil {
+		return fmt.Errorf("invalid ai.compaction.model_context_windows: %w", err)
+	}
+	transports, err := transportConfigs()
+	if err != nil {
+		return err
+	}
+	config.Transports = transports
+	if config.LocalEndpoints, err = localEndpoints(); err != nil {
+		return err
 	}
 
 	// Convert config and create AI engine using the factory
@@ -54,6 +86,29 @@ func initAIEngine() error {
 	return nil
 }
 
+// transportConfigs reads the HTTP transport of each provider from ai.transport
+func transportConfigs() (map[string]transport.Config, error) {
+	var configs map[string]transport.Config
+	if err := viper.UnmarshalKey("ai.transport", &configs); err != nil {
+		return nil, fmt.Errorf("invalid ai.transport: %w", err)
+	}
+	return configs, nil
+}
+
+// localEndpoints reads the Ollama servers of ai.local.endpoints
+func localEndpoints() ([]types.LocalEndpoint, error) {
+	var endpoints []types.LocalEndpoint
+	if err := viper.UnmarshalKey("ai.local.endpoints", &endpoints); err != nil {
+		return nil, fmt.Errorf("invalid ai.local.endpoints: %w", err)
+	}
+	for i, endpoint := range endpoints {
+		if endpoint.URL == "" {
+			return nil, fmt.Errorf("invalid ai.local.endpoints: entry %d has no url", i+1)
+		}
+	}
+	return endpoints, nil
+}
+
 // runAICommand executes the main AI command
 func runAICommand(cmd *cobra.Command, args []string) {
 	// Initialize AI engine if not already initialized
@@ -67,14 +122,17 @@ func runAICommand(cmd *cobra.Command, args []string) {
 	// List available models
 	fmt.Println("Available AI models:")
 	
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(cmd.Context(), 5*time.Second)
 	defer cancel()
 	
 	models, err := aiEngine.ListModels(ctx, "")
-	if err != nil {
+	if err != nil && len(models) == 0 {
 		fmt.Printf("Error listing models: %v\n", err)
 		return
 	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
 	
 	// Group models by provider
 	modelsByProvider := make(map[string][]ai.ModelInfo)
@@ -116,84 +174,148 @@ func runChatCommand(cmd *cobra.Command, args []string) {
 	model, _ := cmd.Flags().GetString("model")
 	includeContext, _ := cmd.Flags().GetBool("context")
 	temperature, _ := cmd.Flags().GetFloat64("temperature")
+	profileName, _ := cmd.Flags().GetString("profile")
+	
+	// Initialize the chat session
+	session := chat.NewSession(aiEngine, model)
+	session.Temperature = temperature
+	session.ContextLoader = loadProjectContext
+	
+	var profiles map[string]chat.Profile
+	if err := viper.UnmarshalKey("ai.profiles", &profiles); err != nil {
+		fmt.Printf("Warning: Could not read chat profiles: %v\n", err)
+	}
+	for name, profile := range profiles {
+		profile.Name = name
+		session.Profiles[name] = profile
+	}
+	if profileName != "" {
+		profile, ok := session.Profiles[profileName]
+		if !ok {
+			fmt.Printf("Error: profile %q not found\n", profileName)
+			return
+		}
+		session.ApplyProfile(profile)
+	}
 	
 	// Welcome message
-	fmt.Println("Starting AI chat session. Type 'exit' or 'quit' to end the session.")
-	fmt.Printf("Using model: %s\n", model)
+	fmt.Println("Starting AI chat session. Type /help for commands, 'exit' or 'quit' to end the session.")
+	fmt.Printf("Using model: %s\n", session.Model)
 	
 	// Initialize context data if needed
-	var contextData []byte
 	if includeContext {
 		fmt.Println("Analyzing project context...")
-		contextData = getProjectContext()
-	}
-	
-	// Initialize chat history
-	messages := []ai.Message{
-		{
-			Role:    "system",
-			Content: "You are a helpful AI assistant for software development. Provide concise and accurate responses.",
-		},
+		session.Context = getProjectContext(cmd.Context())
 	}
 	
 	// Chat loop
-	scanner := bufio.NewScanner(os.Stdin)
+	editor := lineedit.New(chatHistoryPath())
+	session.Compose = lineedit.EditText
 	userColor := color.New(color.FgCyan).SprintFunc()
 	aiColor := color.New(color.FgGreen).SprintFunc()
+	errColor := color.New(color.FgRed).SprintFunc()
+	noticeColor := color.New(color.FgYellow).SprintFunc()
+	
+	if editor.Interactive() {
+		fmt.Println("Alt-Enter adds a line, Ctrl-X Ctrl-E opens $EDITOR, /multiline toggles multi-line mode.")
+	}
+	
+	renderer := newMarkdownRenderer()
+	printChunk := func(chunk string) error {
+		_, err := renderer.WriteString(chunk)
+		return err
+	}
 	
 	for {
 		// Get user input
-		fmt.Print(userColor("You: "))
-		if !scanner.Scan() {
+		editor.SetMultiline(session.Multiline)
+		userInput, err := editor.ReadLine(userColor("You: "))
+		if err == lineedit.ErrInterrupted {
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				fmt.Printf("Error reading input: %v\n", err)
+			}
 			break
 		}
 		
-		userInput := scanner.Text()
+		userInput = strings.TrimSpace(userInput)
+		if userInput == "" {
+			continue
+		}
+		editor.AddHistory(userInput)
 		if userInput == "exit" || userInput == "quit" {
 			break
 		}
 		
-		// Add user message to history
-		messages = append(messages, ai.Message{
-			Role:    "user",
-			Content: userInput,
-		})
-		
-		// Create AI request
-		req := ai.AIRequest{
-			Model:       model,
-			ModelType:   ai.ModelTypeChat,
-			Messages:    messages,
-			Temperature: temperature,
-			Context:     contextData,
+		// Handle slash commands
+		action, handled, err := chat.DefaultRegistry.Dispatch(cmd.Context(), session, userInput)
+		if err != nil {
+			fmt.Println(errColor("Error: " + err.Error()))
+		}
+		if handled && action == chat.ActionExit {
+			break
+		}
+		if handled && action == chat.ActionSubmit {
+			userInput = session.Draft
+			session.Draft = ""
+			editor.AddHistory(userInput)
+			handled = false
+		}
+		if handled && action != chat.ActionResend {
+			continue
 		}
 		
 		// Stream the response
 		fmt.Print(aiColor("AI: "))
 		
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		var responseText strings.Builder
-		
-		_, err := aiEngine.StreamChat(ctx, req, func(chunk string) error {
-			fmt.Print(chunk)
-			responseText.WriteString(chunk)
-			return nil
-		})
-		
+		ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+		var resp *ai.AIResponse
+		if handled {
+			resp, err = session.Resend(ctx, printChunk)
+		} else {
+			resp, err = session.Send(ctx, userInput, printChunk)
+		}
 		cancel()
+		renderer.Flush()
 		fmt.Println()
 		
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			continue
+			printAIError(os.Stdout, err)
 		}
-		
-		// Add assistant message to history
-		messages = append(messages, ai.Message{
-			Role:    "assistant",
-			Content: responseText.String(),
-		})
+		if resp != nil && resp.Compaction != nil {
+			fmt.Println(noticeColor("[" + chat.DescribeCompaction(resp.Compaction) + "]"))
+		}
+	}
+}
+
+// chatHistoryPath returns the file used to persist chat input history
+func chatHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
 	}
+	return filepath.Join(home, ".crazy-dev", "chat_history")
+}
+
+// newMarkdownRenderer returns a renderer for AI output on stdout. Markdown is only
+// rendered when stdout is a terminal and the output format is text.
+func newMarkdownRenderer() *markdown.Renderer {
+	fd := int(os.Stdout.Fd())
+	output := viper.GetString("output")
+	plain := (output != "" && output != "text") || !term.IsTerminal(fd)
+	
+	width := 0
+	if w, _, err := term.GetSize(fd); err == nil {
+		width = w
+	}
+	
+	return markdown.New(os.Stdout, markdown.Options{
+		Theme: markdown.NewTheme(viper.GetStringMapString("ui.colors")),
+		Width: width,
+		Plain: plain,
+	})
 }
 
 // runSuggestCommand executes the AI suggest subcommand
@@ -212,7 +334,7 @@ func runSuggestCommand(cmd *cobra.Command, args []string) {
 	
 	// Get project context
 	fmt.Println("Analyzing project context...")
-	contextData := getProjectContext()
+	contextData := getProjectContext(cmd.Context())
 	
 	// Create prompt based on suggestion type
 	var prompt string
@@ -246,46 +368,55 @@ func runSuggestCommand(cmd *cobra.Command, args []string) {
 	}
 	
 	// Get the response
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
 	defer cancel()
 	
 	fmt.Printf("Generating %s suggestions using %s...\n", suggestionType, model)
 	
 	response, err := aiEngine.Chat(ctx, req)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		printAIError(os.Stdout, err)
 		return
 	}
 	
 	// Print the response
 	fmt.Println("\n--- AI Suggestions ---")
-	fmt.Println(response.Text)
+	renderer := newMarkdownRenderer()
+	renderer.WriteString(response.Text)
+	renderer.Flush()
+	fmt.Println()
 	fmt.Println("---------------------")
 }
 
-// getProjectContext gets the project context data
-func getProjectContext() []byte {
-	// Get current directory
+// loadProjectContext analyzes the current directory and returns the context data
+func loadProjectContext(ctx context.Context, refresh bool) ([]byte, error) {
 	currentDir, err := os.Getwd()
 	if err != nil {
-		fmt.Printf("Warning: Could not get current directory: %v\n", err)
-		return nil
+		return nil, fmt.Errorf("could not get current directory: %w", err)
 	}
 	
-	// Create analyzer
 	analyzer := ctxanalyzer.NewContextAnalyzer(500, 10*1024*1024) // Max 500 files, 10MB max file size
 	
-	// Run analysis
-	result, err := analyzer.Analyze(currentDir)
+	var result *ctxanalyzer.AnalysisResult
+	if refresh {
+		result, err = analyzer.RefreshAnalysisContext(ctx, currentDir)
+	} else {
+		result, err = analyzer.AnalyzeContext(ctx, currentDir)
+	}
 	if err != nil {
-		fmt.Printf("Warning: Could not analyze project context: %v\n", err)
-		return nil
+		return nil, fmt.Errorf("could not analyze project context: %w", err)
 	}
 	
-	// Convert to JSON
-	contextData, err := json.Marshal(result)
+	// The shell integration exports the file being edited, if any
+	currentFile := os.Getenv("CRAZY_CURRENT_FILE")
+	return prompt.EncodeContext(result, currentDir, currentFile, ctxanalyzer.CurrentBranch(currentDir))
+}
+
+// getProjectContext gets the project context data
+func getProjectContext(ctx context.Context) []byte {
+	contextData, err := loadProjectContext(ctx, false)
 	if err != nil {
-		fmt.Printf("Warning: Could not marshal context data: %v\n", err)
+		fmt.Printf("Warning: %v\n", err)
 		return nil
 	}
 	
@@ -306,14 +437,20 @@ func runModelsCommand(cmd *cobra.Command, args []string) {
 	provider, _ := cmd.Flags().GetString("provider")
 	
 	// List available models
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(cmd.Context(), 5*time.Second)
 	defer cancel()
 	
 	models, err := aiEngine.ListModels(ctx, provider)
-	if err != nil {
+	if err != nil && len(models) == 0 {
 		fmt.Printf("Error listing models: %v\n", err)
+		if hint := aiErrorHint(err); hint != "" {
+			fmt.Printf("Hint: %s\n", hint)
+		}
 		return
 	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
 	
 	// Group models by provider
 	modelsByProvider := make(map[string][]ai.ModelInfo)
@@ -322,6 +459,10 @@ func runModelsCommand(cmd *cobra.Command, args []string) {
 		modelsByProvider[provider] = append(modelsByProvider[provider], model)
 	}
 	
+	// With several Ollama servers, show which ones host each model
+	endpoints, _ := localEndpoints()
+	showEndpoints := len(endpoints) > 0
+	
 	// Print models by provider
 	for provider, models := range modelsByProvider {
 		fmt.Printf("\n%s:\n", strings.ToUpper(provider))
@@ -333,6 +474,9 @@ func runModelsCommand(cmd *cobra.Command, args []string) {
 			if model.Default {
 				status += " (Default)"
 			}
+			if showEndpoints && len(model.Endpoints) > 0 {
+				status += " on " + strings.Join(model.Endpoints, ", ")
+			}
 			fmt.Printf("  - %s: %s [%s]\n", model.Name, model.Description, status)
 		}
 	}
@@ -360,12 +504,15 @@ func runInstallCommand(cmd *cobra.Command, args []string) {
 	// Install the model
 	fmt.Printf("Installing model %s...\n", modelName)
 	
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(cmd.Context(), 5*time.Minute)
 	defer cancel()
 	
 	err := aiEngine.InstallModel(ctx, modelName)
 	if err != nil {
 		fmt.Printf("Error installing model: %v\n", err)
+		if hint := aiErrorHint(err); hint != "" {
+			fmt.Printf("Hint: %s\n", hint)
+		}
 		return
 	}
 	
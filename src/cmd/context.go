@@ -10,6 +10,7 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/rrecio/crazy-dev-zsh/src/core/context"
+	"github.com/rrecio/crazy-dev-zsh/src/core/privacy"
 )
 
 // contextCmd represents the context command
@@ -60,12 +61,12 @@ Examples:
 			if verbose {
 				fmt.Println("Refreshing context analysis...")
 			}
-			result, err = analyzer.RefreshAnalysis(path)
+			result, err = analyzer.RefreshAnalysisContext(cmd.Context(), path)
 		} else {
 			if verbose {
 				fmt.Println("Analyzing project context...")
 			}
-			result, err = analyzer.Analyze(path)
+			result, err = analyzer.AnalyzeContext(cmd.Context(), path)
 		}
 		
 		if err != nil {
@@ -77,7 +78,10 @@ Examples:
 		switch outputFormat {
 		case "json":
 			// Output as JSON
-			jsonData, err := json.MarshalIndent(result, "", "  ")
+			jsonData, err := json.MarshalIndent(struct {
+				*context.AnalysisResult
+				Privacy *privacy.Policy `json:"privacy"`
+			}{result, privacy.Load(result.ProjectPath)}, "", "  ")
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error marshaling to JSON: %v\n", err)
 				os.Exit(1)
@@ -167,6 +171,10 @@ func printAnalysisResult(result *context.AnalysisResult, verbose bool) {
 	}
 	fmt.Println()
 	
+	// Whether the project's code may be sent to cloud providers
+	printPrivacyPolicy(privacy.Load(result.ProjectPath))
+	fmt.Println()
+	
 	// Dependencies
 	if len(result.Dependencies) > 0 {
 		fmt.Println("Dependencies:")
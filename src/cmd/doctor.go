@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rrecio/crazy-dev-zsh/src/core/doctor"
+)
+
+// doctorReport is the structured output of crazy doctor
+type doctorReport struct {
+	Results  []doctor.Result `json:"results" yaml:"results"`
+	Passed   int             `json:"passed" yaml:"passed"`
+	Warnings int             `json:"warnings" yaml:"warnings"`
+	Failed   int             `json:"failed" yaml:"failed"`
+}
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the installation and environment",
+	Long: `Diagnose the installation and environment.
+
+Doctor checks that Ollama answers at ai.local.endpoint, or at each server of
+ai.local.endpoints, that the configured
+models are installed, which cloud API keys are set, that the context cache is
+writable, which config file is in use and whether shell completion is
+installed. Each check passes, warns or fails with a suggestion on how to fix
+it, and --fix repairs what can be repaired automatically.
+
+The exit status is 1 when a check fails.`,
+	Example: `  crazy doctor
+  crazy doctor --fix
+  crazy doctor -o json`,
+	Args: cobra.NoArgs,
+	Run:  runDoctorCommand,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+
+	doctorCmd.Flags().Bool("fix", false, "Repair the problems that can be fixed automatically")
+}
+
+// runDoctorCommand executes the doctor command
+func runDoctorCommand(cmd *cobra.Command, args []string) {
+	fix, _ := cmd.Flags().GetBool("fix")
+	output := viper.GetString("output")
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	results := doctor.Run(ctx, doctor.Checks(), fix)
+	report := doctorReport{Results: results}
+	report.Passed, report.Warnings, report.Failed = doctor.Count(results)
+
+	switch output {
+	case "json":
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	case "yaml":
+		data, _ := yaml.Marshal(report)
+		fmt.Print(string(data))
+	default:
+		printDoctorReport(report)
+	}
+
+	if ctx.Err() != nil {
+		exitWith(exitCodeInterrupted)
+	}
+	if report.Failed > 0 {
+		exitWith(exitCodeError)
+	}
+}
+
+// printDoctorReport prints one line per check with suggestions and a summary
+func printDoctorReport(report doctorReport) {
+	fixable := 0
+	for _, result := range report.Results {
+		fmt.Printf("%s %-12s %s\n", statusMark(result.Status), result.Check, result.Message)
+		switch {
+		case result.Fixed:
+			fmt.Printf("  %s\n", color.New(color.FgGreen).Sprint("fixed"))
+		case result.FixError != "":
+			fmt.Printf("  %s %s\n", color.New(color.FgRed).Sprint("fix failed:"), result.FixError)
+		}
+		if result.Status != doctor.StatusPass && result.Suggestion != "" {
+			fmt.Printf("  → %s\n", result.Suggestion)
+		}
+		if result.Status != doctor.StatusPass && result.Fixable && !result.Fixed && result.FixError == "" {
+			fixable++
+		}
+	}
+
+	fmt.Printf("\n%d passed, %d warnings, %d failed\n", report.Passed, report.Warnings, report.Failed)
+	if fixable > 0 {
+		fmt.Printf("Run `crazy doctor --fix` to repair %d of them\n", fixable)
+	}
+}
+
+// statusMark returns a colored mark for a check status
+func statusMark(status doctor.Status) string {
+	switch status {
+	case doctor.StatusPass:
+		return color.New(color.FgGreen).Sprint("✓")
+	case doctor.StatusWarn:
+		return color.New(color.FgYellow).Sprint("!")
+	}
+	return color.New(color.FgRed).Sprint("✗")
+}
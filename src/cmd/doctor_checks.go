@@ -0,0 +1,449 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/ollama"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/transport"
+	"github.com/rrecio/crazy-dev-zsh/src/ai/types"
+	"github.com/rrecio/crazy-dev-zsh/src/core/doctor"
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
+)
+
+// cloudKeyEnv maps cloud providers to the environment variable holding their API key
+var cloudKeyEnv = map[string]string{
+	"openai":    "CRAZY_OPENAI_API_KEY",
+	"anthropic": "CRAZY_ANTHROPIC_API_KEY",
+}
+
+func init() {
+	doctor.Register(doctor.Check{Name: "config", Run: checkConfig, Fix: fixConfig})
+	doctor.Register(doctor.Check{Name: "ollama", Run: checkOllama})
+	// Pulling a model can take minutes
+	doctor.Register(doctor.Check{Name: "models", Run: checkModels, Fix: fixModels, FixTimeout: 30 * time.Minute})
+	doctor.Register(doctor.Check{Name: "api keys", Run: checkAPIKeys})
+	doctor.Register(doctor.Check{Name: "cache", Run: checkCache, Fix: fixCache})
+	doctor.Register(doctor.Check{Name: "completion", Run: checkCompletion, Fix: fixCompletion})
+}
+
+// checkConfig reports which config file viper picked and whether it parses
+func checkConfig(ctx context.Context) doctor.Result {
+	used := viper.ConfigFileUsed()
+	if used == "" || strings.HasPrefix(filepath.Base(used), "config-default") {
+		result := doctor.Warn("create "+getDefaultConfigPath()+" to keep your settings", "No user config file, using built-in defaults")
+		if used != "" {
+			result.Message = "No user config file, using the defaults in " + used
+		}
+		return result
+	}
+
+	data, err := os.ReadFile(used)
+	if err != nil {
+		return doctor.Fail("check the file's permissions", "Cannot read %s: %v", used, err)
+	}
+	var parsed map[string]interface{}
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return doctor.Fail("fix the YAML syntax of "+used, "Invalid config %s: %v", used, err)
+	}
+	return doctor.Pass("Using %s", used)
+}
+
+// fixConfig creates the user config file from the defaults in use
+func fixConfig(ctx context.Context) error {
+	path := getDefaultConfigPath()
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create config directory: %w", err)
+	}
+
+	// Copy the bundled defaults to keep their comments
+	if used := viper.ConfigFileUsed(); used != "" {
+		if data, err := os.ReadFile(used); err == nil {
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				return err
+			}
+			return useConfigFile(path)
+		}
+	}
+	if err := viper.SafeWriteConfigAs(path); err != nil {
+		return err
+	}
+	return useConfigFile(path)
+}
+
+// useConfigFile switches viper to a newly created config file
+func useConfigFile(path string) error {
+	viper.SetConfigFile(path)
+	return viper.ReadInConfig()
+}
+
+// checkOllama reports whether Ollama answers at each of its endpoints
+func checkOllama(ctx context.Context) doctor.Result {
+	if !viper.GetBool("ai.local.enabled") {
+		return doctor.Pass("Local models are disabled (ai.local.enabled)")
+	}
+	pool, err := newOllamaPool()
+	if err != nil {
+		return doctor.Fail("check ai.local.endpoint(s) and ai.transport", "Cannot create the Ollama client: %v", err)
+	}
+
+	statuses := pool.Status(ctx)
+	if len(statuses) == 1 {
+		status := statuses[0]
+		if !status.Healthy {
+			return doctor.Fail(aiErrorHint(status.Err), "Ollama is not reachable at %s: %s", status.URL, status.Error)
+		}
+		return doctor.Pass("Reachable at %s, %d models installed", status.URL, len(status.Installed))
+	}
+
+	var up, down []string
+	for _, status := range statuses {
+		if status.Healthy {
+			up = append(up, fmt.Sprintf("%s (%d models, %d loaded)", status.Name, len(status.Installed), len(status.Loaded)))
+		} else {
+			down = append(down, fmt.Sprintf("%s at %s: %s", status.Name, status.URL, status.Error))
+		}
+	}
+	switch {
+	case len(up) == 0:
+		return doctor.Fail("check the servers and tokens of ai.local.endpoints", "No endpoint is reachable: %s", strings.Join(down, "; "))
+	case len(down) > 0:
+		return doctor.Warn("requests fail over to the reachable endpoints", "Unreachable: %s; reachable: %s", strings.Join(down, "; "), strings.Join(up, ", "))
+	}
+	return doctor.Pass("All %d endpoints reachable: %s", len(up), strings.Join(up, ", "))
+}
+
+// checkModels reports which models of ai.local.models are not installed
+func checkModels(ctx context.Context) doctor.Result {
+	if !viper.GetBool("ai.local.enabled") {
+		return doctor.Pass("Local models are disabled (ai.local.enabled)")
+	}
+	missing, err := missingModels(ctx)
+	if err != nil {
+		return doctor.Warn("fix the ollama check first", "Skipped, Ollama is not reachable")
+	}
+	if len(missing) > 0 {
+		return doctor.Warn("run `crazy ai install "+missing[0]+"` for each missing model", "Not installed: %s", strings.Join(missing, ", "))
+	}
+	return doctor.Pass("All configured models are installed: %s", strings.Join(viper.GetStringSlice("ai.local.models"), ", "))
+}
+
+// fixModels pulls the configured models that are not installed
+func fixModels(ctx context.Context) error {
+	missing, err := missingModels(ctx)
+	if err != nil {
+		return err
+	}
+	pool, err := newOllamaPool()
+	if err != nil {
+		return err
+	}
+	for _, model := range missing {
+		if err := pool.InstallModel(ctx, model); err != nil {
+			return fmt.Errorf("failed to install %s: %w", model, err)
+		}
+	}
+	return nil
+}
+
+// newOllamaPool connects to the configured Ollama endpoints with the
+// configured transport
+func newOllamaPool() (*ollama.Pool, error) {
+	configs, err := transportConfigs()
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := localEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		endpoints = []types.LocalEndpoint{{URL: viper.GetString("ai.local.endpoint")}}
+	}
+	return ollama.NewPool(endpoints, transport.For(configs, "ollama"))
+}
+
+// missingModels returns the models of ai.local.models Ollama does not have
+func missingModels(ctx context.Context) ([]string, error) {
+	pool, err := newOllamaPool()
+	if err != nil {
+		return nil, err
+	}
+	installed, err := pool.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for _, model := range installed {
+		names[model.Name] = true
+		// Ollama lists "llama3.2" as "llama3.2:latest"
+		names[strings.TrimSuffix(model.Name, ":latest")] = true
+	}
+	var missing []string
+	for _, model := range viper.GetStringSlice("ai.local.models") {
+		if !names[model] {
+			missing = append(missing, model)
+		}
+	}
+	return missing, nil
+}
+
+// checkAPIKeys reports which cloud API keys are set
+func checkAPIKeys(ctx context.Context) doctor.Result {
+	provider := viper.GetString("ai.cloud.provider")
+	env, known := cloudKeyEnv[provider]
+	if !known {
+		return doctor.Fail("set ai.cloud.provider to openai or anthropic", "Unknown cloud provider %q", provider)
+	}
+
+	var set []string
+	for _, name := range []string{"CRAZY_OPENAI_API_KEY", "CRAZY_ANTHROPIC_API_KEY"} {
+		if os.Getenv(name) != "" {
+			set = append(set, name)
+		}
+	}
+	if os.Getenv(env) == "" {
+		return doctor.Warn("export "+env+"=<key> to use "+provider+" models", "%s is not set, so %s models are unavailable", env, provider)
+	}
+	return doctor.Pass("Set: %s", strings.Join(set, ", "))
+}
+
+// cacheDir returns the directory of the context cache
+func cacheDir() string {
+	return logging.ExpandHome("~/.crazy-dev/cache")
+}
+
+// checkCache reports whether the context cache database is writable
+func checkCache(ctx context.Context) doctor.Result {
+	dir := cacheDir()
+	db := filepath.Join(dir, "context.db")
+	info, err := os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return doctor.Warn("it is created on the first analysis, or with --fix", "%s does not exist yet", dir)
+	}
+	if err != nil || !info.IsDir() {
+		return doctor.Fail("remove it and run `crazy doctor --fix`", "%s is not a directory", dir)
+	}
+
+	probe, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return doctor.Fail("run `chmod u+w "+dir+"`", "%s is not writable: %v", dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	if _, err := os.Stat(db); err == nil {
+		f, err := os.OpenFile(db, os.O_WRONLY, 0)
+		if err != nil {
+			return doctor.Fail("run `chmod u+w "+db+"`", "%s is not writable: %v", db, err)
+		}
+		f.Close()
+	}
+	return doctor.Pass("%s is writable", db)
+}
+
+// fixCache creates the cache directory
+func fixCache(ctx context.Context) error {
+	return os.MkdirAll(cacheDir(), 0755)
+}
+
+// completionPaths lists where each shell's completion for crazy may be
+// installed. The first path is where --fix installs it.
+func completionPaths(shell string) []string {
+	home, _ := os.UserHomeDir()
+	switch shell {
+	case "zsh":
+		return []string{
+			filepath.Join(home, ".zsh", "completions", "_crazy"),
+			filepath.Join(home, ".oh-my-zsh", "completions", "_crazy"),
+			"/usr/local/share/zsh/site-functions/_crazy",
+			"/opt/homebrew/share/zsh/site-functions/_crazy",
+			"/usr/share/zsh/site-functions/_crazy",
+		}
+	case "bash":
+		return []string{
+			filepath.Join(home, ".local", "share", "bash-completion", "completions", "crazy"),
+			"/etc/bash_completion.d/crazy",
+			"/usr/local/etc/bash_completion.d/crazy",
+			"/usr/share/bash-completion/completions/crazy",
+		}
+	case "fish":
+		return []string{filepath.Join(home, ".config", "fish", "completions", "crazy.fish")}
+	}
+	return nil
+}
+
+// currentShell returns the name of the user's shell
+func currentShell() string {
+	return filepath.Base(os.Getenv("SHELL"))
+}
+
+// checkCompletion reports whether shell completion is installed for the user's shell
+func checkCompletion(ctx context.Context) doctor.Result {
+	shell := currentShell()
+	paths := completionPaths(shell)
+	if paths == nil {
+		return doctor.Pass("No completion check for shell %q", shell)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if shell == "zsh" {
+			return checkZshCompletion(ctx, path)
+		}
+		return doctor.Pass("Installed for %s at %s", shell, path)
+	}
+
+	// Completion may also be loaded from the shell's startup file
+	rc := logging.ExpandHome("~/." + shell + "rc")
+	if data, err := os.ReadFile(rc); err == nil && strings.Contains(string(data), "crazy completion "+shell) {
+		return doctor.Pass("Loaded for %s from %s", shell, rc)
+	}
+	return doctor.Warn("see `crazy completion --help`", "Not installed for %s", shell)
+}
+
+// checkZshCompletion reports whether zsh loads the completion installed at
+// path: its directory must be on $fpath and compinit must run
+func checkZshCompletion(ctx context.Context, path string) doctor.Result {
+	zshrc := readZshrc()
+	dir := filepath.Dir(path)
+	if !zshFpathHas(ctx, zshrc, dir) {
+		return doctor.Warn("run `crazy doctor --fix` or add `fpath=("+dir+" $fpath)` before compinit in ~/.zshrc",
+			"Installed at %s, but %s is not on $fpath", path, dir)
+	}
+	if !zshRunsCompinit(zshrc) {
+		return doctor.Warn("run `crazy doctor --fix` or add `autoload -U compinit; compinit` to ~/.zshrc",
+			"Installed at %s, but ~/.zshrc does not run compinit", path)
+	}
+	return doctor.Pass("Installed for zsh at %s", path)
+}
+
+// readZshrc returns the contents of ~/.zshrc, empty when it does not exist
+func readZshrc() string {
+	data, _ := os.ReadFile(logging.ExpandHome("~/.zshrc"))
+	return string(data)
+}
+
+// zshFpathHas reports whether dir is on zsh's default $fpath or added to it
+// by ~/.zshrc, directly or through oh-my-zsh
+func zshFpathHas(ctx context.Context, zshrc, dir string) bool {
+	if out, err := exec.CommandContext(ctx, "zsh", "-fc", "print -rl -- $fpath").Output(); err == nil {
+		for _, entry := range strings.Split(string(out), "\n") {
+			if filepath.Clean(entry) == dir {
+				return true
+			}
+		}
+	}
+	return zshrcAddsToFpath(zshrc, dir)
+}
+
+// zshrcAddsToFpath reports whether a ~/.zshrc adds dir to $fpath. oh-my-zsh
+// adds its completions directory itself.
+func zshrcAddsToFpath(zshrc, dir string) bool {
+	home, _ := os.UserHomeDir()
+	forms := []string{dir}
+	if rel, err := filepath.Rel(home, dir); err == nil && !strings.HasPrefix(rel, "..") {
+		forms = append(forms, "~/"+rel, "$HOME/"+rel, "${HOME}/"+rel)
+	}
+	if dir == filepath.Join(home, ".oh-my-zsh", "completions") && strings.Contains(zshrc, "oh-my-zsh.sh") {
+		return true
+	}
+	for _, line := range strings.Split(zshrc, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") || !strings.Contains(line, "fpath") {
+			continue
+		}
+		for _, form := range forms {
+			if strings.Contains(line, form) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// zshRunsCompinit reports whether a ~/.zshrc initialises completion, directly
+// or through oh-my-zsh
+func zshRunsCompinit(zshrc string) bool {
+	return strings.Contains(zshrc, "compinit") || strings.Contains(zshrc, "oh-my-zsh.sh")
+}
+
+// fixCompletion installs the completion script for the user's shell
+func fixCompletion(ctx context.Context) error {
+	shell := currentShell()
+	paths := completionPaths(shell)
+	if paths == nil {
+		return fmt.Errorf("unsupported shell %q", shell)
+	}
+	path := paths[0]
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch shell {
+	case "zsh":
+		if err := rootCmd.GenZshCompletion(f); err != nil {
+			return err
+		}
+		return enableZshCompletion(ctx, filepath.Dir(path))
+	case "bash":
+		err = rootCmd.GenBashCompletion(f)
+	case "fish":
+		err = rootCmd.GenFishCompletion(f, true)
+	}
+	return err
+}
+
+// enableZshCompletion appends what ~/.zshrc is missing to load completions
+// from dir: dir on $fpath and a call to compinit after it
+func enableZshCompletion(ctx context.Context, dir string) error {
+	zshrc := readZshrc()
+	var lines []string
+	if !zshFpathHas(ctx, zshrc, dir) {
+		home, _ := os.UserHomeDir()
+		fpathDir := dir
+		if rel, err := filepath.Rel(home, dir); err == nil && !strings.HasPrefix(rel, "..") {
+			fpathDir = "~/" + rel
+		}
+		lines = append(lines, "fpath=("+fpathDir+" $fpath)", "autoload -U compinit; compinit")
+	} else if !zshRunsCompinit(zshrc) {
+		lines = append(lines, "autoload -U compinit; compinit")
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	rc := logging.ExpandHome("~/.zshrc")
+	f, err := os.OpenFile(rc, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	block := "\n# crazy completion\n" + strings.Join(lines, "\n") + "\n"
+	if zshrc != "" && !strings.HasSuffix(zshrc, "\n") {
+		block = "\n" + block
+	}
+	if _, err := f.WriteString(block); err != nil {
+		return fmt.Errorf("failed to update %s: %w", rc, err)
+	}
+	fmt.Fprintf(os.Stderr, "Added to %s, open a new shell to load completion:\n%s\n", rc, strings.Join(lines, "\n"))
+	return nil
+}
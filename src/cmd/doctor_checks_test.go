@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZshrcAddsToFpath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".zsh", "completions")
+
+	tests := []struct {
+		name  string
+		zshrc string
+		want  bool
+	}{
+		{name: "empty", zshrc: "", want: false},
+		{name: "tilde", zshrc: "fpath=(~/.zsh/completions $fpath)\n", want: true},
+		{name: "home variable", zshrc: "fpath+=${HOME}/.zsh/completions\n", want: true},
+		{name: "absolute", zshrc: "fpath=(" + dir + " $fpath)\n", want: true},
+		{name: "commented out", zshrc: "# fpath=(~/.zsh/completions $fpath)\n", want: false},
+		{name: "other directory", zshrc: "fpath=(~/.zfunc $fpath)\n", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, zshrcAddsToFpath(tt.zshrc, dir))
+		})
+	}
+
+	// oh-my-zsh puts its own completions directory on $fpath
+	omz := filepath.Join(home, ".oh-my-zsh", "completions")
+	assert.True(t, zshrcAddsToFpath("source $ZSH/oh-my-zsh.sh\n", omz))
+	assert.False(t, zshrcAddsToFpath("source $ZSH/oh-my-zsh.sh\n", dir))
+}
@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rrecio/crazy-dev-zsh/src/core/history"
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
+	"github.com/rrecio/crazy-dev-zsh/src/core/shellhook"
+	"github.com/rrecio/crazy-dev-zsh/src/ui/picker"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Search the shell history",
+	Long: `Search the commands run in the shell.
+
+Commands recorded by the hooks of 'crazy init' are moved into a SQLite
+database (history.db) with their working directory, project, exit status,
+duration, time and shell session. An existing zsh history can seed it with
+'crazy history import'.`,
+}
+
+// historySearchCmd represents the history search subcommand
+var historySearchCmd = &cobra.Command{
+	Use:   "search [query...]",
+	Short: "Search commands by fuzzy query, frequency and recency",
+	Long: `Search the distinct commands of the history.
+
+Every word of the query must match the command, as a substring or as
+characters in order. Matches are ranked by how well they match, how often
+the command ran and how recently.
+
+--cwd and --project alone filter on the current directory and project.
+--since takes a duration such as 2h or 7d, or a date such as 2024-05-01.
+
+With --interactive the results are shown in a picker on the terminal that
+filters as you type: Up/Down or Ctrl-P/Ctrl-N to move, Enter to print the
+command, Esc or Ctrl-C to cancel.`,
+	Example: `  crazy history search docker run
+  crazy history search --project --failed
+  crazy history search --cwd --since 1d make
+  crazy history search --interactive`,
+	Run: runHistorySearchCommand,
+}
+
+// historyImportCmd represents the history import subcommand
+var historyImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import a zsh history file",
+	Long: `Import a zsh history file written with the EXTENDED_HISTORY option,
+~/.zsh_history by default. Lines without a timestamp are skipped. Importing
+again only adds the new commands.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runHistoryImportCommand,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historySearchCmd)
+	historyCmd.AddCommand(historyImportCmd)
+
+	historySearchCmd.Flags().String("cwd", "", "Only commands run in this directory")
+	historySearchCmd.Flags().Lookup("cwd").NoOptDefVal = "."
+	historySearchCmd.Flags().String("project", "", "Only commands run in this project, by root path or name")
+	historySearchCmd.Flags().Lookup("project").NoOptDefVal = "."
+	historySearchCmd.Flags().Bool("failed", false, "Only commands that exited with an error")
+	historySearchCmd.Flags().String("since", "", "Only commands run since a duration ago or a date")
+	historySearchCmd.Flags().IntP("limit", "n", 20, "Maximum number of commands shown, 0 for all")
+	historySearchCmd.Flags().BoolP("interactive", "i", false, "Pick a command in an interactive list and print it")
+}
+
+// runHistorySearchCommand executes the history search subcommand
+func runHistorySearchCommand(cmd *cobra.Command, args []string) {
+	filter, err := historyFilter(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeUsage)
+	}
+	limit, _ := cmd.Flags().GetInt("limit")
+	interactive, _ := cmd.Flags().GetBool("interactive")
+	query := strings.Join(args, " ")
+
+	store := mustOpenHistory()
+	defer store.Close()
+
+	if interactive {
+		runHistoryPicker(store, filter, query)
+		return
+	}
+
+	commands, err := store.Search(filter, query, limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeError)
+	}
+
+	switch viper.GetString("output") {
+	case "json":
+		data, _ := json.MarshalIndent(commands, "", "  ")
+		fmt.Println(string(data))
+		return
+	case "yaml":
+		data, _ := yaml.Marshal(commands)
+		fmt.Print(string(data))
+		return
+	}
+
+	if len(commands) == 0 {
+		fmt.Fprintln(os.Stderr, "No matching commands")
+		return
+	}
+	now := time.Now()
+	for _, c := range commands {
+		status := " "
+		if c.LastExitCode != 0 {
+			status = "✗"
+		}
+		fmt.Printf("%5d  %8s  %s %s\n", c.Count, formatAge(now.Sub(c.LastUsed)), status, strings.ReplaceAll(c.Command, "\n", "↵"))
+	}
+}
+
+// runHistoryPicker lets the user pick a command on the terminal and prints
+// it. Cancelling prints nothing and exits with an error status, so shell
+// widgets leave the command line alone.
+func runHistoryPicker(store *history.Store, filter history.Filter, query string) {
+	commands, err := store.Commands(filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeError)
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: the interactive picker needs a terminal: %v\n", err)
+		exitWith(exitCodeError)
+	}
+	defer tty.Close()
+
+	now := time.Now()
+	p := &picker.Picker{
+		Prompt: "history> ",
+		Filter: func(query string) []picker.Item {
+			ranked := history.Rank(commands, query, now)
+			items := make([]picker.Item, len(ranked))
+			for i, c := range ranked {
+				detail := fmt.Sprintf("%d× %s", c.Count, formatAge(now.Sub(c.LastUsed)))
+				if c.LastExitCode != 0 {
+					detail += " ✗"
+				}
+				items[i] = picker.Item{Text: c.Command, Detail: detail}
+			}
+			return items
+		},
+	}
+	selected, err := p.Run(tty, query)
+	if errors.Is(err, picker.ErrCancelled) {
+		tty.Close()
+		exitWith(exitCodeError)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeError)
+	}
+	fmt.Println(selected)
+}
+
+// runHistoryImportCommand executes the history import subcommand
+func runHistoryImportCommand(cmd *cobra.Command, args []string) {
+	path := "~/.zsh_history"
+	if len(args) > 0 {
+		path = args[0]
+	}
+	path = logging.ExpandHome(path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to open zsh history: %v\n", err)
+		exitWith(exitCodeError)
+	}
+	defer f.Close()
+
+	events, skipped, err := history.ParseZshHistory(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeError)
+	}
+
+	store := mustOpenHistory()
+	defer store.Close()
+
+	added, err := store.Add(events...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeError)
+	}
+
+	fmt.Printf("Imported %d commands from %s (%d already present)\n", added, path, len(events)-added)
+	if skipped > 0 {
+		fmt.Printf("Skipped %d lines without a timestamp: enable EXTENDED_HISTORY in zsh to record them\n", skipped)
+	}
+}
+
+// mustOpenHistory opens the history database and moves the commands recorded
+// by the shell hooks into it, exiting on failure
+func mustOpenHistory() *history.Store {
+	store, err := history.Open(logging.ExpandHome(viper.GetString("history.db")))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeError)
+	}
+	if _, err := store.Ingest(historyEventsFile()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return store
+}
+
+// historyFilter builds the search filter from the flags
+func historyFilter(cmd *cobra.Command) (history.Filter, error) {
+	var filter history.Filter
+	filter.Failed, _ = cmd.Flags().GetBool("failed")
+
+	if cwd, _ := cmd.Flags().GetString("cwd"); cwd != "" {
+		abs, err := filepath.Abs(logging.ExpandHome(cwd))
+		if err != nil {
+			return filter, fmt.Errorf("invalid --cwd: %w", err)
+		}
+		filter.Cwd = abs
+	}
+
+	if project, _ := cmd.Flags().GetString("project"); project == "." {
+		wd, _ := os.Getwd()
+		root, _ := shellhook.Repository(wd)
+		if root == "" {
+			return filter, fmt.Errorf("--project without a value needs to run inside a git repository")
+		}
+		filter.Project = root
+	} else {
+		filter.Project = strings.TrimSuffix(logging.ExpandHome(project), "/")
+	}
+
+	if since, _ := cmd.Flags().GetString("since"); since != "" {
+		t, err := parseSince(since, time.Now())
+		if err != nil {
+			return filter, err
+		}
+		filter.Since = t
+	}
+	return filter, nil
+}
+
+// parseSince parses a duration ago, with d for days and w for weeks, or a
+// date
+func parseSince(value string, now time.Time) (time.Time, error) {
+	for unit, length := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(value, unit); ok {
+			if count, err := strconv.Atoi(n); err == nil && count >= 0 {
+				return now.Add(-time.Duration(count) * length), nil
+			}
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q, expected a duration such as 2h or 7d, or a date such as 2006-01-02", value)
+}
+
+// formatAge renders how long ago something happened, e.g. "5m ago"
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	case age < 365*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(age.Hours()/24))
+	default:
+		return fmt.Sprintf("%dy ago", int(age.Hours()/24/365))
+	}
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
+	"github.com/rrecio/crazy-dev-zsh/src/core/shellhook"
+)
+
+// hookCmd groups the commands called by the shell integration
+var hookCmd = &cobra.Command{
+	Use:    "hook",
+	Short:  "Commands called by the shell integration",
+	Hidden: true,
+}
+
+// hookRecordCmd records a command reported by the shell hooks
+var hookRecordCmd = &cobra.Command{
+	Use:   "record [flags] -- <command line>",
+	Short: "Record a command run in the shell",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   runHookRecordCommand,
+}
+
+func init() {
+	rootCmd.AddCommand(hookCmd)
+	hookCmd.AddCommand(hookRecordCmd)
+
+	hookRecordCmd.Flags().String("shell", "", "Shell the command ran in")
+	hookRecordCmd.Flags().String("session", "", "Identifier of the shell session")
+	hookRecordCmd.Flags().String("cwd", "", "Working directory of the command")
+	hookRecordCmd.Flags().Int("exit", 0, "Exit status of the command")
+	hookRecordCmd.Flags().Int64("duration", 0, "Duration of the command in milliseconds")
+	hookRecordCmd.Flags().String("stderr-file", "", "File holding the error output of the command")
+}
+
+// runHookRecordCommand executes the hook record subcommand
+func runHookRecordCommand(cmd *cobra.Command, args []string) {
+	event := shellhook.Event{Command: strings.Join(args, " ")}
+	event.Shell, _ = cmd.Flags().GetString("shell")
+	event.Session, _ = cmd.Flags().GetString("session")
+	event.Cwd, _ = cmd.Flags().GetString("cwd")
+	event.ExitCode, _ = cmd.Flags().GetInt("exit")
+	event.DurationMs, _ = cmd.Flags().GetInt64("duration")
+	event.Time = time.Now().Add(-event.Duration())
+
+	if event.Cwd == "" {
+		event.Cwd, _ = os.Getwd()
+	}
+	event.Project, event.Branch = shellhook.Repository(event.Cwd)
+
+	if path, _ := cmd.Flags().GetString("stderr-file"); path != "" && event.Failed() {
+		stderr, err := shellhook.ReadStderr(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		event.Stderr = stderr
+	}
+
+	if err := shellhook.Append(historyEventsFile(), event); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeError)
+	}
+}
+
+// historyEventsFile is where the shell hooks record commands
+func historyEventsFile() string {
+	return logging.ExpandHome(viper.GetString("history.events_file"))
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rrecio/crazy-dev-zsh/src/core/shellhook"
+)
+
+// initCmd represents the init command
+var initCmd = &cobra.Command{
+	Use:   "init [zsh|bash|fish]",
+	Short: "Print the shell integration script",
+	Long: `Print the script that integrates crazy with your shell.
+
+The script registers prompt hooks that report every command line with its
+working directory, exit status, duration and git branch. The report runs in
+the background after the command finishes, so the prompt does not wait for
+it. Commands are appended to history.events_file, where the history and AI
+commands read them.
+
+Commands starting with a space are not recorded, nor any while the
+CRAZY_NO_HISTORY variable is set.
+
+The script also defines a widget that opens the history picker of
+'crazy history search --interactive' and puts the chosen command on the
+command line. --bind-ctrl-r binds it to Ctrl-R, replacing the shell's own
+reverse search.
+
+Another widget replaces a task described on the command line with a command
+from 'crazy ai cmd', for review before running it, and a third puts the fix
+'crazy ai fix' proposes for the last failed command there. --bind-ai binds
+them to Ctrl-X Ctrl-A and Ctrl-X Ctrl-F.
+
+--fix-hint prints a reminder of 'crazy ai fix' after a command fails.
+--capture-stderr (zsh and bash 4.1+) copies the error output of commands to
+a file only you can read, so failed commands are recorded with it for
+'crazy ai fix'. Commands then write errors to a pipe rather than the
+terminal, which turns off colors and progress bars in some of them.
+
+Zsh, in ~/.zshrc:
+  eval "$(crazy init zsh --bind-ctrl-r --bind-ai)"
+
+Bash, at the end of ~/.bashrc (bash-preexec is used when loaded):
+  eval "$(crazy init bash)"
+
+Fish, in ~/.config/fish/config.fish:
+  crazy init fish | source`,
+	DisableFlagsInUseLine: true,
+	ValidArgs:             shellhook.Shells(),
+	Args:                  cobra.ExactValidArgs(1),
+	Run:                   runInitCommand,
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+
+	initCmd.Flags().Bool("bind-ctrl-r", false, "Bind Ctrl-R to the history picker")
+	initCmd.Flags().Bool("bind-ai", false, "Bind Ctrl-X Ctrl-A and Ctrl-X Ctrl-F to the AI command and fix widgets")
+	initCmd.Flags().Bool("fix-hint", false, "Suggest 'crazy ai fix' after a command fails")
+	initCmd.Flags().Bool("capture-stderr", false, "Record the error output of failed commands")
+}
+
+// runInitCommand executes the init command
+func runInitCommand(cmd *cobra.Command, args []string) {
+	var opts shellhook.Options
+	opts.BindHistory, _ = cmd.Flags().GetBool("bind-ctrl-r")
+	opts.BindAI, _ = cmd.Flags().GetBool("bind-ai")
+	opts.FixHint, _ = cmd.Flags().GetBool("fix-hint")
+	opts.CaptureStderr, _ = cmd.Flags().GetBool("capture-stderr")
+
+	script, err := shellhook.Script(args[0], hookBinary(), opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeUsage)
+	}
+	fmt.Print(script)
+}
+
+// hookBinary is how the hooks call this binary: by name when the crazy on
+// PATH is this binary, so upgrades that move it keep working, and by its
+// absolute path otherwise
+func hookBinary() string {
+	self, err := os.Executable()
+	if err != nil {
+		return "crazy"
+	}
+	if onPath, err := exec.LookPath("crazy"); err == nil {
+		selfInfo, err1 := os.Stat(self)
+		pathInfo, err2 := os.Stat(onPath)
+		if err1 == nil && err2 == nil && os.SameFile(selfInfo, pathInfo) {
+			return "crazy"
+		}
+	}
+	return self
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/rrecio/crazy-dev-zsh/src/core/privacy"
+)
+
+// cloudConfirmations remembers the answers given in ask mode, so a command
+// asks once per provider
+var cloudConfirmations = struct {
+	sync.Mutex
+	answers map[string]bool
+}{answers: make(map[string]bool)}
+
+// loadPrivacyPolicy returns the privacy policy of the project in the current
+// directory, warning when it could not be read and falls back to local-only
+func loadPrivacyPolicy() *privacy.Policy {
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = "."
+	}
+	policy := privacy.Load(dir)
+	if policy.Error != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s, keeping requests on this machine\n", policy.Error)
+	}
+	return policy
+}
+
+// confirmCloudRequest asks on the terminal whether a request may be sent to
+// provider. Without a terminal nobody can confirm, so the request is refused.
+func confirmCloudRequest(provider string) bool {
+	cloudConfirmations.Lock()
+	defer cloudConfirmations.Unlock()
+	if answer, ok := cloudConfirmations.answers[provider]; ok {
+		return answer
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+	fmt.Fprintf(tty, "This project asks before code leaves the machine. Send the request to %s? [y/N] ", provider)
+	line, _ := bufio.NewReader(tty).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	allowed := answer == "y" || answer == "yes"
+	cloudConfirmations.answers[provider] = allowed
+	return allowed
+}
+
+// printPrivacyPolicy prints the policy shown by the context command
+func printPrivacyPolicy(policy *privacy.Policy) {
+	fmt.Println("Privacy Policy:")
+	fmt.Printf("  Mode: %s\n", policy.Mode)
+	if policy.Source != "" {
+		fmt.Printf("  Source: %s\n", policy.Source)
+	} else {
+		fmt.Printf("  Source: none, no %s found (ai.local.fallback_to_cloud decides)\n", privacy.ProjectFile)
+	}
+	if policy.Error != "" {
+		fmt.Printf("  Error: %s\n", policy.Error)
+	}
+	if len(policy.DenyPaths) > 0 {
+		fmt.Println("  Deny Paths:")
+		for _, pattern := range policy.DenyPaths {
+			fmt.Printf("    - %s\n", pattern)
+		}
+	}
+}
@@ -113,6 +113,12 @@ func runProjectCreateCommand(cmd *cobra.Command, args []string) {
 name: %s
 created: %s
 template: %s
+
+# Whether code may leave the machine: local-only, cloud-allowed or ask.
+# Requests mentioning a deny path never go to cloud providers.
+# privacy:
+#   mode: local-only
+#   deny_paths: [secrets/, "*.pem"]
 `, projectName, viper.GetTime("now").Format("2006-01-02 15:04:05"), template)
 	
 	if _, err := f.WriteString(configContent); err != nil {
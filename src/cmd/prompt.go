@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// promptCmd represents the prompt command
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Manage and run prompt templates",
+	Long: `Manage and run prompt templates.
+
+Templates are Go text/template files with optional YAML frontmatter holding
+the description, variables, default model and request parameters:
+
+  ---
+  description: Review a diff
+  model: codellama
+  params:
+    temperature: 0.2
+  variables:
+    - name: Diff
+      required: true
+    - name: Focus
+      default: correctness
+  ---
+  Review this diff for {{.Focus}}:
+  {{.Diff}}
+
+Built-in templates are overridden by files with the same name (without the
+extension) in ~/.crazy-dev/prompts (prompts.user_dir), which are in turn
+overridden by the project's .crazy/prompts directory.
+
+The project context is available to templates as well, e.g. {{.Project.Name}},
+{{.Git.CurrentBranch}}, {{.CurrentDir}} or {{range .TechStacks}}{{.Name}}{{end}},
+and fills variables of the same name unless they are set with --var.
+
+Templates can read the project themselves. Paths are relative to the project
+root and cannot leave it:
+
+  readFile "main.go"           file contents
+  lines "main.go" 10 40        lines 10 to 40 of a file
+  gitDiff "HEAD~1"             git diff, also --staged, --cached and --stat
+  gitStatus                    git status --short --branch
+  glob "cmd/*.go"              matching files
+  symbol "pkg.Func"            source of a Go declaration, also Type.Method
+  truncateTokens 500           shorten piped text to about 500 tokens
+  env "EDITOR"                 environment variable, credentials are hidden
+  shell "make -n"              output of a command in prompts.shell_allow,
+                               only with prompts.allow_shell`,
+}
+
+// promptListCmd represents the prompt list subcommand
+var promptListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List available prompt templates",
+	Args:  cobra.NoArgs,
+	Run:   runPromptListCommand,
+}
+
+// promptShowCmd represents the prompt show subcommand
+var promptShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show a prompt template and its variables",
+	Args:  cobra.ExactArgs(1),
+	Run:   runPromptShowCommand,
+}
+
+// promptRenderCmd represents the prompt render subcommand
+var promptRenderCmd = &cobra.Command{
+	Use:   "render <name>",
+	Short: "Render a prompt template without sending it",
+	Args:  cobra.ExactArgs(1),
+	Example: `  crazy prompt render code_explanation --var Code=@main.go
+  crazy prompt render git_help --var query="undo my last commit" --var GitStatus=clean`,
+	Run: runPromptRenderCommand,
+}
+
+// promptRunCmd represents the prompt run subcommand
+var promptRunCmd = &cobra.Command{
+	Use:   "run <name>",
+	Short: "Render a prompt template and send it to the AI",
+	Args:  cobra.ExactArgs(1),
+	Example: `  crazy prompt run code_explanation --var Code=@main.go
+  crazy prompt run review --var Diff="$(git diff)" -m codellama`,
+	Run: runPromptRunCommand,
+}
+
+func init() {
+	rootCmd.AddCommand(promptCmd)
+
+	promptCmd.AddCommand(promptListCmd)
+	promptCmd.AddCommand(promptShowCmd)
+	promptCmd.AddCommand(promptRenderCmd)
+	promptCmd.AddCommand(promptRunCmd)
+
+	// Variables are shared by render and run, @path reads the value from a file
+	for _, c := range []*cobra.Command{promptRenderCmd, promptRunCmd} {
+		c.Flags().StringArray("var", nil, "Template variable as key=value, or key=@file to read the value from a file")
+		c.Flags().Bool("context", true, "Make the project context available to the template")
+	}
+
+	// Flags for the run subcommand, defaults come from the template's frontmatter
+	promptRunCmd.Flags().StringP("model", "m", "", "AI model to use (default: the template's model, then llama3.2)")
+	promptRunCmd.Flags().Float64P("temperature", "t", 0.7, "Temperature for response generation (0.0-1.0)")
+	promptRunCmd.Flags().Int("max-tokens", 0, "Maximum tokens to generate")
+	promptRunCmd.Flags().Duration("timeout", 2*time.Minute, "Maximum time to wait for the answer")
+	promptRunCmd.Flags().Bool("auto-continue", false, "Continue answers cut off at the length limit (default: ai.auto_continue.enabled)")
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/eval"
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
+)
+
+// defaultEvalTimeout limits each answer during an evaluation
+const defaultEvalTimeout = 2 * time.Minute
+
+// promptEvalCmd represents the prompt eval subcommand
+var promptEvalCmd = &cobra.Command{
+	Use:   "eval <suite.yaml>",
+	Short: "Evaluate prompt templates against fixtures on one or more models",
+	Long: `Evaluate prompt templates against fixtures on one or more models.
+
+A suite lists cases that render a template, or an inline prompt, and check the
+answer of every model with assertions:
+
+  name: review
+  models: [llama3.2, codellama]
+  judge_model: llama3.2
+  cases:
+    - name: finds-nil-deref
+      template: review
+      vars:
+        Diff: "@fixtures/nil.diff"
+      assert:
+        - contains: nil
+        - regex: "(?i)pointer"
+        - rubric: Points out the nil dereference before style issues
+    - name: json-summary
+      prompt: Summarize this repository as JSON with a name and a list of languages
+      assert:
+        - json_schema: {type: object, required: [name, languages]}
+
+Fixture paths (@file) and schema files are relative to the suite. Contains is
+case-insensitive, json_schema supports a subset of JSON Schema and rubric
+assertions are graded by the judge model.
+
+Results are saved to prompts.eval_dir and compared with the previous run of
+the suite, so regressions stand out. The exit status is 1 when a case fails.`,
+	Args: cobra.ExactArgs(1),
+	Example: `  crazy prompt eval evals/review.yaml
+  crazy prompt eval evals/review.yaml -m llama3.2 -m qwen2.5-coder --judge llama3.2
+  crazy prompt eval evals/review.yaml --baseline ~/.crazy-dev/evals/review/20261001-120000.json`,
+	Run: runPromptEvalCommand,
+}
+
+func init() {
+	promptCmd.AddCommand(promptEvalCmd)
+
+	promptEvalCmd.Flags().StringArrayP("model", "m", nil, "Model to evaluate, repeat for several (default: the suite's models)")
+	promptEvalCmd.Flags().String("judge", "", "Model grading rubric assertions (default: the suite's judge_model, then the evaluated model)")
+	promptEvalCmd.Flags().Duration("timeout", defaultEvalTimeout, "Maximum time to wait for each answer")
+	promptEvalCmd.Flags().String("baseline", "", "Saved results to compare with (default: the suite's previous run)")
+	promptEvalCmd.Flags().Bool("no-save", false, "Do not save the results")
+}
+
+// runPromptEvalCommand executes the prompt eval subcommand
+func runPromptEvalCommand(cmd *cobra.Command, args []string) {
+	models, _ := cmd.Flags().GetStringArray("model")
+	judge, _ := cmd.Flags().GetString("judge")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	baselinePath, _ := cmd.Flags().GetString("baseline")
+	noSave, _ := cmd.Flags().GetBool("no-save")
+	output := viper.GetString("output")
+	resultsDir := logging.ExpandHome(viper.GetString("prompts.eval_dir"))
+
+	suite, err := eval.LoadSuite(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeUsage)
+	}
+	lib, err := loadPromptLibrary()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading prompt templates: %v\n", err)
+		exitWith(exitCodeError)
+	}
+
+	// Compare with an explicit baseline, or the previous run before this one is saved
+	var baseline *eval.RunResult
+	if baselinePath != "" {
+		baseline, err = eval.LoadResult(logging.ExpandHome(baselinePath))
+	} else {
+		baseline, err = eval.LatestResult(resultsDir, suite.Name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: no baseline to compare with: %v\n", err)
+	}
+
+	if aiEngine == nil {
+		if err := initAIEngine(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing AI engine: %v\n", err)
+			exitWith(exitCodeError)
+		}
+	}
+
+	// Progress goes to stderr so structured output stays clean
+	showProgress := term.IsTerminal(int(os.Stderr.Fd()))
+	runner := &eval.Runner{
+		Engine:     aiEngine,
+		Library:    lib,
+		Models:     models,
+		JudgeModel: judge,
+		Timeout:    timeout,
+		Progress: func(result eval.CaseResult) {
+			if showProgress {
+				fmt.Fprintf(os.Stderr, "\r\033[K%s on %s: %s", result.Case, result.Model, passLabel(result.Passed))
+			}
+		},
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	run, err := runner.Run(ctx, suite)
+	if showProgress {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, context.Canceled) {
+			exitWith(exitCodeInterrupted)
+		}
+		exitWith(exitCodeUsage)
+	}
+
+	savedPath := ""
+	if !noSave {
+		if savedPath, err = run.Save(resultsDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	switch output {
+	case "json":
+		data, _ := json.MarshalIndent(run, "", "  ")
+		fmt.Println(string(data))
+	case "yaml":
+		data, _ := yaml.Marshal(run)
+		fmt.Print(string(data))
+	default:
+		printEvalMatrix(run)
+		printEvalFailures(run)
+		if baseline != nil {
+			printEvalChanges(run, baseline)
+		}
+		if savedPath != "" {
+			fmt.Printf("\nResults saved to %s\n", savedPath)
+		}
+	}
+
+	if !run.Passed() {
+		exitWith(exitCodeError)
+	}
+}
+
+// passLabel returns a colored PASS or FAIL
+func passLabel(passed bool) string {
+	if passed {
+		return color.New(color.FgGreen).Sprint("PASS")
+	}
+	return color.New(color.FgRed).Sprint("FAIL")
+}
+
+// printEvalMatrix prints one row per case and one column per model
+func printEvalMatrix(run *eval.RunResult) {
+	cases := run.Cases()
+	caseWidth := len("CASE")
+	for _, name := range cases {
+		if len(name) > caseWidth {
+			caseWidth = len(name)
+		}
+	}
+
+	// Widths ignore the color of the PASS or FAIL label
+	colWidth := make([]int, len(run.Models))
+	for i, model := range run.Models {
+		colWidth[i] = len(model)
+		for _, name := range cases {
+			result, _ := run.Get(name, model)
+			if width := len("PASS ") + len(evalCellStats(result)); width > colWidth[i] {
+				colWidth[i] = width
+			}
+		}
+	}
+
+	fmt.Printf("%-*s", caseWidth, "CASE")
+	for i, model := range run.Models {
+		fmt.Printf("  %-*s", colWidth[i], model)
+	}
+	fmt.Println()
+	for _, name := range cases {
+		fmt.Printf("%-*s", caseWidth, name)
+		for i, model := range run.Models {
+			result, _ := run.Get(name, model)
+			fmt.Printf("  %s %-*s", passLabel(result.Passed), colWidth[i]-len("PASS "), evalCellStats(result))
+		}
+		fmt.Println()
+	}
+
+	passed, failed := run.Counts()
+	fmt.Printf("\n%d passed, %d failed in %s\n", passed, failed, time.Duration(run.DurationMs)*time.Millisecond)
+}
+
+// evalCellStats returns the latency and tokens shown in a matrix cell
+func evalCellStats(result eval.CaseResult) string {
+	if result.Error != "" {
+		return "error"
+	}
+	return fmt.Sprintf("%5.1fs %5d tok", float64(result.LatencyMs)/1000, result.Usage.TotalTokens)
+}
+
+// printEvalFailures lists the failed assertions and errors
+func printEvalFailures(run *eval.RunResult) {
+	for _, result := range run.Results {
+		if result.Passed {
+			continue
+		}
+		fmt.Printf("\n%s on %s:\n", result.Case, result.Model)
+		if result.Error != "" {
+			fmt.Printf("  error: %s\n", result.Error)
+			continue
+		}
+		for _, assertion := range result.Assertions {
+			if assertion.Passed {
+				continue
+			}
+			line := "  failed " + assertion.Assertion
+			if assertion.Message != "" {
+				line += ": " + assertion.Message
+			}
+			fmt.Println(line)
+		}
+	}
+}
+
+// printEvalChanges lists the outcomes that changed since the baseline
+func printEvalChanges(run, baseline *eval.RunResult) {
+	changes := run.Compare(baseline)
+	fmt.Printf("\nCompared to the run of %s: ", baseline.StartedAt.Format("2006-01-02 15:04"))
+	if len(changes) == 0 {
+		fmt.Println("no changes")
+		return
+	}
+	fmt.Println()
+	for _, change := range changes {
+		if change.Regression() {
+			fmt.Printf("  %s %s on %s now fails\n", color.New(color.FgRed).Sprint("regressed"), change.Case, change.Model)
+		} else {
+			fmt.Printf("  %s %s on %s now passes\n", color.New(color.FgGreen).Sprint("fixed"), change.Case, change.Model)
+		}
+	}
+}
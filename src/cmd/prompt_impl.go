@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rrecio/crazy-dev-zsh/src/ai/prompt"
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
+)
+
+// defaultPromptModel is used when neither the flag nor the template names a model
+const defaultPromptModel = "llama3.2"
+
+// loadPromptLibrary loads the built-in, user and project prompt templates
+func loadPromptLibrary() (*prompt.Library, error) {
+	userDir := logging.ExpandHome(viper.GetString("prompts.user_dir"))
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("could not get current directory: %w", err)
+	}
+	lib, err := prompt.LoadLibrary(userDir, prompt.FindProjectDir(cwd))
+	if err != nil {
+		return nil, err
+	}
+	lib.SetFuncOptions(promptFuncOptions(cwd))
+	return lib, nil
+}
+
+// promptFuncOptions confines template functions to the project and applies the shell settings
+func promptFuncOptions(cwd string) prompt.FuncOptions {
+	return prompt.FuncOptions{
+		Root:         prompt.FindProjectRoot(cwd),
+		AllowShell:   viper.GetBool("prompts.allow_shell"),
+		ShellAllow:   viper.GetStringSlice("prompts.shell_allow"),
+		ShellTimeout: viper.GetDuration("prompts.shell_timeout"),
+	}
+}
+
+// mustLoadPromptTemplate loads the library and returns the named template, exiting on failure
+func mustLoadPromptTemplate(name string) (*prompt.Library, *prompt.Template) {
+	lib, err := loadPromptLibrary()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading prompt templates: %v\n", err)
+		exitWith(exitCodeError)
+	}
+	tmpl, ok := lib.Get(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: prompt template %q not found, see 'crazy prompt list'\n", name)
+		exitWith(exitCodeUsage)
+	}
+	return lib, tmpl
+}
+
+// parsePromptVars parses --var key=value flags, reading key=@path values from files
+func parsePromptVars(cmd *cobra.Command) (map[string]string, error) {
+	flags, _ := cmd.Flags().GetStringArray("var")
+	vars := make(map[string]string, len(flags))
+	for _, flag := range flags {
+		key, value, found := strings.Cut(flag, "=")
+		if !found || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid --var %q, expected key=value", flag)
+		}
+		if path, ok := strings.CutPrefix(value, "@"); ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read value of %s: %w", key, err)
+			}
+			value = string(data)
+		}
+		vars[strings.TrimSpace(key)] = value
+	}
+	return vars, nil
+}
+
+// loadPromptContext returns the project context for templates, or nil when
+// --context=false or the project cannot be analyzed
+func loadPromptContext(cmd *cobra.Command) *prompt.ContextData {
+	if enabled, _ := cmd.Flags().GetBool("context"); !enabled {
+		return nil
+	}
+	payload, err := loadProjectContext(cmd.Context(), false)
+	if err != nil {
+		slog.Warn("project context unavailable", "error", err)
+		return nil
+	}
+	ctxData, err := prompt.DecodeContextData(payload)
+	if err != nil {
+		slog.Warn("project context unavailable", "error", err)
+		return nil
+	}
+	return ctxData
+}
+
+// renderPromptTemplate renders a template from the command's --var flags, exiting on failure
+func renderPromptTemplate(cmd *cobra.Command, lib *prompt.Library, tmpl *prompt.Template) string {
+	vars, err := parsePromptVars(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exitWith(exitCodeUsage)
+	}
+
+	rendered, err := lib.Render(tmpl.Name, vars, loadPromptContext(cmd))
+	var missing *prompt.MissingVariablesError
+	if errors.As(err, &missing) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		for _, v := range tmpl.Variables {
+			for _, name := range missing.Missing {
+				if v.Name == name {
+					fmt.Fprintf(os.Stderr, "  --var %s=...  %s\n", v.Name, v.Description)
+				}
+			}
+		}
+		exitWith(exitCodeUsage)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering template: %v\n", err)
+		exitWith(exitCodeError)
+	}
+	return rendered
+}
+
+// runPromptListCommand executes the prompt list subcommand
+func runPromptListCommand(cmd *cobra.Command, args []string) {
+	lib, err := loadPromptLibrary()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading prompt templates: %v\n", err)
+		exitWith(exitCodeError)
+	}
+	templates := lib.Templates()
+
+	switch viper.GetString("output") {
+	case "json":
+		data, _ := json.MarshalIndent(templates, "", "  ")
+		fmt.Println(string(data))
+		return
+	case "yaml":
+		data, _ := yaml.Marshal(templates)
+		fmt.Print(string(data))
+		return
+	}
+
+	width := len("NAME")
+	for _, tmpl := range templates {
+		if len(tmpl.Name) > width {
+			width = len(tmpl.Name)
+		}
+	}
+	fmt.Printf("%-*s  %-8s  %s\n", width, "NAME", "SOURCE", "DESCRIPTION")
+	for _, tmpl := range templates {
+		fmt.Printf("%-*s  %-8s  %s\n", width, tmpl.Name, tmpl.Source, tmpl.Description)
+	}
+}
+
+// runPromptShowCommand executes the prompt show subcommand
+func runPromptShowCommand(cmd *cobra.Command, args []string) {
+	_, tmpl := mustLoadPromptTemplate(args[0])
+
+	switch viper.GetString("output") {
+	case "json":
+		data, _ := json.MarshalIndent(tmpl, "", "  ")
+		fmt.Println(string(data))
+		return
+	case "yaml":
+		data, _ := yaml.Marshal(tmpl)
+		fmt.Print(string(data))
+		return
+	}
+
+	fmt.Printf("Name:   %s\n", tmpl.Name)
+	fmt.Printf("Source: %s", tmpl.Source)
+	if tmpl.Path != "" {
+		fmt.Printf(" (%s)", tmpl.Path)
+	}
+	fmt.Println()
+	if tmpl.Description != "" {
+		fmt.Printf("Description: %s\n", tmpl.Description)
+	}
+	if tmpl.Model != "" {
+		fmt.Printf("Model: %s\n", tmpl.Model)
+	}
+	if tmpl.Params.Temperature != nil {
+		fmt.Printf("Temperature: %g\n", *tmpl.Params.Temperature)
+	}
+	if tmpl.Params.MaxTokens > 0 {
+		fmt.Printf("Max tokens: %d\n", tmpl.Params.MaxTokens)
+	}
+	if len(tmpl.Variables) > 0 {
+		fmt.Println("Variables:")
+		for _, v := range tmpl.Variables {
+			flags := ""
+			if v.Required {
+				flags = " (required)"
+			} else if v.Default != "" {
+				flags = fmt.Sprintf(" (default %q)", v.Default)
+			}
+			fmt.Println(strings.TrimRight(fmt.Sprintf("  %s%s  %s", v.Name, flags, v.Description), " "))
+		}
+	}
+	fmt.Println("---")
+	fmt.Println(strings.TrimRight(tmpl.Body, "\n"))
+}
+
+// runPromptRenderCommand executes the prompt render subcommand
+func runPromptRenderCommand(cmd *cobra.Command, args []string) {
+	lib, tmpl := mustLoadPromptTemplate(args[0])
+	fmt.Println(strings.TrimRight(renderPromptTemplate(cmd, lib, tmpl), "\n"))
+}
+
+// runPromptRunCommand executes the prompt run subcommand
+func runPromptRunCommand(cmd *cobra.Command, args []string) {
+	lib, tmpl := mustLoadPromptTemplate(args[0])
+	rendered := renderPromptTemplate(cmd, lib, tmpl)
+
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	autoContinue, _ := cmd.Flags().GetBool("auto-continue")
+	output := viper.GetString("output")
+
+	if aiEngine == nil {
+		if err := initAIEngine(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing AI engine: %v\n", err)
+			exitWith(exitCodeError)
+		}
+	}
+
+	req := templateRequest(cmd, tmpl, rendered)
+	req.AutoContinue = autoContinue
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := sendAIRequest(ctx, req, output)
+	if err != nil {
+		printAIError(os.Stderr, err)
+		if errors.Is(err, context.Canceled) {
+			exitWith(exitCodeInterrupted)
+		}
+		exitWith(exitCodeError)
+	}
+	warnIfTruncated(resp)
+	printAskResult(resp, req.Model, start, output)
+}
@@ -2,12 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
 )
 
 var (
@@ -15,6 +18,12 @@ var (
 	cfgFile     string
 	userLicense string
 
+	// logCloser closes the log file opened for this invocation
+	logCloser io.Closer
+
+	// runningCmd is the command being executed, used by exitWith
+	runningCmd *cobra.Command
+
 	rootCmd = &cobra.Command{
 		Use:   "crazy",
 		Short: "Crazy Dev - AI-powered developer terminal",
@@ -23,6 +32,8 @@ development workflow with context-aware suggestions, project analysis,
 and intelligent automation.
 
 Complete documentation is available at https://crazy-dev.io`,
+		PersistentPreRun:  setupLogging,
+		PersistentPostRun: finishLogging,
 		Run: func(cmd *cobra.Command, args []string) {
 			// If no subcommand is provided, print help
 			cmd.Help()
@@ -71,6 +82,39 @@ func init() {
 	}
 }
 
+// setupLogging configures logging and starts the request trace for this invocation
+func setupLogging(cmd *cobra.Command, args []string) {
+	runningCmd = cmd
+	closer, err := logging.Setup(logging.Options{
+		Level: viper.GetString("core.log_level"),
+		File:  viper.GetString("core.log_file"),
+	})
+	logCloser = closer
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	requestID := logging.NewRequestID()
+	ctx := logging.WithRequestID(cmd.Context(), requestID)
+	ctx = logging.WithTrace(ctx, logging.NewTrace())
+	cmd.SetContext(ctx)
+
+	logging.FromContext(ctx).Debug("command started", "command", cmd.CommandPath(), "args", len(args))
+}
+
+// finishLogging prints the timing breakdown in verbose mode and closes the log file
+func finishLogging(cmd *cobra.Command, args []string) {
+	ctx := cmd.Context()
+	if trace := logging.TraceFromContext(ctx); trace != nil && viper.GetBool("verbose") {
+		trace.WriteSummary(os.Stderr, logging.RequestID(ctx))
+	}
+	logging.FromContext(ctx).Debug("command finished", "command", cmd.CommandPath())
+
+	if logCloser != nil {
+		logCloser.Close()
+	}
+}
+
 // initConfig reads in config file and ENV variables if set.
 // setDefaults sets default configuration values
 func setDefaults() {
@@ -81,6 +125,8 @@ func setDefaults() {
 	viper.SetDefault("core.context.ignoreExts", []string{".log", ".tmp"})
 	viper.SetDefault("core.ai_response_timeout", "30s")
 	viper.SetDefault("core.cloud_ai_timeout", "60s")
+	viper.SetDefault("core.log_level", "info")
+	viper.SetDefault("core.log_file", "~/.crazy-dev/logs/crazy-dev.log")
 	
 	// AI engine settings
 	viper.SetDefault("ai.local.enabled", true)
@@ -91,6 +137,35 @@ func setDefaults() {
 	viper.SetDefault("ai.cloud.rate_limit", 20)
 	viper.SetDefault("ai.cloud.cache_ttl", "24h")
 	viper.SetDefault("ai.cloud.models", []string{"gpt-4", "claude-3-opus"})
+	viper.SetDefault("ai.cloud.pricing", map[string]interface{}{
+		"gpt-4":         map[string]float64{"input": 30, "output": 60},
+		"claude-3-opus": map[string]float64{"input": 15, "output": 75},
+	})
+	viper.SetDefault("ai.compaction.enabled", true)
+	viper.SetDefault("ai.compaction.context_window", 4096)
+	viper.SetDefault("ai.compaction.threshold", 0.8)
+	viper.SetDefault("ai.compaction.keep_recent", 4)
+	viper.SetDefault("ai.scripted.file", "")
+	viper.SetDefault("ai.redaction.enabled", true)
+	viper.SetDefault("ai.auto_continue.enabled", false)
+	viper.SetDefault("ai.auto_continue.max_continuations", 3)
+	viper.SetDefault("ai.complete.model", "")
+	viper.SetDefault("ai.transport.default.connect_timeout", "10s")
+	viper.SetDefault("ai.transport.default.response_header_timeout", "0s")
+	viper.SetDefault("ai.transport.default.idle_timeout", "90s")
+	viper.SetDefault("ai.transport.default.max_idle_conns", 100)
+	viper.SetDefault("ai.transport.default.max_idle_conns_per_host", 10)
+	
+	// Shell history
+	viper.SetDefault("history.events_file", "~/.crazy-dev/history/events.jsonl")
+	viper.SetDefault("history.db", "~/.crazy-dev/history/history.db")
+	
+	// Prompt templates
+	viper.SetDefault("prompts.user_dir", "~/.crazy-dev/prompts")
+	viper.SetDefault("prompts.allow_shell", false)
+	viper.SetDefault("prompts.shell_allow", []string{})
+	viper.SetDefault("prompts.shell_timeout", "10s")
+	viper.SetDefault("prompts.eval_dir", "~/.crazy-dev/evals")
 	
 	// UI settings
 	viper.SetDefault("ui.theme", "default")
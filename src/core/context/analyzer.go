@@ -1,10 +1,13 @@
 package context
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/rrecio/crazy-dev-zsh/src/core/logging"
 )
 
 // AnalysisResult represents the result of a project context analysis
@@ -92,11 +95,21 @@ func NewContextAnalyzer(maxFiles int, maxFileSize int64) *ContextAnalyzer {
 
 // Analyze analyzes a project directory and returns the analysis result
 func (ca *ContextAnalyzer) Analyze(path string) (*AnalysisResult, error) {
+	return ca.AnalyzeContext(context.Background(), path)
+}
+
+// AnalyzeContext analyzes a project directory, logging and tracing against ctx
+func (ca *ContextAnalyzer) AnalyzeContext(ctx context.Context, path string) (result *AnalysisResult, err error) {
 	// Check if we have a cached result
 	if cached, found := ca.GetCachedAnalysis(path); found {
+		logging.FromContext(ctx).Debug("using cached project analysis", "path", path)
 		return cached, nil
 	}
 	
+	ctx, span := logging.StartSpan(ctx, "context.analyze", "path", path)
+	defer func() { span.End(err) }()
+	logger := logging.FromContext(ctx)
+	
 	// Start timing the analysis
 	startTime := time.Now()
 	
@@ -112,7 +125,7 @@ func (ca *ContextAnalyzer) Analyze(path string) (*AnalysisResult, error) {
 	}
 	
 	// Initialize the result
-	result := &AnalysisResult{
+	result = &AnalysisResult{
 		ProjectPath: absPath,
 		ProjectName: filepath.Base(absPath),
 		TechStacks:  []TechStack{},
@@ -124,7 +137,7 @@ func (ca *ContextAnalyzer) Analyze(path string) (*AnalysisResult, error) {
 	gitInfo, isGitRepo, err := ca.gitAnalyzer.AnalyzeRepository(absPath)
 	if err != nil {
 		// Non-fatal error, continue with analysis
-		fmt.Fprintf(os.Stderr, "Warning: Git analysis error: %v\n", err)
+		logger.Warn("git analysis failed", "path", absPath, "error", err)
 	}
 	result.IsGitRepo = isGitRepo
 	if isGitRepo {
@@ -150,7 +163,7 @@ func (ca *ContextAnalyzer) Analyze(path string) (*AnalysisResult, error) {
 		deps, err := ca.techDetector.ExtractDependencies(absPath, tech)
 		if err != nil {
 			// Non-fatal error, continue with analysis
-			fmt.Fprintf(os.Stderr, "Warning: Dependency extraction error for %s: %v\n", tech.Name, err)
+			logger.Warn("dependency extraction failed", "stack", tech.Name, "error", err)
 			continue
 		}
 		
@@ -181,6 +194,11 @@ func (ca *ContextAnalyzer) GetCachedAnalysis(path string) (*AnalysisResult, bool
 
 // RefreshAnalysis forces a refresh of the analysis
 func (ca *ContextAnalyzer) RefreshAnalysis(path string) (*AnalysisResult, error) {
+	return ca.RefreshAnalysisContext(context.Background(), path)
+}
+
+// RefreshAnalysisContext forces a refresh of the analysis, logging and tracing against ctx
+func (ca *ContextAnalyzer) RefreshAnalysisContext(ctx context.Context, path string) (*AnalysisResult, error) {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve absolute path: %w", err)
@@ -190,5 +208,5 @@ func (ca *ContextAnalyzer) RefreshAnalysis(path string) (*AnalysisResult, error)
 	ca.cacheManager.InvalidateAnalysis(absPath)
 	
 	// Re-analyze
-	return ca.Analyze(absPath)
+	return ca.AnalyzeContext(ctx, absPath)
 }
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
@@ -41,21 +42,21 @@ func NewCacheManager() CacheManager {
 	// Get user's home directory
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Could not get user home directory: %v\n", err)
+		slog.Warn("could not get user home directory", "error", err)
 		homeDir = "."
 	}
 	
 	// Create cache directory
 	cacheDir := filepath.Join(homeDir, ".crazy-dev", "cache")
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Could not create cache directory: %v\n", err)
+		slog.Warn("could not create cache directory", "error", err)
 	}
 	
 	// Create cache database
 	dbPath := filepath.Join(cacheDir, "context.db")
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Could not open cache database: %v\n", err)
+		slog.Warn("could not open cache database", "error", err)
 		return &CacheManagerImpl{
 			cachePath:   dbPath,
 			maxCacheAge: 24 * time.Hour, // Default cache age: 1 day
@@ -72,7 +73,7 @@ func NewCacheManager() CacheManager {
 		)
 	`)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Could not create cache table: %v\n", err)
+		slog.Warn("could not create cache table", "error", err)
 		db.Close()
 		return &CacheManagerImpl{
 			cachePath:   dbPath,
@@ -153,7 +154,7 @@ func (cm *CacheManagerImpl) GetAnalysis(path string) (*AnalysisResult, bool) {
 	
 	if err != nil {
 		if err != sql.ErrNoRows {
-			fmt.Fprintf(os.Stderr, "Warning: Error querying cache: %v\n", err)
+			slog.Warn("error querying cache", "path", path, "error", err)
 		}
 		return nil, false
 	}
@@ -168,7 +169,7 @@ func (cm *CacheManagerImpl) GetAnalysis(path string) (*AnalysisResult, bool) {
 	// Deserialize result
 	var cachedResult AnalysisResult
 	if err := json.Unmarshal([]byte(resultJSON), &cachedResult); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Failed to deserialize cached result: %v\n", err)
+		slog.Warn("failed to deserialize cached result", "path", path, "error", err)
 		return nil, false
 	}
 	
@@ -123,3 +123,17 @@ func (ga *GitAnalyzerImpl) AnalyzeRepository(path string) (GitInfo, bool, error)
 	
 	return gitInfo, true, nil
 }
+
+// CurrentBranch returns the branch checked out in the repository containing path,
+// or an empty string outside a repository or on a detached HEAD
+func CurrentBranch(path string) string {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return ""
+	}
+	head, err := repo.Head()
+	if err != nil || !head.Name().IsBranch() {
+		return ""
+	}
+	return head.Name().Short()
+}
@@ -0,0 +1,146 @@
+// Package doctor runs diagnostic checks on the environment and repairs what
+// it can
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout limits each check and each fix
+const DefaultTimeout = 10 * time.Second
+
+// Status is the outcome of a check
+type Status string
+
+// Check outcomes
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// Result is the outcome of one check
+type Result struct {
+	Check   string `json:"check" yaml:"check"`
+	Status  Status `json:"status" yaml:"status"`
+	Message string `json:"message" yaml:"message"`
+
+	// Suggestion explains how to fix a warning or failure by hand
+	Suggestion string `json:"suggestion,omitempty" yaml:"suggestion,omitempty"`
+
+	// Fixable reports whether --fix can repair it, Fixed whether the check
+	// passed after the repair. FixError is set when the repair failed or
+	// left the problem in place.
+	Fixable  bool   `json:"fixable,omitempty" yaml:"fixable,omitempty"`
+	Fixed    bool   `json:"fixed,omitempty" yaml:"fixed,omitempty"`
+	FixError string `json:"fix_error,omitempty" yaml:"fix_error,omitempty"`
+}
+
+// Pass returns a passing result
+func Pass(message string, args ...interface{}) Result {
+	return Result{Status: StatusPass, Message: fmt.Sprintf(message, args...)}
+}
+
+// Warn returns a warning with a suggestion
+func Warn(suggestion, message string, args ...interface{}) Result {
+	return Result{Status: StatusWarn, Message: fmt.Sprintf(message, args...), Suggestion: suggestion}
+}
+
+// Fail returns a failure with a suggestion
+func Fail(suggestion, message string, args ...interface{}) Result {
+	return Result{Status: StatusFail, Message: fmt.Sprintf(message, args...), Suggestion: suggestion}
+}
+
+// Check diagnoses one part of the environment
+type Check struct {
+	Name string
+
+	// Run returns the outcome of the check
+	Run func(ctx context.Context) Result
+
+	// Fix repairs a warning or failure, nil when only a person can fix it
+	Fix func(ctx context.Context) error
+
+	// FixTimeout limits Fix, DefaultTimeout when zero
+	FixTimeout time.Duration
+}
+
+var (
+	registryMutex sync.Mutex
+	registry      []Check
+)
+
+// Register adds a check run by crazy doctor, in registration order
+func Register(check Check) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry = append(registry, check)
+}
+
+// Checks returns the registered checks
+func Checks() []Check {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	return append([]Check(nil), registry...)
+}
+
+// Run runs the checks in order. With fix, checks that warn or fail and can
+// be repaired are fixed and run again.
+func Run(ctx context.Context, checks []Check, fix bool) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		result := runCheck(ctx, check)
+		if result.Status != StatusPass && check.Fix != nil {
+			result.Fixable = true
+			if fix {
+				timeout := check.FixTimeout
+				if timeout <= 0 {
+					timeout = DefaultTimeout
+				}
+				fixCtx, cancel := context.WithTimeout(ctx, timeout)
+				err := check.Fix(fixCtx)
+				cancel()
+				if err != nil {
+					result.FixError = err.Error()
+				} else {
+					// Only a passing check proves the fix worked
+					result = runCheck(ctx, check)
+					result.Fixable = true
+					result.Fixed = result.Status == StatusPass
+					if !result.Fixed {
+						result.FixError = "the fix did not resolve it"
+					}
+				}
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// runCheck runs one check with a timeout
+func runCheck(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+	result := check.Run(ctx)
+	result.Check = check.Name
+	return result
+}
+
+// Count returns the number of results with each status
+func Count(results []Result) (passed, warnings, failed int) {
+	for _, result := range results {
+		switch result.Status {
+		case StatusPass:
+			passed++
+		case StatusWarn:
+			warnings++
+		case StatusFail:
+			failed++
+		}
+	}
+	return passed, warnings, failed
+}
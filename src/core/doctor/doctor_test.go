@@ -0,0 +1,58 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun_FixesAndRechecks(t *testing.T) {
+	broken := true
+	checks := []Check{
+		{Name: "ok", Run: func(ctx context.Context) Result { return Pass("fine") }},
+		{
+			Name: "repairable",
+			Run: func(ctx context.Context) Result {
+				if broken {
+					return Fail("run the fix", "broken")
+				}
+				return Pass("repaired")
+			},
+			Fix: func(ctx context.Context) error { broken = false; return nil },
+		},
+		{
+			Name: "stubborn",
+			Run:  func(ctx context.Context) Result { return Warn("ask someone", "still odd") },
+			Fix:  func(ctx context.Context) error { return errors.New("no permission") },
+		},
+		{Name: "manual", Run: func(ctx context.Context) Result { return Warn("edit it by hand", "odd") }},
+		{
+			Name: "ineffective",
+			Run:  func(ctx context.Context) Result { return Fail("make it writable", "read-only") },
+			Fix:  func(ctx context.Context) error { return nil },
+		},
+	}
+
+	results := Run(context.Background(), checks, false)
+	assert.Equal(t, "repairable", results[1].Check)
+	assert.Equal(t, StatusFail, results[1].Status)
+	assert.True(t, results[1].Fixable)
+	assert.False(t, results[3].Fixable)
+	passed, warnings, failed := Count(results)
+	assert.Equal(t, []int{1, 2, 2}, []int{passed, warnings, failed})
+
+	results = Run(context.Background(), checks, true)
+	assert.Equal(t, StatusPass, results[1].Status)
+	assert.True(t, results[1].Fixed)
+	assert.Equal(t, StatusWarn, results[2].Status)
+	assert.Equal(t, "no permission", results[2].FixError)
+	assert.False(t, results[2].Fixed)
+
+	// A fix that returns no error but leaves the check failing did not fix it
+	assert.Equal(t, StatusFail, results[4].Status)
+	assert.False(t, results[4].Fixed)
+	assert.True(t, results[4].Fixable)
+	assert.Equal(t, "the fix did not resolve it", results[4].FixError)
+}
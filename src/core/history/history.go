@@ -0,0 +1,352 @@
+// Package history stores the commands run in the shell in SQLite and ranks
+// them for search. Commands come from the events file written by the shell
+// hooks and from imported shell history files.
+package history
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/rrecio/crazy-dev-zsh/src/core/shellhook"
+)
+
+// rotateSize is the size from which a fully ingested events file is rotated
+const rotateSize = 4 * 1024 * 1024
+
+// schema creates the tables of the store. A command is unique by its start
+// time, session and text, so ingesting or importing twice adds nothing.
+const schema = `
+CREATE TABLE IF NOT EXISTS commands (
+	id          INTEGER PRIMARY KEY,
+	command     TEXT NOT NULL,
+	cwd         TEXT NOT NULL DEFAULT '',
+	project     TEXT NOT NULL DEFAULT '',
+	branch      TEXT NOT NULL DEFAULT '',
+	exit_code   INTEGER NOT NULL DEFAULT 0,
+	duration_ms INTEGER NOT NULL DEFAULT 0,
+	time        INTEGER NOT NULL,
+	session     TEXT NOT NULL DEFAULT '',
+	shell       TEXT NOT NULL DEFAULT '',
+	UNIQUE (time, session, command)
+);
+CREATE INDEX IF NOT EXISTS commands_time ON commands (time);
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`
+
+// migrations upgrade the schema, in order. The number of migrations applied
+// is the database's user_version.
+var migrations = []string{
+	`ALTER TABLE commands ADD COLUMN stderr TEXT NOT NULL DEFAULT ''`,
+}
+
+// Store is the history database
+type Store struct {
+	db *sql.DB
+}
+
+// Filter restricts the commands searched. Zero fields do not filter.
+type Filter struct {
+	// Cwd matches the exact working directory
+	Cwd string
+
+	// Project matches the repository root, by path or by directory name
+	Project string
+
+	// Failed keeps only runs that exited with a non-zero status
+	Failed bool
+
+	// Since keeps only runs started at or after this time
+	Since time.Time
+
+	// Session matches the shell session the commands ran in
+	Session string
+}
+
+// Command is a distinct command line with statistics over its runs
+type Command struct {
+	Command  string    `json:"command" yaml:"command"`
+	Count    int       `json:"count" yaml:"count"`
+	Failures int       `json:"failures" yaml:"failures"`
+	LastUsed time.Time `json:"last_used" yaml:"last_used"`
+
+	// LastExitCode and LastCwd are those of the latest run
+	LastExitCode int    `json:"last_exit_code" yaml:"last_exit_code"`
+	LastCwd      string `json:"last_cwd" yaml:"last_cwd"`
+
+	// Score is the rank of the command in a search, higher first
+	Score float64 `json:"score" yaml:"score"`
+}
+
+// Open opens the store at path, creating it if needed
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	// Several shells may ingest at once: wait for the lock instead of failing
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history tables: %w", err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// migrate applies the migrations the database does not have yet
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read history schema version: %w", err)
+	}
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to start transaction: %w", err)
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to upgrade history schema: %w", err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to upgrade history schema: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to upgrade history schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add stores commands, skipping those already stored. It returns how many
+// were added.
+func (s *Store) Add(events ...shellhook.Event) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO commands
+		(command, cwd, project, branch, exit_code, duration_ms, time, session, shell, stderr)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	added := 0
+	for _, e := range events {
+		if strings.TrimSpace(e.Command) == "" {
+			continue
+		}
+		result, err := stmt.Exec(e.Command, e.Cwd, e.Project, e.Branch, e.ExitCode, e.DurationMs, e.Time.UnixMilli(), e.Session, e.Shell, e.Stderr)
+		if err != nil {
+			return added, fmt.Errorf("failed to store command: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			added++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to store commands: %w", err)
+	}
+	return added, nil
+}
+
+// Ingest stores the events appended to the events file since the last
+// ingestion. A line still being written is left for the next time. Once
+// everything is ingested, a large events file is rotated.
+func (s *Store) Ingest(path string) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open events file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read events file: %w", err)
+	}
+	offset, err := s.eventsOffset()
+	if err != nil {
+		return 0, err
+	}
+	if offset > info.Size() {
+		// The file was replaced since the last ingestion
+		offset = 0
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to read events file: %w", err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read events file: %w", err)
+	}
+	end := bytes.LastIndexByte(data, '\n') + 1
+	if end == 0 {
+		return 0, nil
+	}
+
+	events, err := shellhook.Decode(bytes.NewReader(data[:end]), false)
+	if err != nil {
+		return 0, err
+	}
+	added, err := s.Add(events...)
+	if err != nil {
+		return added, err
+	}
+	offset += int64(end)
+
+	if offset >= rotateSize && offset == info.Size() {
+		if err := os.Rename(path, path+".1"); err == nil {
+			offset = 0
+		}
+	}
+	return added, s.setEventsOffset(offset)
+}
+
+// eventsOffset returns how much of the events file was ingested
+func (s *Store) eventsOffset() (int64, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM meta WHERE key = 'events_offset'`).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read history state: %w", err)
+	}
+	offset, _ := strconv.ParseInt(value, 10, 64)
+	return offset, nil
+}
+
+// setEventsOffset records how much of the events file was ingested
+func (s *Store) setEventsOffset(offset int64) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES ('events_offset', ?)`, strconv.FormatInt(offset, 10))
+	if err != nil {
+		return fmt.Errorf("failed to save history state: %w", err)
+	}
+	return nil
+}
+
+// where returns the SQL condition of the filter and its arguments
+func (f Filter) where() (string, []any) {
+	var where []string
+	var args []any
+	if f.Cwd != "" {
+		where = append(where, "cwd = ?")
+		args = append(args, f.Cwd)
+	}
+	if f.Project != "" {
+		where = append(where, "(project = ? OR project LIKE ?)")
+		args = append(args, f.Project, "%/"+f.Project)
+	}
+	if f.Failed {
+		where = append(where, "exit_code <> 0")
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "time >= ?")
+		args = append(args, f.Since.UnixMilli())
+	}
+	if f.Session != "" {
+		where = append(where, "session = ?")
+		args = append(args, f.Session)
+	}
+	if len(where) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(where, " AND "), args
+}
+
+// Last returns the latest run matching filter, or nil when there is none
+func (s *Store) Last(filter Filter) (*shellhook.Event, error) {
+	where, args := filter.where()
+	row := s.db.QueryRow(`SELECT command, cwd, project, branch, exit_code, duration_ms, time, session, shell, stderr
+		FROM commands`+where+` ORDER BY time DESC, id DESC LIMIT 1`, args...)
+
+	var e shellhook.Event
+	var start int64
+	err := row.Scan(&e.Command, &e.Cwd, &e.Project, &e.Branch, &e.ExitCode, &e.DurationMs, &start, &e.Session, &e.Shell, &e.Stderr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	e.Time = time.UnixMilli(start)
+	return &e, nil
+}
+
+// Commands returns the distinct commands matching filter with the
+// statistics of their matching runs, most recently used first
+func (s *Store) Commands(filter Filter) ([]Command, error) {
+	where, args := filter.where()
+
+	// With a single max() aggregate, SQLite takes the bare columns exit_code
+	// and cwd from the latest run
+	query := `SELECT command, COUNT(*), SUM(exit_code <> 0), MAX(time) AS last_used, exit_code, cwd FROM commands` +
+		where + " GROUP BY command ORDER BY last_used DESC"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search history: %w", err)
+	}
+	defer rows.Close()
+
+	var commands []Command
+	for rows.Next() {
+		var c Command
+		var lastUsed int64
+		if err := rows.Scan(&c.Command, &c.Count, &c.Failures, &lastUsed, &c.LastExitCode, &c.LastCwd); err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		c.LastUsed = time.UnixMilli(lastUsed)
+		commands = append(commands, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return commands, nil
+}
+
+// Search returns up to limit commands matching filter and query, best
+// ranked first. A limit of 0 or less returns all of them.
+func (s *Store) Search(filter Filter, query string, limit int) ([]Command, error) {
+	commands, err := s.Commands(filter)
+	if err != nil {
+		return nil, err
+	}
+	ranked := Rank(commands, query, time.Now())
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked, nil
+}
@@ -0,0 +1,175 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rrecio/crazy-dev-zsh/src/core/shellhook"
+)
+
+func TestSearch(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	now := time.Now()
+	events := []shellhook.Event{
+		{Command: "go test ./...", Cwd: "/src/api", Project: "/src/api", ExitCode: 1, Time: now.Add(-3 * time.Hour)},
+		{Command: "go test ./...", Cwd: "/src/api", Project: "/src/api", Time: now.Add(-2 * time.Hour)},
+		{Command: "go test ./...", Cwd: "/src/api/cmd", Project: "/src/api", Time: now.Add(-time.Hour)},
+		{Command: "git status", Cwd: "/src/web", Project: "/src/web", Time: now.Add(-10 * 24 * time.Hour)},
+		{Command: "make build", Cwd: "/tmp", ExitCode: 2, Time: now.Add(-time.Minute)},
+	}
+	added, err := store.Add(events...)
+	require.NoError(t, err)
+	assert.Equal(t, 5, added)
+
+	// Adding the same runs again is a no-op
+	added, err = store.Add(events...)
+	require.NoError(t, err)
+	assert.Zero(t, added)
+
+	commands, err := store.Search(Filter{}, "", 0)
+	require.NoError(t, err)
+	require.Len(t, commands, 3)
+	assert.Equal(t, "go test ./...", commands[0].Command)
+	assert.Equal(t, 3, commands[0].Count)
+	assert.Equal(t, 1, commands[0].Failures)
+	assert.Equal(t, "/src/api/cmd", commands[0].LastCwd)
+	assert.Zero(t, commands[0].LastExitCode)
+
+	commands, err = store.Search(Filter{}, "gst", 0)
+	require.NoError(t, err)
+	require.Len(t, commands, 2)
+	assert.Equal(t, "go test ./...", commands[0].Command)
+
+	commands, err = store.Search(Filter{Project: "web"}, "", 0)
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	assert.Equal(t, "git status", commands[0].Command)
+
+	commands, err = store.Search(Filter{Cwd: "/src/api", Failed: true}, "", 0)
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	assert.Equal(t, 1, commands[0].Count)
+
+	commands, err = store.Search(Filter{Since: now.Add(-90 * time.Minute)}, "", 1)
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	assert.Equal(t, "make build", commands[0].Command)
+}
+
+func TestIngest(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(filepath.Join(dir, "history.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	path := filepath.Join(dir, "events.jsonl")
+	added, err := store.Ingest(path)
+	require.NoError(t, err)
+	assert.Zero(t, added)
+
+	now := time.Now()
+	require.NoError(t, shellhook.Append(path, shellhook.Event{Command: "ls", Time: now}))
+	require.NoError(t, shellhook.Append(path, shellhook.Event{Command: "pwd", Time: now}))
+	// A line still being written waits for the next ingestion
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	f.WriteString(`{"command":"make",`)
+
+	added, err = store.Ingest(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	f.WriteString(`"time":"` + now.Format(time.RFC3339Nano) + "\"}\n")
+	f.Close()
+	added, err = store.Ingest(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, added)
+
+	commands, err := store.Commands(Filter{})
+	require.NoError(t, err)
+	assert.Len(t, commands, 3)
+}
+
+func TestParseZshHistory(t *testing.T) {
+	data := ": 1700000000:0;git status\n" +
+		": 1700000010:12;for f in *.go; do\\\n  gofmt -l $f\\\ndone\n" +
+		"plain line without time\n" +
+		": 1700000020:1;echo caf\x83\xa3\n" +
+		"\n"
+
+	events, skipped, err := ParseZshHistory(strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 1, skipped)
+	require.Len(t, events, 3)
+
+	assert.Equal(t, "git status", events[0].Command)
+	assert.Equal(t, time.Unix(1700000000, 0), events[0].Time)
+	assert.Equal(t, ZshSession, events[0].Session)
+
+	assert.Equal(t, "for f in *.go; do\n  gofmt -l $f\ndone", events[1].Command)
+	assert.Equal(t, 12*time.Second, events[1].Duration())
+
+	assert.Equal(t, "echo caf\x83", events[2].Command)
+}
+
+func TestRank(t *testing.T) {
+	now := time.Now()
+	commands := []Command{
+		{Command: "kubectl get pods", Count: 1, LastUsed: now.Add(-40 * 24 * time.Hour)},
+		{Command: "kubectl get pods -n prod", Count: 20, LastUsed: now.Add(-time.Minute)},
+		{Command: "ls", Count: 50, LastUsed: now},
+	}
+
+	ranked := Rank(commands, "kgp", now)
+	require.Len(t, ranked, 2)
+	assert.Equal(t, "kubectl get pods -n prod", ranked[0].Command)
+	assert.Greater(t, ranked[0].Score, ranked[1].Score)
+
+	// A prefix beats a substring, which beats scattered characters
+	prefix, _ := Match([]string{"git"}, "git push")
+	substring, _ := Match([]string{"push"}, "git push")
+	scattered, _ := Match([]string{"gph"}, "git push")
+	assert.Greater(t, prefix, substring)
+	assert.Greater(t, substring, scattered)
+
+	_, ok := Match([]string{"git", "pull"}, "git push")
+	assert.False(t, ok)
+}
+
+func TestLast(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	last, err := store.Last(Filter{Failed: true})
+	require.NoError(t, err)
+	assert.Nil(t, last)
+
+	now := time.Now().Truncate(time.Millisecond)
+	_, err = store.Add(
+		shellhook.Event{Command: "make", ExitCode: 2, Session: "a", Stderr: "no rule", Time: now.Add(-time.Minute)},
+		shellhook.Event{Command: "go test", ExitCode: 1, Session: "b", Time: now.Add(-time.Second)},
+		shellhook.Event{Command: "ls", Session: "a", Time: now},
+	)
+	require.NoError(t, err)
+
+	last, err = store.Last(Filter{Failed: true, Session: "a"})
+	require.NoError(t, err)
+	require.NotNil(t, last)
+	assert.Equal(t, "make", last.Command)
+	assert.Equal(t, "no rule", last.Stderr)
+	assert.True(t, now.Add(-time.Minute).Equal(last.Time))
+
+	last, err = store.Last(Filter{})
+	require.NoError(t, err)
+	assert.Equal(t, "ls", last.Command)
+}
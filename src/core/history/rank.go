@@ -0,0 +1,93 @@
+package history
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Rank filters commands by a fuzzy query and sorts them by how well they
+// match, weighted by frecency: how often and how recently they ran. An
+// empty query keeps every command.
+func Rank(commands []Command, query string, now time.Time) []Command {
+	terms := strings.Fields(strings.ToLower(query))
+
+	ranked := make([]Command, 0, len(commands))
+	for _, c := range commands {
+		match, ok := Match(terms, c.Command)
+		if !ok {
+			continue
+		}
+		c.Score = match * Frecency(c.Count, c.LastUsed, now)
+		ranked = append(ranked, c)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked
+}
+
+// Match scores how well text matches every term, between 0 and 1. A term
+// found as is scores higher at the start of text than elsewhere; a term
+// whose characters only appear in order scores less, the more spread out
+// they are.
+func Match(terms []string, text string) (float64, bool) {
+	lower := strings.ToLower(text)
+	score := 1.0
+	for _, term := range terms {
+		switch i := strings.Index(lower, term); {
+		case i == 0:
+		case i > 0:
+			score *= 0.8
+		default:
+			span, ok := subsequenceSpan(lower, term)
+			if !ok {
+				return 0, false
+			}
+			score *= 0.5 * float64(utf8.RuneCountInString(term)) / float64(span)
+		}
+	}
+	return score, true
+}
+
+// subsequenceSpan returns how many characters of text the first in-order
+// match of term's characters spans
+func subsequenceSpan(text, term string) (int, bool) {
+	want := []rune(term)
+	start, matched, pos := -1, 0, 0
+	for _, r := range text {
+		if r == want[matched] {
+			if matched == 0 {
+				start = pos
+			}
+			matched++
+			if matched == len(want) {
+				return pos - start + 1, true
+			}
+		}
+		pos++
+	}
+	return 0, false
+}
+
+// Frecency weighs how often a command ran by how recently it last did
+func Frecency(count int, lastUsed, now time.Time) float64 {
+	frequency := 1 + math.Log(float64(max(count, 1)))
+
+	var recency float64
+	switch age := now.Sub(lastUsed); {
+	case age < time.Hour:
+		recency = 4
+	case age < 24*time.Hour:
+		recency = 2
+	case age < 7*24*time.Hour:
+		recency = 1
+	case age < 30*24*time.Hour:
+		recency = 0.5
+	default:
+		recency = 0.25
+	}
+	return frequency * recency
+}
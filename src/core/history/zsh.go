@@ -0,0 +1,101 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rrecio/crazy-dev-zsh/src/core/shellhook"
+)
+
+// ZshSession is the session of commands imported from a zsh history file
+const ZshSession = "zsh_history"
+
+// zshMeta precedes a byte of the history file that zsh stored XORed with 32
+const zshMeta = 0x83
+
+// ParseZshHistory reads a zsh history file in the EXTENDED_HISTORY format,
+// ": <start>:<elapsed>;<command>", with multi-line commands continued by a
+// trailing backslash. Lines in the plain format have no time and are
+// skipped; their number is returned.
+func ParseZshHistory(r io.Reader) ([]shellhook.Event, int, error) {
+	var events []shellhook.Event
+	skipped := 0
+
+	reader := bufio.NewReader(r)
+	var entry []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			line = bytes.TrimSuffix(line, []byte("\n"))
+			entry = append(entry, line...)
+			if bytes.HasSuffix(line, []byte(`\`)) && err == nil {
+				// The command goes on, the backslash stands for a line break
+				entry[len(entry)-1] = '\n'
+			} else {
+				if event, ok := parseZshEntry(unmetafy(entry)); ok {
+					events = append(events, event)
+				} else if len(bytes.TrimSpace(entry)) > 0 {
+					skipped++
+				}
+				entry = entry[:0]
+			}
+		}
+		if err == io.EOF {
+			return events, skipped, nil
+		}
+		if err != nil {
+			return events, skipped, fmt.Errorf("failed to read zsh history: %w", err)
+		}
+	}
+}
+
+// parseZshEntry parses ": <start>:<elapsed>;<command>"
+func parseZshEntry(entry string) (shellhook.Event, bool) {
+	rest, ok := strings.CutPrefix(entry, ": ")
+	if !ok {
+		return shellhook.Event{}, false
+	}
+	header, command, ok := strings.Cut(rest, ";")
+	if !ok || strings.TrimSpace(command) == "" {
+		return shellhook.Event{}, false
+	}
+	startText, elapsedText, ok := strings.Cut(header, ":")
+	if !ok {
+		return shellhook.Event{}, false
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(startText), 10, 64)
+	if err != nil {
+		return shellhook.Event{}, false
+	}
+	elapsed, _ := strconv.ParseInt(strings.TrimSpace(elapsedText), 10, 64)
+
+	return shellhook.Event{
+		Command:    command,
+		DurationMs: elapsed * 1000,
+		Time:       time.Unix(start, 0),
+		Session:    ZshSession,
+		Shell:      "zsh",
+	}, true
+}
+
+// unmetafy decodes the bytes zsh escapes in its history file
+func unmetafy(entry []byte) string {
+	if bytes.IndexByte(entry, zshMeta) < 0 {
+		return string(entry)
+	}
+	out := make([]byte, 0, len(entry))
+	for i := 0; i < len(entry); i++ {
+		if entry[i] == zshMeta && i+1 < len(entry) {
+			i++
+			out = append(out, entry[i]^32)
+			continue
+		}
+		out = append(out, entry[i])
+	}
+	return string(out)
+}
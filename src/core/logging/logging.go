@@ -0,0 +1,124 @@
+// Package logging configures structured logging and per-invocation request tracing
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Options configures the logging subsystem
+type Options struct {
+	// Level is the minimum level written: debug, info, warn or error
+	Level string
+
+	// File is the log file, "~" is expanded. Logs go to stderr when empty.
+	File string
+}
+
+// ParseLevel converts a level name to a slog level
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q", level)
+}
+
+// Setup installs the default slog logger. Logs are written as JSON to the log
+// file, or as text to stderr when no file is configured or it cannot be opened.
+// The returned closer flushes and closes the log file.
+func Setup(opts Options) (io.Closer, error) {
+	level, levelErr := ParseLevel(opts.Level)
+
+	if opts.File == "" {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+		return nopCloser{}, levelErr
+	}
+
+	f, err := openLogFile(opts.File)
+	if err != nil {
+		// Fall back to stderr, only showing problems so regular output stays clean
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+		return nopCloser{}, err
+	}
+
+	slog.SetDefault(slog.New(slog.NewJSONHandler(f, &slog.HandlerOptions{Level: level})))
+	return f, levelErr
+}
+
+// nopCloser is returned when logs are not written to a file
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// openLogFile opens path for appending, creating its directory if needed
+func openLogFile(path string) (*os.File, error) {
+	path = ExpandHome(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return f, nil
+}
+
+// ExpandHome replaces a leading "~" with the user's home directory
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+type requestIDKey struct{}
+
+// NewRequestID returns a random identifier for a command invocation
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a context carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, if any
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext returns the default logger annotated with the request ID from ctx
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if id := RequestID(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	return logger
+}
@@ -0,0 +1,209 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Trace collects the spans of a single command invocation
+type Trace struct {
+	mutex sync.Mutex
+	start time.Time
+	spans []*Span
+	notes []string
+}
+
+// Span is a timed step of an invocation, such as a provider attempt
+type Span struct {
+	Name     string
+	Detail   string
+	Start    time.Time
+	Duration time.Duration
+	Depth    int
+	Err      error
+
+	// Marks records named points in time relative to the span start, e.g. the first streamed token
+	Marks map[string]time.Duration
+
+	trace *Trace
+	ctx   context.Context
+	mutex sync.Mutex
+	ended bool
+}
+
+type traceKey struct{}
+type spanKey struct{}
+
+// NewTrace starts a new trace
+func NewTrace() *Trace {
+	return &Trace{start: time.Now()}
+}
+
+// WithTrace returns a context carrying the trace
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// TraceFromContext returns the trace carried by ctx, if any
+func TraceFromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// SpanFromContext returns the innermost span started on ctx, if any
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// StartSpan starts a span named name. The key/value pairs in args describe the
+// span and are logged along with it. End must be called when the step is done.
+func StartSpan(ctx context.Context, name string, args ...any) (context.Context, *Span) {
+	span := &Span{
+		Name:   name,
+		Detail: formatArgs(args),
+		Start:  time.Now(),
+		trace:  TraceFromContext(ctx),
+		ctx:    ctx,
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.Depth = parent.Depth + 1
+	}
+	if span.trace != nil {
+		span.trace.mutex.Lock()
+		span.trace.spans = append(span.trace.spans, span)
+		span.trace.mutex.Unlock()
+	}
+
+	FromContext(ctx).Debug("span started", append([]any{"span", name}, args...)...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Mark records a named point in time within the span. Only the first mark of each name is kept.
+func (s *Span) Mark(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.Marks == nil {
+		s.Marks = make(map[string]time.Duration)
+	}
+	if _, ok := s.Marks[name]; !ok {
+		s.Marks[name] = time.Since(s.Start)
+	}
+}
+
+// End finishes the span and logs its duration and error
+func (s *Span) End(err error) {
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended = true
+	s.Duration = time.Since(s.Start)
+	s.Err = err
+	marks := make([]any, 0, len(s.Marks)*2)
+	for name, offset := range s.Marks {
+		marks = append(marks, name+"_ms", offset.Milliseconds())
+	}
+	s.mutex.Unlock()
+
+	args := append([]any{"span", s.Name, "duration_ms", s.Duration.Milliseconds()}, marks...)
+	if s.Detail != "" {
+		args = append(args, "detail", s.Detail)
+	}
+	logger := FromContext(s.ctx)
+	if err != nil {
+		logger.Warn("span failed", append(args, "error", err)...)
+		return
+	}
+	logger.Debug("span finished", args...)
+}
+
+// Spans returns the spans recorded so far, in start order
+func (t *Trace) Spans() []*Span {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]*Span(nil), t.spans...)
+}
+
+// Note records a message for the verbose summary of the trace carried by ctx, if any
+func Note(ctx context.Context, format string, args ...any) {
+	t := TraceFromContext(ctx)
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.notes = append(t.notes, fmt.Sprintf(format, args...))
+}
+
+// Notes returns the notes recorded so far
+func (t *Trace) Notes() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]string(nil), t.notes...)
+}
+
+// WriteSummary writes the notes and a timing breakdown of the trace
+func (t *Trace) WriteSummary(w io.Writer, requestID string) {
+	for _, note := range t.Notes() {
+		fmt.Fprintln(w, note)
+	}
+
+	spans := t.Spans()
+	if len(spans) == 0 {
+		return
+	}
+
+	labels := make([]string, len(spans))
+	width := len("total")
+	for i, s := range spans {
+		labels[i] = strings.Repeat("  ", s.Depth) + s.Name
+		if s.Detail != "" {
+			labels[i] += " " + s.Detail
+		}
+		if len(labels[i]) > width {
+			width = len(labels[i])
+		}
+	}
+
+	fmt.Fprintf(w, "Timing (request %s):\n", requestID)
+	for i, s := range spans {
+		s.mutex.Lock()
+		line := fmt.Sprintf("  %-*s %8s", width, labels[i], formatDuration(s.Duration, s.ended))
+		if offset, ok := s.Marks["first_token"]; ok {
+			line += fmt.Sprintf("  (first token %s)", formatDuration(offset, true))
+		}
+		if s.Err != nil {
+			line += "  failed: " + s.Err.Error()
+		}
+		s.mutex.Unlock()
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "  %-*s %8s\n", width, "total", formatDuration(time.Since(t.start), true))
+}
+
+// formatDuration rounds a duration for display
+func formatDuration(d time.Duration, ended bool) string {
+	if !ended {
+		return "running"
+	}
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// formatArgs renders key/value pairs as "key=value ..."
+func formatArgs(args []any) string {
+	parts := make([]string, 0, len(args)/2)
+	for i := 0; i+1 < len(args); i += 2 {
+		parts = append(parts, fmt.Sprintf("%v=%v", args[i], args[i+1]))
+	}
+	return strings.Join(parts, " ")
+}
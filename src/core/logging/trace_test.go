@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace_SpansAndSummary(t *testing.T) {
+	trace := NewTrace()
+	ctx := WithTrace(WithRequestID(context.Background(), "abc123"), trace)
+
+	ctx, outer := StartSpan(ctx, "provider.stream", "model", "llama3.2")
+	_, inner := StartSpan(ctx, "compaction")
+	inner.End(errors.New("boom"))
+	outer.Mark("first_token")
+	outer.End(nil)
+
+	spans := trace.Spans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, 0, spans[0].Depth)
+	assert.Equal(t, 1, spans[1].Depth)
+	assert.Equal(t, "model=llama3.2", spans[0].Detail)
+
+	var out bytes.Buffer
+	trace.WriteSummary(&out, RequestID(ctx))
+	summary := out.String()
+	assert.Contains(t, summary, "Timing (request abc123):")
+	assert.Contains(t, summary, "provider.stream model=llama3.2")
+	assert.Contains(t, summary, "(first token ")
+	assert.Contains(t, summary, "    compaction")
+	assert.Contains(t, summary, "failed: boom")
+	assert.Contains(t, summary, "total")
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("DEBUG")
+	assert.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	level, err = ParseLevel("loud")
+	assert.Error(t, err)
+	assert.Equal(t, slog.LevelInfo, level)
+}
@@ -0,0 +1,199 @@
+// Package privacy reads a project's policy on sending its code to cloud providers
+package privacy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFile holds the settings of a project, at its root
+const ProjectFile = ".crazy-dev.yaml"
+
+// Mode decides whether requests may leave the machine
+type Mode string
+
+// Privacy modes
+const (
+	// ModeLocalOnly never sends anything to a cloud provider
+	ModeLocalOnly Mode = "local-only"
+	// ModeCloudAllowed leaves the decision to the global settings
+	ModeCloudAllowed Mode = "cloud-allowed"
+	// ModeAsk asks before each cloud request
+	ModeAsk Mode = "ask"
+)
+
+// ErrCloudBlocked is returned when the policy keeps a request on the machine
+var ErrCloudBlocked = errors.New("blocked by the project privacy policy")
+
+// pathPattern finds strings in a request that look like file paths
+var pathPattern = regexp.MustCompile(`[\w@~.+-]*(?:/[\w@.+-]+)+/?|[\w@+-]+(?:\.[\w+-]+)+`)
+
+// Policy is the privacy section of a project file:
+//
+//	privacy:
+//	  mode: local-only
+//	  deny_paths: [secrets/, "*.pem", "clients/**/data"]
+type Policy struct {
+	Mode      Mode     `json:"mode" yaml:"mode"`
+	DenyPaths []string `json:"deny_paths,omitempty" yaml:"deny_paths,omitempty"`
+
+	// Root is the project directory and Source the file the policy was read
+	// from, both empty when no project file was found
+	Root   string `json:"root,omitempty" yaml:"-"`
+	Source string `json:"source,omitempty" yaml:"-"`
+
+	// Error explains why an unreadable policy fell back to local-only
+	Error string `json:"error,omitempty" yaml:"-"`
+}
+
+// Default returns the policy of directories outside any project
+func Default() *Policy {
+	return &Policy{Mode: ModeCloudAllowed}
+}
+
+// Load finds the project file in dir or its parents and reads its policy.
+// A project file that cannot be read or holds an invalid policy yields a
+// local-only policy, so a mistake never sends code out.
+func Load(dir string) *Policy {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return &Policy{Mode: ModeLocalOnly, Error: err.Error()}
+	}
+	for current := abs; ; current = filepath.Dir(current) {
+		file := filepath.Join(current, ProjectFile)
+		if _, err := os.Stat(file); err == nil {
+			return loadFile(file)
+		}
+		if filepath.Dir(current) == current {
+			return Default()
+		}
+	}
+}
+
+// loadFile reads the policy of a project file
+func loadFile(file string) *Policy {
+	policy := &Policy{Root: filepath.Dir(file), Source: file}
+	failClosed := func(err error) *Policy {
+		policy.Mode = ModeLocalOnly
+		policy.Error = err.Error()
+		return policy
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return failClosed(fmt.Errorf("failed to read %s: %w", file, err))
+	}
+	var project struct {
+		Privacy Policy `yaml:"privacy"`
+	}
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		return failClosed(fmt.Errorf("invalid %s: %w", file, err))
+	}
+
+	policy.Mode = project.Privacy.Mode
+	policy.DenyPaths = project.Privacy.DenyPaths
+	switch policy.Mode {
+	case "":
+		policy.Mode = ModeCloudAllowed
+	case ModeLocalOnly, ModeCloudAllowed, ModeAsk:
+	default:
+		return failClosed(fmt.Errorf("invalid privacy mode %q in %s, expected %s, %s or %s", policy.Mode, file, ModeLocalOnly, ModeCloudAllowed, ModeAsk))
+	}
+	for _, pattern := range policy.DenyPaths {
+		if _, err := path.Match(strings.Trim(pattern, "/"), ""); err != nil {
+			return failClosed(fmt.Errorf("invalid deny path %q in %s: %w", pattern, file, err))
+		}
+	}
+	return policy
+}
+
+// Denied reports whether a file, absolute or relative to the project root,
+// matches one of the deny paths. Files outside the project never match.
+func (p *Policy) Denied(file string) bool {
+	if len(p.DenyPaths) == 0 {
+		return false
+	}
+	rel := filepath.ToSlash(filepath.Clean(file))
+	if filepath.IsAbs(file) {
+		if p.Root == "" {
+			return false
+		}
+		r, err := filepath.Rel(p.Root, file)
+		if err != nil || r == ".." || strings.HasPrefix(r, "../") {
+			return false
+		}
+		rel = filepath.ToSlash(r)
+	}
+	for _, pattern := range p.DenyPaths {
+		if matchPattern(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// MentionedDenied returns the denied paths mentioned in texts, such as the
+// file names in a prompt or the project context of a request
+func (p *Policy) MentionedDenied(texts ...string) []string {
+	if len(p.DenyPaths) == 0 {
+		return nil
+	}
+	var denied []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, candidate := range pathPattern.FindAllString(text, -1) {
+			candidate = strings.TrimRight(candidate, ".")
+			if !seen[candidate] && p.Denied(candidate) {
+				denied = append(denied, candidate)
+			}
+			seen[candidate] = true
+		}
+	}
+	return denied
+}
+
+// matchPattern matches a slash-separated path against a deny pattern. A
+// pattern without a slash matches any file or directory name, "**" matches
+// any number of directories and matching a directory denies its contents.
+func matchPattern(pattern, rel string) bool {
+	pattern = strings.Trim(pattern, "/")
+	segments := strings.Split(rel, "/")
+	if !strings.Contains(pattern, "/") {
+		for _, segment := range segments {
+			if ok, _ := path.Match(pattern, segment); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return matchSegments(strings.Split(pattern, "/"), segments)
+}
+
+// matchSegments matches path segments against pattern segments
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package privacy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeProject(t *testing.T, content string) string {
+	t.Helper()
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, ProjectFile), []byte(content), 0644))
+	sub := filepath.Join(root, "pkg", "api")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+	return sub
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeProject(t, "name: acme\nprivacy:\n  mode: ask\n  deny_paths: [secrets/]\n")
+	policy := Load(dir)
+	assert.Equal(t, ModeAsk, policy.Mode)
+	assert.Equal(t, []string{"secrets/"}, policy.DenyPaths)
+	assert.Equal(t, ProjectFile, filepath.Base(policy.Source))
+	assert.Empty(t, policy.Error)
+
+	policy = Load(writeProject(t, "name: acme\n"))
+	assert.Equal(t, ModeCloudAllowed, policy.Mode)
+}
+
+func TestLoad_FailsClosed(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown mode": "privacy:\n  mode: cloud-only\n",
+		"invalid yaml": "privacy: [mode\n",
+		"bad pattern":  "privacy:\n  deny_paths: [\"[\"]\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			policy := Load(writeProject(t, content))
+			assert.Equal(t, ModeLocalOnly, policy.Mode)
+			assert.NotEmpty(t, policy.Error)
+		})
+	}
+}
+
+func TestPolicy_Denied(t *testing.T) {
+	policy := &Policy{Root: "/work/acme", DenyPaths: []string{"secrets/", "*.pem", "clients/**/data"}}
+	for file, denied := range map[string]bool{
+		"secrets/prod.env":             true,
+		"config/secrets/dev.env":       true,
+		"certs/server.pem":             true,
+		"/work/acme/clients/x/y/data":  true,
+		"clients/data/report.csv":      true,
+		"clients/x/notes.md":           false,
+		"src/main.go":                  false,
+		"/elsewhere/secrets/prod.env":  false,
+		"/work/acme-other/secrets/key": false,
+	} {
+		assert.Equal(t, denied, policy.Denied(file), file)
+	}
+
+	mentioned := policy.MentionedDenied("Why does ./secrets/prod.env fail to load?", "see src/main.go and server.pem.")
+	assert.Equal(t, []string{"./secrets/prod.env", "server.pem"}, mentioned)
+}
@@ -0,0 +1,427 @@
+package shellhook
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Options tunes the integration scripts
+type Options struct {
+	// BindHistory binds Ctrl-R to the history picker
+	BindHistory bool
+
+	// BindAI binds Ctrl-X Ctrl-A to the widget turning a description into
+	// a command, and Ctrl-X Ctrl-F to the one fixing the last failed command
+	BindAI bool
+
+	// FixHint prints a hint to run `crazy ai fix` after a command fails
+	FixHint bool
+
+	// CaptureStderr tees the error output of commands to a file, so failed
+	// commands are recorded with it. Commands then write their errors to a
+	// pipe instead of the terminal, which disables colors in some of them.
+	CaptureStderr bool
+}
+
+// Script returns the integration script of a shell, calling the binary at
+// bin. The hooks time each command and hand it to `crazy hook record` in the
+// background once it finishes, so the prompt never waits for the binary.
+// The script also defines widgets that replace the command line with one
+// picked from the history, a command generated from the description typed
+// on it, or a fix for the last failed command.
+func Script(shell, bin string, opts Options) (string, error) {
+	script, ok := scripts[shell]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell %q, expected one of %s", shell, strings.Join(Shells(), ", "))
+	}
+	if opts.CaptureStderr && shell == "fish" {
+		return "", fmt.Errorf("capturing error output is not supported in fish")
+	}
+	quoted := posixQuote(bin)
+	if shell == "fish" {
+		quoted = fishQuote(bin)
+	}
+	bind := ""
+	if opts.BindHistory {
+		bind += historyBindings[shell]
+	}
+	if opts.BindAI {
+		bind += aiBindings[shell]
+	}
+	return strings.NewReplacer(
+		"@CRAZY_BIN@", quoted,
+		"@CRAZY_BIND@\n", bind,
+		"@CRAZY_FIX_HINT@", flag(opts.FixHint),
+		"@CRAZY_CAPTURE@", flag(opts.CaptureStderr),
+	).Replace(script), nil
+}
+
+// flag is the value of a script variable enabling an option: set or empty
+func flag(enabled bool) string {
+	if enabled {
+		return "1"
+	}
+	return "''"
+}
+
+// Shells lists the shells with an integration script
+func Shells() []string {
+	shells := make([]string, 0, len(scripts))
+	for shell := range scripts {
+		shells = append(shells, shell)
+	}
+	sort.Strings(shells)
+	return shells
+}
+
+// posixQuote quotes s for zsh and bash
+func posixQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes s for fish, where backslashes escape inside single quotes
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// scripts are the integration scripts by shell. They must not `return` at
+// the top level: that would leave the rc file that evaluates them.
+var scripts = map[string]string{
+	"zsh":  zshScript,
+	"bash": bashScript,
+	"fish": fishScript,
+}
+
+// historyBindings bind Ctrl-R to the history widget, and aiBindings the AI
+// widgets to Ctrl-X sequences, in place of the @CRAZY_BIND@ line of the
+// scripts
+var historyBindings = map[string]string{
+	"zsh": `  bindkey '^R' _crazy_history_widget
+  bindkey -M viins '^R' _crazy_history_widget
+`,
+	"bash": `  bind -x '"\C-r": _crazy_history_widget'
+`,
+	"fish": `    bind \cr __crazy_history_widget
+    bind -M insert \cr __crazy_history_widget
+`,
+}
+
+var aiBindings = map[string]string{
+	"zsh": `  bindkey '^X^A' _crazy_ai_cmd_widget
+  bindkey -M viins '^X^A' _crazy_ai_cmd_widget
+  bindkey '^X^F' _crazy_ai_fix_widget
+  bindkey -M viins '^X^F' _crazy_ai_fix_widget
+`,
+	"bash": `  bind -x '"\C-x\C-a": _crazy_ai_cmd_widget'
+  bind -x '"\C-x\C-f": _crazy_ai_fix_widget'
+`,
+	"fish": `    bind \cx\ca __crazy_ai_cmd_widget
+    bind -M insert \cx\ca __crazy_ai_cmd_widget
+    bind \cx\cf __crazy_ai_fix_widget
+    bind -M insert \cx\cf __crazy_ai_fix_widget
+`,
+}
+
+const zshScript = `# crazy-dev shell integration for zsh
+# Add to ~/.zshrc:  eval "$(crazy init zsh)"
+# Commands starting with a space are not recorded, nor any while
+# CRAZY_NO_HISTORY is set.
+if [[ -o interactive ]] && (( ! ${+_crazy_hooks_loaded} )); then
+  typeset -g _crazy_hooks_loaded=1
+  zmodload zsh/datetime 2>/dev/null
+
+  typeset -g _crazy_bin=@CRAZY_BIN@
+  typeset -g _crazy_session="$$-$EPOCHSECONDS"
+  typeset -g _crazy_cmd="" _crazy_start=""
+  typeset -g _crazy_fix_hint=@CRAZY_FIX_HINT@ _crazy_capture=@CRAZY_CAPTURE@
+  typeset -g _crazy_stderr_file="" _crazy_stderr_fd=""
+  # Lets 'crazy ai fix' find the commands of this shell
+  typeset -gx CRAZY_SESSION=$_crazy_session
+
+  _crazy_preexec() {
+    _crazy_cmd=$1
+    _crazy_start=$EPOCHREALTIME
+    if [[ -n $_crazy_capture && -z $_crazy_stderr_fd ]]; then
+      : >| $_crazy_stderr_file
+      exec {_crazy_stderr_fd}>&2
+      exec 2> >(tee -a -- $_crazy_stderr_file >&$_crazy_stderr_fd)
+    fi
+  }
+
+  _crazy_precmd() {
+    local -i exit_status=$?
+    if [[ -n $_crazy_stderr_fd ]]; then
+      exec 2>&$_crazy_stderr_fd {_crazy_stderr_fd}>&-
+      _crazy_stderr_fd=""
+    fi
+    [[ -z $_crazy_start ]] && return 0
+    local -i duration
+    (( duration = (EPOCHREALTIME - _crazy_start) * 1000 ))
+    local cmd=$_crazy_cmd
+    _crazy_cmd="" _crazy_start=""
+    [[ -n $CRAZY_NO_HISTORY || $cmd == [[:space:]]* ]] && return 0
+    "$_crazy_bin" hook record --shell zsh --session "$_crazy_session" \
+      --cwd "$PWD" --exit $exit_status --duration $duration \
+      ${_crazy_capture:+--stderr-file=$_crazy_stderr_file} -- "$cmd" &>/dev/null &!
+    # Above 128 the command was interrupted by a signal, e.g. Ctrl-C
+    if [[ -n $_crazy_fix_hint ]] && (( exit_status != 0 && exit_status < 128 )); then
+      print -ru2 -- "crazy: exited with $exit_status, 'crazy ai fix' explains why"
+    fi
+  }
+
+  autoload -Uz add-zsh-hook
+  add-zsh-hook preexec _crazy_preexec
+  # First, so the exit status is not clobbered by other precmd hooks
+  precmd_functions=(_crazy_precmd ${precmd_functions:#_crazy_precmd})
+
+  # The error output may hold secrets: mktemp creates a new file only the
+  # user can read, with a name others cannot guess to plant a link
+  if [[ -n $_crazy_capture ]]; then
+    if _crazy_stderr_file=$(mktemp "${TMPDIR:-/tmp}/crazy-stderr.XXXXXXXXXX"); then
+      _crazy_zshexit() { rm -f -- $_crazy_stderr_file }
+      add-zsh-hook zshexit _crazy_zshexit
+    else
+      _crazy_capture=""
+      print -ru2 -- "crazy: cannot create a file for the error output, it is not captured"
+    fi
+  fi
+
+  # Replaces the command line with one picked from the history
+  _crazy_history_widget() {
+    local selected
+    selected=$("$_crazy_bin" history search --interactive -- "$BUFFER" </dev/tty)
+    if [[ -n $selected ]]; then
+      BUFFER=$selected
+      CURSOR=${#BUFFER}
+    fi
+    zle reset-prompt
+  }
+  zle -N _crazy_history_widget
+
+  # Replaces the description typed on the command line with a command for
+  # it, printing the explanation above the prompt
+  _crazy_ai_cmd_widget() {
+    [[ -z ${BUFFER//[[:space:]]/} ]] && return 0
+    local suggestion
+    zle -I
+    suggestion=$("$_crazy_bin" ai cmd --shell zsh --command-only -- "$BUFFER" </dev/null)
+    if [[ -n $suggestion ]]; then
+      BUFFER=$suggestion
+      CURSOR=${#BUFFER}
+    fi
+    zle reset-prompt
+  }
+  zle -N _crazy_ai_cmd_widget
+
+  # Replaces the command line with a fix for the last failed command,
+  # printing the diagnosis above the prompt
+  _crazy_ai_fix_widget() {
+    local fix
+    zle -I
+    fix=$("$_crazy_bin" ai fix --command-only </dev/null)
+    if [[ -n $fix ]]; then
+      BUFFER=$fix
+      CURSOR=${#BUFFER}
+    fi
+    zle reset-prompt
+  }
+  zle -N _crazy_ai_fix_widget
+@CRAZY_BIND@
+fi
+`
+
+const bashScript = `# crazy-dev shell integration for bash
+# Add to the end of ~/.bashrc:  eval "$(crazy init bash)"
+# Uses bash-preexec when it is loaded, the DEBUG trap and PROMPT_COMMAND
+# otherwise. Commands starting with a space are not recorded, nor any while
+# CRAZY_NO_HISTORY is set.
+if [[ $- == *i* && -z ${_crazy_hooks_loaded:-} ]]; then
+  _crazy_hooks_loaded=1
+  _crazy_bin=@CRAZY_BIN@
+  _crazy_session="$$-${EPOCHSECONDS:-$(date +%s)}"
+  _crazy_start="" _crazy_status=0 _crazy_in_prompt="" _crazy_histnum=""
+  _crazy_fix_hint=@CRAZY_FIX_HINT@ _crazy_capture=@CRAZY_CAPTURE@
+  _crazy_stderr_file="" _crazy_stderr_fd=""
+  # Lets 'crazy ai fix' find the commands of this shell
+  export CRAZY_SESSION=$_crazy_session
+
+  # Microseconds since the epoch with bash 5, since the shell started before
+  _crazy_now() {
+    if [[ -n ${EPOCHREALTIME:-} ]]; then
+      _crazy_now_us=${EPOCHREALTIME/[.,]/}
+    else
+      _crazy_now_us=$(( SECONDS * 1000000 ))
+    fi
+  }
+
+  _crazy_preexec() {
+    [[ -n $_crazy_start || -n $_crazy_in_prompt || -n ${COMP_LINE:-} ]] && return
+    [[ $BASH_COMMAND == _crazy_* ]] && return
+    _crazy_now
+    _crazy_start=$_crazy_now_us
+    if [[ -n $_crazy_capture && -z $_crazy_stderr_fd ]]; then
+      : >| "$_crazy_stderr_file"
+      exec {_crazy_stderr_fd}>&2
+      exec 2> >(tee -a -- "$_crazy_stderr_file" >&"$_crazy_stderr_fd")
+    fi
+  }
+
+  _crazy_restore_stderr() {
+    if [[ -n $_crazy_stderr_fd ]]; then
+      exec 2>&"$_crazy_stderr_fd" {_crazy_stderr_fd}>&-
+      _crazy_stderr_fd=""
+    fi
+  }
+
+  _crazy_prompt_start() {
+    _crazy_status=$?
+    _crazy_in_prompt=1
+    _crazy_restore_stderr
+  }
+
+  _crazy_precmd() {
+    local last=$?
+    [[ -n ${__bp_imported:-}${bash_preexec_imported:-} ]] && _crazy_status=$last
+    _crazy_restore_stderr
+    local exit_status=$_crazy_status start=$_crazy_start
+    _crazy_start="" _crazy_in_prompt=""
+    [[ -z $start ]] && return 0
+    _crazy_now
+    local duration=$(( (_crazy_now_us - start) / 1000 ))
+
+    # A command left out of the history, e.g. by HISTCONTROL=ignorespace,
+    # leaves the previous entry last: it must not be recorded twice
+    local line
+    line=$(HISTTIMEFORMAT= builtin history 1)
+    [[ $line =~ ^[[:space:]]*([0-9]+)[*[:space:]]+(.*)$ ]] || return 0
+    [[ ${BASH_REMATCH[1]} == "$_crazy_histnum" ]] && return 0
+    _crazy_histnum=${BASH_REMATCH[1]}
+    local cmd=${BASH_REMATCH[2]}
+    [[ -n ${CRAZY_NO_HISTORY:-} || $cmd == [[:space:]]* ]] && return 0
+    ("$_crazy_bin" hook record --shell bash --session "$_crazy_session" \
+      --cwd "$PWD" --exit "$exit_status" --duration "$duration" \
+      ${_crazy_capture:+"--stderr-file=$_crazy_stderr_file"} -- "$cmd" >/dev/null 2>&1 &)
+    # Above 128 the command was interrupted by a signal, e.g. Ctrl-C
+    if [[ -n $_crazy_fix_hint ]] && (( exit_status != 0 && exit_status < 128 )); then
+      printf "crazy: exited with %d, 'crazy ai fix' explains why\n" "$exit_status" >&2
+    fi
+  }
+
+  # The error output may hold secrets: mktemp creates a new file only the
+  # user can read, with a name others cannot guess to plant a link. It is
+  # removed on exit unless another EXIT trap is already set.
+  if [[ -n $_crazy_capture ]]; then
+    if _crazy_stderr_file=$(mktemp "${TMPDIR:-/tmp}/crazy-stderr.XXXXXXXXXX"); then
+      [[ -z $(trap -p EXIT) ]] && trap 'rm -f -- "$_crazy_stderr_file"' EXIT
+    else
+      _crazy_capture=""
+      printf "crazy: cannot create a file for the error output, it is not captured\n" >&2
+    fi
+  fi
+
+  # Replaces the command line with one picked from the history
+  _crazy_history_widget() {
+    local selected
+    selected=$("$_crazy_bin" history search --interactive -- "$READLINE_LINE" </dev/tty)
+    if [[ -n $selected ]]; then
+      READLINE_LINE=$selected
+      READLINE_POINT=${#READLINE_LINE}
+    fi
+  }
+
+  # Replaces the description typed on the command line with a command for
+  # it, printing the explanation above the prompt
+  _crazy_ai_cmd_widget() {
+    [[ -z ${READLINE_LINE//[[:space:]]/} ]] && return 0
+    local suggestion
+    suggestion=$("$_crazy_bin" ai cmd --shell bash --command-only -- "$READLINE_LINE" </dev/null)
+    if [[ -n $suggestion ]]; then
+      READLINE_LINE=$suggestion
+      READLINE_POINT=${#READLINE_LINE}
+    fi
+  }
+
+  # Replaces the command line with a fix for the last failed command,
+  # printing the diagnosis above the prompt
+  _crazy_ai_fix_widget() {
+    local fix
+    fix=$("$_crazy_bin" ai fix --command-only </dev/null)
+    if [[ -n $fix ]]; then
+      READLINE_LINE=$fix
+      READLINE_POINT=${#READLINE_LINE}
+    fi
+  }
+@CRAZY_BIND@
+  # Last, so the DEBUG trap does not fire for the lines above
+  if [[ -n ${__bp_imported:-}${bash_preexec_imported:-} ]]; then
+    preexec_functions+=(_crazy_preexec)
+    precmd_functions=(_crazy_precmd "${precmd_functions[@]}")
+  else
+    trap '_crazy_preexec' DEBUG
+    PROMPT_COMMAND="_crazy_prompt_start${PROMPT_COMMAND:+; $PROMPT_COMMAND}; _crazy_precmd"
+  fi
+fi
+`
+
+const fishScript = `# crazy-dev shell integration for fish
+# Add to ~/.config/fish/config.fish:  crazy init fish | source
+# Commands starting with a space are not recorded, nor any while
+# CRAZY_NO_HISTORY is set.
+if status is-interactive; and not set -q __crazy_hooks_loaded
+    set -g __crazy_hooks_loaded 1
+    set -g __crazy_bin @CRAZY_BIN@
+    set -g __crazy_session $fish_pid-(date +%s)
+    set -g __crazy_fix_hint @CRAZY_FIX_HINT@
+    # Lets 'crazy ai fix' find the commands of this shell
+    set -gx CRAZY_SESSION $__crazy_session
+
+    function __crazy_postexec --on-event fish_postexec
+        set -l exit_status $status
+        set -l duration $CMD_DURATION
+        set -l cmd $argv[1]
+        if test -z "$cmd"; or set -q CRAZY_NO_HISTORY; or string match -qr '^\s' -- $cmd
+            return
+        end
+        $__crazy_bin hook record --shell fish --session $__crazy_session \
+            --cwd $PWD --exit $exit_status --duration $duration -- $cmd >/dev/null 2>&1 &
+        disown 2>/dev/null
+        # Above 128 the command was interrupted by a signal, e.g. Ctrl-C
+        if test -n "$__crazy_fix_hint"; and test $exit_status -ne 0; and test $exit_status -lt 128
+            echo "crazy: exited with $exit_status, 'crazy ai fix' explains why" >&2
+        end
+    end
+
+    # Replaces the command line with one picked from the history
+    function __crazy_history_widget
+        set -l selected ($__crazy_bin history search --interactive -- (commandline) </dev/tty | string collect)
+        if test -n "$selected"
+            commandline -r -- $selected
+        end
+        commandline -f repaint
+    end
+
+    # Replaces the description typed on the command line with a command for
+    # it, printing the explanation above the prompt
+    function __crazy_ai_cmd_widget
+        set -l description (commandline | string collect)
+        string match -qr '\S' -- $description; or return
+        set -l suggestion ($__crazy_bin ai cmd --shell fish --command-only -- $description </dev/null | string collect)
+        if test -n "$suggestion"
+            commandline -r -- $suggestion
+        end
+        commandline -f repaint
+    end
+
+    # Replaces the command line with a fix for the last failed command,
+    # printing the diagnosis above the prompt
+    function __crazy_ai_fix_widget
+        set -l fix ($__crazy_bin ai fix --command-only </dev/null | string collect)
+        if test -n "$fix"
+            commandline -r -- $fix
+        end
+        commandline -f repaint
+    end
+@CRAZY_BIND@
+end
+`
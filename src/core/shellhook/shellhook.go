@@ -0,0 +1,228 @@
+// Package shellhook records the commands run in an interactive shell. The
+// scripts printed by `crazy init` report each command from the shell's
+// prompt hooks, and the events are appended to a JSON lines file that the
+// history store and AI features read.
+package shellhook
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxCommandSize truncates pasted scripts and other huge command lines
+const maxCommandSize = 16 * 1024
+
+// maxTailSize is how much of the end of the events file Recent reads
+const maxTailSize = 1024 * 1024
+
+// maxStderrSize is how much of the end of a failed command's error output
+// is kept
+const maxStderrSize = 8 * 1024
+
+// ansiEscape matches terminal color and cursor sequences
+var ansiEscape = regexp.MustCompile(`\x1b(\[[0-9;?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[@-Z\\-_])`)
+
+// Event is a command run in an interactive shell
+type Event struct {
+	Command string `json:"command"`
+	Cwd     string `json:"cwd"`
+
+	// Project is the root of the git repository of Cwd, Branch its branch
+	Project string `json:"project,omitempty"`
+	Branch  string `json:"branch,omitempty"`
+
+	ExitCode   int   `json:"exit_code"`
+	DurationMs int64 `json:"duration_ms"`
+
+	// Time is when the command started
+	Time time.Time `json:"time"`
+
+	// Session identifies the shell the command ran in
+	Session string `json:"session,omitempty"`
+	Shell   string `json:"shell,omitempty"`
+
+	// Stderr is the end of the error output of a failed command, when the
+	// shell captures it
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// Failed reports whether the command exited with a non-zero status
+func (e Event) Failed() bool {
+	return e.ExitCode != 0
+}
+
+// Duration returns how long the command ran
+func (e Event) Duration() time.Duration {
+	return time.Duration(e.DurationMs) * time.Millisecond
+}
+
+// ReadStderr returns the end of the error output captured in the file at
+// path, without terminal escape sequences
+func ReadStderr(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open captured error output: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to read captured error output: %w", err)
+	}
+	offset := max(info.Size()-maxStderrSize, 0)
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to read captured error output: %w", err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", fmt.Errorf("failed to read captured error output: %w", err)
+	}
+	if offset > 0 {
+		// Start at a full line
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	text := strings.ToValidUTF8(ansiEscape.ReplaceAllString(string(data), ""), string(utf8.RuneError))
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.TrimSpace(text), nil
+}
+
+// Append adds an event to the events file at path. Each event is a single
+// write to a file opened for appending, so concurrent shells do not
+// interleave their lines.
+func Append(path string, event Event) error {
+	if len(event.Command) > maxCommandSize {
+		event.Command = event.Command[:maxCommandSize]
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	line = append(line, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create events directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open events file: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return f.Close()
+}
+
+// Recent returns up to n of the latest events, oldest first. A missing
+// events file has no events.
+func Recent(path string, n int) ([]Event, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open events file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events file: %w", err)
+	}
+	offset := info.Size() - maxTailSize
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read events file: %w", err)
+	}
+	events, err := Decode(f, offset > 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > n {
+		events = events[len(events)-n:]
+	}
+	return events, nil
+}
+
+// Decode reads events from JSON lines. Lines that do not parse, such as one
+// still being written, are skipped. With skipFirst the first line is
+// dropped, for reads that start in the middle of a file.
+func Decode(r io.Reader, skipFirst bool) ([]Event, error) {
+	var events []Event
+	reader := bufio.NewReader(r)
+	for first := true; ; first = false {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && !(first && skipFirst) {
+			var event Event
+			if json.Unmarshal(bytes.TrimSpace(line), &event) == nil && event.Command != "" {
+				events = append(events, event)
+			}
+		}
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, fmt.Errorf("failed to read events: %w", err)
+		}
+	}
+}
+
+// Repository returns the root of the git repository containing dir and its
+// current branch, or a short commit hash when HEAD is detached. It reads
+// .git directly rather than running git, which would slow the hook down.
+func Repository(dir string) (root, branch string) {
+	for dir != "" {
+		gitPath := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitPath); err == nil {
+			gitDir := gitPath
+			if !info.IsDir() {
+				// Worktrees and submodules have a .git file pointing to the git directory
+				data, err := os.ReadFile(gitPath)
+				if err != nil {
+					return dir, ""
+				}
+				gitDir = strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+			}
+			return dir, headBranch(gitDir)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return "", ""
+}
+
+// headBranch reads the branch name from the HEAD file of a git directory
+func headBranch(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	head := strings.TrimSpace(string(data))
+	if ref, ok := strings.CutPrefix(head, "ref: "); ok {
+		return strings.TrimPrefix(ref, "refs/heads/")
+	}
+	if len(head) > 7 {
+		return head[:7]
+	}
+	return head
+}
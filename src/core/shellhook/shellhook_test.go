@@ -0,0 +1,125 @@
+package shellhook
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendAndRecent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history", "events.jsonl")
+
+	events, err := Recent(path, 10)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for i, command := range []string{"make", "go test ./...", "git push"} {
+		require.NoError(t, Append(path, Event{Command: command, Cwd: "/src", ExitCode: i, DurationMs: 1500, Time: now}))
+	}
+	// A line cut short by a crash is skipped
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	f.WriteString(`{"command":"tr`)
+	f.Close()
+
+	events, err = Recent(path, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "go test ./...", events[0].Command)
+	assert.True(t, events[0].Failed())
+	assert.Equal(t, "git push", events[1].Command)
+	assert.Equal(t, 1500*time.Millisecond, events[1].Duration())
+	assert.True(t, now.Equal(events[1].Time))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestRepository(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".git"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".git", "HEAD"), []byte("ref: refs/heads/feature/login\n"), 0644))
+	sub := filepath.Join(root, "cmd", "app")
+	require.NoError(t, os.MkdirAll(sub, 0755))
+
+	project, branch := Repository(sub)
+	assert.Equal(t, root, project)
+	assert.Equal(t, "feature/login", branch)
+
+	// A worktree points to its git directory, here with a detached HEAD
+	worktree := t.TempDir()
+	gitDir := filepath.Join(root, ".git", "worktrees", "wt")
+	require.NoError(t, os.MkdirAll(gitDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("3f2a9c1d8e7b6a5f4e3d2c1b0a9f8e7d6c5b4a39\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+gitDir+"\n"), 0644))
+
+	project, branch = Repository(worktree)
+	assert.Equal(t, worktree, project)
+	assert.Equal(t, "3f2a9c1", branch)
+}
+
+func TestScript(t *testing.T) {
+	script, err := Script("zsh", "/opt/it's here/crazy", Options{})
+	require.NoError(t, err)
+	assert.Contains(t, script, `typeset -g _crazy_bin='/opt/it'\''s here/crazy'`)
+	assert.NotContains(t, script, "@CRAZY_BIN@")
+
+	script, err = Script("fish", "/opt/it's here/crazy", Options{})
+	require.NoError(t, err)
+	assert.Contains(t, script, `set -g __crazy_bin '/opt/it\'s here/crazy'`)
+
+	for _, shell := range Shells() {
+		script, err := Script(shell, "crazy", Options{})
+		require.NoError(t, err)
+		assert.Contains(t, script, "hook record", shell)
+		assert.Contains(t, script, "history search --interactive", shell)
+		assert.NotContains(t, script, "@CRAZY_BIND@", shell)
+		assert.NotRegexp(t, `bind(key)? .*history_widget`, script, shell)
+
+		bound, err := Script(shell, "crazy", Options{BindHistory: true})
+		require.NoError(t, err)
+		assert.Regexp(t, `(?i)bind(key)? .*(\^R|C-r|\\cr).*history_widget`, bound, shell)
+		assert.NotRegexp(t, `bind(key)? .*ai_cmd_widget`, bound, shell)
+
+		bound, err = Script(shell, "crazy", Options{BindAI: true})
+		require.NoError(t, err)
+		assert.Regexp(t, `bind(key)? .*ai_cmd_widget`, bound, shell)
+		assert.Regexp(t, `bind(key)? .*ai_fix_widget`, bound, shell)
+		assert.NotContains(t, bound, "@CRAZY_", shell)
+		for _, line := range strings.Split(script, "\n") {
+			assert.False(t, strings.HasPrefix(line, "return"), "%s: a top-level return leaves the rc file", shell)
+		}
+	}
+
+	_, err = Script("tcsh", "crazy", Options{})
+	assert.ErrorContains(t, err, "unsupported shell")
+
+	for _, shell := range []string{"zsh", "bash"} {
+		script, err := Script(shell, "crazy", Options{CaptureStderr: true, FixHint: true})
+		require.NoError(t, err)
+		assert.Contains(t, script, "_crazy_capture=1", shell)
+		assert.Contains(t, script, "_crazy_fix_hint=1", shell)
+		assert.Contains(t, script, `_crazy_stderr_file=$(mktemp "${TMPDIR:-/tmp}/crazy-stderr.XXXXXXXXXX")`, shell)
+	}
+	_, err = Script("fish", "crazy", Options{CaptureStderr: true})
+	assert.ErrorContains(t, err, "not supported in fish")
+}
+
+func TestReadStderr(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stderr")
+	output := strings.Repeat("noise\n", 2000) + "\x1b[31merror:\x1b[0m build failed\r\n"
+	require.NoError(t, os.WriteFile(path, []byte(output), 0600))
+
+	stderr, err := ReadStderr(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(stderr, "noise\n"))
+	assert.True(t, strings.HasSuffix(stderr, "noise\nerror: build failed"))
+	assert.LessOrEqual(t, len(stderr), maxStderrSize)
+}
@@ -0,0 +1,509 @@
+// Package lineedit provides interactive line editing for terminal prompts
+package lineedit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// ErrInterrupted is returned by ReadLine when the user presses Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+const (
+	bracketedPasteOn  = "\x1b[?2004h"
+	bracketedPasteOff = "\x1b[?2004l"
+	pasteEnd          = "\x1b[201~"
+
+	// newlineMarker is shown in place of line breaks inside the edit buffer
+	newlineMarker = '↵'
+
+	defaultMaxHistory = 1000
+)
+
+// ansiPattern matches ANSI escape sequences, used to measure prompt width
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[a-zA-Z]`)
+
+// Editor reads user input with line editing, history and bracketed paste.
+// When stdin is not a terminal it falls back to plain line reading.
+type Editor struct {
+	in          *os.File
+	out         io.Writer
+	reader      *bufio.Reader
+	interactive bool
+
+	history     []string
+	historyFile string
+	maxHistory  int
+
+	multiline bool
+
+	// cursorRow is the terminal row of the cursor relative to the first prompt row
+	cursorRow int
+}
+
+// New creates an editor on stdin/stdout. If historyFile is not empty, history
+// is loaded from and appended to that file.
+func New(historyFile string) *Editor {
+	e := &Editor{
+		in:          os.Stdin,
+		out:         os.Stdout,
+		reader:      bufio.NewReader(os.Stdin),
+		interactive: term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())),
+		historyFile: historyFile,
+		maxHistory:  defaultMaxHistory,
+	}
+	e.loadHistory()
+	return e
+}
+
+// SetMultiline switches multi-line mode. In multi-line mode Enter inserts a
+// line break and Alt-Enter or Ctrl-D submits the input.
+func (e *Editor) SetMultiline(on bool) {
+	e.multiline = on
+}
+
+// Multiline reports whether multi-line mode is enabled
+func (e *Editor) Multiline() bool {
+	return e.multiline
+}
+
+// Interactive reports whether the editor is attached to a terminal
+func (e *Editor) Interactive() bool {
+	return e.interactive
+}
+
+// History returns the recorded history entries, oldest first
+func (e *Editor) History() []string {
+	return e.history
+}
+
+// AddHistory records an entry so it can be recalled with the up arrow
+func (e *Editor) AddHistory(entry string) {
+	if strings.TrimSpace(entry) == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == entry {
+		return
+	}
+
+	e.history = append(e.history, entry)
+	if len(e.history) > e.maxHistory {
+		e.history = e.history[len(e.history)-e.maxHistory:]
+	}
+
+	if e.historyFile == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(e.historyFile), 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	// Entries are stored as JSON strings so multi-line prompts fit on one line
+	data, _ := json.Marshal(entry)
+	f.Write(append(data, '\n'))
+}
+
+// loadHistory reads the history file, ignoring malformed lines
+func (e *Editor) loadHistory() {
+	if e.historyFile == "" {
+		return
+	}
+
+	f, err := os.Open(e.historyFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry string
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil && entry != "" {
+			e.history = append(e.history, entry)
+		}
+	}
+
+	if len(e.history) > e.maxHistory {
+		e.history = e.history[len(e.history)-e.maxHistory:]
+	}
+}
+
+// ReadLine shows the prompt and returns the submitted input. It returns
+// io.EOF when input ends (Ctrl-D on an empty line) and ErrInterrupted on Ctrl-C.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if !e.interactive {
+		return e.readPlain(prompt)
+	}
+
+	fd := int(e.in.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return e.readPlain(prompt)
+	}
+	defer term.Restore(fd, state)
+
+	fmt.Fprint(e.out, bracketedPasteOn)
+	defer fmt.Fprint(e.out, bracketedPasteOff)
+
+	return e.readInteractive(prompt, func() (string, error) {
+		// The external editor needs the terminal in its normal state
+		fmt.Fprint(e.out, bracketedPasteOff)
+		term.Restore(fd, state)
+		defer func() {
+			term.MakeRaw(fd)
+			fmt.Fprint(e.out, bracketedPasteOn)
+		}()
+		return EditText("")
+	})
+}
+
+// readPlain reads input when stdin is not a terminal. In multi-line mode,
+// lines are collected until a line containing a single "." or end of input.
+func (e *Editor) readPlain(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+
+	var lines []string
+	for {
+		line, err := e.reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if err != nil {
+			if err == io.EOF && (line != "" || len(lines) > 0) {
+				return strings.Join(append(lines, line), "\n"), nil
+			}
+			return "", err
+		}
+
+		if !e.multiline {
+			return line, nil
+		}
+		if line == "." {
+			return strings.Join(lines, "\n"), nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+// readInteractive runs the key handling loop on a terminal in raw mode.
+// compose is called for Ctrl-X Ctrl-E to write the input in an external editor.
+func (e *Editor) readInteractive(prompt string, compose func() (string, error)) (string, error) {
+	var buf []rune
+	pos := 0
+	historyIndex := len(e.history)
+	draft := ""
+	e.cursorRow = 0
+
+	insert := func(runes ...rune) {
+		tail := append([]rune{}, buf[pos:]...)
+		buf = append(append(buf[:pos], runes...), tail...)
+		pos += len(runes)
+	}
+	recall := func(index int) {
+		if index < 0 || index > len(e.history) {
+			return
+		}
+		if historyIndex == len(e.history) {
+			draft = string(buf)
+		}
+		historyIndex = index
+		if index == len(e.history) {
+			buf = []rune(draft)
+		} else {
+			buf = []rune(e.history[index])
+		}
+		pos = len(buf)
+	}
+	submit := func() (string, error) {
+		e.refresh(prompt, buf, len(buf))
+		fmt.Fprint(e.out, "\r\n")
+		e.cursorRow = 0
+		return string(buf), nil
+	}
+
+	e.refresh(prompt, buf, pos)
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(buf) > 0 {
+				return submit()
+			}
+			return "", err
+		}
+
+		switch r {
+		case '\r': // Enter
+			if e.multiline {
+				insert('\n')
+			} else {
+				return submit()
+			}
+		case '\n': // Ctrl-J
+			insert('\n')
+		case 3: // Ctrl-C
+			e.refresh(prompt, buf, len(buf))
+			fmt.Fprint(e.out, "^C\r\n")
+			e.cursorRow = 0
+			return "", ErrInterrupted
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				e.cursorRow = 0
+				return "", io.EOF
+			}
+			if e.multiline {
+				return submit()
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(buf)
+		case 2: // Ctrl-B
+			if pos > 0 {
+				pos--
+			}
+		case 6: // Ctrl-F
+			if pos < len(buf) {
+				pos++
+			}
+		case 11: // Ctrl-K
+			buf = buf[:pos]
+		case 21: // Ctrl-U
+			buf = append([]rune{}, buf[pos:]...)
+			pos = 0
+		case 23: // Ctrl-W
+			start := wordStart(buf, pos)
+			buf = append(buf[:start], buf[pos:]...)
+			pos = start
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+			e.cursorRow = 0
+		case 16: // Ctrl-P
+			recall(historyIndex - 1)
+		case 14: // Ctrl-N
+			recall(historyIndex + 1)
+		case 24: // Ctrl-X, followed by Ctrl-E opens the external editor
+			next, _, err := e.reader.ReadRune()
+			if err != nil {
+				break
+			}
+			if next != 5 || compose == nil {
+				// Not Ctrl-X Ctrl-E, handle the key on its own
+				e.reader.UnreadRune()
+				break
+			}
+			fmt.Fprint(e.out, "\r\n")
+			e.cursorRow = 0
+			text, err := compose()
+			if err != nil {
+				fmt.Fprintf(e.out, "Editor error: %v\r\n", err)
+				break
+			}
+			if text = strings.TrimSpace(text); text != "" {
+				fmt.Fprint(e.out, prompt+strings.ReplaceAll(text, "\n", "\r\n")+"\r\n")
+				return text, nil
+			}
+		case 27: // Escape sequences
+			seq, err := e.readEscape()
+			if err != nil {
+				return "", err
+			}
+			switch seq {
+			case "[A", "OA":
+				recall(historyIndex - 1)
+			case "[B", "OB":
+				recall(historyIndex + 1)
+			case "[C", "OC":
+				if pos < len(buf) {
+					pos++
+				}
+			case "[D", "OD":
+				if pos > 0 {
+					pos--
+				}
+			case "[H", "OH", "[1~", "[7~":
+				pos = 0
+			case "[F", "OF", "[4~", "[8~":
+				pos = len(buf)
+			case "[3~":
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			case "b":
+				pos = wordStart(buf, pos)
+			case "f":
+				pos = wordEnd(buf, pos)
+			case "\r": // Alt-Enter
+				if e.multiline {
+					return submit()
+				}
+				insert('\n')
+			case "[200~":
+				text, err := e.readPaste()
+				if err != nil {
+					return "", err
+				}
+				insert([]rune(text)...)
+			}
+		default:
+			if r == '\t' || unicode.IsPrint(r) {
+				insert(r)
+			}
+		}
+
+		e.refresh(prompt, buf, pos)
+	}
+}
+
+// readEscape reads the rest of an escape sequence after ESC. Terminals send
+// a sequence in a single write, so when nothing follows ESC in the buffer it
+// was a lone Esc key press and an empty sequence is returned.
+func (e *Editor) readEscape() (string, error) {
+	if e.reader.Buffered() == 0 {
+		return "", nil
+	}
+	r, _, err := e.reader.ReadRune()
+	if err != nil {
+		return "", err
+	}
+
+	switch r {
+	case '[':
+		// CSI: parameters followed by a final byte in the range @ to ~
+		seq := []rune{r}
+		for {
+			c, _, err := e.reader.ReadRune()
+			if err != nil {
+				return "", err
+			}
+			seq = append(seq, c)
+			if c >= '@' && c <= '~' {
+				return string(seq), nil
+			}
+		}
+	case 'O':
+		c, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		return string([]rune{r, c}), nil
+	default:
+		return string(r), nil
+	}
+}
+
+// readPaste reads a bracketed paste up to the closing sequence
+func (e *Editor) readPaste() (string, error) {
+	var sb strings.Builder
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		sb.WriteRune(r)
+		if r == '~' && strings.HasSuffix(sb.String(), pasteEnd) {
+			break
+		}
+	}
+
+	text := strings.TrimSuffix(sb.String(), pasteEnd)
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n"), nil
+}
+
+// refresh redraws the prompt and buffer and places the cursor at pos
+func (e *Editor) refresh(prompt string, buf []rune, pos int) {
+	width := e.width()
+	promptWidth := len([]rune(ansiPattern.ReplaceAllString(prompt, "")))
+
+	var sb strings.Builder
+	if e.cursorRow > 0 {
+		sb.WriteString(fmt.Sprintf("\x1b[%dA", e.cursorRow))
+	}
+	sb.WriteString("\r\x1b[J")
+	sb.WriteString(prompt)
+	for _, r := range buf {
+		switch r {
+		case '\n':
+			sb.WriteRune(newlineMarker)
+		case '\t':
+			sb.WriteRune(' ')
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	end := promptWidth + len(buf)
+	if end > 0 && end%width == 0 {
+		// Force the pending wrap so the cursor math below holds
+		sb.WriteString("\r\n")
+	}
+
+	endRow := end / width
+	cursor := promptWidth + pos
+	cursorRow, cursorCol := cursor/width, cursor%width
+	if up := endRow - cursorRow; up > 0 {
+		sb.WriteString(fmt.Sprintf("\x1b[%dA", up))
+	}
+	sb.WriteString("\r")
+	if cursorCol > 0 {
+		sb.WriteString(fmt.Sprintf("\x1b[%dC", cursorCol))
+	}
+
+	e.cursorRow = cursorRow
+	fmt.Fprint(e.out, sb.String())
+}
+
+// width returns the terminal width, defaulting to 80 columns
+func (e *Editor) width() int {
+	if f, ok := e.out.(*os.File); ok {
+		if w, _, err := term.GetSize(int(f.Fd())); err == nil && w > 0 {
+			return w
+		}
+	}
+	return 80
+}
+
+// wordStart returns the start of the word before pos
+func wordStart(buf []rune, pos int) int {
+	for pos > 0 && unicode.IsSpace(buf[pos-1]) {
+		pos--
+	}
+	for pos > 0 && !unicode.IsSpace(buf[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// wordEnd returns the end of the word after pos
+func wordEnd(buf []rune, pos int) int {
+	for pos < len(buf) && unicode.IsSpace(buf[pos]) {
+		pos++
+	}
+	for pos < len(buf) && !unicode.IsSpace(buf[pos]) {
+		pos++
+	}
+	return pos
+}
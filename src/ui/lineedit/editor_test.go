@@ -0,0 +1,138 @@
+package lineedit
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEditor(input string) *Editor {
+	return &Editor{
+		out:        &bytes.Buffer{},
+		reader:     bufio.NewReader(strings.NewReader(input)),
+		maxHistory: defaultMaxHistory,
+	}
+}
+
+func TestReadInteractive_BracketedPasteIsOneMessage(t *testing.T) {
+	e := newTestEditor("why?\x1b[200~panic: boom\r\ngoroutine 1\r\n\x1b[201~\r")
+
+	line, err := e.readInteractive("> ", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "why?panic: boom\ngoroutine 1\n", line)
+}
+
+func TestReadInteractive_EditingKeys(t *testing.T) {
+	// Type "helo", move left once, insert "l", jump to the end, then Alt-Enter for a new line
+	e := newTestEditor("helo\x1b[Dl\x05\x1b\rworld\r")
+
+	line, err := e.readInteractive("> ", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\nworld", line)
+}
+
+func TestReadInteractive_HistoryRecall(t *testing.T) {
+	e := newTestEditor("\x1b[A\x1b[A!\r")
+	e.history = []string{"first", "second"}
+
+	line, err := e.readInteractive("> ", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "first!", line)
+}
+
+func TestReadInteractive_MultilineMode(t *testing.T) {
+	e := newTestEditor("a\rb\x04")
+	e.SetMultiline(true)
+
+	line, err := e.readInteractive("> ", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb", line)
+}
+
+func TestReadInteractive_CtrlDAndCtrlC(t *testing.T) {
+	_, err := newTestEditor("\x04").readInteractive("> ", nil)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = newTestEditor("abc\x03").readInteractive("> ", nil)
+	assert.Equal(t, ErrInterrupted, err)
+}
+
+func TestReadInteractive_ComposeInEditor(t *testing.T) {
+	e := newTestEditor("\x18\x05")
+
+	line, err := e.readInteractive("> ", func() (string, error) {
+		return "long\nprompt\n", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "long\nprompt", line)
+}
+
+// chunkReader returns one chunk per Read, like key presses arriving from a terminal
+type chunkReader struct {
+	chunks []string
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, c.chunks[0])
+	c.chunks = c.chunks[1:]
+	return n, nil
+}
+
+func TestReadInteractive_LoneEscDoesNotBlock(t *testing.T) {
+	e := newTestEditor("")
+	e.reader = bufio.NewReader(&chunkReader{chunks: []string{"ab", "\x1b", "c", "\x1b[D", "!\r"}})
+
+	line, err := e.readInteractive("> ", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ab!c", line)
+}
+
+func TestReadInteractive_CtrlXWithoutCtrlEKeepsKey(t *testing.T) {
+	e := newTestEditor("\x18a\x18\x01b\r")
+
+	line, err := e.readInteractive("> ", func() (string, error) {
+		t.Fatal("compose must not be called")
+		return "", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ba", line)
+}
+
+func TestReadPlain_Multiline(t *testing.T) {
+	e := newTestEditor("one\ntwo\n.\nthree\n")
+	e.SetMultiline(true)
+
+	line, err := e.readPlain("")
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo", line)
+
+	e.SetMultiline(false)
+	line, err = e.readPlain("")
+	require.NoError(t, err)
+	assert.Equal(t, "three", line)
+
+	_, err = e.readPlain("")
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestHistoryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	e := &Editor{historyFile: path, maxHistory: defaultMaxHistory}
+	e.AddHistory("single line")
+	e.AddHistory("multi\nline")
+	e.AddHistory("multi\nline")
+
+	reloaded := &Editor{historyFile: path, maxHistory: defaultMaxHistory}
+	reloaded.loadHistory()
+	assert.Equal(t, []string{"single line", "multi\nline"}, reloaded.History())
+}
@@ -0,0 +1,88 @@
+package markdown
+
+import (
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/fatih/color"
+)
+
+// languageAliases maps fence labels that chroma does not know to lexer names
+var languageAliases = map[string]string{
+	"sh":      "bash",
+	"shell":   "bash",
+	"zsh":     "bash",
+	"console": "bash",
+	"golang":  "go",
+	"js":      "javascript",
+	"ts":      "typescript",
+	"py":      "python",
+	"yml":     "yaml",
+}
+
+// lexerFor returns the lexer for a fence language, or nil if the language is unknown
+func lexerFor(language string) chroma.Lexer {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if language == "" {
+		return nil
+	}
+	if alias, ok := languageAliases[language]; ok {
+		language = alias
+	}
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		return nil
+	}
+	return chroma.Coalesce(lexer)
+}
+
+// highlight colors a single line of code. Code is highlighted line by line so
+// it can be streamed; constructs spanning lines are colored per line.
+func (r *Renderer) highlight(lexer chroma.Lexer, line string) string {
+	if lexer == nil {
+		return line
+	}
+
+	iterator, err := lexer.Tokenise(nil, line)
+	if err != nil {
+		return line
+	}
+
+	var sb strings.Builder
+	for _, token := range iterator.Tokens() {
+		value := strings.TrimSuffix(token.Value, "\n")
+		if value == "" {
+			continue
+		}
+		if c := r.tokenColor(token.Type); c != nil {
+			sb.WriteString(c.Sprint(value))
+		} else {
+			sb.WriteString(value)
+		}
+	}
+	return sb.String()
+}
+
+// tokenColor returns the theme color for a token type, or nil for plain text
+func (r *Renderer) tokenColor(t chroma.TokenType) *color.Color {
+	switch {
+	case t.InCategory(chroma.Comment):
+		return r.theme.Comment
+	case t == chroma.KeywordType || t == chroma.NameClass || t == chroma.NameBuiltin:
+		return r.theme.Type
+	case t.InCategory(chroma.Keyword):
+		return r.theme.Keyword
+	case t == chroma.NameFunction:
+		return r.theme.Function
+	case t.InSubCategory(chroma.LiteralString):
+		return r.theme.String
+	case t.InSubCategory(chroma.LiteralNumber):
+		return r.theme.Number
+	case t == chroma.GenericInserted:
+		return r.theme.Inserted
+	case t == chroma.GenericDeleted:
+		return r.theme.Deleted
+	}
+	return nil
+}
@@ -0,0 +1,486 @@
+// Package markdown renders streamed markdown for the terminal
+package markdown
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+)
+
+// defaultWidth is used for rules and tables when the terminal width is unknown
+const defaultWidth = 80
+
+var (
+	headingPattern   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	rulePattern      = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	orderedPattern   = regexp.MustCompile(`^(\d{1,9})[.)] `)
+	separatorPattern = regexp.MustCompile(`^:?-+:?$`)
+	ansiPattern      = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+)
+
+// Options configures a Renderer
+type Options struct {
+	// Theme holds the colors used for markdown elements and code
+	Theme Theme
+
+	// Width is the terminal width used for rules and tables
+	Width int
+
+	// Plain writes the text unchanged, e.g. when stdout is not a terminal
+	Plain bool
+}
+
+// Renderer renders markdown written to it in arbitrary chunks. Prose, list
+// items and quotes are written word by word as they arrive, code blocks are
+// highlighted line by line and tables are printed once they are complete.
+type Renderer struct {
+	w     io.Writer
+	theme Theme
+	width int
+	plain bool
+
+	// pending holds the current, unterminated line. When streamed is set its
+	// block prefix has been written along with the first emitted bytes.
+	pending  string
+	emitted  int
+	streamed bool
+
+	inFence bool
+	fence   string
+	lexer   chroma.Lexer
+
+	table []string
+	err   error
+}
+
+// New creates a renderer writing to w
+func New(w io.Writer, opts Options) *Renderer {
+	width := opts.Width
+	if width <= 0 {
+		width = defaultWidth
+	}
+	theme := opts.Theme
+	if theme.Heading == nil {
+		theme = NewTheme(nil)
+	}
+	return &Renderer{
+		w:     w,
+		theme: theme,
+		width: width,
+		plain: opts.Plain,
+	}
+}
+
+// Write renders a chunk of markdown
+func (r *Renderer) Write(p []byte) (int, error) {
+	if r.plain {
+		return r.w.Write(p)
+	}
+
+	r.pending += string(p)
+	for {
+		i := strings.IndexByte(r.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSuffix(r.pending[:i], "\r")
+		r.pending = r.pending[i+1:]
+		r.finishLine(line, true)
+	}
+	r.streamPartial()
+
+	if r.err != nil {
+		return 0, r.err
+	}
+	return len(p), nil
+}
+
+// WriteString renders a chunk of markdown
+func (r *Renderer) WriteString(s string) (int, error) {
+	return r.Write([]byte(s))
+}
+
+// Flush renders any buffered text and resets the renderer for the next response
+func (r *Renderer) Flush() error {
+	if r.plain {
+		return nil
+	}
+
+	if r.pending != "" {
+		line := r.pending
+		r.pending = ""
+		r.finishLine(line, false)
+	}
+	r.flushTable()
+	r.inFence = false
+	r.lexer = nil
+
+	err := r.err
+	r.err = nil
+	return err
+}
+
+// write writes s, remembering the first error
+func (r *Renderer) write(s string) {
+	if r.err != nil || s == "" {
+		return
+	}
+	_, r.err = io.WriteString(r.w, s)
+}
+
+// finishLine renders a complete line, or the rest of a line that was partially streamed
+func (r *Renderer) finishLine(line string, newline bool) {
+	if r.streamed {
+		r.write(r.renderInline(line[r.emitted:]))
+		r.streamed = false
+		r.emitted = 0
+	} else {
+		r.renderLine(line)
+	}
+	if newline && !r.buffering() {
+		r.write("\n")
+	}
+}
+
+// buffering reports whether the last line was held back, e.g. as a table row
+func (r *Renderer) buffering() bool {
+	return len(r.table) > 0
+}
+
+// streamPartial writes the part of the pending line that can be rendered safely
+func (r *Renderer) streamPartial() {
+	if r.inFence || r.pending == "" {
+		return
+	}
+
+	line := r.pending
+	if !r.streamed {
+		if !streamable(line) {
+			return
+		}
+		r.flushTable()
+		prefix, offset := r.blockPrefix(line)
+		r.write(prefix)
+		r.emitted = offset
+		r.streamed = true
+	}
+
+	if end := safePoint(line, r.emitted); end > r.emitted {
+		r.write(r.renderInline(line[r.emitted:end]))
+		r.emitted = end
+	}
+}
+
+// streamable reports whether an unterminated line is known to be prose, a list item or a quote
+func streamable(line string) bool {
+	t := strings.TrimLeft(line, " \t")
+	if len(t) < 4 {
+		// Too short to tell fences, rules and list markers apart
+		return false
+	}
+	if strings.HasPrefix(t, "```") || strings.HasPrefix(t, "~~~") || t[0] == '|' || t[0] == '#' {
+		return false
+	}
+	return strings.Trim(t, "-*_ ") != ""
+}
+
+// renderLine renders a complete line outside of streaming
+func (r *Renderer) renderLine(line string) {
+	trimmed := strings.TrimSpace(line)
+
+	if r.inFence {
+		if strings.HasPrefix(trimmed, r.fence) && strings.Trim(trimmed, r.fence[:1]) == "" {
+			r.write(r.theme.Muted.Sprint(line))
+			r.inFence = false
+			r.lexer = nil
+			return
+		}
+		r.write(r.highlight(r.lexer, line))
+		return
+	}
+
+	if strings.HasPrefix(trimmed, "|") {
+		r.table = append(r.table, trimmed)
+		return
+	}
+	r.flushTable()
+
+	if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		r.inFence = true
+		r.fence = trimmed[:3]
+		r.lexer = lexerFor(firstField(strings.TrimLeft(trimmed, r.fence[:1])))
+		r.write(r.theme.Muted.Sprint(line))
+		return
+	}
+
+	if m := headingPattern.FindStringSubmatch(trimmed); m != nil {
+		if len(m[1]) == 1 {
+			r.write(r.theme.Title.Sprint(m[2]))
+		} else {
+			r.write(r.theme.Heading.Sprint(m[2]))
+		}
+		return
+	}
+
+	if rulePattern.MatchString(line) {
+		r.write(r.theme.Muted.Sprint(strings.Repeat("─", r.width)))
+		return
+	}
+
+	prefix, offset := r.blockPrefix(line)
+	r.write(prefix + r.renderInline(line[offset:]))
+}
+
+// blockPrefix renders list markers and quote bars, returning the rendered
+// prefix and the offset of the remaining text in line
+func (r *Renderer) blockPrefix(line string) (string, int) {
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+	rest := line[indent:]
+
+	switch {
+	case len(rest) > 1 && strings.ContainsRune("-*+", rune(rest[0])) && rest[1] == ' ':
+		bullet := r.theme.Bullet.Sprint("•")
+		if strings.HasPrefix(rest[2:], "[ ] ") {
+			return line[:indent] + bullet + " ☐ ", indent + 6
+		}
+		if strings.HasPrefix(rest[2:], "[x] ") || strings.HasPrefix(rest[2:], "[X] ") {
+			return line[:indent] + bullet + " ☑ ", indent + 6
+		}
+		return line[:indent] + bullet + " ", indent + 2
+	case orderedPattern.MatchString(rest):
+		marker := orderedPattern.FindString(rest)
+		return line[:indent] + r.theme.Bullet.Sprint(strings.TrimSpace(marker)) + " ", indent + len(marker)
+	case strings.HasPrefix(rest, ">"):
+		offset := indent + 1
+		if strings.HasPrefix(rest, "> ") {
+			offset++
+		}
+		return line[:indent] + r.theme.Muted.Sprint("│ "), offset
+	}
+	return "", 0
+}
+
+// renderInline renders emphasis, inline code and links
+func (r *Renderer) renderInline(text string) string {
+	var sb strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '`':
+			if end := strings.IndexByte(text[i+1:], '`'); end >= 0 {
+				sb.WriteString(r.theme.Code.Sprint(text[i+1 : i+1+end]))
+				i += end + 2
+				continue
+			}
+		case strings.HasPrefix(text[i:], "**") || strings.HasPrefix(text[i:], "__"):
+			marker := text[i : i+2]
+			if end := strings.Index(text[i+2:], marker); end > 0 {
+				sb.WriteString(r.theme.Bold.Sprint(text[i+2 : i+2+end]))
+				i += end + 4
+				continue
+			}
+		case (c == '*' || c == '_') && opensEmphasis(text, i):
+			if end := closingEmphasis(text, i); end > 0 {
+				sb.WriteString(r.theme.Italic.Sprint(text[i+1 : end]))
+				i = end + 1
+				continue
+			}
+		case c == '[':
+			if label, url, n, ok := parseLink(text[i:]); ok {
+				sb.WriteString(r.theme.Link.Sprint(label))
+				if url != label {
+					sb.WriteString(r.theme.Muted.Sprint(" (" + url + ")"))
+				}
+				i += n
+				continue
+			}
+		}
+		sb.WriteByte(c)
+		i++
+	}
+	return sb.String()
+}
+
+// opensEmphasis reports whether the * or _ at i can start emphasis.
+// Underscores inside words, as in snake_case, never do.
+func opensEmphasis(text string, i int) bool {
+	if i+1 >= len(text) || text[i+1] == ' ' {
+		return false
+	}
+	if text[i] == '_' && i > 0 && isWordByte(text[i-1]) {
+		return false
+	}
+	return true
+}
+
+// closingEmphasis returns the index of the marker closing the emphasis opened at i, or -1
+func closingEmphasis(text string, i int) int {
+	marker := text[i]
+	for j := i + 2; j < len(text); j++ {
+		if text[j] != marker || text[j-1] == ' ' {
+			continue
+		}
+		if marker == '_' && j+1 < len(text) && isWordByte(text[j+1]) {
+			continue
+		}
+		if marker == '*' && j+1 < len(text) && text[j+1] == '*' {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// parseLink parses "[label](url)" at the start of text
+func parseLink(text string) (label, url string, n int, ok bool) {
+	closeLabel := strings.Index(text, "](")
+	if closeLabel < 1 || strings.ContainsAny(text[1:closeLabel], "[]") {
+		return "", "", 0, false
+	}
+	closeURL := strings.IndexByte(text[closeLabel+2:], ')')
+	if closeURL < 0 {
+		return "", "", 0, false
+	}
+	return text[1:closeLabel], text[closeLabel+2 : closeLabel+2+closeURL], closeLabel + 3 + closeURL, true
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= utf8.RuneSelf || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// safePoint returns the end of the longest prefix of line[from:] that ends
+// after a space with all inline markup closed, so it renders the same on its own
+func safePoint(line string, from int) int {
+	safe := from
+	inCode, bold, italic := false, "", byte(0)
+	link := 0
+
+	for i := from; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '`':
+			inCode = !inCode
+		case inCode:
+		case strings.HasPrefix(line[i:], "**") || strings.HasPrefix(line[i:], "__"):
+			if bold == line[i:i+2] {
+				bold = ""
+			} else if bold == "" {
+				bold = line[i : i+2]
+			}
+			i++
+		case c == '*' || c == '_':
+			if italic == c && (c == '*' || i+1 >= len(line) || !isWordByte(line[i+1])) {
+				italic = 0
+			} else if italic == 0 && opensEmphasis(line, i) {
+				italic = c
+			}
+		case c == '[':
+			link++
+		case c == ')' && link > 0:
+			link--
+		case c == ' ' && !inCode && bold == "" && italic == 0 && link == 0:
+			safe = i + 1
+		}
+	}
+	return safe
+}
+
+// flushTable renders the buffered table rows with aligned columns
+func (r *Renderer) flushTable() {
+	if len(r.table) == 0 {
+		return
+	}
+	rows := r.table
+	r.table = nil
+
+	var cells [][]string
+	header := -1
+	for _, row := range rows {
+		fields := splitRow(row)
+		if header < 0 && len(cells) == 1 && isSeparatorRow(fields) {
+			header = 0
+			continue
+		}
+		rendered := make([]string, len(fields))
+		for i, field := range fields {
+			rendered[i] = r.renderInline(field)
+		}
+		cells = append(cells, rendered)
+	}
+
+	var widths []int
+	for _, row := range cells {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if w := visibleWidth(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+
+	bar := r.theme.Muted.Sprint(" │ ")
+	for n, row := range cells {
+		parts := make([]string, len(widths))
+		for i := range widths {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			if n == header {
+				cell = r.theme.Bold.Sprint(ansiPattern.ReplaceAllString(cell, ""))
+			}
+			parts[i] = cell + strings.Repeat(" ", widths[i]-visibleWidth(cell))
+		}
+		r.write(strings.TrimRight(strings.Join(parts, bar), " ") + "\n")
+
+		if n == header {
+			rules := make([]string, len(widths))
+			for i, w := range widths {
+				rules[i] = strings.Repeat("─", w)
+			}
+			r.write(r.theme.Muted.Sprint(strings.Join(rules, "─┼─")) + "\n")
+		}
+	}
+}
+
+// splitRow splits a table row into trimmed cells
+func splitRow(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	row = strings.TrimSuffix(row, "|")
+
+	fields := strings.Split(row, "|")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields
+}
+
+// isSeparatorRow reports whether cells form the row below a table header
+func isSeparatorRow(cells []string) bool {
+	for _, cell := range cells {
+		if !separatorPattern.MatchString(cell) {
+			return false
+		}
+	}
+	return len(cells) > 0
+}
+
+// visibleWidth returns the number of terminal columns used by s
+func visibleWidth(s string) int {
+	return utf8.RuneCountInString(ansiPattern.ReplaceAllString(s, ""))
+}
+
+// firstField returns the first whitespace-separated word of s
+func firstField(s string) string {
+	if fields := strings.Fields(s); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
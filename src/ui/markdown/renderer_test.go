@@ -0,0 +1,125 @@
+package markdown
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sample = "# Title\n" +
+	"Some **bold** and `code` with snake_case_name.\n" +
+	"- first [docs](https://example.com)\n" +
+	"2. second\n" +
+	"> quoted\n" +
+	"```go\n" +
+	"func main() {}\n" +
+	"```\n" +
+	"| a | b |\n" +
+	"|---|---|\n" +
+	"| 1 | 22 |\n" +
+	"---\n" +
+	"done"
+
+const sampleRendered = "Title\n" +
+	"Some bold and code with snake_case_name.\n" +
+	"• first docs (https://example.com)\n" +
+	"2. second\n" +
+	"│ quoted\n" +
+	"```go\n" +
+	"func main() {}\n" +
+	"```\n" +
+	"a │ b\n" +
+	"──┼───\n" +
+	"1 │ 22\n" +
+	"──────────\n" +
+	"done"
+
+func withoutColor(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = noColor })
+}
+
+func TestRenderer_Blocks(t *testing.T) {
+	withoutColor(t)
+
+	var out bytes.Buffer
+	r := New(&out, Options{Width: 10})
+	_, err := r.WriteString(sample)
+	require.NoError(t, err)
+	require.NoError(t, r.Flush())
+
+	assert.Equal(t, sampleRendered, out.String())
+}
+
+func TestRenderer_ChunkedInputMatchesWholeInput(t *testing.T) {
+	withoutColor(t)
+
+	var out bytes.Buffer
+	r := New(&out, Options{Width: 10})
+	for i := 0; i < len(sample); i += 3 {
+		end := i + 3
+		if end > len(sample) {
+			end = len(sample)
+		}
+		_, err := r.WriteString(sample[i:end])
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.Flush())
+
+	assert.Equal(t, sampleRendered, out.String())
+}
+
+func TestRenderer_StreamsProseBeforeLineEnds(t *testing.T) {
+	withoutColor(t)
+
+	var out bytes.Buffer
+	r := New(&out, Options{})
+
+	r.WriteString("- Hello **wor")
+	assert.Equal(t, "• Hello ", out.String())
+
+	r.WriteString("ld** again")
+	assert.Equal(t, "• Hello world ", out.String())
+
+	require.NoError(t, r.Flush())
+	assert.Equal(t, "• Hello world again", out.String())
+}
+
+func TestRenderer_HighlightsCode(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	t.Cleanup(func() { color.NoColor = noColor })
+
+	var out bytes.Buffer
+	r := New(&out, Options{})
+	r.WriteString("```go\nreturn \"hi\"\n```\n")
+	require.NoError(t, r.Flush())
+
+	theme := NewTheme(nil)
+	assert.Contains(t, out.String(), theme.Keyword.Sprint("return"))
+	assert.Contains(t, out.String(), theme.String.Sprint("\"hi\""))
+}
+
+func TestRenderer_Plain(t *testing.T) {
+	var out bytes.Buffer
+	r := New(&out, Options{Plain: true})
+	r.WriteString(sample)
+	require.NoError(t, r.Flush())
+
+	assert.Equal(t, sample, out.String())
+}
+
+func TestNewTheme_InvalidColorsFallBack(t *testing.T) {
+	r, g, b, ok := parseHex("#abc")
+	assert.True(t, ok)
+	assert.Equal(t, []int{0xaa, 0xbb, 0xcc}, []int{r, g, b})
+
+	_, _, _, ok = parseHex("blue")
+	assert.False(t, ok)
+
+	assert.NotNil(t, NewTheme(map[string]string{"primary": "blue"}).Heading)
+}
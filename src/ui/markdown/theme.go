@@ -0,0 +1,90 @@
+package markdown
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Default colors, matching ui.colors in the default configuration
+const (
+	DefaultPrimary   = "#4285F4"
+	DefaultSecondary = "#34A853"
+	DefaultAccent    = "#FBBC05"
+	DefaultError     = "#EA4335"
+)
+
+// Theme holds the colors used to render markdown and highlighted code
+type Theme struct {
+	Title   *color.Color
+	Heading *color.Color
+	Bold    *color.Color
+	Italic  *color.Color
+	Code    *color.Color
+	Link    *color.Color
+	Bullet  *color.Color
+	Muted   *color.Color
+
+	Keyword  *color.Color
+	String   *color.Color
+	Number   *color.Color
+	Comment  *color.Color
+	Type     *color.Color
+	Function *color.Color
+	Inserted *color.Color
+	Deleted  *color.Color
+}
+
+// NewTheme builds a theme from the ui.colors palette (primary, secondary, accent, error).
+// Missing or invalid entries fall back to the default palette.
+func NewTheme(colors map[string]string) Theme {
+	primary := func() *color.Color { return hexColor(colors["primary"], DefaultPrimary) }
+	secondary := func() *color.Color { return hexColor(colors["secondary"], DefaultSecondary) }
+	accent := func() *color.Color { return hexColor(colors["accent"], DefaultAccent) }
+
+	return Theme{
+		Title:   primary().Add(color.Bold, color.Underline),
+		Heading: primary().Add(color.Bold),
+		Bold:    color.New(color.Bold),
+		Italic:  color.New(color.Italic),
+		Code:    accent(),
+		Link:    primary().Add(color.Underline),
+		Bullet:  accent(),
+		Muted:   color.New(color.FgHiBlack),
+
+		Keyword:  primary().Add(color.Bold),
+		String:   secondary(),
+		Number:   accent(),
+		Comment:  color.New(color.FgHiBlack, color.Italic),
+		Type:     accent(),
+		Function: primary(),
+		Inserted: secondary(),
+		Deleted:  hexColor(colors["error"], DefaultError),
+	}
+}
+
+// hexColor returns a foreground color for a "#RRGGBB" value, or for fallback if value is invalid
+func hexColor(value, fallback string) *color.Color {
+	r, g, b, ok := parseHex(value)
+	if !ok {
+		r, g, b, _ = parseHex(fallback)
+	}
+	return color.RGB(r, g, b)
+}
+
+// parseHex parses a "#RRGGBB" or "#RGB" color
+func parseHex(value string) (int, int, int, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+	if len(value) != 6 {
+		return 0, 0, 0, false
+	}
+	rgb, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return int(rgb >> 16 & 0xFF), int(rgb >> 8 & 0xFF), int(rgb & 0xFF), true
+}
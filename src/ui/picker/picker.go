@@ -0,0 +1,204 @@
+// Package picker lets the user pick one item of a list filtered as they type
+package picker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// ErrCancelled is returned when the user leaves the picker without choosing
+var ErrCancelled = errors.New("cancelled")
+
+// defaultHeight is the number of items shown at once
+const defaultHeight = 10
+
+// Item is an entry of the list
+type Item struct {
+	// Text is what is shown and returned when the item is picked
+	Text string
+
+	// Detail is shown dimmed after the text
+	Detail string
+}
+
+// Picker draws the list below the cursor, under a query line
+type Picker struct {
+	// Prompt precedes the query
+	Prompt string
+
+	// Height is the number of items shown at once
+	Height int
+
+	// Filter returns the items matching a query, best first
+	Filter func(query string) []Item
+
+	width int
+}
+
+// Run shows the picker on the terminal tty, starting from query, and
+// returns the text of the item picked with Enter. Esc, Ctrl-C and Ctrl-G
+// return ErrCancelled.
+func (p *Picker) Run(tty *os.File, query string) (string, error) {
+	fd := int(tty.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", fmt.Errorf("picker needs a terminal: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	p.width = 80
+	if width, _, err := term.GetSize(fd); err == nil && width > 0 {
+		p.width = width
+	}
+	return p.run(bufio.NewReader(tty), tty, query)
+}
+
+// run is the key handling loop, reading keys from in and drawing on out
+func (p *Picker) run(in *bufio.Reader, out io.Writer, query string) (string, error) {
+	if p.Height <= 0 {
+		p.Height = defaultHeight
+	}
+	if p.width <= 0 {
+		p.width = 80
+	}
+
+	// Make room below the cursor once, so redrawing never scrolls
+	fmt.Fprint(out, strings.Repeat("\r\n", p.Height)+fmt.Sprintf("\x1b[%dA", p.Height))
+	defer fmt.Fprint(out, "\r\x1b[J")
+
+	buf := []rune(query)
+	items := p.Filter(query)
+	selected := 0
+	for {
+		if selected >= len(items) {
+			selected = max(len(items)-1, 0)
+		}
+		p.draw(out, string(buf), items, selected)
+
+		r, _, err := in.ReadRune()
+		if err != nil {
+			return "", ErrCancelled
+		}
+		previous := string(buf)
+		switch r {
+		case '\r', '\n':
+			if len(items) == 0 {
+				return "", ErrCancelled
+			}
+			return items[selected].Text, nil
+		case 3, 7: // Ctrl-C, Ctrl-G
+			return "", ErrCancelled
+		case 127, 8: // Backspace
+			if len(buf) > 0 {
+				buf = buf[:len(buf)-1]
+			}
+		case 21: // Ctrl-U
+			buf = buf[:0]
+		case 23: // Ctrl-W
+			trimmed := strings.TrimRightFunc(string(buf), unicode.IsSpace)
+			if i := strings.LastIndexFunc(trimmed, unicode.IsSpace); i >= 0 {
+				buf = []rune(trimmed[:i+1])
+			} else {
+				buf = buf[:0]
+			}
+		case 16: // Ctrl-P
+			selected = max(selected-1, 0)
+		case 14, 18: // Ctrl-N, Ctrl-R
+			selected++
+		case 27: // Escape, alone or starting a sequence
+			if in.Buffered() == 0 {
+				return "", ErrCancelled
+			}
+			switch readEscape(in) {
+			case "[A", "OA":
+				selected = max(selected-1, 0)
+			case "[B", "OB":
+				selected++
+			}
+		default:
+			if unicode.IsPrint(r) {
+				buf = append(buf, r)
+			}
+		}
+		if string(buf) != previous {
+			items = p.Filter(string(buf))
+			selected = 0
+		}
+	}
+}
+
+// readEscape reads the rest of an escape sequence after ESC
+func readEscape(in *bufio.Reader) string {
+	r, _, err := in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return ""
+	}
+	seq := []rune{r}
+	for in.Buffered() > 0 {
+		c, _, err := in.ReadRune()
+		if err != nil {
+			break
+		}
+		seq = append(seq, c)
+		if r == 'O' || (c >= '@' && c <= '~') {
+			break
+		}
+	}
+	return string(seq)
+}
+
+// draw redraws the query line and the items, leaving the cursor after the
+// query
+func (p *Picker) draw(out io.Writer, query string, items []Item, selected int) {
+	var sb strings.Builder
+	sb.WriteString("\r\x1b[J")
+
+	// Scroll the window so the selected item is visible
+	first := 0
+	if selected >= p.Height {
+		first = selected - p.Height + 1
+	}
+	for i := first; i < len(items) && i < first+p.Height; i++ {
+		sb.WriteString("\r\n")
+		line := p.fit(items[i])
+		if i == selected {
+			sb.WriteString("\x1b[7m" + line + "\x1b[0m")
+		} else {
+			sb.WriteString(line)
+		}
+	}
+	shown := min(len(items)-first, p.Height)
+	if shown > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dA", shown)
+	}
+	sb.WriteString("\r" + p.Prompt + query)
+	fmt.Fprint(out, sb.String())
+}
+
+// fit renders an item on one line within the terminal width
+func (p *Picker) fit(item Item) string {
+	text := strings.ReplaceAll(item.Text, "\n", "↵")
+	detail := ""
+	if item.Detail != "" {
+		detail = "  " + item.Detail
+	}
+	room := p.width - 1 - utf8.RuneCountInString(detail)
+	if room < 10 {
+		room, detail = p.width-1, ""
+	}
+	if runes := []rune(text); len(runes) > room {
+		text = string(runes[:room-1]) + "…"
+	}
+	if detail != "" {
+		return text + "\x1b[2m" + detail + "\x1b[22m"
+	}
+	return text
+}
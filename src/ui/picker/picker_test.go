@@ -0,0 +1,51 @@
+package picker
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPicker() *Picker {
+	all := []string{"git status", "git push", "go test ./...", "make"}
+	return &Picker{
+		Filter: func(query string) []Item {
+			var items []Item
+			for _, text := range all {
+				if strings.Contains(text, query) {
+					items = append(items, Item{Text: text})
+				}
+			}
+			return items
+		},
+	}
+}
+
+func TestRun_FilterAndMove(t *testing.T) {
+	// Type "gi", move down twice (past the end), up once, then Enter
+	in := bufio.NewReader(strings.NewReader("gi\x0e\x1b[B\x1b[A\r"))
+
+	selected, err := newTestPicker().run(in, &bytes.Buffer{}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "git status", selected)
+}
+
+func TestRun_EditQuery(t *testing.T) {
+	// Start from "zz", erase it and type "ma"
+	in := bufio.NewReader(strings.NewReader("\x15ma\r"))
+
+	selected, err := newTestPicker().run(in, &bytes.Buffer{}, "zz")
+	require.NoError(t, err)
+	assert.Equal(t, "make", selected)
+}
+
+func TestRun_Cancel(t *testing.T) {
+	for _, keys := range []string{"\x1b", "\x03", "nothing\r", ""} {
+		_, err := newTestPicker().run(bufio.NewReader(strings.NewReader(keys)), &bytes.Buffer{}, "")
+		assert.ErrorIs(t, err, ErrCancelled, "%q", keys)
+	}
+}